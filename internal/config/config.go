@@ -3,22 +3,41 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	DBHost            string
-	DBPort            string
-	DBUser            string
-	DBPassword        string
-	DBName            string
-	Port              string
-	FirebaseProjectID string
-	LogLevel          string
-	Environment       string
-	RateLimitRPS      int
-	RateLimitBurst    int
-	JWTSecret         string
-	VaultConfig       VaultConfig
+	DBHost                string
+	DBPort                string
+	DBUser                string
+	DBPassword            string
+	DBName                string
+	Port                  string
+	FirebaseProjectID     string
+	LogLevel              string
+	Environment           string
+	RateLimitRPS          int
+	RateLimitBurst        int
+	JWTSecret             string
+	VaultConfig           VaultConfig
+	MFAConfig             MFAConfig
+	StepUpConfig          StepUpConfig
+	ClaimsSyncConfig      ClaimsSyncConfig
+	GuestConfig           GuestConfig
+	DataExportConfig      DataExportConfig
+	BulkImportConfig      BulkImportConfig
+	ErasureConfig         ErasureConfig
+	UsernameConfig        UsernameConfig
+	GroupsConfig          GroupsConfig
+	MailConfig            MailConfig
+	InvitationConfig      InvitationConfig
+	EmailChangeConfig     EmailChangeConfig
+	StorageConfig         StorageConfig
+	AvatarConfig          AvatarConfig
+	LifecycleConfig       LifecycleConfig
+	DormancyConfig        DormancyConfig
+	DisposableEmailConfig DisposableEmailConfig
 }
 
 type VaultConfig struct {
@@ -27,6 +46,142 @@ type VaultConfig struct {
 	Path    string
 }
 
+// MFAConfig contiene la configuración de autenticación multifactor (TOTP)
+type MFAConfig struct {
+	Issuer        string   // Nombre mostrado en la app de autenticación
+	EncryptionKey string   // Clave para cifrar los secretos TOTP en base de datos
+	RequiredRoles []string // Roles que deben tener MFA obligatoriamente
+}
+
+// StepUpConfig controla la reautenticación exigida para operaciones sensibles
+type StepUpConfig struct {
+	MaxAge       time.Duration // Antigüedad máxima de auth_time para operaciones sensibles
+	ReauthWindow time.Duration // Antigüedad máxima del login de Firebase al reautenticar
+}
+
+// ClaimsSyncConfig controla la sincronización de roles y estado con los custom claims de Firebase
+type ClaimsSyncConfig struct {
+	Enabled           bool
+	ReconcileInterval time.Duration // Frecuencia del job de reconciliación (0 lo desactiva)
+	Workers           int           // Sincronizaciones simultáneas tras los cambios de usuario
+	QueueSize         int           // Cambios pendientes como máximo; el resto queda para la reconciliación
+}
+
+// GuestConfig controla las sesiones de invitado (login anónimo)
+type GuestConfig struct {
+	TokenTTL   time.Duration // Duración de los JWT de invitado
+	TTL        time.Duration // Inactividad tras la que se eliminan los invitados
+	GCInterval time.Duration // Frecuencia del job de limpieza (0 lo desactiva)
+}
+
+// DataExportConfig controla las exportaciones de datos personales (derecho de acceso, RGPD)
+type DataExportConfig struct {
+	LinkTTL    time.Duration // Validez del enlace de descarga y del fichero generado
+	Cooldown   time.Duration // Tiempo mínimo entre dos solicitudes del mismo usuario
+	GCInterval time.Duration // Frecuencia del job que borra exportaciones caducadas (0 lo desactiva)
+	RunTimeout time.Duration // Tiempo tras el que una exportación sin terminar se da por fallida
+}
+
+// BulkImportConfig controla la vigilancia de las importaciones masivas de usuarios
+type BulkImportConfig struct {
+	StaleAfter    time.Duration // Tiempo sin progreso tras el que un job se da por fallido
+	SweepInterval time.Duration // Frecuencia del job que los busca (0 sólo al arrancar)
+}
+
+// ErasureConfig controla el borrado definitivo de las cuentas eliminadas (derecho de supresión)
+type ErasureConfig struct {
+	GracePeriod time.Duration // Tiempo durante el que una cuenta eliminada aún puede restaurarse
+	Interval    time.Duration // Frecuencia del job que ejecuta los borrados vencidos (0 lo desactiva)
+}
+
+// UsernameConfig limita los cambios de username que hace el propio usuario
+type UsernameConfig struct {
+	ChangeInterval time.Duration // Tiempo mínimo entre dos cambios de username
+	HoldPeriod     time.Duration // Tiempo durante el que un username abandonado no puede reutilizarse
+}
+
+// GroupsConfig controla la resolución de permisos por grupos y el claim groups de los JWT
+type GroupsConfig struct {
+	PermissionCacheTTL time.Duration // Validez de los permisos resueltos en caché (0 la desactiva)
+	ClaimEnabled       bool          // Incluir los grupos del usuario en el claim groups
+	ClaimMaxGroups     int           // Por encima de este número se omite el claim y se marca groups_overflow
+}
+
+// MailConfig configura el envío de emails por SMTP; sin SMTPHost los emails sólo se
+// registran en el log (desarrollo)
+type MailConfig struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+}
+
+// InvitationConfig controla las invitaciones de usuarios por email
+type InvitationConfig struct {
+	TTL       time.Duration // Validez de una invitación desde su último envío
+	AcceptURL string        // Página del frontend que recibe el token en ?token=
+}
+
+// EmailChangeConfig controla el cambio de email verificado por el propio usuario
+type EmailChangeConfig struct {
+	CodeTTL     time.Duration // Validez del código enviado a la nueva dirección
+	MaxAttempts int           // Intentos fallidos tras los que el código se invalida
+	RevertTTL   time.Duration // Tiempo durante el que la dirección anterior puede deshacer el cambio
+	RevertURL   string        // Página del frontend que recibe el token de reversión en ?token=
+}
+
+// StorageConfig selecciona dónde se guardan los ficheros subidos (avatares): el disco
+// local o un bucket compatible con S3 (AWS, MinIO, R2...)
+type StorageConfig struct {
+	Backend           string // local o s3
+	PublicBaseURL     string // Prefijo de las URLs que sirve el propio servicio (vacío: rutas relativas)
+	LocalDir          string
+	S3Endpoint        string // p. ej. https://s3.eu-west-1.amazonaws.com
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PathStyle       bool // Bucket en la ruta en lugar del subdominio (MinIO)
+}
+
+// AvatarConfig limita las imágenes de perfil subidas y los tamaños que se generan
+type AvatarConfig struct {
+	MaxBytes  int           // Tamaño máximo del fichero subido
+	MaxPixels int           // Ancho x alto máximo, para rechazar bombas de descompresión
+	Sizes     []int         // Lados en píxeles de las versiones cuadradas que se generan
+	URLTTL    time.Duration // Validez de las URLs firmadas
+}
+
+// LifecycleConfig controla el estado inicial de las cuentas nuevas
+type LifecycleConfig struct {
+	// Las cuentas cuyo proveedor no verificó el email empiezan en pending_verification
+	// y no pueden autenticarse hasta que lo verifiquen
+	RequireVerifiedEmail bool
+}
+
+// DormancyConfig controla la detección de cuentas inactivas según su último login
+type DormancyConfig struct {
+	WarnAfter       time.Duration // Inactividad a partir de la cual se avisa por email
+	DeactivateAfter time.Duration // Inactividad a partir de la cual se desactiva la cuenta
+	Interval        time.Duration // Frecuencia del job (0 lo desactiva)
+	DryRun          bool          // El job sólo registra en el log lo que haría
+	BatchSize       int           // Cuentas avisadas y desactivadas como máximo por ejecución
+	CodeTTL         time.Duration // Validez del código de reactivación
+	MaxAttempts     int           // Intentos fallidos permitidos por código
+	ReactivateURL   string        // Página del cliente desde la que se pide la reactivación
+	RequestCooldown time.Duration // Tiempo mínimo entre dos códigos para la misma cuenta
+	MaxRequests     int           // Códigos pedidos como máximo por cuenta y por IP en una hora
+}
+
+// DisposableEmailConfig controla el rechazo de las direcciones de email desechables en
+// las altas. La lista integrada se amplía con un fichero local, un dominio por línea.
+type DisposableEmailConfig struct {
+	Block          bool          // Rechazar las altas con emails desechables
+	DomainsFile    string        // Fichero con dominios adicionales ("" sólo usa la lista integrada)
+	ReloadInterval time.Duration // Frecuencia con la que se relee el fichero (0 sólo al arrancar)
+}
+
 func LoadConfig() Config {
 	return Config{
 		DBHost:            getEnv("DB_HOST", "localhost"),
@@ -46,6 +201,103 @@ func LoadConfig() Config {
 			Token:   getEnv("VAULT_TOKEN", ""),
 			Path:    getEnv("VAULT_PATH", "secret/"),
 		},
+		MFAConfig: MFAConfig{
+			Issuer:        getEnv("MFA_ISSUER", "IT Auth Service"),
+			EncryptionKey: getEnv("MFA_ENCRYPTION_KEY", ""),
+			RequiredRoles: getEnvAsSlice("MFA_REQUIRED_ROLES", nil),
+		},
+		StepUpConfig: StepUpConfig{
+			MaxAge:       getEnvAsDuration("STEP_UP_MAX_AGE", 10*time.Minute),
+			ReauthWindow: getEnvAsDuration("REAUTH_WINDOW", 5*time.Minute),
+		},
+		ClaimsSyncConfig: ClaimsSyncConfig{
+			Enabled:           getEnvAsBool("FIREBASE_CLAIMS_SYNC_ENABLED", true),
+			ReconcileInterval: getEnvAsDuration("FIREBASE_CLAIMS_RECONCILE_INTERVAL", 6*time.Hour),
+			Workers:           getEnvAsInt("FIREBASE_CLAIMS_SYNC_WORKERS", 4),
+			QueueSize:         getEnvAsInt("FIREBASE_CLAIMS_SYNC_QUEUE_SIZE", 1000),
+		},
+		GuestConfig: GuestConfig{
+			TokenTTL:   getEnvAsDuration("GUEST_TOKEN_TTL", time.Hour),
+			TTL:        getEnvAsDuration("GUEST_TTL", 30*24*time.Hour),
+			GCInterval: getEnvAsDuration("GUEST_GC_INTERVAL", time.Hour),
+		},
+		DataExportConfig: DataExportConfig{
+			LinkTTL:    getEnvAsDuration("DATA_EXPORT_LINK_TTL", 24*time.Hour),
+			Cooldown:   getEnvAsDuration("DATA_EXPORT_COOLDOWN", time.Hour),
+			GCInterval: getEnvAsDuration("DATA_EXPORT_GC_INTERVAL", time.Hour),
+			RunTimeout: getEnvAsDuration("DATA_EXPORT_RUN_TIMEOUT", 30*time.Minute),
+		},
+		BulkImportConfig: BulkImportConfig{
+			StaleAfter:    getEnvAsDuration("BULK_IMPORT_STALE_AFTER", 30*time.Minute),
+			SweepInterval: getEnvAsDuration("BULK_IMPORT_SWEEP_INTERVAL", 10*time.Minute),
+		},
+		ErasureConfig: ErasureConfig{
+			GracePeriod: getEnvAsDuration("ERASURE_GRACE_PERIOD", 30*24*time.Hour),
+			Interval:    getEnvAsDuration("ERASURE_INTERVAL", time.Hour),
+		},
+		UsernameConfig: UsernameConfig{
+			ChangeInterval: getEnvAsDuration("USERNAME_CHANGE_INTERVAL", 7*24*time.Hour),
+			HoldPeriod:     getEnvAsDuration("USERNAME_HOLD_PERIOD", 90*24*time.Hour),
+		},
+		GroupsConfig: GroupsConfig{
+			PermissionCacheTTL: getEnvAsDuration("GROUPS_PERMISSION_CACHE_TTL", time.Minute),
+			ClaimEnabled:       getEnvAsBool("GROUPS_CLAIM_ENABLED", false),
+			ClaimMaxGroups:     getEnvAsInt("GROUPS_CLAIM_MAX_GROUPS", 50),
+		},
+		MailConfig: MailConfig{
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			From:         getEnv("MAIL_FROM", "no-reply@localhost"),
+		},
+		InvitationConfig: InvitationConfig{
+			TTL:       getEnvAsDuration("INVITATION_TTL", 7*24*time.Hour),
+			AcceptURL: getEnv("INVITATION_ACCEPT_URL", "http://localhost:3000/accept-invitation"),
+		},
+		EmailChangeConfig: EmailChangeConfig{
+			CodeTTL:     getEnvAsDuration("EMAIL_CHANGE_CODE_TTL", 15*time.Minute),
+			MaxAttempts: getEnvAsInt("EMAIL_CHANGE_MAX_ATTEMPTS", 5),
+			RevertTTL:   getEnvAsDuration("EMAIL_CHANGE_REVERT_TTL", 7*24*time.Hour),
+			RevertURL:   getEnv("EMAIL_CHANGE_REVERT_URL", "http://localhost:3000/revert-email-change"),
+		},
+		StorageConfig: StorageConfig{
+			Backend:           getEnv("STORAGE_BACKEND", "local"),
+			PublicBaseURL:     getEnv("STORAGE_PUBLIC_BASE_URL", ""),
+			LocalDir:          getEnv("STORAGE_LOCAL_DIR", "./data/storage"),
+			S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+			S3Region:          getEnv("S3_REGION", "us-east-1"),
+			S3Bucket:          getEnv("S3_BUCKET", ""),
+			S3AccessKeyID:     getEnv("S3_ACCESS_KEY_ID", ""),
+			S3SecretAccessKey: getEnv("S3_SECRET_ACCESS_KEY", ""),
+			S3PathStyle:       getEnvAsBool("S3_PATH_STYLE", false),
+		},
+		AvatarConfig: AvatarConfig{
+			MaxBytes:  getEnvAsInt("AVATAR_MAX_BYTES", 5<<20),
+			MaxPixels: getEnvAsInt("AVATAR_MAX_PIXELS", 4096*4096),
+			Sizes:     getEnvAsIntSlice("AVATAR_SIZES", []int{64, 256, 512}),
+			URLTTL:    getEnvAsDuration("AVATAR_URL_TTL", time.Hour),
+		},
+		LifecycleConfig: LifecycleConfig{
+			RequireVerifiedEmail: getEnvAsBool("REQUIRE_VERIFIED_EMAIL", false),
+		},
+		DormancyConfig: DormancyConfig{
+			WarnAfter:       getEnvAsDuration("DORMANCY_WARN_AFTER", 335*24*time.Hour),
+			DeactivateAfter: getEnvAsDuration("DORMANCY_DEACTIVATE_AFTER", 365*24*time.Hour),
+			Interval:        getEnvAsDuration("DORMANCY_INTERVAL", 0),
+			DryRun:          getEnvAsBool("DORMANCY_DRY_RUN", false),
+			BatchSize:       getEnvAsInt("DORMANCY_BATCH_SIZE", 100),
+			CodeTTL:         getEnvAsDuration("DORMANCY_CODE_TTL", 15*time.Minute),
+			MaxAttempts:     getEnvAsInt("DORMANCY_MAX_ATTEMPTS", 5),
+			ReactivateURL:   getEnv("DORMANCY_REACTIVATE_URL", ""),
+			RequestCooldown: getEnvAsDuration("DORMANCY_REQUEST_COOLDOWN", time.Minute),
+			MaxRequests:     getEnvAsInt("DORMANCY_MAX_REQUESTS_PER_HOUR", 5),
+		},
+		DisposableEmailConfig: DisposableEmailConfig{
+			Block:          getEnvAsBool("DISPOSABLE_EMAIL_BLOCK", true),
+			DomainsFile:    getEnv("DISPOSABLE_EMAIL_DOMAINS_FILE", ""),
+			ReloadInterval: getEnvAsDuration("DISPOSABLE_EMAIL_RELOAD_INTERVAL", time.Hour),
+		},
 	}
 }
 
@@ -63,4 +315,51 @@ func getEnvAsInt(key string, defaultValue int) int {
 		}
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
+
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	if value, exists := os.LookupEnv(key); exists && value != "" {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return defaultValue
+}
+
+func getEnvAsIntSlice(key string, defaultValue []int) []int {
+	items := getEnvAsSlice(key, nil)
+	if items == nil {
+		return defaultValue
+	}
+	values := make([]int, 0, len(items))
+	for _, item := range items {
+		value, err := strconv.Atoi(item)
+		if err != nil || value <= 0 {
+			return defaultValue
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
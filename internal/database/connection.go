@@ -3,9 +3,11 @@ package database
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"it-auth-service/internal/config"
 	"it-auth-service/internal/models"
@@ -21,6 +23,8 @@ func Connect(cfg config.Config) error {
 	var err error
 	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// Traducir errores del driver (p. ej. violaciones de unicidad a gorm.ErrDuplicatedKey)
+		TranslateError: true,
 	})
 
 	if err != nil {
@@ -50,16 +54,269 @@ func AutoMigrate() error {
 		&models.PasswordResetToken{},
 		&models.RevokedToken{},
 		&models.UserSession{},
+		&models.MFAFactor{},
+		&models.MFARecoveryCode{},
+		&models.MFAChallenge{},
+		&models.UserIdentity{},
+		&models.UnlinkedIdentity{},
+		&models.AuditLog{},
+		&models.AccountMergeRequest{},
+		&models.FirebaseClaimsSync{},
+		&models.UserImportJob{},
+		&models.UserImportRowError{},
+		&models.SCIMToken{},
+		&models.SCIMUserLink{},
+		&models.DataExport{},
+		&models.UserErasure{},
+		&models.UserErasureTombstone{},
+		&models.UsernameHistory{},
+		&models.ReservedUsername{},
+		&models.AttributeDefinition{},
+		&models.Organization{},
+		&models.OrganizationMembership{},
+		&models.UserGroup{},
+		&models.UserGroupMember{},
+		&models.UserGroupNesting{},
+		&models.UserGroupRole{},
+		&models.RoleDefinition{},
+		&models.Invitation{},
+		&models.EmailChangeRequest{},
+		&models.UserAvatar{},
+		&models.AccountReactivation{},
+		&models.ConsentDocument{},
+		&models.ConsentAcceptance{},
+		&models.DomainPolicy{},
+		&schemaMigration{},
 	)
 
 	if err != nil {
 		return fmt.Errorf("failed to migrate auth database tables: %w", err)
 	}
 
+	if err := createUserListingIndexes(); err != nil {
+		return fmt.Errorf("failed to create user listing indexes: %w", err)
+	}
+
+	createUsernameIndex()
+	createCanonicalEmailIndex()
+
+	if err := runOnce(DB, "schedule_legacy_deleted_erasures", migrateUserStatuses); err != nil {
+		return fmt.Errorf("failed to migrate user statuses: %w", err)
+	}
+
+	if err := migrateSCIMGroups(); err != nil {
+		return fmt.Errorf("failed to migrate SCIM groups: %w", err)
+	}
+
 	log.Println("Auth service database migration completed successfully")
 	return nil
 }
 
+// createUsernameIndex hace que los usernames sean únicos sin distinguir mayúsculas.
+// Si ya existen duplicados que sólo difieren en mayúsculas el índice no puede crearse;
+// el servicio sigue comprobándolo en cada alta o cambio, pero hay que resolverlos a mano.
+func createUsernameIndex() {
+	if err := DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (lower(username))").Error; err != nil {
+		log.Printf("Case-insensitive username index not created, resolve duplicate usernames: %v", err)
+	}
+}
+
+// createCanonicalEmailIndex impide dos cuentas con variantes del mismo buzón. Las cuentas
+// anteriores tienen el email canónico vacío, fuera del índice, hasta que el servicio lo
+// calcula al arrancar.
+func createCanonicalEmailIndex() {
+	if err := DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_canonical_email ON users (canonical_email) WHERE canonical_email <> ''").Error; err != nil {
+		log.Printf("Canonical email index not created, resolve duplicate mailboxes: %v", err)
+	}
+}
+
+// schemaMigration registra las migraciones de datos ya aplicadas para no repetirlas
+// en cada arranque
+type schemaMigration struct {
+	Name      string    `gorm:"primaryKey;size:128"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// runOnce aplica migrate en la misma transacción en que la registra. Si otra instancia
+// la está aplicando a la vez, el INSERT espera a que termine y después no hace nada.
+func runOnce(db *gorm.DB, name string, migrate func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&schemaMigration{Name: name, AppliedAt: time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		return migrate(tx)
+	})
+}
+
+// legacyErasureReason es el motivo de los borrados programados por la migración
+const legacyErasureReason = "legacy_deleted"
+
+// migrateUserStatuses adapta las cuentas al ciclo de vida actual: "deleted" pasó a
+// ser el estado final tras el borrado definitivo, y las cuentas eliminadas cuyos
+// datos aún no se han borrado corresponden ahora a pending_deletion. Como no pasan
+// por LifecycleService nadie les programa el borrado, así que se programa aquí, ya
+// vencido: su periodo de gracia terminó hace tiempo. Incluye las que pasaron a
+// pending_deletion en arranques anteriores sin borrado programado.
+func migrateUserStatuses(tx *gorm.DB) error {
+	now := time.Now()
+	if err := tx.Model(&models.User{}).
+		Where("status = ? AND firebase_id NOT LIKE ?", models.StatusDeleted, "erased:%").
+		Updates(map[string]interface{}{
+			"status":            models.StatusPendingDeletion,
+			"status_reason":     legacyErasureReason,
+			"status_changed_at": now,
+		}).Error; err != nil {
+		return err
+	}
+
+	var userIDs []string
+	if err := tx.Model(&models.User{}).
+		Where("status = ? AND NOT EXISTS (SELECT 1 FROM user_erasures e WHERE e.user_id = users.id AND e.status = ?)",
+			models.StatusPendingDeletion, models.ErasureStatusScheduled).
+		Pluck("id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		erasure := &models.UserErasure{
+			UserID:       userID,
+			Status:       models.ErasureStatusScheduled,
+			Reason:       legacyErasureReason,
+			ScheduledFor: now,
+		}
+		if err := tx.Create(erasure).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.AuditLog{
+			UserID: userID,
+			Action: models.AuditActionErasureScheduled,
+			Details: models.JSONMap{
+				"erasure_id":    erasure.ID,
+				"scheduled_for": erasure.ScheduledFor,
+				"reason":        legacyErasureReason,
+			},
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(userIDs) > 0 {
+		log.Printf("Scheduled erasure of %d accounts pending deletion", len(userIDs))
+	}
+	return nil
+}
+
+// migrateSCIMGroups pasa los grupos SCIM, que antes tenían tablas propias, a los grupos
+// de usuarios del mismo tenant para que sus miembros reciban los roles asignados.
+func migrateSCIMGroups() error {
+	if !DB.Migrator().HasTable("scim_groups") {
+		return nil
+	}
+	return DB.Transaction(migrateSCIMGroupsTx)
+}
+
+// scimGroupMerge es un grupo SCIM con el mismo nombre que un grupo existente del tenant
+type scimGroupMerge struct {
+	SCIMID   string
+	GroupID  string
+	TenantID string
+	Name     string
+}
+
+// migrateSCIMGroupsTx copia los grupos SCIM conservando su id. Uno con el mismo nombre
+// que un grupo existente del tenant se fusiona con él, que guarda en scim_id el id que
+// conoce el IdP para que sus PATCH y DELETE lo sigan encontrando. Si algún grupo SCIM
+// no queda localizable por su id se aborta; las tablas antiguas se conservan
+// renombradas para poder revisar las fusiones.
+func migrateSCIMGroupsTx(tx *gorm.DB) error {
+	var merges []scimGroupMerge
+	if err := tx.Raw(`SELECT sg.id AS scim_id, g.id AS group_id, sg.tenant_id, sg.display_name AS name
+		FROM scim_groups sg
+		JOIN user_groups g ON g.tenant_id = sg.tenant_id AND g.name = sg.display_name AND g.id <> sg.id`).
+		Scan(&merges).Error; err != nil {
+		return err
+	}
+	for _, merge := range merges {
+		log.Printf("SCIM group %s of tenant %q merged into existing group %s (%q); it keeps its SCIM id",
+			merge.SCIMID, merge.TenantID, merge.GroupID, merge.Name)
+	}
+
+	statements := []string{
+		`INSERT INTO user_groups (id, tenant_id, name, external_id, created_at, updated_at)
+			SELECT id, tenant_id, display_name, external_id, created_at, updated_at FROM scim_groups
+			ON CONFLICT (tenant_id, name) DO UPDATE SET external_id = EXCLUDED.external_id, scim_id = EXCLUDED.id`,
+		`INSERT INTO user_group_members (group_id, user_id, created_at)
+			SELECT g.id, m.user_id, now() FROM scim_group_members m
+			JOIN scim_groups sg ON sg.id = m.group_id
+			JOIN user_groups g ON g.tenant_id = sg.tenant_id AND g.name = sg.display_name
+			ON CONFLICT DO NOTHING`,
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	var unreachable int64
+	if err := tx.Raw(`SELECT count(*) FROM scim_groups sg
+		WHERE NOT EXISTS (SELECT 1 FROM user_groups g WHERE g.id = sg.id OR g.scim_id = sg.id)`).
+		Scan(&unreachable).Error; err != nil {
+		return err
+	}
+	if unreachable > 0 {
+		return fmt.Errorf("%d SCIM groups would no longer be found by their id", unreachable)
+	}
+
+	for _, statement := range []string{
+		"ALTER TABLE IF EXISTS scim_group_members RENAME TO scim_group_members_migrated",
+		"ALTER TABLE scim_groups RENAME TO scim_groups_migrated",
+	} {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	if len(merges) > 0 {
+		log.Printf("Merged %d SCIM groups into existing groups; review scim_groups_migrated and drop it afterwards", len(merges))
+	}
+	return nil
+}
+
+// createUserListingIndexes crea los índices del listado de usuarios que GORM no puede
+// declarar en los tags: keyset por fecha, dominio de email y búsqueda por trigramas.
+// Las expresiones se comparten con services.SearchUsers.
+func createUserListingIndexes() error {
+	statements := []string{
+		"CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users (created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_users_status_created_at_id ON users (status, created_at, id)",
+		"CREATE INDEX IF NOT EXISTS idx_users_last_login_id ON users ((" + models.UserLastLoginExpression + "), id)",
+		"CREATE INDEX IF NOT EXISTS idx_users_email_domain ON users ((" + models.UserEmailDomainExpression + "))",
+		"CREATE INDEX IF NOT EXISTS idx_users_provider ON users (provider)",
+	}
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	// pg_trgm puede no estar disponible (p. ej. sin permisos para crear extensiones);
+	// la búsqueda sigue funcionando, aunque sin índice
+	if err := DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm").Error; err != nil {
+		log.Printf("pg_trgm extension unavailable, user search will not be indexed: %v", err)
+		return nil
+	}
+	return DB.Exec("CREATE INDEX IF NOT EXISTS idx_users_search_trgm ON users USING gin (" +
+		models.UserSearchExpression + " gin_trgm_ops)").Error
+}
+
 // Close cierra la conexión a la base de datos
 func Close() error {
 	if DB == nil {
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"it-auth-service/internal/models"
+)
+
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	return db, mock
+}
+
+func TestRunOnceSkipsAppliedMigration(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "schema_migrations" .* ON CONFLICT DO NOTHING`).
+		WithArgs("already_applied", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := runOnce(db, "already_applied", func(*gorm.DB) error {
+		t.Fatal("migration applied twice")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateUserStatusesSchedulesErasure(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "schema_migrations"`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "users" SET "status"=\$1,"status_changed_at"=\$2,"status_reason"=\$3,"updated_at"=\$4 WHERE status = \$5 AND firebase_id NOT LIKE \$6`).
+		WithArgs(models.StatusPendingDeletion, sqlmock.AnyArg(), legacyErasureReason, sqlmock.AnyArg(), models.StatusDeleted, "erased:%").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Las cuentas sin borrado programado, incluidas las migradas en arranques anteriores
+	mock.ExpectQuery(`SELECT "id" FROM "users" WHERE status = \$1 AND NOT EXISTS \(SELECT 1 FROM user_erasures e WHERE e.user_id = users.id AND e.status = \$2\)`).
+		WithArgs(models.StatusPendingDeletion, models.ErasureStatusScheduled).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("legacy-id"))
+	mock.ExpectQuery(`INSERT INTO "user_erasures"`).
+		WithArgs("legacy-id", models.ErasureStatusScheduled, legacyErasureReason, sqlmock.AnyArg(), 0, "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("erasure-id"))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WithArgs("legacy-id", "", models.AuditActionErasureScheduled, sqlmock.AnyArg(), "", "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+
+	require.NoError(t, runOnce(db, "schedule_legacy_deleted_erasures", migrateUserStatuses))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateSCIMGroupsKeepsSCIMID(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT sg.id AS scim_id, g.id AS group_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"scim_id", "group_id", "tenant_id", "name"}).
+			AddRow("scim-group-id", "group-id", "acme", "Sales"))
+	// El grupo existente guarda el id SCIM en lugar de perderlo
+	mock.ExpectExec(`INSERT INTO user_groups .* ON CONFLICT \(tenant_id, name\) DO UPDATE SET external_id = EXCLUDED.external_id, scim_id = EXCLUDED.id`).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO user_group_members`).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM scim_groups sg\s+WHERE NOT EXISTS \(SELECT 1 FROM user_groups g WHERE g.id = sg.id OR g.scim_id = sg.id\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	// Las tablas antiguas se conservan para revisar las fusiones
+	mock.ExpectExec(`ALTER TABLE IF EXISTS scim_group_members RENAME TO scim_group_members_migrated`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ALTER TABLE scim_groups RENAME TO scim_groups_migrated`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, db.Transaction(migrateSCIMGroupsTx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrateSCIMGroupsAbortsOnUnreachableGroup(t *testing.T) {
+	db, mock := newMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT sg.id AS scim_id, g.id AS group_id`).
+		WillReturnRows(sqlmock.NewRows([]string{"scim_id", "group_id", "tenant_id", "name"}))
+	mock.ExpectExec(`INSERT INTO user_groups`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO user_group_members`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM scim_groups`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	err := db.Transaction(migrateSCIMGroupsTx)
+	assert.ErrorContains(t, err, "1 SCIM groups would no longer be found")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// ConfirmAccountMerge godoc
+// @Summary Confirm account merge
+// @Description Vincula la identidad de un login con email coincidente a la cuenta existente, demostrando su control con un token de Firebase reciente de esa cuenta
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ConfirmAccountMergeRequest true "Merge token and proof of control"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/merge/confirm [post]
+func (h *Handler) ConfirmAccountMerge(c *gin.Context) {
+	var req models.ConfirmAccountMergeRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	authData, err := h.firebaseAuthService.ConfirmAccountMerge(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.WithError(err).Warn("Account merge failed")
+
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrInvalidMergeToken),
+			errors.Is(err, services.ErrInvalidFirebaseToken),
+			errors.Is(err, services.ErrStaleReauthentication):
+			statusCode = http.StatusUnauthorized
+		case errors.Is(err, services.ErrMergeProofMismatch), services.IsAccountStatusError(err):
+			statusCode = http.StatusForbidden
+		case errors.Is(err, services.ErrIdentityLinkedToOtherUser):
+			statusCode = http.StatusConflict
+		}
+
+		c.JSON(statusCode, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    authData,
+	})
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// AdminGetUser godoc
+// @Summary Get any user (Admin only)
+// @Description Obtiene cualquier usuario, incluidos los suspendidos o eliminados
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id} [get]
+func (h *Handler) AdminGetUser(c *gin.Context) {
+	user, err := h.userAdminService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"user": user,
+		},
+	})
+}
+
+// AdminUpdateUserStatus godoc
+// @Summary Change user status (Admin only)
+// @Description Cambia el estado de la cuenta (pending_verification, active, locked, suspended, pending_deletion) con un motivo; cualquier estado distinto de active cierra todas sus sesiones. Las transiciones no permitidas por la máquina de estados devuelven 400
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body models.UpdateUserStatusRequest true "New status and reason"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/users/{id}/status [patch]
+func (h *Handler) AdminUpdateUserStatus(c *gin.Context) {
+	var req models.UpdateUserStatusRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	user, err := h.userAdminService.SetStatus(c.Request.Context(), adminActor(c), c.Param("id"), req.Status, req.Reason, req.LockedUntil)
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"user": user,
+		},
+	})
+}
+
+// AdminDeleteUser godoc
+// @Summary Delete user (Admin only)
+// @Description Pasa la cuenta a pending_deletion (soft delete), cierra todas sus sesiones y programa el borrado definitivo de sus datos al terminar el periodo de gracia
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param reason query string false "Reason for the deletion"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id} [delete]
+func (h *Handler) AdminDeleteUser(c *gin.Context) {
+	_, err := h.userAdminService.SetStatus(c.Request.Context(), adminActor(c), c.Param("id"), models.StatusPendingDeletion, c.Query("reason"), nil)
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "User deleted successfully",
+		},
+	})
+}
+
+// AdminForceLogout godoc
+// @Summary Force logout (Admin only)
+// @Description Revoca todas las sesiones del usuario y sus refresh tokens de Firebase
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body models.ForceLogoutRequest false "Reason"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id}/logout [post]
+func (h *Handler) AdminForceLogout(c *gin.Context) {
+	var req models.ForceLogoutRequest
+	if c.Request.ContentLength > 0 && !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	revoked, err := h.userAdminService.ForceLogout(c.Request.Context(), adminActor(c), c.Param("id"), req.Reason)
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: models.ForceLogoutResponse{
+			RevokedSessions: revoked,
+		},
+	})
+}
+
+// adminActor identifica al administrador autenticado para la auditoría
+func adminActor(c *gin.Context) services.AdminActor {
+	return services.AdminActor{
+		UserID:    c.GetString(middleware.ContextUserID),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+}
+
+// writeAdminError traduce los errores de administración a códigos HTTP
+func (h *Handler) writeAdminError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidStatusValue):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrCannotModifySelf):
+		statusCode = http.StatusConflict
+	case errors.Is(err, services.ErrErasureNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrErasureNotScheduled):
+		statusCode = http.StatusConflict
+	default:
+		h.logger.WithError(err).Error("Admin user operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
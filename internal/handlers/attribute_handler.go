@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// GetMyAttributes godoc
+// @Summary Get my custom attributes
+// @Description Devuelve los atributos personalizados visibles para el usuario y su esquema
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /users/attributes [get]
+func (h *Handler) GetMyAttributes(c *gin.Context) {
+	h.getAttributes(c, c.GetString(middleware.ContextUserID), false)
+}
+
+// UpdateMyAttributes godoc
+// @Summary Update my custom attributes
+// @Description Aplica un merge patch (null borra) a los atributos que el usuario puede editar
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body map[string]interface{} true "Attributes to change"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /users/attributes [patch]
+func (h *Handler) UpdateMyAttributes(c *gin.Context) {
+	actor := adminActor(c)
+	h.updateAttributes(c, actor, actor.UserID, false)
+}
+
+// AdminGetUserAttributes godoc
+// @Summary Get user custom attributes (Admin only)
+// @Description Devuelve todos los atributos personalizados del usuario, incluidos los admin_only
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id}/attributes [get]
+func (h *Handler) AdminGetUserAttributes(c *gin.Context) {
+	h.getAttributes(c, c.Param("id"), true)
+}
+
+// AdminUpdateUserAttributes godoc
+// @Summary Update user custom attributes (Admin only)
+// @Description Aplica un merge patch (null borra) a cualquier atributo del usuario
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body map[string]interface{} true "Attributes to change"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id}/attributes [patch]
+func (h *Handler) AdminUpdateUserAttributes(c *gin.Context) {
+	h.updateAttributes(c, adminActor(c), c.Param("id"), true)
+}
+
+// AdminListAttributeDefinitions godoc
+// @Summary List attribute definitions (Admin only)
+// @Description Lista el esquema de atributos personalizados; tenant_id filtra por tenant (vacío para el global)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param tenant_id query string false "Tenant ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/attributes [get]
+func (h *Handler) AdminListAttributeDefinitions(c *gin.Context) {
+	var tenantID *string
+	if value, ok := c.GetQuery("tenant_id"); ok {
+		tenantID = &value
+	}
+
+	definitions, err := h.attributeService.ListDefinitions(c.Request.Context(), tenantID)
+	if err != nil {
+		h.writeAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"definitions": definitions,
+		},
+	})
+}
+
+// AdminCreateAttributeDefinition godoc
+// @Summary Create attribute definition (Admin only)
+// @Description Define un atributo personalizado con su tipo, validación, visibilidad y si se incluye en los JWT
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.AttributeDefinitionRequest true "Attribute definition"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/attributes [post]
+func (h *Handler) AdminCreateAttributeDefinition(c *gin.Context) {
+	var req models.AttributeDefinitionRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	definition, err := h.attributeService.CreateDefinition(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"definition": definition,
+		},
+	})
+}
+
+// AdminUpdateAttributeDefinition godoc
+// @Summary Replace attribute definition (Admin only)
+// @Description Reemplaza la definición de un atributo; tenant_id y key no pueden cambiar
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Definition ID"
+// @Param request body models.AttributeDefinitionRequest true "Attribute definition"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/attributes/{id} [put]
+func (h *Handler) AdminUpdateAttributeDefinition(c *gin.Context) {
+	var req models.AttributeDefinitionRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	definition, err := h.attributeService.UpdateDefinition(c.Request.Context(), adminActor(c), c.Param("id"), &req)
+	if err != nil {
+		h.writeAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"definition": definition,
+		},
+	})
+}
+
+// AdminDeleteAttributeDefinition godoc
+// @Summary Delete attribute definition (Admin only)
+// @Description Elimina un atributo del esquema; sus valores dejan de exponerse
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Definition ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/attributes/{id} [delete]
+func (h *Handler) AdminDeleteAttributeDefinition(c *gin.Context) {
+	if err := h.attributeService.DeleteDefinition(c.Request.Context(), adminActor(c), c.Param("id")); err != nil {
+		h.writeAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "Attribute definition deleted",
+		},
+	})
+}
+
+func (h *Handler) getAttributes(c *gin.Context, userID string, admin bool) {
+	values, schema, err := h.attributeService.GetAttributes(c.Request.Context(), userID, admin)
+	if err != nil {
+		h.writeAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"attributes": values,
+			"schema":     schema,
+		},
+	})
+}
+
+func (h *Handler) updateAttributes(c *gin.Context, actor services.AdminActor, userID string, admin bool) {
+	var patch map[string]interface{}
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil || patch == nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Request body must be a JSON object",
+		})
+		return
+	}
+
+	values, err := h.attributeService.UpdateAttributes(c.Request.Context(), actor, userID, patch, admin)
+	if err != nil {
+		h.writeAttributeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"attributes": values,
+		},
+	})
+}
+
+// writeAttributeError traduce los errores de atributos a códigos HTTP
+func (h *Handler) writeAttributeError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrAttributeDefinitionNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidAttributes), errors.Is(err, services.ErrInvalidAttributeDefinition):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrAttributeDefinitionExists):
+		statusCode = http.StatusConflict
+	default:
+		h.logger.WithError(err).Error("Attribute operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+	"it-auth-service/pkg/storage"
+)
+
+// UploadAvatar godoc
+// @Summary Upload profile picture
+// @Description Sube la imagen de perfil (cuerpo crudo o campo multipart "file"; JPEG, PNG o GIF). Se recorta a cuadrado, se genera en varios tamaños sin metadatos EXIF y photo_url pasa a apuntar a ella.
+// @Tags users
+// @Accept image/jpeg,image/png,image/gif,multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 413 {object} models.APIResponse
+// @Failure 415 {object} models.APIResponse
+// @Failure 422 {object} models.APIResponse
+// @Router /users/profile/avatar [put]
+func (h *Handler) UploadAvatar(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.avatarService.MaxBytes())
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			h.writeAvatarError(c, err)
+			return
+		}
+		if fileHeader.Size > h.avatarService.MaxBytes() {
+			h.writeAvatarError(c, &http.MaxBytesError{Limit: h.avatarService.MaxBytes()})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			h.writeAvatarError(c, err)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		h.writeAvatarError(c, err)
+		return
+	}
+
+	user, err := h.avatarService.Upload(c.Request.Context(), adminActor(c), data)
+	if err != nil {
+		h.writeAvatarError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"user": user,
+		},
+	})
+}
+
+// GetAvatar godoc
+// @Summary Get profile picture URLs
+// @Description Devuelve URLs firmadas de cada tamaño del avatar subido
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /users/profile/avatar [get]
+func (h *Handler) GetAvatar(c *gin.Context) {
+	urls, err := h.avatarService.GetURLs(c.Request.Context(), c.GetString(middleware.ContextUserID))
+	if err != nil {
+		h.writeAvatarError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"avatar": urls,
+		},
+	})
+}
+
+// DeleteAvatar godoc
+// @Summary Delete profile picture
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /users/profile/avatar [delete]
+func (h *Handler) DeleteAvatar(c *gin.Context) {
+	user, err := h.avatarService.Delete(c.Request.Context(), adminActor(c))
+	if err != nil {
+		h.writeAvatarError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"user": user,
+		},
+	})
+}
+
+// ServeAvatar godoc
+// @Summary Profile picture
+// @Description URL estable de un avatar (la de photo_url); redirige a una URL firmada de corta duración del tamaño más cercano
+// @Tags users
+// @Param id path string true "Avatar ID"
+// @Param size query int false "Requested side in pixels"
+// @Success 302
+// @Failure 404 {object} models.APIResponse
+// @Router /avatars/{id} [get]
+func (h *Handler) ServeAvatar(c *gin.Context) {
+	size, _ := strconv.Atoi(c.Query("size"))
+	signed, err := h.avatarService.SignedURL(c.Request.Context(), c.Param("id"), size)
+	if err != nil {
+		h.writeAvatarError(c, err)
+		return
+	}
+
+	// La redirección caduca con la firma; el navegador no debe cachearla más tiempo
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Redirect(http.StatusFound, signed)
+}
+
+// ServeBlob sirve los ficheros del almacenamiento local a partir de sus URLs
+// firmadas. Con el backend S3 las URLs firmadas apuntan directamente al bucket.
+func (h *Handler) ServeBlob(c *gin.Context) {
+	local, ok := h.blobStorage.(*storage.LocalStorage)
+	if !ok {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "Not found",
+		})
+		return
+	}
+
+	file, contentType, err := local.Open(strings.TrimPrefix(c.Param("key"), "/"), c.Query("expires"), c.Query("signature"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, storage.ErrInvalidSignature), errors.Is(err, storage.ErrInvalidKey):
+			statusCode = http.StatusForbidden
+		case errors.Is(err, storage.ErrNotFound):
+			statusCode = http.StatusNotFound
+		default:
+			h.logger.WithError(err).Error("Failed to serve blob")
+		}
+		c.JSON(statusCode, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to serve blob")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, info.Size(), contentType, file, nil)
+}
+
+func (h *Handler) writeAvatarError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		statusCode = http.StatusRequestEntityTooLarge
+	case errors.Is(err, http.ErrMissingFile):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrAvatarUnsupportedType):
+		statusCode = http.StatusUnsupportedMediaType
+	case errors.Is(err, services.ErrAvatarInvalidImage), errors.Is(err, services.ErrAvatarTooManyPixels):
+		statusCode = http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrAvatarNotFound), errors.Is(err, services.ErrUserNotFound):
+		statusCode = http.StatusNotFound
+	default:
+		h.logger.WithError(err).Error("Avatar operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+	"it-auth-service/internal/validator"
+)
+
+// Tamaño máximo del fichero de importación
+const maxImportFileSize = 50 << 20
+
+// AdminImportUsers godoc
+// @Summary Bulk import users (Admin only)
+// @Description Importa usuarios desde CSV o NDJSON (cuerpo crudo o campo multipart "file"). El procesamiento es asíncrono: devuelve el job para consultar su progreso
+// @Tags admin
+// @Accept text/csv,application/x-ndjson,multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param format query string true "csv or ndjson"
+// @Param dry_run query bool false "Validate without writing"
+// @Param create_firebase_users query bool false "Create missing users in Firebase"
+// @Success 202 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 413 {object} models.APIResponse
+// @Router /admin/users/import [post]
+func (h *Handler) AdminImportUsers(c *gin.Context) {
+	var opts models.UserImportOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			h.writeImportBodyError(c, err)
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			h.writeImportBodyError(c, err)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	job, err := h.userBulkService.StartImport(c.Request.Context(), adminActor(c), opts, body)
+	if err != nil {
+		h.writeImportBodyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"job": job,
+		},
+	})
+}
+
+// AdminGetImportJob godoc
+// @Summary Get bulk import job (Admin only)
+// @Description Devuelve el estado de una importación y los errores de cada fila rechazada
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/import/{id} [get]
+func (h *Handler) AdminGetImportJob(c *gin.Context) {
+	job, err := h.userBulkService.GetImportJob(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, services.ErrImportJobNotFound) {
+			statusCode = http.StatusNotFound
+		} else {
+			h.logger.WithError(err).Error("Failed to get import job")
+		}
+		c.JSON(statusCode, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// AdminExportUsers godoc
+// @Summary Bulk export users (Admin only)
+// @Description Exporta en streaming los usuarios que cumplen los filtros del listado, en CSV o NDJSON y con las columnas indicadas
+// @Tags admin
+// @Produce text/csv,application/x-ndjson
+// @Security BearerAuth
+// @Param format query string false "csv (default) or ndjson"
+// @Param fields query string false "Comma-separated list of columns"
+// @Param status query string false "Comma-separated statuses"
+// @Param provider query string false "Provider"
+// @Param email_domain query string false "Email domain"
+// @Param q query string false "Free-text search"
+// @Success 200 {file} file
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/users/export [get]
+func (h *Handler) AdminExportUsers(c *gin.Context) {
+	format := c.DefaultQuery("format", models.BulkFormatCSV)
+	if format != models.BulkFormatCSV && format != models.BulkFormatNDJSON {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "format must be csv or ndjson",
+		})
+		return
+	}
+
+	fields, err := services.ParseExportFields(c.Query("fields"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var query models.ListUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == models.BulkFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("users-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// Una vez enviada la cabecera ya no se puede cambiar el código de estado; un
+	// fallo a mitad se refleja en el log y en una respuesta truncada
+	exported, err := h.userBulkService.Export(c.Request.Context(), adminActor(c), c.Writer, format, fields, query)
+	if err != nil {
+		h.logger.WithError(err).WithField("exported", exported).Error("User export failed")
+	}
+}
+
+// writeImportBodyError traduce los errores al recibir el fichero de importación
+func (h *Handler) writeImportBodyError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		statusCode = http.StatusRequestEntityTooLarge
+	case errors.Is(err, http.ErrMissingFile):
+		statusCode = http.StatusBadRequest
+	default:
+		h.logger.WithError(err).Error("Failed to start user import")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
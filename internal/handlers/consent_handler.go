@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// ListCurrentConsents godoc
+// @Summary Current legal documents
+// @Description Devuelve la versión vigente de los términos de servicio y de la política de privacidad
+// @Tags consents
+// @Produce json
+// @Success 200 {object} models.APIResponse
+// @Router /consents [get]
+func (h *Handler) ListCurrentConsents(c *gin.Context) {
+	documents, err := h.consentService.Current(c.Request.Context())
+	if err != nil {
+		h.writeConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"documents": documents,
+		},
+	})
+}
+
+// AcceptLoginConsents godoc
+// @Summary Accept consents to complete login
+// @Description Acepta los documentos de una respuesta consent_required y completa el login (o devuelve el challenge de MFA)
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.LoginConsentRequest true "Firebase token and accepted documents"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /auth/consent [post]
+func (h *Handler) AcceptLoginConsents(c *gin.Context) {
+	var req models.LoginConsentRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	authData, err := h.firebaseAuthService.AcceptLoginConsents(c.Request.Context(), &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.writeConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    authData,
+	})
+}
+
+// GetMyConsents godoc
+// @Summary Get my consents
+// @Description Documentos vigentes, obligatorios pendientes de aceptar e historial de aceptaciones del usuario
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /users/consents [get]
+func (h *Handler) GetMyConsents(c *gin.Context) {
+	status, err := h.consentService.Status(c.Request.Context(), c.GetString(middleware.ContextUserID))
+	if err != nil {
+		h.writeConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"consents": status,
+		},
+	})
+}
+
+// AcceptMyConsents godoc
+// @Summary Accept consents
+// @Description Registra la aceptación de versiones vigentes de los documentos
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.AcceptConsentsRequest true "Accepted documents"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /users/consents [post]
+func (h *Handler) AcceptMyConsents(c *gin.Context) {
+	var req models.AcceptConsentsRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	acceptances, err := h.consentService.Accept(c.Request.Context(), adminActor(c), req.DocumentIDs)
+	if err != nil {
+		h.writeConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"accepted": acceptances,
+		},
+	})
+}
+
+// AdminListConsentDocuments godoc
+// @Summary List consent documents
+// @Description Todas las versiones de los documentos legales, publicadas o no
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "terms_of_service or privacy_policy"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/consents [get]
+func (h *Handler) AdminListConsentDocuments(c *gin.Context) {
+	documents, err := h.consentService.ListDocuments(c.Request.Context(), c.Query("type"))
+	if err != nil {
+		h.writeConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"documents": documents,
+		},
+	})
+}
+
+// AdminCreateConsentDocument godoc
+// @Summary Create consent document version
+// @Description Registra una versión nueva de un documento legal; con publish=true pasa a ser la vigente. Si es obligatoria, los usuarios deberán aceptarla en su próximo login.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateConsentDocumentRequest true "Document version"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/consents [post]
+func (h *Handler) AdminCreateConsentDocument(c *gin.Context) {
+	var req models.CreateConsentDocumentRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	document, err := h.consentService.CreateDocument(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"document": document,
+		},
+	})
+}
+
+// AdminPublishConsentDocument godoc
+// @Summary Publish consent document version
+// @Description Hace vigente la versión; pasa a ser la última publicada de su tipo
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Document ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/consents/{id}/publish [post]
+func (h *Handler) AdminPublishConsentDocument(c *gin.Context) {
+	document, err := h.consentService.PublishDocument(c.Request.Context(), adminActor(c), c.Param("id"))
+	if err != nil {
+		h.writeConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"document": document,
+		},
+	})
+}
+
+// AdminConsentCoverage godoc
+// @Summary Consent coverage report
+// @Description Para cada documento vigente, cuántas cuentas que pueden iniciar sesión lo han aceptado y cuántas aceptaron cada versión
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/consents/coverage [get]
+func (h *Handler) AdminConsentCoverage(c *gin.Context) {
+	coverage, err := h.consentService.Coverage(c.Request.Context())
+	if err != nil {
+		h.writeConsentError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"coverage": coverage,
+		},
+	})
+}
+
+func (h *Handler) writeConsentError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidFirebaseToken), errors.Is(err, services.ErrUserNotFound):
+		statusCode = http.StatusUnauthorized
+	case services.IsAccountStatusError(err):
+		statusCode = http.StatusForbidden
+	case errors.Is(err, services.ErrConsentNotCurrent):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrConsentDocumentNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrConsentDocumentExists):
+		statusCode = http.StatusConflict
+	default:
+		h.logger.WithError(err).Error("Consent operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
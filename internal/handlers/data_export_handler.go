@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// RequestDataExport godoc
+// @Summary Request personal data export
+// @Description Solicita la exportación de todos los datos personales del usuario (RGPD). Se genera en segundo plano; consulte su estado para obtener el enlace de descarga
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
+// @Router /users/data-exports [post]
+func (h *Handler) RequestDataExport(c *gin.Context) {
+	export, err := h.dataExportService.RequestExport(
+		c.Request.Context(),
+		c.GetString(middleware.ContextUserID),
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+	)
+	if err != nil {
+		h.writeDataExportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"export": export,
+		},
+	})
+}
+
+// GetDataExport godoc
+// @Summary Get personal data export status
+// @Description Devuelve el estado de una exportación y, cuando está lista, un enlace de descarga firmado y de duración limitada
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Export ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /users/data-exports/{id} [get]
+func (h *Handler) GetDataExport(c *gin.Context) {
+	export, err := h.dataExportService.GetExport(c.Request.Context(), c.GetString(middleware.ContextUserID), c.Param("id"))
+	if err != nil {
+		h.writeDataExportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"export": export,
+		},
+	})
+}
+
+// DownloadDataExport godoc
+// @Summary Download personal data export
+// @Description Descarga el fichero JSON de la exportación. El enlace está firmado y caduca; no requiere JWT
+// @Tags users
+// @Produce json
+// @Param id path string true "Export ID"
+// @Param expires query int true "Link expiry (unix seconds)"
+// @Param signature query string true "Link signature"
+// @Success 200 {file} file
+// @Failure 403 {object} models.APIResponse
+// @Router /users/data-exports/{id}/download [get]
+func (h *Handler) DownloadDataExport(c *gin.Context) {
+	export, err := h.dataExportService.Download(
+		c.Request.Context(),
+		c.Param("id"),
+		c.Query("expires"),
+		c.Query("signature"),
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+	)
+	if err != nil {
+		h.writeDataExportError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"data-export-%s.json\"", export.ID))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/json", export.Bundle)
+}
+
+// writeDataExportError traduce los errores de exportación a códigos HTTP
+func (h *Handler) writeDataExportError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrDataExportNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrDataExportTooSoon):
+		statusCode = http.StatusTooManyRequests
+	case errors.Is(err, services.ErrDataExportLinkInvalid):
+		statusCode = http.StatusForbidden
+	default:
+		h.logger.WithError(err).Error("Data export operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
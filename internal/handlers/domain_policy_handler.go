@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// AdminListDomainPolicies godoc
+// @Summary List email domain policies
+// @Description Reglas por dominio de email: bloqueo de altas, verificación automática y unión a una organización
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/domain-policies [get]
+func (h *Handler) AdminListDomainPolicies(c *gin.Context) {
+	policies, err := h.domainPolicyService.List(c.Request.Context())
+	if err != nil {
+		h.writeDomainPolicyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"policies": policies,
+		},
+	})
+}
+
+// AdminCreateDomainPolicy godoc
+// @Summary Create email domain policy
+// @Description Crea la regla de un dominio; un dominio que bloquea altas no puede verificar ni unir automáticamente
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.DomainPolicyRequest true "Domain policy"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/domain-policies [post]
+func (h *Handler) AdminCreateDomainPolicy(c *gin.Context) {
+	var req models.DomainPolicyRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	policy, err := h.domainPolicyService.Create(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeDomainPolicyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"policy": policy,
+		},
+	})
+}
+
+// AdminUpdateDomainPolicy godoc
+// @Summary Replace email domain policy
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Policy ID"
+// @Param request body models.DomainPolicyRequest true "Domain policy"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/domain-policies/{id} [put]
+func (h *Handler) AdminUpdateDomainPolicy(c *gin.Context) {
+	var req models.DomainPolicyRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	policy, err := h.domainPolicyService.Update(c.Request.Context(), adminActor(c), c.Param("id"), &req)
+	if err != nil {
+		h.writeDomainPolicyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"policy": policy,
+		},
+	})
+}
+
+// AdminDeleteDomainPolicy godoc
+// @Summary Delete email domain policy
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Policy ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/domain-policies/{id} [delete]
+func (h *Handler) AdminDeleteDomainPolicy(c *gin.Context) {
+	if err := h.domainPolicyService.Delete(c.Request.Context(), adminActor(c), c.Param("id")); err != nil {
+		h.writeDomainPolicyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "Domain policy deleted",
+		},
+	})
+}
+
+// AdminGetDisposableEmailDomains godoc
+// @Summary Disposable email domain list status
+// @Description Estado de la lista de dominios desechables cargada en esta instancia
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/disposable-email-domains [get]
+func (h *Handler) AdminGetDisposableEmailDomains(c *gin.Context) {
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    h.disposableEmails.Status(),
+	})
+}
+
+// AdminReloadDisposableEmailDomains godoc
+// @Summary Reload disposable email domains
+// @Description Relee el fichero de dominios desechables en esta instancia; el resto lo relee en su siguiente ciclo
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/disposable-email-domains/reload [post]
+func (h *Handler) AdminReloadDisposableEmailDomains(c *gin.Context) {
+	status, err := h.disposableEmails.Reload()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to reload disposable email domains")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    status,
+	})
+}
+
+func (h *Handler) writeDomainPolicyError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrDomainPolicyNotFound), errors.Is(err, services.ErrOrganizationNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidDomainPolicy):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrDomainPolicyExists):
+		statusCode = http.StatusConflict
+	default:
+		h.logger.WithError(err).Error("Domain policy operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
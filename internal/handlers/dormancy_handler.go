@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+const (
+	defaultDormancyReportLimit = 100
+	maxDormancyReportLimit     = 1000
+)
+
+// RequestReactivation godoc
+// @Summary Request account reactivation
+// @Description Envía un código al email de una cuenta desactivada por inactividad. El token de Firebase demuestra el control de una de sus identidades; un código nuevo anula el anterior.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RequestReactivationRequest true "Firebase token"
+// @Success 202 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
+// @Router /auth/reactivate [post]
+func (h *Handler) RequestReactivation(c *gin.Context) {
+	var req models.RequestReactivationRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	reactivation, err := h.firebaseAuthService.RequestReactivation(c.Request.Context(), &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.writeDormancyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"expires_at": reactivation.ExpiresAt,
+		},
+	})
+}
+
+// ConfirmReactivation godoc
+// @Summary Confirm account reactivation
+// @Description Reactiva la cuenta con el código recibido por email y completa el login (o devuelve el challenge de MFA)
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ConfirmReactivationRequest true "Firebase token and code"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 410 {object} models.APIResponse
+// @Router /auth/reactivate/confirm [post]
+func (h *Handler) ConfirmReactivation(c *gin.Context) {
+	var req models.ConfirmReactivationRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	authData, err := h.firebaseAuthService.ConfirmReactivation(c.Request.Context(), &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.writeDormancyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    authData,
+	})
+}
+
+// AdminDormancyReport godoc
+// @Summary Dormant accounts report
+// @Description Enumera, sin cambiar nada, las cuentas que el job de inactividad avisaría y desactivaría ahora, y las organizaciones exentas
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Max accounts per list (default 100, max 1000)"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/dormancy/report [get]
+func (h *Handler) AdminDormancyReport(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultDormancyReportLimit
+	}
+	if limit > maxDormancyReportLimit {
+		limit = maxDormancyReportLimit
+	}
+
+	report, err := h.dormancyService.Report(c.Request.Context(), limit)
+	if err != nil {
+		h.writeDormancyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"report": report,
+		},
+	})
+}
+
+func (h *Handler) writeDormancyError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidFirebaseToken):
+		statusCode = http.StatusUnauthorized
+	case errors.Is(err, services.ErrNotDormant):
+		statusCode = http.StatusConflict
+	case errors.Is(err, services.ErrReactivationNotFound), errors.Is(err, services.ErrUserNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrReactivationInvalidCode):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrReactivationExpired):
+		statusCode = http.StatusGone
+	case errors.Is(err, services.ErrReactivationTooSoon):
+		statusCode = http.StatusTooManyRequests
+	default:
+		h.logger.WithError(err).Error("Account reactivation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
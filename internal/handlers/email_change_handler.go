@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// RequestEmailChange godoc
+// @Summary Request email change
+// @Description Envía un código de confirmación a la nueva dirección. Requiere autenticación reciente; una solicitud nueva anula la anterior.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.RequestEmailChangeRequest true "New email"
+// @Success 202 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /users/email-change [post]
+func (h *Handler) RequestEmailChange(c *gin.Context) {
+	var req models.RequestEmailChangeRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	change, err := h.emailChangeService.Request(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeEmailChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"email_change": change,
+		},
+	})
+}
+
+// ConfirmEmailChange godoc
+// @Summary Confirm email change
+// @Description Aplica el cambio con el código recibido en la nueva dirección y envía a la anterior un enlace para deshacerlo
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ConfirmEmailChangeRequest true "Confirmation code"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 410 {object} models.APIResponse
+// @Router /users/email-change/confirm [post]
+func (h *Handler) ConfirmEmailChange(c *gin.Context) {
+	var req models.ConfirmEmailChangeRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	user, err := h.emailChangeService.Confirm(c.Request.Context(), adminActor(c), req.Code)
+	if err != nil {
+		h.writeEmailChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"user": user,
+		},
+	})
+}
+
+// RevertEmailChange godoc
+// @Summary Revert email change
+// @Description Restaura el email anterior con el enlace enviado a esa dirección y cierra todas las sesiones de la cuenta
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body models.RevertEmailChangeRequest true "Revert token"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 410 {object} models.APIResponse
+// @Router /users/email-change/revert [post]
+func (h *Handler) RevertEmailChange(c *gin.Context) {
+	var req models.RevertEmailChangeRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	if _, err := h.emailChangeService.Revert(c.Request.Context(), req.Token, c.ClientIP(), c.GetHeader("User-Agent")); err != nil {
+		h.writeEmailChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "Email change reverted, all sessions have been signed out",
+		},
+	})
+}
+
+func (h *Handler) writeEmailChangeError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrEmailChangeNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrEmailUnchanged), errors.Is(err, services.ErrEmailChangeInvalidCode):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrEmailAlreadyRegistered):
+		statusCode = http.StatusConflict
+	case errors.Is(err, services.ErrEmailChangeExpired), errors.Is(err, services.ErrEmailChangeRevertInvalid):
+		statusCode = http.StatusGone
+	default:
+		h.logger.WithError(err).Error("Email change failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
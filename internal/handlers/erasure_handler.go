@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/validator"
+)
+
+// AdminGetUserErasure godoc
+// @Summary Get user erasure (Admin only)
+// @Description Devuelve el borrado definitivo programado o completado de una cuenta eliminada
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id}/erasure [get]
+func (h *Handler) AdminGetUserErasure(c *gin.Context) {
+	erasure, err := h.erasureService.GetErasure(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"erasure": erasure,
+		},
+	})
+}
+
+// AdminEraseUser godoc
+// @Summary Erase user now (Admin only)
+// @Description Ejecuta inmediatamente, sin esperar al periodo de gracia, el borrado definitivo de una cuenta eliminada. Es irreversible
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/users/{id}/erasure [post]
+func (h *Handler) AdminEraseUser(c *gin.Context) {
+	erasure, err := h.erasureService.EraseNow(c.Request.Context(), adminActor(c), c.Param("id"))
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"erasure": erasure,
+		},
+	})
+}
+
+// AdminListErasureTombstones godoc
+// @Summary List erasure tombstones (Admin only)
+// @Description Devuelve en orden cronológico las lápidas de los borrados completados posteriores a since, para que los sistemas downstream purguen sus copias
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param since query string false "RFC 3339 timestamp; only tombstones erased after it"
+// @Param limit query int false "Items per page" default(100)
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/erasures/tombstones [get]
+func (h *Handler) AdminListErasureTombstones(c *gin.Context) {
+	var query models.ListErasureTombstonesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tombstones, err := h.erasureService.ListTombstones(c.Request.Context(), &query)
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"tombstones": tombstones,
+		},
+	})
+}
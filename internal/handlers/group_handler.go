@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// GetMyPermissions godoc
+// @Summary Get my effective permissions
+// @Description Devuelve los grupos (incluidos los heredados por anidamiento), roles y permisos efectivos del usuario
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /users/permissions [get]
+func (h *Handler) GetMyPermissions(c *gin.Context) {
+	user, _ := c.MustGet(middleware.ContextUser).(*models.User)
+	h.writeEffectivePermissions(c, user)
+}
+
+// AdminGetUserPermissions godoc
+// @Summary Get user effective permissions (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/users/{id}/permissions [get]
+func (h *Handler) AdminGetUserPermissions(c *gin.Context) {
+	user, err := h.userAdminService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeEffectivePermissions(c, user)
+}
+
+// AdminListGroups godoc
+// @Summary List groups (Admin only)
+// @Description Lista los grupos; tenant_id filtra por tenant (vacío para los globales)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param tenant_id query string false "Tenant ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/groups [get]
+func (h *Handler) AdminListGroups(c *gin.Context) {
+	var tenantID *string
+	if value, ok := c.GetQuery("tenant_id"); ok {
+		tenantID = &value
+	}
+
+	groups, err := h.groupService.ListGroups(c.Request.Context(), tenantID)
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"groups": groups,
+		},
+	})
+}
+
+// AdminCreateGroup godoc
+// @Summary Create group (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.UserGroupRequest true "Group"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/groups [post]
+func (h *Handler) AdminCreateGroup(c *gin.Context) {
+	var req models.UserGroupRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	group, err := h.groupService.CreateGroup(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"group": group,
+		},
+	})
+}
+
+// AdminGetGroup godoc
+// @Summary Get group (Admin only)
+// @Description Devuelve el grupo con sus miembros directos, subgrupos y roles
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/groups/{id} [get]
+func (h *Handler) AdminGetGroup(c *gin.Context) {
+	group, err := h.groupService.GetGroup(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"group": group,
+		},
+	})
+}
+
+// AdminUpdateGroup godoc
+// @Summary Update group (Admin only)
+// @Description Cambia el nombre y la descripción; el tenant no cambia
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID"
+// @Param request body models.UserGroupRequest true "Group"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/groups/{id} [put]
+func (h *Handler) AdminUpdateGroup(c *gin.Context) {
+	var req models.UserGroupRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	group, err := h.groupService.UpdateGroup(c.Request.Context(), adminActor(c), c.Param("id"), &req)
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"group": group,
+		},
+	})
+}
+
+// AdminDeleteGroup godoc
+// @Summary Delete group (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/groups/{id} [delete]
+func (h *Handler) AdminDeleteGroup(c *gin.Context) {
+	if err := h.groupService.DeleteGroup(c.Request.Context(), adminActor(c), c.Param("id")); err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeGroupMessage(c, "Group deleted")
+}
+
+// AdminAddGroupMember godoc
+// @Summary Add user to group (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID"
+// @Param request body models.AddGroupMemberRequest true "User"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/groups/{id}/members [post]
+func (h *Handler) AdminAddGroupMember(c *gin.Context) {
+	var req models.AddGroupMemberRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.groupService.AddMember(c.Request.Context(), adminActor(c), c.Param("id"), req.UserID); err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeGroupMessage(c, "Member added")
+}
+
+// AdminRemoveGroupMember godoc
+// @Summary Remove user from group (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/groups/{id}/members/{user_id} [delete]
+func (h *Handler) AdminRemoveGroupMember(c *gin.Context) {
+	if err := h.groupService.RemoveMember(c.Request.Context(), adminActor(c), c.Param("id"), c.Param("user_id")); err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeGroupMessage(c, "Member removed")
+}
+
+// AdminAddGroupChild godoc
+// @Summary Nest group (Admin only)
+// @Description Hace al grupo indicado miembro de este; sus usuarios heredan los roles. Se rechazan los ciclos.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Parent group ID"
+// @Param request body models.AddGroupChildRequest true "Child group"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/groups/{id}/groups [post]
+func (h *Handler) AdminAddGroupChild(c *gin.Context) {
+	var req models.AddGroupChildRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.groupService.AddChild(c.Request.Context(), adminActor(c), c.Param("id"), req.GroupID); err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeGroupMessage(c, "Group nested")
+}
+
+// AdminRemoveGroupChild godoc
+// @Summary Unnest group (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Parent group ID"
+// @Param child_id path string true "Child group ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/groups/{id}/groups/{child_id} [delete]
+func (h *Handler) AdminRemoveGroupChild(c *gin.Context) {
+	if err := h.groupService.RemoveChild(c.Request.Context(), adminActor(c), c.Param("id"), c.Param("child_id")); err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeGroupMessage(c, "Nested group removed")
+}
+
+// AdminAssignGroupRole godoc
+// @Summary Assign role to group (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID"
+// @Param request body models.AssignGroupRoleRequest true "Role"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/groups/{id}/roles [post]
+func (h *Handler) AdminAssignGroupRole(c *gin.Context) {
+	var req models.AssignGroupRoleRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	if err := h.groupService.AssignRole(c.Request.Context(), adminActor(c), c.Param("id"), req.Role); err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeGroupMessage(c, "Role assigned")
+}
+
+// AdminUnassignGroupRole godoc
+// @Summary Remove role from group (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group ID"
+// @Param role path string true "Role name"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/groups/{id}/roles/{role} [delete]
+func (h *Handler) AdminUnassignGroupRole(c *gin.Context) {
+	if err := h.groupService.UnassignRole(c.Request.Context(), adminActor(c), c.Param("id"), c.Param("role")); err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeGroupMessage(c, "Role removed")
+}
+
+// AdminListRoles godoc
+// @Summary List roles (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/roles [get]
+func (h *Handler) AdminListRoles(c *gin.Context) {
+	roles, err := h.groupService.ListRoles(c.Request.Context())
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"roles": roles,
+		},
+	})
+}
+
+// AdminCreateRole godoc
+// @Summary Create role (Admin only)
+// @Description Define un rol con sus permisos (recurso:acción, "recurso:*" o "*")
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.RoleDefinitionRequest true "Role"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/roles [post]
+func (h *Handler) AdminCreateRole(c *gin.Context) {
+	var req models.RoleDefinitionRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	role, err := h.groupService.CreateRole(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"role": role,
+		},
+	})
+}
+
+// AdminUpdateRole godoc
+// @Summary Replace role (Admin only)
+// @Description Reemplaza la descripción y los permisos; el nombre no cambia
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Param request body models.RoleDefinitionRequest true "Role"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/roles/{name} [put]
+func (h *Handler) AdminUpdateRole(c *gin.Context) {
+	var req models.RoleDefinitionRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	role, err := h.groupService.UpdateRole(c.Request.Context(), adminActor(c), c.Param("name"), &req)
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"role": role,
+		},
+	})
+}
+
+// AdminDeleteRole godoc
+// @Summary Delete role (Admin only)
+// @Description Elimina el rol y sus asignaciones a grupos
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/roles/{name} [delete]
+func (h *Handler) AdminDeleteRole(c *gin.Context) {
+	if err := h.groupService.DeleteRole(c.Request.Context(), adminActor(c), c.Param("name")); err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+	h.writeGroupMessage(c, "Role deleted")
+}
+
+func (h *Handler) writeEffectivePermissions(c *gin.Context, user *models.User) {
+	effective, err := h.groupService.EffectivePermissions(c.Request.Context(), user)
+	if err != nil {
+		h.writeGroupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    effective,
+	})
+}
+
+func (h *Handler) writeGroupMessage(c *gin.Context, message string) {
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": message,
+		},
+	})
+}
+
+// writeGroupError traduce los errores de grupos y roles a códigos HTTP
+func (h *Handler) writeGroupError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrGroupNotFound), errors.Is(err, services.ErrRoleNotFound),
+		errors.Is(err, services.ErrGroupMemberNotFound), errors.Is(err, services.ErrUserNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrGroupTenantMismatch):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrGroupExists), errors.Is(err, services.ErrRoleExists),
+		errors.Is(err, services.ErrGroupMemberExists), errors.Is(err, services.ErrGroupCycle):
+		statusCode = http.StatusConflict
+	default:
+		h.logger.WithError(err).Error("Group operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
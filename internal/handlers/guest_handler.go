@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// GuestLogin godoc
+// @Summary Guest login
+// @Description Inicia una sesión de invitado con un token de login anónimo de Firebase; el token emitido es de corta duración y scope limitado
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.GuestLoginRequest true "Anonymous Firebase token"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/guest [post]
+func (h *Handler) GuestLogin(c *gin.Context) {
+	var req models.GuestLoginRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	authData, err := h.firebaseAuthService.GuestLogin(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.WithError(err).Warn("Guest login failed")
+		h.writeGuestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    authData,
+	})
+}
+
+// UpgradeGuest godoc
+// @Summary Upgrade guest to full account
+// @Description Vincula una identidad real (Google, Facebook, email/contraseña) al invitado autenticado conservando su ID de usuario
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.GuestUpgradeRequest true "Firebase token of the identity to attach"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /auth/guest/upgrade [post]
+func (h *Handler) UpgradeGuest(c *gin.Context) {
+	var req models.GuestUpgradeRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	authData, err := h.firebaseAuthService.UpgradeGuest(
+		c.Request.Context(),
+		c.GetString(middleware.ContextToken),
+		c.GetString(middleware.ContextUserID),
+		&req,
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+	)
+	if err != nil {
+		h.logger.WithError(err).Warn("Guest upgrade failed")
+		h.writeGuestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    authData,
+	})
+}
+
+// writeGuestError traduce los errores de invitados a códigos HTTP
+func (h *Handler) writeGuestError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidFirebaseToken):
+		statusCode = http.StatusUnauthorized
+	case errors.Is(err, services.ErrNotAnonymousToken), errors.Is(err, services.ErrUpgradeRequiresEmail):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrNotGuest), errors.Is(err, services.ErrEmailAlreadyInUse),
+		errors.Is(err, services.ErrIdentityLinkedToOtherUser):
+		statusCode = http.StatusConflict
+	case errors.Is(err, services.ErrSignupDomainBlocked), errors.Is(err, services.ErrDisposableEmail):
+		statusCode = http.StatusForbidden
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
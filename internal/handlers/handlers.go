@@ -1,8 +1,9 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -10,29 +11,101 @@ import (
 	"github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"it-auth-service/internal/config"
 	"it-auth-service/internal/logger"
+	"it-auth-service/internal/middleware"
 	"it-auth-service/internal/models"
 	"it-auth-service/internal/services"
+	"it-auth-service/internal/validator"
+	"it-auth-service/pkg/storage"
 )
 
 type Handler struct {
 	firebaseAuthService *services.FirebaseAuthService
 	userService         *services.UserService
 	tokenService        *services.TokenService
+	mfaService          *services.MFAService
+	identityService     *services.IdentityService
+	userAdminService    *services.UserAdminService
+	userBulkService     *services.UserBulkService
+	scimService         *services.SCIMService
+	dataExportService   *services.DataExportService
+	erasureService      *services.ErasureService
+	usernameService     *services.UsernameService
+	attributeService    *services.AttributeService
+	organizationService *services.OrganizationService
+	groupService        *services.GroupService
+	invitationService   *services.InvitationService
+	emailChangeService  *services.EmailChangeService
+	avatarService       *services.AvatarService
+	dormancyService     *services.DormancyService
+	consentService      *services.ConsentService
+	domainPolicyService *services.DomainPolicyService
+	disposableEmails    *services.DisposableEmailService
+	blobStorage         storage.Storage
 	logger              *logrus.Logger
 }
 
-func NewHandler(firebaseAuthService *services.FirebaseAuthService, userService *services.UserService, tokenService *services.TokenService) *Handler {
+// Services agrupa los servicios de negocio que usan los handlers
+type Services struct {
+	Config           *config.Config
+	FirebaseAuth     *services.FirebaseAuthService
+	User             *services.UserService
+	Token            *services.TokenService
+	MFA              *services.MFAService
+	Identity         *services.IdentityService
+	UserAdmin        *services.UserAdminService
+	UserBulk         *services.UserBulkService
+	SCIM             *services.SCIMService
+	DataExport       *services.DataExportService
+	Erasure          *services.ErasureService
+	Username         *services.UsernameService
+	Attributes       *services.AttributeService
+	Organization     *services.OrganizationService
+	Groups           *services.GroupService
+	Invitations      *services.InvitationService
+	EmailChange      *services.EmailChangeService
+	Avatars          *services.AvatarService
+	Dormancy         *services.DormancyService
+	Consents         *services.ConsentService
+	DomainPolicies   *services.DomainPolicyService
+	DisposableEmails *services.DisposableEmailService
+	Blobs            storage.Storage
+}
+
+func NewHandler(svc Services) *Handler {
 	return &Handler{
-		firebaseAuthService: firebaseAuthService,
-		userService:         userService,
-		tokenService:        tokenService,
+		firebaseAuthService: svc.FirebaseAuth,
+		userService:         svc.User,
+		tokenService:        svc.Token,
+		mfaService:          svc.MFA,
+		identityService:     svc.Identity,
+		userAdminService:    svc.UserAdmin,
+		userBulkService:     svc.UserBulk,
+		scimService:         svc.SCIM,
+		dataExportService:   svc.DataExport,
+		erasureService:      svc.Erasure,
+		usernameService:     svc.Username,
+		attributeService:    svc.Attributes,
+		organizationService: svc.Organization,
+		groupService:        svc.Groups,
+		invitationService:   svc.Invitations,
+		emailChangeService:  svc.EmailChange,
+		avatarService:       svc.Avatars,
+		dormancyService:     svc.Dormancy,
+		consentService:      svc.Consents,
+		domainPolicyService: svc.DomainPolicies,
+		disposableEmails:    svc.DisposableEmails,
+		blobStorage:         svc.Blobs,
 		logger:              logger.GetLogger(),
 	}
 }
 
-func SetupRoutes(router *gin.Engine, firebaseAuthService *services.FirebaseAuthService, userService *services.UserService, tokenService *services.TokenService) {
-	h := NewHandler(firebaseAuthService, userService, tokenService)
+func SetupRoutes(router *gin.Engine, svc Services) {
+	h := NewHandler(svc)
+	requireJWT := middleware.RequireJWT(svc.FirebaseAuth.GetJWTSecret(), svc.Token, svc.User)
+	requireRecentAuth := middleware.RequireRecentAuth(svc.Config.StepUpConfig.MaxAge, models.ACRSingleFactor)
+	rejectGuests := middleware.RejectGuests()
 
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -52,14 +125,243 @@ func SetupRoutes(router *gin.Engine, firebaseAuthService *services.FirebaseAuthS
 			auth.POST("/firebase-register", h.FirebaseRegister)
 			auth.POST("/refresh-token", h.RefreshToken)
 			auth.POST("/logout", h.Logout)
+			auth.POST("/reauthenticate", requireJWT, rejectGuests, h.Reauthenticate)
+			auth.POST("/switch-org", requireJWT, rejectGuests, h.SwitchOrganization)
+
+			// Invitados (login anónimo) y su conversión a cuenta completa
+			auth.POST("/guest", h.GuestLogin)
+			auth.POST("/guest/upgrade", requireJWT, middleware.RequireGuest(), h.UpgradeGuest)
+
+			// Segunda fase de login con MFA (autenticado por challenge_token)
+			auth.POST("/mfa/verify", h.VerifyMFA)
+			auth.POST("/mfa/enroll", h.EnrollMFAWithChallenge)
+
+			// Vinculación de cuentas cuando un login coincide por email con otra cuenta
+			auth.POST("/merge/confirm", h.ConfirmAccountMerge)
+
+			// Reactivación de cuentas desactivadas por inactividad
+			auth.POST("/reactivate", h.RequestReactivation)
+			auth.POST("/reactivate/confirm", h.ConfirmReactivation)
+
+			// Aceptación de documentos legales pendiente (respuesta consent_required)
+			auth.POST("/consent", h.AcceptLoginConsents)
 		}
 
 		// User Management
 		users := api.Group("/users")
 		{
-			users.GET("/profile", h.GetUserProfile)
-			users.PUT("/profile", h.UpdateUserProfile)
-			users.GET("", h.ListUsers) // Admin only
+			users.GET("/profile", requireJWT, rejectGuests, h.GetUserProfile)
+			users.PUT("/profile", requireJWT, rejectGuests, h.UpdateUserProfile)
+			users.PATCH("/profile", requireJWT, rejectGuests, h.PatchUserProfile)
+			users.PUT("/profile/avatar", requireJWT, rejectGuests, h.UploadAvatar)
+			users.GET("/profile/avatar", requireJWT, rejectGuests, h.GetAvatar)
+			users.DELETE("/profile/avatar", requireJWT, rejectGuests, h.DeleteAvatar)
+			users.GET("", requireJWT, rejectGuests, middleware.RequireOrgAdmin(svc.Organization), h.ListUsers)
+			users.GET("/username-availability", requireJWT, h.CheckUsernameAvailability)
+			users.GET("/attributes", requireJWT, rejectGuests, h.GetMyAttributes)
+			users.PATCH("/attributes", requireJWT, rejectGuests, h.UpdateMyAttributes)
+			users.GET("/orgs", requireJWT, rejectGuests, h.ListMyOrganizations)
+			users.GET("/permissions", requireJWT, rejectGuests, h.GetMyPermissions)
+			users.GET("/consents", requireJWT, rejectGuests, h.GetMyConsents)
+			users.POST("/consents", requireJWT, rejectGuests, h.AcceptMyConsents)
+
+			// Cambio de email verificado; la reversión llega desde el enlace enviado a la dirección anterior
+			users.POST("/email-change", requireJWT, rejectGuests, requireRecentAuth, h.RequestEmailChange)
+			users.POST("/email-change/confirm", requireJWT, rejectGuests, h.ConfirmEmailChange)
+			users.POST("/email-change/revert", h.RevertEmailChange)
+
+			// Gestión de MFA del usuario autenticado
+			mfa := users.Group("/mfa", requireJWT, rejectGuests)
+			{
+				mfa.POST("/enroll", requireRecentAuth, h.EnrollMFA)
+				mfa.POST("/confirm", requireRecentAuth, h.ConfirmMFA)
+				mfa.POST("/recovery-codes", requireRecentAuth, h.RegenerateRecoveryCodes)
+				mfa.POST("/disable", requireRecentAuth, h.DisableMFA)
+			}
+
+			// Identidades vinculadas (Google, Facebook, email/contraseña...)
+			identities := users.Group("/identities", requireJWT, rejectGuests)
+			{
+				identities.GET("", h.ListIdentities)
+				identities.POST("", requireRecentAuth, h.LinkIdentity)
+				identities.DELETE("/:id", requireRecentAuth, h.UnlinkIdentity)
+			}
+
+			// Exportación de datos personales (RGPD); la descarga se autoriza con el enlace firmado
+			users.POST("/data-exports", requireJWT, rejectGuests, requireRecentAuth, h.RequestDataExport)
+			users.GET("/data-exports/:id", requireJWT, rejectGuests, h.GetDataExport)
+			users.GET("/data-exports/:id/download", h.DownloadDataExport)
+		}
+
+		// Listado de cuentas: los owners y admins de una organización sólo ven la activa de su token
+		api.GET("/admin/users", requireJWT, rejectGuests, middleware.RequireOrgAdmin(svc.Organization), h.ListUsers)
+
+		// Administración de cuentas (sólo administradores)
+		admin := api.Group("/admin/users", requireJWT, rejectGuests, middleware.RequireAdmin())
+		{
+			admin.POST("/import", h.AdminImportUsers)
+			admin.GET("/import/:id", h.AdminGetImportJob)
+			admin.GET("/export", h.AdminExportUsers)
+			admin.GET("/:id", h.AdminGetUser)
+			admin.PATCH("/:id/status", h.AdminUpdateUserStatus)
+			admin.DELETE("/:id", requireRecentAuth, h.AdminDeleteUser)
+			admin.POST("/:id/logout", h.AdminForceLogout)
+			admin.GET("/:id/erasure", h.AdminGetUserErasure)
+			admin.POST("/:id/erasure", requireRecentAuth, h.AdminEraseUser)
+			admin.GET("/:id/attributes", h.AdminGetUserAttributes)
+			admin.PATCH("/:id/attributes", h.AdminUpdateUserAttributes)
+			admin.GET("/:id/permissions", h.AdminGetUserPermissions)
+		}
+
+		// Miembros de una organización, gestionados por sus owners y admins
+		orgMembers := api.Group("/orgs/:org_id/members", requireJWT, rejectGuests,
+			middleware.RequireOrgRole(svc.Organization, models.OrgRoleOwner, models.OrgRoleAdmin))
+		{
+			orgMembers.GET("", h.ListOrganizationMembers)
+			orgMembers.POST("", h.AddOrganizationMember)
+			orgMembers.PATCH("/:user_id", h.UpdateOrganizationMember)
+			orgMembers.DELETE("/:user_id", h.RemoveOrganizationMember)
+		}
+		api.POST("/orgs/:org_id/invitations", requireJWT, rejectGuests,
+			middleware.RequireOrgRole(svc.Organization, models.OrgRoleOwner, models.OrgRoleAdmin), h.InviteOrganizationMember)
+
+		// Organizaciones (tenants)
+		orgs := api.Group("/admin/orgs", requireJWT, rejectGuests, middleware.RequireAdmin())
+		{
+			orgs.GET("", h.AdminListOrganizations)
+			orgs.POST("", h.AdminCreateOrganization)
+			orgs.GET("/:org_id", h.AdminGetOrganization)
+			orgs.PUT("/:org_id", h.AdminUpdateOrganization)
+			orgs.DELETE("/:org_id", h.AdminDeleteOrganization)
+		}
+
+		// Términos de servicio y política de privacidad versionados
+		api.GET("/consents", h.ListCurrentConsents)
+		consents := api.Group("/admin/consents", requireJWT, rejectGuests, middleware.RequireAdmin())
+		{
+			consents.GET("", h.AdminListConsentDocuments)
+			consents.POST("", h.AdminCreateConsentDocument)
+			consents.GET("/coverage", h.AdminConsentCoverage)
+			consents.POST("/:id/publish", h.AdminPublishConsentDocument)
+		}
+
+		// Reglas por dominio de email aplicadas en el registro y el login
+		domainPolicies := api.Group("/admin/domain-policies", requireJWT, rejectGuests, middleware.RequireAdmin())
+		{
+			domainPolicies.GET("", h.AdminListDomainPolicies)
+			domainPolicies.POST("", h.AdminCreateDomainPolicy)
+			domainPolicies.PUT("/:id", h.AdminUpdateDomainPolicy)
+			domainPolicies.DELETE("/:id", h.AdminDeleteDomainPolicy)
+		}
+		api.GET("/admin/disposable-email-domains", requireJWT, rejectGuests, middleware.RequireAdmin(), h.AdminGetDisposableEmailDomains)
+		api.POST("/admin/disposable-email-domains/reload", requireJWT, rejectGuests, middleware.RequireAdmin(), h.AdminReloadDisposableEmailDomains)
+
+		// Lápidas de los borrados definitivos, para los consumidores downstream
+		api.GET("/admin/erasures/tombstones", requireJWT, rejectGuests, middleware.RequireAdmin(), h.AdminListErasureTombstones)
+
+		// Informe de cuentas inactivas
+		api.GET("/admin/dormancy/report", requireJWT, rejectGuests, middleware.RequireAdmin(), h.AdminDormancyReport)
+
+		// Avatares: URL estable que redirige a una firmada, y los ficheros del almacenamiento local
+		api.GET("/avatars/:id", h.ServeAvatar)
+		api.GET("/blobs/*key", h.ServeBlob)
+
+		// Invitaciones por email; se aceptan con invitation_token en el login o el registro
+		api.POST("/invitations/preview", h.PreviewInvitation)
+		invitations := api.Group("/admin/invitations", requireJWT, rejectGuests, middleware.RequireAdmin())
+		{
+			invitations.GET("", h.AdminListInvitations)
+			invitations.POST("", h.AdminCreateInvitation)
+			invitations.POST("/:id/resend", h.AdminResendInvitation)
+			invitations.DELETE("/:id", h.AdminRevokeInvitation)
+		}
+
+		// Grupos anidables y roles con permisos; el acceso depende de los permisos
+		// efectivos del administrador y no sólo de su rol
+		groups := api.Group("/admin/groups", requireJWT, rejectGuests)
+		{
+			readGroups := middleware.RequirePermission(svc.Groups, models.PermissionGroupsRead)
+			writeGroups := middleware.RequirePermission(svc.Groups, models.PermissionGroupsWrite)
+			// Asignar un rol concede sus permisos, así que exige poder gestionar roles
+			assignRoles := middleware.RequirePermission(svc.Groups, models.PermissionRolesWrite)
+
+			groups.GET("", readGroups, h.AdminListGroups)
+			groups.POST("", writeGroups, h.AdminCreateGroup)
+			groups.GET("/:id", readGroups, h.AdminGetGroup)
+			groups.PUT("/:id", writeGroups, h.AdminUpdateGroup)
+			groups.DELETE("/:id", writeGroups, h.AdminDeleteGroup)
+			groups.POST("/:id/members", writeGroups, h.AdminAddGroupMember)
+			groups.DELETE("/:id/members/:user_id", writeGroups, h.AdminRemoveGroupMember)
+			groups.POST("/:id/groups", writeGroups, h.AdminAddGroupChild)
+			groups.DELETE("/:id/groups/:child_id", writeGroups, h.AdminRemoveGroupChild)
+			groups.POST("/:id/roles", writeGroups, assignRoles, h.AdminAssignGroupRole)
+			groups.DELETE("/:id/roles/:role", writeGroups, assignRoles, h.AdminUnassignGroupRole)
+		}
+
+		roles := api.Group("/admin/roles", requireJWT, rejectGuests)
+		{
+			readRoles := middleware.RequirePermission(svc.Groups, models.PermissionRolesRead)
+			writeRoles := middleware.RequirePermission(svc.Groups, models.PermissionRolesWrite)
+
+			roles.GET("", readRoles, h.AdminListRoles)
+			roles.POST("", writeRoles, h.AdminCreateRole)
+			roles.PUT("/:name", writeRoles, h.AdminUpdateRole)
+			roles.DELETE("/:name", writeRoles, h.AdminDeleteRole)
+		}
+
+		// Esquema de atributos personalizados, global o por tenant
+		attributes := api.Group("/admin/attributes", requireJWT, rejectGuests, middleware.RequireAdmin())
+		{
+			attributes.GET("", h.AdminListAttributeDefinitions)
+			attributes.POST("", h.AdminCreateAttributeDefinition)
+			attributes.PUT("/:id", h.AdminUpdateAttributeDefinition)
+			attributes.DELETE("/:id", h.AdminDeleteAttributeDefinition)
+		}
+
+		// Usernames reservados además de la lista fija del servicio
+		reservedUsernames := api.Group("/admin/usernames/reserved", requireJWT, rejectGuests, middleware.RequireAdmin())
+		{
+			reservedUsernames.GET("", h.AdminListReservedUsernames)
+			reservedUsernames.POST("", h.AdminReserveUsername)
+			reservedUsernames.DELETE("/:name", h.AdminUnreserveUsername)
+		}
+
+		// Tokens de aprovisionamiento SCIM por tenant
+		scimTokens := api.Group("/admin/scim/tokens", requireJWT, rejectGuests, middleware.RequireAdmin(), requireRecentAuth)
+		{
+			scimTokens.POST("", h.AdminCreateSCIMToken)
+			scimTokens.GET("", h.AdminListSCIMTokens)
+			scimTokens.DELETE("/:id", h.AdminRevokeSCIMToken)
+		}
+	}
+
+	// SCIM 2.0 para el aprovisionamiento desde el IdP de cada tenant
+	scim := router.Group(scimBasePath)
+	{
+		scim.GET("/ServiceProviderConfig", h.SCIMServiceProviderConfig)
+		scim.GET("/ResourceTypes", h.SCIMResourceTypes)
+		scim.GET("/ResourceTypes/:id", h.SCIMResourceType)
+		scim.GET("/Schemas", h.SCIMSchemas)
+		scim.GET("/Schemas/:id", h.SCIMSchema)
+
+		scimUsers := scim.Group("/Users", middleware.RequireSCIMToken(svc.SCIM))
+		{
+			scimUsers.GET("", h.SCIMListUsers)
+			scimUsers.POST("", h.SCIMCreateUser)
+			scimUsers.GET("/:id", h.SCIMGetUser)
+			scimUsers.PUT("/:id", h.SCIMReplaceUser)
+			scimUsers.PATCH("/:id", h.SCIMPatchUser)
+			scimUsers.DELETE("/:id", h.SCIMDeleteUser)
+		}
+
+		scimGroups := scim.Group("/Groups", middleware.RequireSCIMToken(svc.SCIM))
+		{
+			scimGroups.GET("", h.SCIMListGroups)
+			scimGroups.POST("", h.SCIMCreateGroup)
+			scimGroups.GET("/:id", h.SCIMGetGroup)
+			scimGroups.PUT("/:id", h.SCIMReplaceGroup)
+			scimGroups.PATCH("/:id", h.SCIMPatchGroup)
+			scimGroups.DELETE("/:id", h.SCIMDeleteGroup)
 		}
 	}
 }
@@ -180,6 +482,7 @@ func (h *Handler) APIInfo(c *gin.Context) {
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /auth/firebase-login [post]
 func (h *Handler) FirebaseLogin(c *gin.Context) {
@@ -206,13 +509,22 @@ func (h *Handler) FirebaseLogin(c *gin.Context) {
 	authData, err := h.firebaseAuthService.FirebaseLogin(c.Request.Context(), &req)
 	if err != nil {
 		h.logger.WithError(err).Error("Firebase login failed")
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
+		c.JSON(authFailureStatus(err), models.APIResponse{
 			Success: false,
 			Error:   "Authentication failed: " + err.Error(),
 		})
 		return
 	}
 
+	// Login pendiente de una segunda fase (MFA, vinculación de cuentas o aceptación de documentos)
+	if authData.Token == "" {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Data:    authData,
+		})
+		return
+	}
+
 	h.logger.WithFields(map[string]interface{}{
 		"user_id":    authData.User.ID,
 		"email":      authData.User.Email,
@@ -266,6 +578,15 @@ func (h *Handler) FirebaseRegister(c *gin.Context) {
 		statusCode := http.StatusInternalServerError
 		if err.Error() == "user already exists" {
 			statusCode = http.StatusConflict
+		} else if errors.Is(err, services.ErrEmailAlreadyRegistered) {
+			statusCode = http.StatusConflict
+		} else if errors.Is(err, services.ErrInvitationEmailMismatch) || errors.Is(err, services.ErrInvitationEmailUnverified) || services.IsAccountStatusError(err) ||
+			errors.Is(err, services.ErrSignupDomainBlocked) || errors.Is(err, services.ErrDisposableEmail) {
+			statusCode = http.StatusForbidden
+		} else if errors.Is(err, services.ErrInvitationInvalid) {
+			statusCode = http.StatusGone
+		} else if errors.Is(err, services.ErrConsentNotCurrent) {
+			statusCode = http.StatusBadRequest
 		}
 		
 		c.JSON(statusCode, models.APIResponse{
@@ -275,6 +596,15 @@ func (h *Handler) FirebaseRegister(c *gin.Context) {
 		return
 	}
 
+	// Login pendiente de una segunda fase (MFA, vinculación de cuentas o aceptación de documentos)
+	if authData.Token == "" {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Data:    authData,
+		})
+		return
+	}
+
 	h.logger.WithFields(map[string]interface{}{
 		"user_id":  authData.User.ID,
 		"email":    authData.User.Email,
@@ -297,6 +627,7 @@ func (h *Handler) FirebaseRegister(c *gin.Context) {
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /auth/refresh-token [post]
 func (h *Handler) RefreshToken(c *gin.Context) {
@@ -323,13 +654,22 @@ func (h *Handler) RefreshToken(c *gin.Context) {
 	authData, err := h.firebaseAuthService.RefreshToken(c.Request.Context(), &req)
 	if err != nil {
 		h.logger.WithError(err).Error("Token refresh failed")
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
+		c.JSON(authFailureStatus(err), models.APIResponse{
 			Success: false,
 			Error:   "Token refresh failed: " + err.Error(),
 		})
 		return
 	}
 
+	// Login pendiente de una segunda fase (MFA o vinculación de cuentas)
+	if authData.Token == "" {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Data:    authData,
+		})
+		return
+	}
+
 	h.logger.WithFields(map[string]interface{}{
 		"user_id": authData.User.ID,
 		"email":   authData.User.Email,
@@ -341,6 +681,20 @@ func (h *Handler) RefreshToken(c *gin.Context) {
 	})
 }
 
+// authFailureStatus devuelve 403 para cuentas cuyo estado no admite autenticación y
+// 401 para el resto de fallos de login
+func authFailureStatus(err error) int {
+	if services.IsAccountStatusError(err) || errors.Is(err, services.ErrInvitationEmailMismatch) ||
+		errors.Is(err, services.ErrInvitationEmailUnverified) || errors.Is(err, services.ErrIdentityUnlinked) ||
+		errors.Is(err, services.ErrSignupDomainBlocked) || errors.Is(err, services.ErrDisposableEmail) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, services.ErrInvitationInvalid) {
+		return http.StatusGone
+	}
+	return http.StatusUnauthorized
+}
+
 // GetUserProfile godoc
 // @Summary Get user profile endpoint
 // @Description Obtiene el perfil del usuario autenticado
@@ -350,22 +704,12 @@ func (h *Handler) RefreshToken(c *gin.Context) {
 // @Security BearerAuth
 // @Success 200 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /users/profile [get]
 func (h *Handler) GetUserProfile(c *gin.Context) {
-	// TODO: Implementar middleware de autenticación JWT
-	// Por ahora, simulamos obtener el user_id del token
-	userID := c.GetHeader("X-User-ID") // Temporal para testing
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
-			Success: false,
-			Error:   "Authentication required",
-		})
-		return
-	}
-
-	user, err := h.userService.GetUserProfile(c.Request.Context(), userID)
+	user, err := h.userService.GetUserProfile(c.Request.Context(), c.GetString(middleware.ContextUserID))
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get user profile")
 		c.JSON(http.StatusNotFound, models.APIResponse{
@@ -385,33 +729,56 @@ func (h *Handler) GetUserProfile(c *gin.Context) {
 
 // UpdateUserProfile godoc
 // @Summary Update user profile endpoint
-// @Description Actualiza el perfil del usuario autenticado
+// @Description Actualiza el perfil del usuario autenticado. Sólo se modifican los campos enviados.
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body map[string]interface{} true "Profile update data"
+// @Param request body models.UpdateProfileRequest true "Profile update data"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /users/profile [put]
 func (h *Handler) UpdateUserProfile(c *gin.Context) {
-	// TODO: Implementar middleware de autenticación JWT
-	// Por ahora, simulamos obtener el user_id del token
-	userID := c.GetHeader("X-User-ID") // Temporal para testing
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, models.APIResponse{
+	var req models.UpdateProfileRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	h.updateProfile(c, c.GetString(middleware.ContextUserID), &req)
+}
+
+// PatchUserProfile godoc
+// @Summary Patch user profile endpoint
+// @Description Modifica el perfil con semántica JSON Merge Patch (RFC 7396): los campos ausentes no cambian y null borra el valor
+// @Tags users
+// @Accept application/merge-patch+json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.UpdateProfileRequest true "Merge patch"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 415 {object} models.APIResponse
+// @Router /users/profile [patch]
+func (h *Handler) PatchUserProfile(c *gin.Context) {
+	if contentType := c.ContentType(); contentType != "application/merge-patch+json" && contentType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, models.APIResponse{
 			Success: false,
-			Error:   "Authentication required",
+			Error:   "Content-Type must be application/merge-patch+json",
 		})
 		return
 	}
 
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		h.logger.WithError(err).Error("Invalid request body for profile update")
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
 			Error:   "Invalid request body: " + err.Error(),
@@ -419,10 +786,33 @@ func (h *Handler) UpdateUserProfile(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.UpdateUserProfile(c.Request.Context(), userID, updates)
+	req, err := services.ParseProfileMergePatch(body)
 	if err != nil {
-		h.logger.WithError(err).Error("Failed to update user profile")
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	h.updateProfile(c, c.GetString(middleware.ContextUserID), req)
+}
+
+// updateProfile aplica la actualización del perfil y escribe la respuesta
+func (h *Handler) updateProfile(c *gin.Context, userID string, req *models.UpdateProfileRequest) {
+	user, err := h.userService.UpdateUserProfile(c.Request.Context(), userID, req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch {
+		case usernameErrorStatus(err) != 0:
+			statusCode = usernameErrorStatus(err)
+		case err.Error() == "user not found":
+			statusCode = http.StatusNotFound
+		default:
+			h.logger.WithError(err).Error("Failed to update user profile")
+		}
+
+		c.JSON(statusCode, models.APIResponse{
 			Success: false,
 			Error:   "Failed to update profile: " + err.Error(),
 		})
@@ -441,50 +831,49 @@ func (h *Handler) UpdateUserProfile(c *gin.Context) {
 }
 
 // ListUsers godoc
-// @Summary List users endpoint (Admin only)
-// @Description Lista usuarios con paginación
+// @Summary List users endpoint (Admin or organization admin)
+// @Description Lista usuarios con filtros, búsqueda libre y paginación por cursor. Los owners y admins de una organización sólo ven a los miembros de la organización activa de su token
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param page query int false "Page number" default(1)
-// @Param limit query int false "Items per page" default(10)
+// @Param status query string false "Status filter (comma-separated); excludes deleted by default"
+// @Param provider query string false "Sign-in provider"
+// @Param email_domain query string false "Email domain"
+// @Param email_verified query bool false "Email verification state"
+// @Param created_after query string false "RFC 3339 timestamp"
+// @Param created_before query string false "RFC 3339 timestamp"
+// @Param last_login_after query string false "RFC 3339 timestamp"
+// @Param last_login_before query string false "RFC 3339 timestamp"
+// @Param q query string false "Free-text search over email, username and name"
+// @Param sort query string false "created_at, last_login_at, email or username; prefix with - for descending" default(-created_at)
+// @Param limit query int false "Items per page" default(20)
+// @Param cursor query string false "Cursor returned as next_cursor by the previous page"
+// @Param include_total query bool false "Include the total number of matching users"
 // @Success 200 {object} models.APIResponse
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
 // @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /users [get]
+// @Router /admin/users [get]
 func (h *Handler) ListUsers(c *gin.Context) {
-	// TODO: Implementar middleware de autenticación y autorización
-	// Por ahora, simulamos la verificación de admin
-	isAdmin := c.GetHeader("X-Is-Admin") == "true" // Temporal para testing
-	if !isAdmin {
-		c.JSON(http.StatusForbidden, models.APIResponse{
-			Success: false,
-			Error:   "Admin access required",
-		})
+	query, ok := h.bindListUsersQuery(c)
+	if !ok {
 		return
 	}
+	query.ScopeOrgID = c.GetString(middleware.ContextOrgScope)
 
-	// Obtener parámetros de paginación
-	page := 1
-	limit := 10
-
-	if pageStr := c.Query("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
-	}
-
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
+	result, err := h.userService.SearchUsers(c.Request.Context(), query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
 		}
-	}
 
-	users, total, err := h.userService.ListUsers(c.Request.Context(), page, limit)
-	if err != nil {
 		h.logger.WithError(err).Error("Failed to list users")
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -495,18 +884,30 @@ func (h *Handler) ListUsers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Data: map[string]interface{}{
-			"users": users,
-			"pagination": map[string]interface{}{
-				"page":       page,
-				"limit":      limit,
-				"total":      total,
-				"total_pages": (total + int64(limit) - 1) / int64(limit),
-			},
-		},
+		Data:    result,
 	})
 }
 
+// bindListUsersQuery lee y valida los filtros y la paginación del listado de usuarios
+func (h *Handler) bindListUsersQuery(c *gin.Context) (*models.ListUsersQuery, bool) {
+	var query models.ListUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid query parameters: " + err.Error(),
+		})
+		return nil, false
+	}
+	if err := validator.ValidateStruct(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return nil, false
+	}
+	return &query, true
+}
+
 // Logout godoc
 // @Summary Logout endpoint
 // @Description Cierra la sesión del usuario e invalida el token
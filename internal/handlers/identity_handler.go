@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// ListIdentities godoc
+// @Summary List linked identities
+// @Description Lista las identidades (provider, subject) vinculadas al usuario autenticado
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /users/identities [get]
+func (h *Handler) ListIdentities(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, err := h.userService.GetUserProfile(ctx, c.GetString(middleware.ContextUserID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return
+	}
+
+	if err := h.identityService.EnsurePrimaryIdentity(ctx, user); err != nil {
+		h.logger.WithError(err).Warn("Failed to backfill primary identity")
+	}
+
+	identities, err := h.identityService.ListIdentities(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to list identities",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"identities": identities,
+		},
+	})
+}
+
+// LinkIdentity godoc
+// @Summary Link identity
+// @Description Vincula al usuario autenticado la identidad de un token de Firebase (requiere autenticación reciente)
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.LinkIdentityRequest true "Firebase token of the identity to link"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /users/identities [post]
+func (h *Handler) LinkIdentity(c *gin.Context) {
+	var req models.LinkIdentityRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	userID := c.GetString(middleware.ContextUserID)
+	identity, err := h.firebaseAuthService.LinkIdentity(c.Request.Context(), userID, &req)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to link identity")
+		h.writeIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"identity": identity,
+		},
+	})
+}
+
+// UnlinkIdentity godoc
+// @Summary Unlink identity
+// @Description Desvincula una identidad del usuario; la última identidad no se puede eliminar (requiere autenticación reciente)
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Identity ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /users/identities/{id} [delete]
+func (h *Handler) UnlinkIdentity(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	user, err := h.userService.GetUserProfile(ctx, c.GetString(middleware.ContextUserID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return
+	}
+
+	if err := h.identityService.EnsurePrimaryIdentity(ctx, user); err != nil {
+		h.logger.WithError(err).Warn("Failed to backfill primary identity")
+	}
+
+	if err := h.identityService.UnlinkIdentity(ctx, user.ID, c.Param("id")); err != nil {
+		h.writeIdentityError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "Identity unlinked successfully",
+		},
+	})
+}
+
+// writeIdentityError traduce los errores de identidades a códigos HTTP
+func (h *Handler) writeIdentityError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidFirebaseToken):
+		statusCode = http.StatusUnauthorized
+	case errors.Is(err, services.ErrIdentityNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrIdentityLinkedToOtherUser), errors.Is(err, services.ErrLastIdentity):
+		statusCode = http.StatusConflict
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+	"it-auth-service/internal/validator"
+)
+
+// PreviewInvitation godoc
+// @Summary Preview invitation
+// @Description Devuelve el email, el rol y la organización de una invitación válida para mostrarlos antes de iniciar sesión. La invitación se acepta enviando invitation_token en firebase-login o firebase-register.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.InvitationPreviewRequest true "Invitation token"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 410 {object} models.APIResponse
+// @Router /invitations/preview [post]
+func (h *Handler) PreviewInvitation(c *gin.Context) {
+	var req models.InvitationPreviewRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	preview, err := h.invitationService.Preview(c.Request.Context(), req.Token)
+	if err != nil {
+		h.writeInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"invitation": preview,
+		},
+	})
+}
+
+// AdminListInvitations godoc
+// @Summary List invitations (Admin only)
+// @Description Lista las invitaciones; por defecto sólo las pendientes sin caducar
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "pending, accepted, revoked or expired"
+// @Param email query string false "Invited email"
+// @Param org_id query string false "Organization ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/invitations [get]
+func (h *Handler) AdminListInvitations(c *gin.Context) {
+	var query models.ListInvitationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid query parameters: " + err.Error(),
+		})
+		return
+	}
+	if err := validator.ValidateStruct(&query); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	invitations, err := h.invitationService.List(c.Request.Context(), &query)
+	if err != nil {
+		h.writeInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"invitations": invitations,
+		},
+	})
+}
+
+// AdminCreateInvitation godoc
+// @Summary Invite by email (Admin only)
+// @Description Invita a un email con un rol global y, opcionalmente, una organización y envía el enlace de aceptación
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateInvitationRequest true "Invitation"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/invitations [post]
+func (h *Handler) AdminCreateInvitation(c *gin.Context) {
+	var req models.CreateInvitationRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	invitation, err := h.invitationService.Create(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"invitation": invitation,
+		},
+	})
+}
+
+// InviteOrganizationMember godoc
+// @Summary Invite to an organization
+// @Description Owners y admins de la organización invitan por email; sólo los owners pueden invitar a otros owners
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param request body models.InviteOrganizationMemberRequest true "Invitation"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /orgs/{org_id}/invitations [post]
+func (h *Handler) InviteOrganizationMember(c *gin.Context) {
+	var req models.InviteOrganizationMemberRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	invitation, err := h.invitationService.CreateForOrganization(c.Request.Context(), adminActor(c),
+		c.GetString(middleware.ContextOrgRole), c.Param("org_id"), &req)
+	if err != nil {
+		h.writeInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"invitation": invitation,
+		},
+	})
+}
+
+// AdminResendInvitation godoc
+// @Summary Resend invitation (Admin only)
+// @Description Reenvía la invitación con un enlace nuevo y renueva su caducidad; los enlaces anteriores dejan de ser válidos
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invitation ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/invitations/{id}/resend [post]
+func (h *Handler) AdminResendInvitation(c *gin.Context) {
+	invitation, err := h.invitationService.Resend(c.Request.Context(), adminActor(c), c.Param("id"))
+	if err != nil {
+		h.writeInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"invitation": invitation,
+		},
+	})
+}
+
+// AdminRevokeInvitation godoc
+// @Summary Revoke invitation (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Invitation ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/invitations/{id} [delete]
+func (h *Handler) AdminRevokeInvitation(c *gin.Context) {
+	invitation, err := h.invitationService.Revoke(c.Request.Context(), adminActor(c), c.Param("id"))
+	if err != nil {
+		h.writeInvitationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"invitation": invitation,
+		},
+	})
+}
+
+func (h *Handler) writeInvitationError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvitationNotFound), errors.Is(err, services.ErrOrganizationNotFound):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrInvitationInvalid):
+		statusCode = http.StatusGone
+	case errors.Is(err, services.ErrOrgRoleForbidden):
+		statusCode = http.StatusForbidden
+	case errors.Is(err, services.ErrInvitationExists), errors.Is(err, services.ErrInvitationNotPending),
+		errors.Is(err, services.ErrEmailAlreadyRegistered), errors.Is(err, services.ErrOrgMemberExists):
+		statusCode = http.StatusConflict
+	default:
+		h.logger.WithError(err).Error("Invitation operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
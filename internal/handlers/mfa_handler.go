@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+	"it-auth-service/internal/validator"
+)
+
+// VerifyMFA godoc
+// @Summary Complete MFA login
+// @Description Completa la segunda fase del login con un código TOTP o de recuperación
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.MFAVerifyRequest true "MFA verification data"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /auth/mfa/verify [post]
+func (h *Handler) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "code or recovery_code is required",
+		})
+		return
+	}
+
+	authData, err := h.firebaseAuthService.CompleteMFALogin(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.WithError(err).Warn("MFA verification failed")
+		h.writeMFAError(c, err)
+		return
+	}
+
+	h.logger.WithField("user_id", authData.User.ID).Info("MFA login successful")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    authData,
+	})
+}
+
+// EnrollMFAWithChallenge godoc
+// @Summary Start mandatory MFA enrollment
+// @Description Inicia el registro TOTP obligatorio usando el challenge_token del login
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.MFAEnrollRequest true "Challenge token"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /auth/mfa/enroll [post]
+func (h *Handler) EnrollMFAWithChallenge(c *gin.Context) {
+	var req models.MFAEnrollRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	if req.ChallengeToken == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "challenge_token is required",
+		})
+		return
+	}
+
+	enrollment, err := h.firebaseAuthService.BeginChallengeEnrollment(c.Request.Context(), req.ChallengeToken)
+	if err != nil {
+		h.logger.WithError(err).Warn("MFA challenge enrollment failed")
+		h.writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    enrollment,
+	})
+}
+
+// EnrollMFA godoc
+// @Summary Start MFA enrollment
+// @Description Genera un secreto TOTP y la URI otpauth para el usuario autenticado (requiere autenticación reciente)
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /users/mfa/enroll [post]
+func (h *Handler) EnrollMFA(c *gin.Context) {
+	user, err := h.userService.GetUserProfile(c.Request.Context(), c.GetString(middleware.ContextUserID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return
+	}
+
+	enrollment, err := h.mfaService.BeginEnrollment(c.Request.Context(), user)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to start MFA enrollment")
+		h.writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    enrollment,
+	})
+}
+
+// ConfirmMFA godoc
+// @Summary Confirm MFA enrollment
+// @Description Confirma el registro TOTP con un primer código y devuelve los códigos de recuperación (requiere autenticación reciente)
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFAConfirmRequest true "TOTP code"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /users/mfa/confirm [post]
+func (h *Handler) ConfirmMFA(c *gin.Context) {
+	var req models.MFAConfirmRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	userID := c.GetString(middleware.ContextUserID)
+	codes, err := h.mfaService.ConfirmEnrollment(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to confirm MFA enrollment")
+		h.writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    models.MFAConfirmResponse{RecoveryCodes: codes},
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate MFA recovery codes
+// @Description Invalida los códigos de recuperación anteriores y genera un nuevo lote
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /users/mfa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetString(middleware.ContextUserID)
+	codes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Warn("Failed to regenerate recovery codes")
+		h.writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    models.MFAConfirmResponse{RecoveryCodes: codes},
+	})
+}
+
+// DisableMFA godoc
+// @Summary Disable MFA
+// @Description Desactiva MFA tras verificar un código TOTP o de recuperación
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFADisableRequest true "Current code"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /users/mfa/disable [post]
+func (h *Handler) DisableMFA(c *gin.Context) {
+	var req models.MFADisableRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := c.GetString(middleware.ContextUserID)
+
+	user, err := h.userService.GetUserProfile(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Error:   "User not found",
+		})
+		return
+	}
+
+	// Si la política exige MFA por el rol del usuario o por su organización no se permite desactivarlo
+	if h.mfaService.IsRequired(ctx, user) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "MFA is required for this account",
+		})
+		return
+	}
+
+	if err := h.mfaService.Verify(ctx, userID, req.Code, req.RecoveryCode); err != nil {
+		h.writeMFAError(c, err)
+		return
+	}
+
+	if err := h.mfaService.Disable(ctx, userID); err != nil {
+		h.writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "MFA disabled successfully",
+		},
+	})
+}
+
+// writeMFAError traduce los errores de MFA a códigos HTTP
+func (h *Handler) writeMFAError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrInvalidMFACode), errors.Is(err, services.ErrInvalidMFAChallenge),
+		errors.Is(err, services.ErrMFACodeRequired):
+		statusCode = http.StatusUnauthorized
+	case errors.Is(err, services.ErrMFALocked):
+		statusCode = http.StatusTooManyRequests
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		statusCode = http.StatusConflict
+	case errors.Is(err, services.ErrMFANotEnrolled):
+		statusCode = http.StatusNotFound
+	case services.IsAccountStatusError(err):
+		statusCode = http.StatusForbidden
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
+
+// bindAndValidate decodifica el body JSON y aplica las reglas `validate` del modelo
+func (h *Handler) bindAndValidate(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		h.logger.WithError(err).Warn("Invalid request body")
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "Invalid request body: " + err.Error(),
+		})
+		return false
+	}
+
+	if err := validator.ValidateStruct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// ListMyOrganizations godoc
+// @Summary List my organizations
+// @Description Devuelve las organizaciones del usuario con su rol en cada una
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /users/orgs [get]
+func (h *Handler) ListMyOrganizations(c *gin.Context) {
+	memberships, err := h.organizationService.ListMemberships(c.Request.Context(), c.GetString(middleware.ContextUserID))
+	if err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"memberships": memberships,
+		},
+	})
+}
+
+// SwitchOrganization godoc
+// @Summary Switch active organization
+// @Description Emite un token para otra organización del usuario (claims org_id, tenant_id y org_role) y revoca el actual
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.SwitchOrganizationRequest true "Organization to switch to"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /auth/switch-org [post]
+func (h *Handler) SwitchOrganization(c *gin.Context) {
+	var req models.SwitchOrganizationRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	claims, _ := c.MustGet(middleware.ContextClaims).(jwt.MapClaims)
+	authData, err := h.firebaseAuthService.SwitchOrganization(
+		c.Request.Context(),
+		c.GetString(middleware.ContextToken),
+		claims,
+		adminActor(c),
+		req.OrgID,
+	)
+	if err != nil {
+		if errors.Is(err, services.ErrNotOrgMember) {
+			c.JSON(http.StatusForbidden, models.APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrSessionNotFound) {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    authData,
+	})
+}
+
+// ListOrganizationMembers godoc
+// @Summary List organization members
+// @Description Lista los usuarios de la organización con los filtros del listado de usuarios y el rol de cada uno (owners y admins de la organización)
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param q query string false "Free text search"
+// @Param cursor query string false "Pagination cursor"
+// @Param limit query int false "Page size"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /orgs/{org_id}/members [get]
+func (h *Handler) ListOrganizationMembers(c *gin.Context) {
+	query, ok := h.bindListUsersQuery(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.organizationService.ListMembers(c.Request.Context(), c.Param("org_id"), query)
+	if err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// AddOrganizationMember godoc
+// @Summary Add organization member
+// @Description Añade una cuenta existente a la organización; sólo los owners pueden añadir otros owners. Salvo los administradores globales, sólo se añaden cuentas del tenant (aprovisionadas por su SCIM); el resto se invita
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param request body models.AddOrganizationMemberRequest true "Member to add"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /orgs/{org_id}/members [post]
+func (h *Handler) AddOrganizationMember(c *gin.Context) {
+	var req models.AddOrganizationMemberRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	membership, err := h.organizationService.AddMember(c.Request.Context(), adminActor(c), c.GetString(middleware.ContextOrgRole), c.Param("org_id"), &req)
+	if err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"membership": membership,
+		},
+	})
+}
+
+// UpdateOrganizationMember godoc
+// @Summary Change organization member role
+// @Description Cambia el rol de un miembro; la organización debe conservar al menos un owner
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param user_id path string true "User ID"
+// @Param request body models.UpdateOrganizationMemberRequest true "New role"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /orgs/{org_id}/members/{user_id} [patch]
+func (h *Handler) UpdateOrganizationMember(c *gin.Context) {
+	var req models.UpdateOrganizationMemberRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	membership, err := h.organizationService.UpdateMemberRole(c.Request.Context(), adminActor(c), c.GetString(middleware.ContextOrgRole), c.Param("org_id"), c.Param("user_id"), &req)
+	if err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"membership": membership,
+		},
+	})
+}
+
+// RemoveOrganizationMember godoc
+// @Summary Remove organization member
+// @Description Saca al usuario de la organización sin modificar su cuenta
+// @Tags organizations
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /orgs/{org_id}/members/{user_id} [delete]
+func (h *Handler) RemoveOrganizationMember(c *gin.Context) {
+	if err := h.organizationService.RemoveMember(c.Request.Context(), adminActor(c), c.GetString(middleware.ContextOrgRole), c.Param("org_id"), c.Param("user_id")); err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "Member removed",
+		},
+	})
+}
+
+// AdminListOrganizations godoc
+// @Summary List organizations (Admin only)
+// @Description Lista todas las organizaciones
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/orgs [get]
+func (h *Handler) AdminListOrganizations(c *gin.Context) {
+	orgs, err := h.organizationService.ListOrganizations(c.Request.Context())
+	if err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"organizations": orgs,
+		},
+	})
+}
+
+// AdminCreateOrganization godoc
+// @Summary Create organization (Admin only)
+// @Description Crea una organización; su slug es el tenant_id de los tokens, de SCIM y del esquema de atributos
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateOrganizationRequest true "Organization"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Router /admin/orgs [post]
+func (h *Handler) AdminCreateOrganization(c *gin.Context) {
+	var req models.CreateOrganizationRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	org, err := h.organizationService.CreateOrganization(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"organization": org,
+		},
+	})
+}
+
+// AdminGetOrganization godoc
+// @Summary Get organization (Admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/orgs/{org_id} [get]
+func (h *Handler) AdminGetOrganization(c *gin.Context) {
+	org, err := h.organizationService.GetOrganization(c.Request.Context(), c.Param("org_id"))
+	if err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"organization": org,
+		},
+	})
+}
+
+// AdminUpdateOrganization godoc
+// @Summary Rename organization (Admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Param request body models.UpdateOrganizationRequest true "Organization"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/orgs/{org_id} [put]
+func (h *Handler) AdminUpdateOrganization(c *gin.Context) {
+	var req models.UpdateOrganizationRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	org, err := h.organizationService.UpdateOrganization(c.Request.Context(), adminActor(c), c.Param("org_id"), &req)
+	if err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"organization": org,
+		},
+	})
+}
+
+// AdminDeleteOrganization godoc
+// @Summary Delete organization (Admin only)
+// @Description Elimina la organización y sus membresías; las cuentas de los miembros se conservan
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param org_id path string true "Organization ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/orgs/{org_id} [delete]
+func (h *Handler) AdminDeleteOrganization(c *gin.Context) {
+	if err := h.organizationService.DeleteOrganization(c.Request.Context(), adminActor(c), c.Param("org_id")); err != nil {
+		h.writeOrganizationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "Organization deleted",
+		},
+	})
+}
+
+// writeOrganizationError traduce los errores de organizaciones a códigos HTTP
+func (h *Handler) writeOrganizationError(c *gin.Context, err error) {
+	statusCode := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound), errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrNotOrgMember):
+		statusCode = http.StatusNotFound
+	case errors.Is(err, services.ErrOrgRoleForbidden), errors.Is(err, services.ErrOrgInvitationRequired):
+		statusCode = http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidOrgSlug), errors.Is(err, services.ErrInvalidCursor):
+		statusCode = http.StatusBadRequest
+	case errors.Is(err, services.ErrOrganizationExists), errors.Is(err, services.ErrOrgMemberExists), errors.Is(err, services.ErrLastOrgOwner):
+		statusCode = http.StatusConflict
+	default:
+		h.logger.WithError(err).Error("Organization operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
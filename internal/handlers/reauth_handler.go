@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// Reauthenticate godoc
+// @Summary Reauthenticate current session (step-up)
+// @Description Renueva auth_time de la sesión actual con un login reciente de Firebase y, si el usuario tiene MFA activado, un código MFA o de recuperación
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ReauthenticateRequest true "Fresh Firebase token and MFA code when enabled"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /auth/reauthenticate [post]
+func (h *Handler) Reauthenticate(c *gin.Context) {
+	var req models.ReauthenticateRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	authData, err := h.firebaseAuthService.Reauthenticate(
+		c.Request.Context(),
+		c.GetString(middleware.ContextToken),
+		c.GetString(middleware.ContextUserID),
+		&req,
+		c.ClientIP(),
+		c.GetHeader("User-Agent"),
+	)
+	if err != nil {
+		h.logger.WithError(err).Warn("Reauthentication failed")
+
+		switch {
+		case errors.Is(err, services.ErrReauthenticationMismatch):
+			c.JSON(http.StatusForbidden, models.APIResponse{Success: false, Error: err.Error()})
+		case errors.Is(err, services.ErrStaleReauthentication), errors.Is(err, services.ErrInvalidFirebaseToken),
+			errors.Is(err, services.ErrSessionNotFound):
+			c.JSON(http.StatusUnauthorized, models.APIResponse{Success: false, Error: err.Error()})
+		default:
+			h.writeMFAError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    authData,
+	})
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// scimBasePath es la ruta base de la API SCIM
+const scimBasePath = "/scim/v2"
+
+// AdminCreateSCIMToken godoc
+// @Summary Create SCIM token (Admin only)
+// @Description Emite un bearer token SCIM para el IdP de un tenant. El token sólo se muestra en esta respuesta
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateSCIMTokenRequest true "Tenant and token name"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/scim/tokens [post]
+func (h *Handler) AdminCreateSCIMToken(c *gin.Context) {
+	var req models.CreateSCIMTokenRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	token, err := h.scimService.CreateToken(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    token,
+	})
+}
+
+// AdminListSCIMTokens godoc
+// @Summary List SCIM tokens (Admin only)
+// @Description Lista los tokens SCIM emitidos, sin su valor
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/scim/tokens [get]
+func (h *Handler) AdminListSCIMTokens(c *gin.Context) {
+	tokens, err := h.scimService.ListTokens(c.Request.Context())
+	if err != nil {
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"tokens": tokens,
+		},
+	})
+}
+
+// AdminRevokeSCIMToken godoc
+// @Summary Revoke SCIM token (Admin only)
+// @Description Revoca un token SCIM; las peticiones del IdP que lo use se rechazarán
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Token ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/scim/tokens/{id} [delete]
+func (h *Handler) AdminRevokeSCIMToken(c *gin.Context) {
+	err := h.scimService.RevokeToken(c.Request.Context(), adminActor(c), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrSCIMTokenNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		h.writeAdminError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "SCIM token revoked successfully",
+		},
+	})
+}
+
+// SCIMServiceProviderConfig devuelve las capacidades del servidor SCIM
+func (h *Handler) SCIMServiceProviderConfig(c *gin.Context) {
+	writeSCIM(c, http.StatusOK, services.SCIMServiceProviderConfig(scimBaseURL(c)))
+}
+
+// SCIMResourceTypes lista los tipos de recurso SCIM
+func (h *Handler) SCIMResourceTypes(c *gin.Context) {
+	types := services.SCIMResourceTypes(scimBaseURL(c))
+	writeSCIM(c, http.StatusOK, scimStaticList(types))
+}
+
+// SCIMResourceType devuelve un tipo de recurso SCIM
+func (h *Handler) SCIMResourceType(c *gin.Context) {
+	h.writeSCIMStaticResource(c, services.SCIMResourceTypes(scimBaseURL(c)))
+}
+
+// SCIMSchemas lista los esquemas SCIM soportados
+func (h *Handler) SCIMSchemas(c *gin.Context) {
+	schemas := services.SCIMSchemas(scimBaseURL(c))
+	writeSCIM(c, http.StatusOK, scimStaticList(schemas))
+}
+
+// SCIMSchema devuelve un esquema SCIM por su URN
+func (h *Handler) SCIMSchema(c *gin.Context) {
+	h.writeSCIMStaticResource(c, services.SCIMSchemas(scimBaseURL(c)))
+}
+
+// SCIMListUsers busca usuarios (GET /Users?filter=...)
+func (h *Handler) SCIMListUsers(c *gin.Context) {
+	var query models.SCIMListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.writeSCIMError(c, services.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	result, err := h.scimService.ListUsers(c.Request.Context(), scimCaller(c), &query)
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	for _, user := range result.Resources.([]*models.SCIMUserResource) {
+		user.Meta.Location = scimBaseURL(c) + "/Users/" + user.ID
+	}
+	writeSCIM(c, http.StatusOK, result)
+}
+
+// SCIMGetUser devuelve un usuario
+func (h *Handler) SCIMGetUser(c *gin.Context) {
+	user, err := h.scimService.GetUser(c.Request.Context(), scimCaller(c), c.Param("id"))
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	if match := c.GetHeader("If-None-Match"); match != "" && match == user.Meta.Version {
+		c.Header("ETag", user.Meta.Version)
+		c.Status(http.StatusNotModified)
+		return
+	}
+	h.writeSCIMUser(c, http.StatusOK, user)
+}
+
+// SCIMCreateUser aprovisiona un usuario
+func (h *Handler) SCIMCreateUser(c *gin.Context) {
+	var resource models.SCIMUserResource
+	if err := c.ShouldBindJSON(&resource); err != nil {
+		h.writeSCIMError(c, services.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	user, err := h.scimService.CreateUser(c.Request.Context(), scimCaller(c), &resource)
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	h.writeSCIMUser(c, http.StatusCreated, user)
+}
+
+// SCIMReplaceUser sustituye un usuario (PUT)
+func (h *Handler) SCIMReplaceUser(c *gin.Context) {
+	var resource models.SCIMUserResource
+	if err := c.ShouldBindJSON(&resource); err != nil {
+		h.writeSCIMError(c, services.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	user, err := h.scimService.ReplaceUser(c.Request.Context(), scimCaller(c), c.Param("id"), c.GetHeader("If-Match"), &resource)
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	h.writeSCIMUser(c, http.StatusOK, user)
+}
+
+// SCIMPatchUser modifica atributos de un usuario (PATCH)
+func (h *Handler) SCIMPatchUser(c *gin.Context) {
+	var req models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Operations) == 0 {
+		h.writeSCIMError(c, services.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	user, err := h.scimService.PatchUser(c.Request.Context(), scimCaller(c), c.Param("id"), c.GetHeader("If-Match"), req.Operations)
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	h.writeSCIMUser(c, http.StatusOK, user)
+}
+
+// SCIMDeleteUser desaprovisiona un usuario
+func (h *Handler) SCIMDeleteUser(c *gin.Context) {
+	if err := h.scimService.DeleteUser(c.Request.Context(), scimCaller(c), c.Param("id"), c.GetHeader("If-Match")); err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SCIMListGroups busca grupos (GET /Groups?filter=...)
+func (h *Handler) SCIMListGroups(c *gin.Context) {
+	var query models.SCIMListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		h.writeSCIMError(c, services.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	result, err := h.scimService.ListGroups(c.Request.Context(), scimCaller(c), &query)
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	for _, group := range result.Resources.([]*models.SCIMGroupResource) {
+		setSCIMGroupLocations(c, group)
+	}
+	writeSCIM(c, http.StatusOK, result)
+}
+
+// SCIMGetGroup devuelve un grupo
+func (h *Handler) SCIMGetGroup(c *gin.Context) {
+	group, err := h.scimService.GetGroup(c.Request.Context(), scimCaller(c), c.Param("id"))
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	if match := c.GetHeader("If-None-Match"); match != "" && match == group.Meta.Version {
+		c.Header("ETag", group.Meta.Version)
+		c.Status(http.StatusNotModified)
+		return
+	}
+	h.writeSCIMGroup(c, http.StatusOK, group)
+}
+
+// SCIMCreateGroup crea un grupo
+func (h *Handler) SCIMCreateGroup(c *gin.Context) {
+	var resource models.SCIMGroupResource
+	if err := c.ShouldBindJSON(&resource); err != nil {
+		h.writeSCIMError(c, services.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	group, err := h.scimService.CreateGroup(c.Request.Context(), scimCaller(c), &resource)
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	h.writeSCIMGroup(c, http.StatusCreated, group)
+}
+
+// SCIMReplaceGroup sustituye un grupo (PUT)
+func (h *Handler) SCIMReplaceGroup(c *gin.Context) {
+	var resource models.SCIMGroupResource
+	if err := c.ShouldBindJSON(&resource); err != nil {
+		h.writeSCIMError(c, services.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	group, err := h.scimService.ReplaceGroup(c.Request.Context(), scimCaller(c), c.Param("id"), c.GetHeader("If-Match"), &resource)
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	h.writeSCIMGroup(c, http.StatusOK, group)
+}
+
+// SCIMPatchGroup modifica un grupo (PATCH), normalmente para altas y bajas de miembros
+func (h *Handler) SCIMPatchGroup(c *gin.Context) {
+	var req models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Operations) == 0 {
+		h.writeSCIMError(c, services.ErrSCIMInvalidSyntax)
+		return
+	}
+
+	group, err := h.scimService.PatchGroup(c.Request.Context(), scimCaller(c), c.Param("id"), c.GetHeader("If-Match"), req.Operations)
+	if err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	h.writeSCIMGroup(c, http.StatusOK, group)
+}
+
+// SCIMDeleteGroup elimina un grupo
+func (h *Handler) SCIMDeleteGroup(c *gin.Context) {
+	if err := h.scimService.DeleteGroup(c.Request.Context(), scimCaller(c), c.Param("id"), c.GetHeader("If-Match")); err != nil {
+		h.writeSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) writeSCIMUser(c *gin.Context, status int, user *models.SCIMUserResource) {
+	user.Meta.Location = scimBaseURL(c) + "/Users/" + user.ID
+	c.Header("ETag", user.Meta.Version)
+	c.Header("Location", user.Meta.Location)
+	writeSCIM(c, status, user)
+}
+
+func (h *Handler) writeSCIMGroup(c *gin.Context, status int, group *models.SCIMGroupResource) {
+	setSCIMGroupLocations(c, group)
+	c.Header("ETag", group.Meta.Version)
+	c.Header("Location", group.Meta.Location)
+	writeSCIM(c, status, group)
+}
+
+func (h *Handler) writeSCIMStaticResource(c *gin.Context, resources []map[string]interface{}) {
+	for _, resource := range resources {
+		if resource["id"] == c.Param("id") {
+			writeSCIM(c, http.StatusOK, resource)
+			return
+		}
+	}
+	h.writeSCIMError(c, services.ErrSCIMNotFound)
+}
+
+func setSCIMGroupLocations(c *gin.Context, group *models.SCIMGroupResource) {
+	base := scimBaseURL(c)
+	group.Meta.Location = base + "/Groups/" + group.ID
+	for i := range group.Members {
+		group.Members[i].Ref = base + "/Users/" + group.Members[i].Value
+	}
+}
+
+func scimStaticList(resources []map[string]interface{}) *models.SCIMListResponse {
+	return &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: int64(len(resources)),
+		ItemsPerPage: len(resources),
+		StartIndex:   1,
+		Resources:    resources,
+	}
+}
+
+// scimCaller identifica el token SCIM autenticado por RequireSCIMToken
+func scimCaller(c *gin.Context) services.SCIMCaller {
+	return services.SCIMCaller{
+		Token:     c.MustGet(middleware.ContextSCIMToken).(*models.SCIMToken),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	}
+}
+
+// scimBaseURL construye la URL absoluta de la API SCIM para meta.location
+func scimBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := c.GetHeader("X-Forwarded-Proto"); forwarded != "" {
+		scheme = forwarded
+	}
+	return scheme + "://" + c.Request.Host + scimBasePath
+}
+
+func writeSCIM(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", models.SCIMContentType)
+	c.JSON(status, body)
+}
+
+// writeSCIMError traduce los errores del servicio al formato de error de SCIM
+func (h *Handler) writeSCIMError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	scimType := ""
+	switch {
+	case errors.Is(err, services.ErrSCIMNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrSCIMUniqueness):
+		status, scimType = http.StatusConflict, "uniqueness"
+	case errors.Is(err, services.ErrSCIMPreconditionFailed):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, services.ErrSCIMInvalidFilter):
+		status, scimType = http.StatusBadRequest, "invalidFilter"
+	case errors.Is(err, services.ErrSCIMInvalidSyntax):
+		status, scimType = http.StatusBadRequest, "invalidSyntax"
+	case errors.Is(err, services.ErrSCIMInvalidValue):
+		status, scimType = http.StatusBadRequest, "invalidValue"
+	case errors.Is(err, services.ErrSCIMInvalidPath):
+		status, scimType = http.StatusBadRequest, "invalidPath"
+	case errors.Is(err, services.ErrSCIMNoTarget):
+		status, scimType = http.StatusBadRequest, "noTarget"
+	case errors.Is(err, services.ErrSCIMMutability):
+		status, scimType = http.StatusBadRequest, "mutability"
+	default:
+		h.logger.WithError(err).Error("SCIM request failed")
+	}
+
+	detail := err.Error()
+	if status == http.StatusInternalServerError {
+		detail = "Internal server error"
+	}
+	writeSCIM(c, status, models.SCIMError{
+		Schemas:  []string{models.SCIMSchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
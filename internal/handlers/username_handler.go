@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/middleware"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// CheckUsernameAvailability godoc
+// @Summary Check username availability
+// @Description Indica si el usuario autenticado puede usar un username y, si no, el motivo (invalid, reserved, taken)
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Param username query string true "Username"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Router /users/username-availability [get]
+func (h *Handler) CheckUsernameAvailability(c *gin.Context) {
+	username := c.Query("username")
+	if username == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "username query parameter is required",
+		})
+		return
+	}
+
+	availability, err := h.usernameService.Availability(c.Request.Context(), username, c.GetString(middleware.ContextUserID))
+	if err != nil {
+		h.writeUsernameError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    availability,
+	})
+}
+
+// AdminListReservedUsernames godoc
+// @Summary List reserved usernames (Admin only)
+// @Description Lista los usernames reservados desde administración (la lista fija del servicio no se incluye)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/usernames/reserved [get]
+func (h *Handler) AdminListReservedUsernames(c *gin.Context) {
+	reserved, err := h.usernameService.ListReserved(c.Request.Context())
+	if err != nil {
+		h.writeUsernameError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"reserved": reserved,
+		},
+	})
+}
+
+// AdminReserveUsername godoc
+// @Summary Reserve username (Admin only)
+// @Description Bloquea un username para que ninguna cuenta pueda tomarlo; no afecta a quien ya lo use
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ReserveUsernameRequest true "Username and reason"
+// @Success 201 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Router /admin/usernames/reserved [post]
+func (h *Handler) AdminReserveUsername(c *gin.Context) {
+	var req models.ReserveUsernameRequest
+	if !h.bindAndValidate(c, &req) {
+		return
+	}
+
+	reserved, err := h.usernameService.Reserve(c.Request.Context(), adminActor(c), &req)
+	if err != nil {
+		h.writeUsernameError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"reserved": reserved,
+		},
+	})
+}
+
+// AdminUnreserveUsername godoc
+// @Summary Release reserved username (Admin only)
+// @Description Libera un username reservado desde administración
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param name path string true "Username"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /admin/usernames/reserved/{name} [delete]
+func (h *Handler) AdminUnreserveUsername(c *gin.Context) {
+	if err := h.usernameService.Unreserve(c.Request.Context(), c.Param("name")); err != nil {
+		h.writeUsernameError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"message": "Username released",
+		},
+	})
+}
+
+// usernameErrorStatus traduce los errores de username a códigos HTTP; 0 si no es uno de ellos
+func usernameErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUsernameInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrUsernameTaken), errors.Is(err, services.ErrUsernameReserved):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUsernameChangeTooSoon):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrReservedUsernameNotFound):
+		return http.StatusNotFound
+	}
+	return 0
+}
+
+func (h *Handler) writeUsernameError(c *gin.Context, err error) {
+	statusCode := usernameErrorStatus(err)
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+		h.logger.WithError(err).Error("Username operation failed")
+	}
+
+	c.JSON(statusCode, models.APIResponse{
+		Success: false,
+		Error:   err.Error(),
+	})
+}
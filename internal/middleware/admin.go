@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/models"
+)
+
+// RequireAdmin restringe la ruta a administradores. El rol se comprueba contra la
+// base de datos (no contra el claim del token) para que un cambio de rol tenga
+// efecto inmediato. Debe ejecutarse después de RequireJWT.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.MustGet(ContextUser).(*models.User)
+		if !ok || user.Role != models.RoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Admin access required",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"it-auth-service/internal/models"
+)
+
+// RejectGuests bloquea los tokens de invitado en rutas que exigen una cuenta completa.
+// Debe ejecutarse después de RequireJWT.
+func RejectGuests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.MustGet(ContextClaims).(jwt.MapClaims)
+		if !ok {
+			abortUnauthorized(c, "Invalid token claims")
+			return
+		}
+
+		if scope, _ := claims["scope"].(string); scope == models.ScopeGuest {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "full_account_required",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireGuest restringe la ruta a tokens de invitado. Debe ejecutarse después de RequireJWT.
+func RequireGuest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.MustGet(ContextClaims).(jwt.MapClaims)
+		if !ok {
+			abortUnauthorized(c, "Invalid token claims")
+			return
+		}
+
+		if scope, _ := claims["scope"].(string); scope != models.ScopeGuest {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Guest token required",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// Claves de contexto de Gin que rellena RequireJWT
+const (
+	ContextUserID = "user_id"
+	ContextToken  = "token"
+	ContextClaims = "claims"
+	ContextUser   = "user"
+)
+
+// RequireJWT valida el JWT interno emitido por el servicio, comprueba que no esté
+// revocado y que la cuenta no esté suspendida ni eliminada
+func RequireJWT(jwtSecret string, tokenService *services.TokenService, userService *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		parts := strings.SplitN(authHeader, " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+			abortUnauthorized(c, "Authorization header format must be Bearer {token}")
+			return
+		}
+		tokenString := parts[1]
+
+		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return []byte(jwtSecret), nil
+		})
+		if err != nil || !token.Valid {
+			logger.GetLogger().WithError(err).Debug("Invalid JWT")
+			abortUnauthorized(c, "Invalid token")
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			abortUnauthorized(c, "Invalid token claims")
+			return
+		}
+
+		userID, ok := claims["user_id"].(string)
+		if !ok || userID == "" {
+			abortUnauthorized(c, "Invalid user ID in token")
+			return
+		}
+
+		revoked, err := tokenService.IsTokenRevoked(c.Request.Context(), tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to validate token",
+			})
+			return
+		}
+		if revoked {
+			abortUnauthorized(c, "Token has been revoked")
+			return
+		}
+
+		user, err := userService.GetUserByID(c.Request.Context(), userID)
+		if err != nil {
+			abortUnauthorized(c, "User not found")
+			return
+		}
+		if err := services.CheckAccountStatus(user); err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+
+		userService.TouchLastSeen(c.Request.Context(), user)
+
+		c.Set(ContextUserID, userID)
+		c.Set(ContextUser, user)
+		c.Set(ContextToken, tokenString)
+		c.Set(ContextClaims, claims)
+		c.Next()
+	}
+}
+
+func abortUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, models.APIResponse{
+		Success: false,
+		Error:   message,
+	})
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// Claves de contexto de Gin que rellenan RequireOrgRole y RequireOrgAdmin
+const (
+	// ContextOrgRole es el rol del usuario en la organización de la ruta
+	ContextOrgRole = "org_role"
+	// ContextOrgScope es la organización a la que se limita un administrador que no es
+	// global; no existe para los administradores globales
+	ContextOrgScope = "org_scope"
+)
+
+// RequireOrgRole restringe la ruta a los miembros de la organización :org_id con alguno
+// de los roles indicados. Como RequireAdmin, la membresía se comprueba contra la base de
+// datos y no contra el claim del token, pero el token debe estar emitido para esa
+// organización: quien administra varias sólo actúa en la activa (POST /auth/switch-org).
+// Los administradores globales actúan como owner. Debe ejecutarse después de RequireJWT.
+func RequireOrgRole(orgService *services.OrganizationService, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.MustGet(ContextUser).(*models.User)
+		if !ok {
+			abortUnauthorized(c, "User not found")
+			return
+		}
+
+		if user.Role == models.RoleAdmin {
+			c.Set(ContextOrgRole, models.OrgRoleOwner)
+			c.Next()
+			return
+		}
+
+		orgID := c.Param("org_id")
+		claims, _ := c.MustGet(ContextClaims).(jwt.MapClaims)
+		if tokenOrgID, _ := claims["org_id"].(string); tokenOrgID != orgID {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Token was not issued for this organization, switch to it first",
+			})
+			return
+		}
+
+		if !requireMembership(c, orgService, orgID, user.ID, roles) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireOrgAdmin admite a los administradores globales sin restricción y a los owners y
+// admins de la organización activa del token (claim org_id), que quedan limitados a ella:
+// ContextOrgScope lleva esa organización y los handlers deben aplicarla a sus consultas.
+// Debe ejecutarse después de RequireJWT.
+func RequireOrgAdmin(orgService *services.OrganizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.MustGet(ContextUser).(*models.User)
+		if !ok {
+			abortUnauthorized(c, "User not found")
+			return
+		}
+
+		if user.Role == models.RoleAdmin {
+			c.Next()
+			return
+		}
+
+		claims, _ := c.MustGet(ContextClaims).(jwt.MapClaims)
+		orgID, _ := claims["org_id"].(string)
+		if orgID == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Admin access required",
+			})
+			return
+		}
+
+		if !requireMembership(c, orgService, orgID, user.ID, []string{models.OrgRoleOwner, models.OrgRoleAdmin}) {
+			return
+		}
+		c.Set(ContextOrgScope, orgID)
+		c.Next()
+	}
+}
+
+// requireMembership comprueba en base de datos el rol del usuario en la organización y
+// lo guarda en ContextOrgRole; si no lo tiene aborta la petición y devuelve false
+func requireMembership(c *gin.Context, orgService *services.OrganizationService, orgID, userID string, roles []string) bool {
+	membership, err := orgService.GetMembership(c.Request.Context(), orgID, userID)
+	if err != nil && !errors.Is(err, services.ErrNotOrgMember) {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Error:   "Failed to check organization membership",
+		})
+		return false
+	}
+	if membership == nil || !containsRole(roles, membership.Role) {
+		c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Error:   "Organization access required",
+		})
+		return false
+	}
+
+	c.Set(ContextOrgRole, membership.Role)
+	return true
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, allowed := range roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// RequirePermission restringe la ruta a los usuarios con el permiso indicado por
+// alguno de sus roles, directos o heredados de sus grupos. Los permisos se resuelven
+// en el momento (con caché) y no se leen del token. Debe ejecutarse después de RequireJWT.
+func RequirePermission(groupService *services.GroupService, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.MustGet(ContextUser).(*models.User)
+		if !ok {
+			abortUnauthorized(c, "User not found")
+			return
+		}
+
+		granted, err := groupService.HasPermission(c.Request.Context(), user, permission)
+		if err != nil {
+			logger.GetLogger().WithError(err).Error("Failed to resolve permissions")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Error:   "Failed to check permissions",
+			})
+			return
+		}
+		if !granted {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Error:   "Permission required: " + permission,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/services"
+)
+
+// ContextSCIMToken es la clave de contexto con el token SCIM autenticado
+const ContextSCIMToken = "scim_token"
+
+// RequireSCIMToken autentica las peticiones SCIM con el bearer token del tenant.
+// Los errores se devuelven con el formato de error de SCIM, no con APIResponse.
+func RequireSCIMToken(scimService *services.SCIMService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+			abortSCIMUnauthorized(c)
+			return
+		}
+
+		token, err := scimService.Authenticate(c.Request.Context(), parts[1])
+		if err != nil {
+			if errors.Is(err, services.ErrSCIMInvalidToken) {
+				abortSCIMUnauthorized(c)
+				return
+			}
+			logger.GetLogger().WithError(err).Error("Failed to authenticate SCIM token")
+			c.Header("Content-Type", models.SCIMContentType)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.SCIMError{
+				Schemas: []string{models.SCIMSchemaError},
+				Status:  strconv.Itoa(http.StatusInternalServerError),
+				Detail:  "Failed to authenticate request",
+			})
+			return
+		}
+
+		c.Set(ContextSCIMToken, token)
+		c.Next()
+	}
+}
+
+func abortSCIMUnauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+	c.Header("Content-Type", models.SCIMContentType)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, models.SCIMError{
+		Schemas: []string{models.SCIMSchemaError},
+		Status:  strconv.Itoa(http.StatusUnauthorized),
+		Detail:  "Invalid or missing SCIM bearer token",
+	})
+}
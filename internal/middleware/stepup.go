@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"it-auth-service/internal/models"
+)
+
+// acrLevels ordena los niveles de autenticación de menor a mayor fuerza
+var acrLevels = map[string]int{
+	models.ACRSingleFactor: 1,
+	models.ACRMultiFactor:  2,
+}
+
+// RequireRecentAuth protege operaciones sensibles: rechaza con un reto step-up
+// (RFC 9470) si la autenticación del token es más antigua que maxAge o más
+// débil que minACR. Los usuarios con MFA activado deben haberlo usado (aal2).
+// Debe ejecutarse después de RequireJWT.
+func RequireRecentAuth(maxAge time.Duration, minACR string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := c.MustGet(ContextClaims).(jwt.MapClaims)
+		if !ok {
+			abortUnauthorized(c, "Invalid token claims")
+			return
+		}
+
+		minACR := minACR
+		if user, _ := c.MustGet(ContextUser).(*models.User); user != nil && user.MFAEnabled &&
+			acrLevels[minACR] < acrLevels[models.ACRMultiFactor] {
+			minACR = models.ACRMultiFactor
+		}
+
+		authTime, _ := claims["auth_time"].(float64)
+		acr, _ := claims["acr"].(string)
+
+		tooOld := authTime == 0 || time.Since(time.Unix(int64(authTime), 0)) > maxAge
+		tooWeak := acrLevels[acr] < acrLevels[minACR]
+		if !tooOld && !tooWeak {
+			c.Next()
+			return
+		}
+
+		description := "A more recent authentication is required"
+		if tooWeak {
+			description = "A stronger authentication is required"
+		}
+
+		c.Header("WWW-Authenticate", fmt.Sprintf(
+			`Bearer error="insufficient_user_authentication", error_description="%s", max_age=%d, acr_values="%s"`,
+			description, int(maxAge.Seconds()), minACR,
+		))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Error:   "step_up_required",
+			Data: map[string]interface{}{
+				"message":    description,
+				"max_age":    int(maxAge.Seconds()),
+				"acr_values": minACR,
+			},
+		})
+	}
+}
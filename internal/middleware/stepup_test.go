@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"it-auth-service/internal/models"
+)
+
+// serveStepUp ejecuta RequireRecentAuth con los claims y el usuario que dejaría RequireJWT
+func serveStepUp(claims jwt.MapClaims, user *models.User) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/sensitive", func(c *gin.Context) {
+		c.Set(ContextClaims, claims)
+		c.Set(ContextUser, user)
+	}, RequireRecentAuth(5*time.Minute, models.ACRSingleFactor), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sensitive", nil))
+	return w
+}
+
+func TestRequireRecentAuthAllowsRecentAuthentication(t *testing.T) {
+	claims := jwt.MapClaims{"auth_time": float64(time.Now().Add(-time.Minute).Unix()), "acr": models.ACRSingleFactor}
+
+	w := serveStepUp(claims, &models.User{})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestRequireRecentAuthRejectsExpiredAuthTime(t *testing.T) {
+	claims := jwt.MapClaims{"auth_time": float64(time.Now().Add(-time.Hour).Unix()), "acr": models.ACRMultiFactor}
+
+	w := serveStepUp(claims, &models.User{})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	challenge := w.Header().Get("WWW-Authenticate")
+	assert.Contains(t, challenge, `error="insufficient_user_authentication"`)
+	assert.Contains(t, challenge, "max_age=300")
+	assert.Contains(t, w.Body.String(), "step_up_required")
+}
+
+func TestRequireRecentAuthForcesMultiFactorForMFAUsers(t *testing.T) {
+	claims := jwt.MapClaims{"auth_time": float64(time.Now().Unix()), "acr": models.ACRSingleFactor}
+
+	// Una autenticación reciente de un solo factor no basta si la cuenta tiene MFA
+	w := serveStepUp(claims, &models.User{MFAEnabled: true})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `acr_values="`+models.ACRMultiFactor+`"`)
+
+	claims["acr"] = models.ACRMultiFactor
+	w = serveStepUp(claims, &models.User{MFAEnabled: true})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
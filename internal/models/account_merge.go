@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// AccountMergeRequest representa una vinculación pendiente cuando un login nuevo
+// coincide por email con una cuenta existente y el email no está verificado
+type AccountMergeRequest struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID      string     `json:"user_id" gorm:"not null;index"`    // Cuenta existente
+	Subject     string     `json:"subject" gorm:"size:128;not null"` // Firebase UID que se quiere vincular
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	TokenHash   string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null;index"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// ConfirmAccountMergeRequest completa la vinculación demostrando el control de la cuenta existente
+type ConfirmAccountMergeRequest struct {
+	MergeToken    string `json:"merge_token" validate:"required"`
+	FirebaseToken string `json:"firebase_token" validate:"required"` // Token de una identidad de la cuenta existente
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UpdateUserStatusRequest cambia el estado de una cuenta desde administración.
+// "deleted" se mantiene como alias de pending_deletion; el borrado definitivo sólo
+// lo ejecuta el proceso de supresión.
+type UpdateUserStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=pending_verification active locked suspended pending_deletion deleted"`
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+	// Sólo con status locked; sin fecha el bloqueo es indefinido
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
+
+// ForceLogoutRequest cierra todas las sesiones de un usuario desde administración
+type ForceLogoutRequest struct {
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// ForceLogoutResponse resume las sesiones cerradas
+type ForceLogoutResponse struct {
+	RevokedSessions int `json:"revoked_sessions"`
+}
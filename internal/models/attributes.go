@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Tipos de atributo personalizado
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeInteger = "integer"
+	AttributeTypeBoolean = "boolean"
+	AttributeTypeEnum    = "enum"
+	AttributeTypeDate    = "date" // YYYY-MM-DD
+)
+
+// Visibilidad de un atributo personalizado
+const (
+	AttributeVisibilitySelfEditable = "self_editable" // El usuario lo ve y lo edita
+	AttributeVisibilitySelfReadOnly = "self_readonly" // El usuario lo ve; sólo lo edita un administrador
+	AttributeVisibilityAdminOnly    = "admin_only"    // Sólo lo ven y editan los administradores
+)
+
+// AttributeDefinition describe un atributo personalizado de los usuarios. Las
+// definiciones con TenantID vacío se aplican a todos; las de un tenant, a los usuarios
+// que ese tenant aprovisionó, y prevalecen sobre las globales con la misma clave.
+type AttributeDefinition struct {
+	ID            string      `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID      string      `json:"tenant_id" gorm:"size:64;not null;default:'';uniqueIndex:idx_attribute_definitions_tenant_key"`
+	Key           string      `json:"key" gorm:"size:64;not null;uniqueIndex:idx_attribute_definitions_tenant_key"`
+	DisplayName   string      `json:"display_name,omitempty"`
+	Type          string      `json:"type" gorm:"size:16;not null"`
+	Required      bool        `json:"required"`
+	EnumValues    StringSlice `json:"enum_values,omitempty" gorm:"type:jsonb"`
+	Pattern       string      `json:"pattern,omitempty"`
+	MaxLength     *int        `json:"max_length,omitempty"`
+	Min           *float64    `json:"min,omitempty"`
+	Max           *float64    `json:"max,omitempty"`
+	Visibility    string      `json:"visibility" gorm:"size:16;not null"`
+	ExposeInToken bool        `json:"expose_in_token"`
+	CreatedAt     time.Time   `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time   `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// AttributeDefinitionRequest crea o reemplaza la definición de un atributo
+type AttributeDefinitionRequest struct {
+	TenantID      string   `json:"tenant_id,omitempty" validate:"max=64"`
+	Key           string   `json:"key" validate:"required,max=64"`
+	DisplayName   string   `json:"display_name,omitempty" validate:"max=255"`
+	Type          string   `json:"type" validate:"required,oneof=string number integer boolean enum date"`
+	Required      bool     `json:"required"`
+	EnumValues    []string `json:"enum_values,omitempty" validate:"required_if=Type enum,dive,required,max=255"`
+	Pattern       string   `json:"pattern,omitempty" validate:"max=500"`
+	MaxLength     *int     `json:"max_length,omitempty" validate:"omitempty,min=1"`
+	Min           *float64 `json:"min,omitempty"`
+	Max           *float64 `json:"max,omitempty"`
+	Visibility    string   `json:"visibility" validate:"required,oneof=self_editable self_readonly admin_only"`
+	ExposeInToken bool     `json:"expose_in_token"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Acciones registradas en el log de auditoría
+const (
+	AuditActionIdentityLinked         = "identity.linked"
+	AuditActionAccountMerged          = "account.merge_completed"
+	AuditActionMergeRequested         = "account.merge_requested"
+	AuditActionStatusChanged          = "user.status_changed"
+	AuditActionForceLogout            = "user.force_logout"
+	AuditActionUsersImported          = "users.imported"
+	AuditActionUsersExported          = "users.exported"
+	AuditActionUserProvisioned        = "user.provisioned"
+	AuditActionSCIMTokenCreated       = "scim.token_created"
+	AuditActionSCIMTokenRevoked       = "scim.token_revoked"
+	AuditActionDataExportRequested    = "user.data_export_requested"
+	AuditActionDataExportDownloaded   = "user.data_export_downloaded"
+	AuditActionErasureScheduled       = "user.erasure_scheduled"
+	AuditActionErasureCancelled       = "user.erasure_cancelled"
+	AuditActionUserErased             = "user.erased"
+	AuditActionAttributesUpdated      = "user.attributes_updated"
+	AuditActionAttributeSchemaChanged = "attributes.schema_changed"
+	AuditActionOrgCreated             = "org.created"
+	AuditActionOrgUpdated             = "org.updated"
+	AuditActionOrgDeleted             = "org.deleted"
+	AuditActionOrgMemberAdded         = "org.member_added"
+	AuditActionOrgMemberUpdated       = "org.member_updated"
+	AuditActionOrgMemberRemoved       = "org.member_removed"
+	AuditActionOrgSwitched            = "user.org_switched"
+	AuditActionGroupChanged           = "groups.changed"
+	AuditActionRoleChanged            = "roles.changed"
+	AuditActionInvitationCreated      = "invitation.created"
+	AuditActionInvitationResent       = "invitation.resent"
+	AuditActionInvitationRevoked      = "invitation.revoked"
+	AuditActionInvitationAccepted     = "invitation.accepted"
+	AuditActionEmailChangeRequested   = "user.email_change_requested"
+	AuditActionEmailChanged           = "user.email_changed"
+	AuditActionEmailChangeReverted    = "user.email_change_reverted"
+	AuditActionEmailChangeRolledBack  = "user.email_change_rolled_back"
+	AuditActionAvatarUpdated          = "user.avatar_updated"
+	AuditActionAvatarRemoved          = "user.avatar_removed"
+	AuditActionDormancyWarned         = "user.dormancy_warned"
+	AuditActionReactivationRequested  = "user.reactivation_requested"
+	AuditActionConsentAccepted        = "user.consent_accepted"
+	AuditActionConsentDocumentCreated = "consent.document_created"
+	AuditActionConsentPublished       = "consent.document_published"
+	AuditActionDomainPolicyChanged    = "domain_policies.changed"
+)
+
+// AuditLog registra acciones relevantes para seguridad sobre una cuenta
+type AuditLog struct {
+	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string    `json:"user_id" gorm:"index"` // Usuario afectado
+	ActorID   string    `json:"actor_id,omitempty"`   // Quién realizó la acción (vacío si fue el sistema)
+	Action    string    `json:"action" gorm:"size:64;not null;index"`
+	Details   JSONMap   `json:"details,omitempty" gorm:"type:jsonb"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+}
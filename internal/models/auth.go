@@ -24,19 +24,88 @@ type AuthStatusResponse struct {
 	ExpiresAt     int64 `json:"expires_at,omitempty"`
 }
 
+// Roles de usuario
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Estados de usuario. Las transiciones permitidas entre ellos están definidas en
+// services/lifecycle.go; ningún otro código debe escribir la columna status.
+const (
+	StatusPendingVerification = "pending_verification" // Falta verificar el email
+	StatusActive              = "active"
+	StatusGuest               = "guest"
+	StatusLocked              = "locked" // Bloqueo temporal hasta LockedUntil (o indefinido)
+	StatusSuspended           = "suspended"
+	StatusPendingDeletion     = "pending_deletion" // Eliminada, restaurable durante el periodo de gracia
+	StatusDeleted             = "deleted"          // Datos borrados definitivamente; estado final
+)
+
+// DeletedStatuses son los estados de las cuentas eliminadas, que no aparecen en
+// búsquedas ni listados por defecto
+var DeletedStatuses = []string{StatusPendingDeletion, StatusDeleted}
+
+// IsDeletedStatus indica si el estado corresponde a una cuenta eliminada
+func IsDeletedStatus(status string) bool {
+	return status == StatusPendingDeletion || status == StatusDeleted
+}
+
+// ProviderAnonymous es el sign_in_provider de los tokens de Firebase de invitados
+const ProviderAnonymous = "anonymous"
+
+// ScopeGuest marca los JWT de invitados, que sólo dan acceso a rutas limitadas
+const ScopeGuest = "guest"
+
+// Nivel de autenticación (acr) y métodos usados (amr, RFC 8176) en los tokens emitidos
+const (
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+
+	AMRPassword  = "pwd"
+	AMRFederated = "fed"
+	AMROTP       = "otp"
+	AMRMFA       = "mfa"
+)
+
+// AuthContext describe cuándo y cómo se autenticó el usuario
+type AuthContext struct {
+	AuthTime time.Time
+	AMR      []string
+	ACR      string
+}
+
 // User model completo para auth service
 type User struct {
 	ID            string `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
 	FirebaseID    string `json:"firebase_id" gorm:"uniqueIndex;not null"`
 	Email         string `json:"email" gorm:"uniqueIndex;not null"`
+	// Buzón del email sin variantes (mayúsculas, puntos y "+etiqueta" según el proveedor)
+	CanonicalEmail string `json:"-" gorm:"size:320;not null;default:''"`
 	Username      string `json:"username" gorm:"uniqueIndex"`
 	FirstName     string `json:"first_name"`
 	LastName      string `json:"last_name"`
 	Provider      string `json:"provider"` // google.com, facebook.com, password
 	PhotoURL      string `json:"photo_url"`
+	// Avatar subido por el usuario; mientras exista, photo_url no se toma de Firebase
+	AvatarID      *string    `json:"-" gorm:"size:32"`
 	Status        string     `json:"status" gorm:"default:active"`
+	StatusReason  string     `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time `json:"status_changed_at,omitempty"`
+	// Fin del bloqueo temporal; nil en una cuenta locked la bloquea hasta que se desbloquee
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	Role          string     `json:"role" gorm:"default:user"` // user, admin
 	EmailVerified bool       `json:"email_verified" gorm:"default:false"`
+	MFAEnabled    bool       `json:"mfa_enabled" gorm:"default:false"`
+	// Atributos personalizados; se exponen filtrados por visibilidad en /attributes
+	Attributes    JSONMap    `json:"-" gorm:"type:jsonb"`
+	// Organización con la que se emiten los tokens; nil usa la primera membresía
+	ActiveOrgID   *string    `json:"active_org_id,omitempty" gorm:"type:uuid"`
 	LastLoginAt   *time.Time `json:"last_login_at,omitempty"`
+	// Última petición autenticada, guardada como mucho una vez por hora
+	LastSeenAt    *time.Time `json:"last_seen_at,omitempty"`
+	// Último aviso de inactividad; sólo cuenta si es posterior a la última actividad
+	DormancyWarnedAt *time.Time `json:"dormancy_warned_at,omitempty"`
 	LastLogoutAt  *time.Time `json:"last_logout_at,omitempty"`
 	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt     time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
@@ -46,6 +115,8 @@ type User struct {
 type FirebaseLoginRequest struct {
 	FirebaseToken string `json:"firebase_token" validate:"required"`
 	Provider      string `json:"provider" validate:"required,oneof=google.com facebook.com password"`
+	// Invitación que se acepta con la identidad de este login
+	InvitationToken string `json:"invitation_token,omitempty"`
 }
 
 // Firebase Register Request
@@ -53,6 +124,9 @@ type FirebaseRegisterRequest struct {
 	FirebaseToken    string                 `json:"firebase_token" validate:"required"`
 	Provider         string                 `json:"provider" validate:"required,oneof=google.com facebook.com password"`
 	RegistrationData map[string]interface{} `json:"registration_data"`
+	InvitationToken  string                 `json:"invitation_token,omitempty"`
+	// Documentos legales vigentes que el usuario acepta al registrarse
+	AcceptedConsents []string `json:"accepted_consents,omitempty" validate:"omitempty,max=10,dive,uuid"`
 }
 
 // Firebase Refresh Token Request
@@ -73,6 +147,28 @@ type AuthResponseData struct {
 	Token     string `json:"token"`
 	User      *User  `json:"user"`
 	IsNewUser bool   `json:"isNewUser"`
+
+	// Segunda fase de login: cuando MFARequired es true no se emite Token,
+	// el cliente debe completar el login con ChallengeToken en /auth/mfa/verify
+	MFARequired           bool     `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool     `json:"mfa_enrollment_required,omitempty"`
+	ChallengeToken        string   `json:"challenge_token,omitempty"`
+	RecoveryCodes         []string `json:"recovery_codes,omitempty"`
+
+	// El email coincide con otra cuenta: hay que demostrar su control en /auth/merge/confirm
+	AccountLinkRequired bool   `json:"account_link_required,omitempty"`
+	MergeToken          string `json:"merge_token,omitempty"`
+
+	// Hay versiones obligatorias de los documentos legales sin aceptar: se aceptan en /auth/consent
+	ConsentRequired  bool               `json:"consent_required,omitempty"`
+	RequiredConsents []*ConsentDocument `json:"required_consents,omitempty"`
+}
+
+// ReauthenticateRequest renueva la autenticación de la sesión actual (step-up)
+type ReauthenticateRequest struct {
+	FirebaseToken string `json:"firebase_token" validate:"required"`
+	Code          string `json:"code,omitempty" validate:"omitempty,len=6,numeric"`
+	RecoveryCode  string `json:"recovery_code,omitempty"`
 }
 
 // Logout Request
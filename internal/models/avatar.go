@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// UserAvatar es la imagen de perfil subida por un usuario, guardada en varias
+// versiones cuadradas. El ID es aleatorio y forma parte de la URL pública del avatar.
+type UserAvatar struct {
+	ID        string    `json:"id" gorm:"primaryKey;size:32"`
+	UserID    string    `json:"user_id" gorm:"type:uuid;not null;index"`
+	Sizes     IntSlice  `json:"sizes" gorm:"type:jsonb"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// AvatarURLs son las URLs firmadas de cada versión del avatar, por lado en píxeles
+type AvatarURLs struct {
+	PhotoURL  string            `json:"photo_url"`
+	URLs      map[string]string `json:"urls"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
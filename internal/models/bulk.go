@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Formatos de importación y exportación masiva
+const (
+	BulkFormatCSV    = "csv"
+	BulkFormatNDJSON = "ndjson"
+)
+
+// Estados de un job de importación
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// UserImportJob registra una importación masiva de usuarios y su resultado
+type UserImportJob struct {
+	ID                  string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Format              string     `json:"format" gorm:"size:16;not null"`
+	DryRun              bool       `json:"dry_run"`
+	CreateFirebaseUsers bool       `json:"create_firebase_users"`
+	Status              string     `json:"status" gorm:"size:16;not null;index"`
+	TotalRows           int        `json:"total_rows"`
+	CreatedRows         int        `json:"created_rows"`
+	UpdatedRows         int        `json:"updated_rows"`
+	FailedRows          int        `json:"failed_rows"`
+	Error               string     `json:"error,omitempty"` // Fallo que abortó el job completo
+	CreatedBy           string     `json:"created_by"`
+	StartedAt           *time.Time `json:"started_at,omitempty"`
+	CompletedAt         *time.Time `json:"completed_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt           time.Time  `json:"updated_at" gorm:"autoUpdateTime;index"` // Último progreso guardado
+}
+
+// UserImportRowError es el error de validación o escritura de una fila importada
+type UserImportRowError struct {
+	ID      uint   `json:"-" gorm:"primaryKey"`
+	JobID   string `json:"-" gorm:"type:uuid;not null;index"`
+	Row     int    `json:"row"`
+	Email   string `json:"email,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportUserRow es una fila del fichero de importación (columna CSV o clave NDJSON)
+type ImportUserRow struct {
+	Email         string `json:"email" validate:"required,email,max=320"`
+	FirebaseID    string `json:"firebase_id" validate:"omitempty,max=128"`
+	Username      string `json:"username" validate:"omitempty,username"`
+	FirstName     string `json:"first_name" validate:"max=100"`
+	LastName      string `json:"last_name" validate:"max=100"`
+	PhotoURL      string `json:"photo_url" validate:"omitempty,max=2048,photourl"`
+	Provider      string `json:"provider" validate:"omitempty,oneof=google.com facebook.com password"`
+	Role          string `json:"role" validate:"omitempty,oneof=user admin"`
+	Status        string `json:"status" validate:"omitempty,oneof=active suspended"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// UserImportOptions son las opciones de un job de importación
+type UserImportOptions struct {
+	Format              string `form:"format" validate:"required,oneof=csv ndjson"`
+	DryRun              bool   `form:"dry_run"`
+	CreateFirebaseUsers bool   `form:"create_firebase_users"`
+}
+
+// UserImportJobDetails es el job con sus errores por fila
+type UserImportJobDetails struct {
+	*UserImportJob
+	RowErrors []*UserImportRowError `json:"row_errors"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// FirebaseClaimsSync guarda el último estado de custom claims enviado a Firebase por UID
+type FirebaseClaimsSync struct {
+	Subject    string     `json:"subject" gorm:"primaryKey;size:128"` // Firebase UID
+	UserID     string     `json:"user_id" gorm:"not null;index"`
+	ClaimsHash string     `json:"claims_hash" gorm:"size:64"`
+	SyncedAt   *time.Time `json:"synced_at,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// Tipos de documento legal que aceptan los usuarios
+const (
+	ConsentTypeTermsOfService = "terms_of_service"
+	ConsentTypePrivacyPolicy  = "privacy_policy"
+)
+
+// ConsentDocument es una versión de los términos de servicio o de la política de
+// privacidad. La última versión publicada de cada tipo es la vigente; si es
+// obligatoria, hay que aceptarla para iniciar sesión.
+type ConsentDocument struct {
+	ID          string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Type        string     `json:"type" gorm:"size:32;not null;uniqueIndex:idx_consent_document_version"`
+	Version     string     `json:"version" gorm:"size:32;not null;uniqueIndex:idx_consent_document_version"`
+	Title       string     `json:"title,omitempty" gorm:"size:255"`
+	URL         string     `json:"url" gorm:"size:2048;not null"`
+	Mandatory   bool       `json:"mandatory" gorm:"default:false"`
+	PublishedAt *time.Time `json:"published_at,omitempty" gorm:"index"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ConsentAcceptance registra qué versión de un documento aceptó un usuario y cuándo.
+// Tipo y versión se copian del documento para que el registro se lea por sí solo.
+type ConsentAcceptance struct {
+	ID         string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID     string    `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_consent_acceptance_user_document"`
+	DocumentID string    `json:"document_id" gorm:"type:uuid;not null;uniqueIndex:idx_consent_acceptance_user_document;index"`
+	Type       string    `json:"type" gorm:"size:32;not null"`
+	Version    string    `json:"version" gorm:"size:32;not null"`
+	AcceptedAt time.Time `json:"accepted_at" gorm:"not null"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// ConsentStatus resume los documentos vigentes, los obligatorios que el usuario aún
+// no ha aceptado y su historial de aceptaciones
+type ConsentStatus struct {
+	Current  []*ConsentDocument   `json:"current"`
+	Pending  []*ConsentDocument   `json:"pending"`
+	Accepted []*ConsentAcceptance `json:"accepted"`
+}
+
+// ConsentCoverage es el grado de aceptación de un documento vigente entre las
+// cuentas que pueden iniciar sesión (ni invitados ni eliminadas)
+type ConsentCoverage struct {
+	Document      *ConsentDocument       `json:"document"`
+	EligibleUsers int64                  `json:"eligible_users"`
+	AcceptedUsers int64                  `json:"accepted_users"`
+	Coverage      float64                `json:"coverage"` // Fracción entre 0 y 1
+	Versions      []*ConsentVersionCount `json:"versions"` // Aceptaciones de cada versión del mismo tipo
+}
+
+// ConsentVersionCount cuenta las cuentas que aceptaron una versión
+type ConsentVersionCount struct {
+	Version string `json:"version"`
+	Users   int64  `json:"users"`
+}
+
+// CreateConsentDocumentRequest registra una versión nueva de un documento
+type CreateConsentDocumentRequest struct {
+	Type      string `json:"type" validate:"required,oneof=terms_of_service privacy_policy"`
+	Version   string `json:"version" validate:"required,max=32"`
+	Title     string `json:"title,omitempty" validate:"max=255"`
+	URL       string `json:"url" validate:"required,url,max=2048"`
+	Mandatory bool   `json:"mandatory"`
+	Publish   bool   `json:"publish,omitempty"` // Publicarla ya en lugar de con /publish
+}
+
+// AcceptConsentsRequest acepta documentos vigentes con la sesión actual
+type AcceptConsentsRequest struct {
+	DocumentIDs []string `json:"document_ids" validate:"required,min=1,max=10,dive,uuid"`
+}
+
+// LoginConsentRequest acepta los documentos pendientes que bloquean un login y lo completa
+type LoginConsentRequest struct {
+	FirebaseToken string   `json:"firebase_token" validate:"required"`
+	DocumentIDs   []string `json:"document_ids" validate:"required,min=1,max=10,dive,uuid"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Estados de una exportación de datos personales
+const (
+	DataExportStatusPending   = "pending"
+	DataExportStatusRunning   = "running"
+	DataExportStatusCompleted = "completed"
+	DataExportStatusFailed    = "failed"
+	DataExportStatusExpired   = "expired"
+)
+
+// DataExport es una solicitud de exportación de los datos personales de un usuario
+// (derecho de acceso del RGPD). El fichero generado se guarda hasta ExpiresAt.
+type DataExport struct {
+	ID           string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID       string     `json:"-" gorm:"type:uuid;not null;index"`
+	Status       string     `json:"status" gorm:"size:16;not null;index"`
+	Error        string     `json:"error,omitempty"`
+	Bundle       []byte     `json:"-" gorm:"type:bytea"`
+	SizeBytes    int        `json:"size_bytes,omitempty"`
+	DownloadURL  string     `json:"download_url,omitempty" gorm:"-"`
+	RequestedAt  time.Time  `json:"requested_at" gorm:"autoCreateTime"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	DownloadedAt *time.Time `json:"downloaded_at,omitempty"`
+}
+
+// DataExportBundle es el contenido del fichero JSON que descarga el usuario
+type DataExportBundle struct {
+	ExportID           string                    `json:"export_id"`
+	GeneratedAt        time.Time                 `json:"generated_at"`
+	User               *User                     `json:"user"`
+	Attributes         JSONMap                   `json:"attributes,omitempty"`
+	Identities         []*UserIdentity           `json:"identities"`
+	Memberships        []*OrganizationMembership `json:"organization_memberships"`
+	GroupMemberships   []*UserGroupMember        `json:"group_memberships"`
+	Sessions           []*UserSession            `json:"sessions"`
+	RevokedTokens      []*RevokedToken           `json:"revoked_tokens"`
+	EmailVerifications []*EmailVerification      `json:"email_verifications"`
+	EmailChanges       []*EmailChangeRequest     `json:"email_changes"`
+	Consents           []*ConsentAcceptance      `json:"consents"`
+	PasswordResets     []*PasswordResetToken     `json:"password_resets"`
+	AuditLogs          []*AuditLog               `json:"audit_logs"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// DomainPolicy es la regla que se aplica a las cuentas cuyo email pertenece a un
+// dominio y, con IncludeSubdomains, a sus subdominios. Si varias coinciden, gana la
+// del dominio más específico.
+type DomainPolicy struct {
+	ID                string `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Domain            string `json:"domain" gorm:"size:255;not null;uniqueIndex"`
+	IncludeSubdomains bool   `json:"include_subdomains" gorm:"default:false"`
+	// Rechaza el alta de cuentas nuevas, salvo que lleguen con una invitación
+	BlockSignup bool `json:"block_signup" gorm:"default:false"`
+	// Da por verificado el email que asegura un proveedor federado (SSO corporativo);
+	// nunca el de una cuenta con contraseña, que no demuestra el control del buzón
+	AutoVerify bool `json:"auto_verify" gorm:"default:false"`
+	// Proveedores (sign_in_provider de Firebase) en los que confía AutoVerify, p. ej. el
+	// SAML u OIDC del dominio; el resto sólo verifica con el claim email_verified
+	TrustedProviders StringSlice `json:"trusted_providers,omitempty" gorm:"type:jsonb"`
+	// Organización a la que se une la cuenta, con AutoJoinRole, la primera vez que
+	// su email está verificado
+	AutoJoinOrgID *string   `json:"auto_join_org_id,omitempty" gorm:"type:uuid;index"`
+	AutoJoinRole  string    `json:"auto_join_role,omitempty" gorm:"size:16"`
+	CreatedBy     string    `json:"created_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// DomainPolicyRequest crea o reemplaza la regla de un dominio
+type DomainPolicyRequest struct {
+	Domain            string   `json:"domain" validate:"required,fqdn,max=255"`
+	IncludeSubdomains bool     `json:"include_subdomains"`
+	BlockSignup       bool     `json:"block_signup"`
+	AutoVerify        bool     `json:"auto_verify"`
+	TrustedProviders  []string `json:"trusted_providers,omitempty" validate:"required_if=AutoVerify true,max=10,dive,required,max=128"`
+	AutoJoinOrgID     *string  `json:"auto_join_org_id,omitempty" validate:"omitempty,uuid"`
+	AutoJoinRole      string   `json:"auto_join_role,omitempty" validate:"required_with=AutoJoinOrgID,omitempty,oneof=admin member"`
+}
+
+// DisposableDomainsStatus describe la lista de dominios de email desechables cargada
+// en la instancia que atiende la petición
+type DisposableDomainsStatus struct {
+	Block          bool      `json:"block"`
+	Domains        int       `json:"domains"`
+	BuiltinDomains int       `json:"builtin_domains"`
+	File           string    `json:"file,omitempty"`
+	FileDomains    int       `json:"file_domains"`
+	LoadedAt       time.Time `json:"loaded_at"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// UserLastActivityExpression es la última actividad de una cuenta para detectar las
+// inactivas: su última petición autenticada, su último login o, si nunca ha iniciado
+// sesión, su alta. last_seen_at se guarda también en cada login, así que nunca es anterior.
+const UserLastActivityExpression = "coalesce(users.last_seen_at, users.last_login_at, users.created_at)"
+
+// AccountReactivation es un código de un solo uso enviado al email de una cuenta
+// desactivada por inactividad para volver a activarla
+type AccountReactivation struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID     string     `json:"user_id" gorm:"type:uuid;not null;index"`
+	CodeHash   string     `json:"-" gorm:"size:64;not null"`
+	Attempts   int        `json:"-" gorm:"default:0"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// DormantUser es una cuenta inactiva incluida en el informe de inactividad
+type DormantUser struct {
+	UserID         string     `json:"user_id"`
+	Email          string     `json:"email"`
+	LastActivityAt time.Time  `json:"last_activity_at"`
+	WarnedAt       *time.Time `json:"warned_at,omitempty"`
+}
+
+// DormancyReport resume las cuentas que el job de inactividad avisa y desactiva. En
+// modo dry-run (o desde el endpoint de administración) sólo enumera lo que haría.
+type DormancyReport struct {
+	DryRun           bool           `json:"dry_run"`
+	GeneratedAt      time.Time      `json:"generated_at"`
+	WarnBefore       time.Time      `json:"warn_before"`
+	DeactivateBefore time.Time      `json:"deactivate_before"`
+	ExemptTenants    []string       `json:"exempt_tenants"`
+	ToWarn           []*DormantUser `json:"to_warn"`
+	ToDeactivate     []*DormantUser `json:"to_deactivate"`
+	Warned           int            `json:"warned"`
+	Deactivated      int            `json:"deactivated"`
+}
+
+// RequestReactivationRequest pide el código de reactivación de una cuenta inactiva
+// demostrando el control de una de sus identidades
+type RequestReactivationRequest struct {
+	FirebaseToken string `json:"firebase_token" validate:"required"`
+}
+
+// ConfirmReactivationRequest reactiva la cuenta con el código recibido por email
+type ConfirmReactivationRequest struct {
+	FirebaseToken string `json:"firebase_token" validate:"required"`
+	Code          string `json:"code" validate:"required,len=6,numeric"`
+}
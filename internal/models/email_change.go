@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Estados de una solicitud de cambio de email
+const (
+	EmailChangeStatusPending   = "pending"   // Esperando el código enviado a la nueva dirección
+	EmailChangeStatusConfirmed = "confirmed" // Aplicado; la dirección anterior aún puede deshacerlo
+	EmailChangeStatusCancelled = "cancelled" // Sustituida por otra solicitud o agotados los intentos
+	EmailChangeStatusReverted  = "reverted"  // Deshecho desde el enlace enviado a la dirección anterior
+)
+
+// EmailChangeRequest es un cambio de email iniciado por el propio usuario. Sólo se
+// guardan los hashes del código de confirmación y del token de reversión.
+type EmailChangeRequest struct {
+	ID              string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID          string     `json:"user_id" gorm:"type:uuid;not null;index"`
+	OldEmail        string     `json:"old_email" gorm:"size:255;not null"`
+	NewEmail        string     `json:"new_email" gorm:"size:255;not null"`
+	Status          string     `json:"status" gorm:"size:16;not null;index"`
+	CodeHash        string     `json:"-" gorm:"size:64;not null"`
+	Attempts        int        `json:"-" gorm:"default:0"`
+	ExpiresAt       time.Time  `json:"expires_at" gorm:"not null"`
+	RevertTokenHash string     `json:"-" gorm:"size:64"`
+	RevertExpiresAt *time.Time `json:"revert_expires_at,omitempty"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+	RevertedAt      *time.Time `json:"reverted_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// RequestEmailChangeRequest inicia el cambio de email del usuario autenticado
+type RequestEmailChangeRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email,max=255"`
+}
+
+// ConfirmEmailChangeRequest confirma el cambio con el código recibido en la nueva dirección
+type ConfirmEmailChangeRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// RevertEmailChangeRequest deshace un cambio con el token enviado a la dirección anterior
+type RevertEmailChangeRequest struct {
+	Token string `json:"token" validate:"required"`
+}
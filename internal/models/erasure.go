@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Estados de un borrado definitivo de cuenta
+const (
+	ErasureStatusScheduled = "scheduled"
+	ErasureStatusCancelled = "cancelled"
+	ErasureStatusCompleted = "completed"
+)
+
+// UserErasure programa el borrado definitivo de los datos personales de una cuenta
+// eliminada. Una vez completado se conserva como prueba de cumplimiento: no contiene
+// datos personales, sólo el ID interno de la cuenta y las fechas.
+type UserErasure struct {
+	ID           string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID       string     `json:"user_id" gorm:"type:uuid;not null;index"`
+	Status       string     `json:"status" gorm:"size:16;not null;index"`
+	Reason       string     `json:"reason,omitempty"`
+	ScheduledFor time.Time  `json:"scheduled_for" gorm:"not null;index"`
+	Attempts     int        `json:"attempts" gorm:"default:0"`
+	LastError    string     `json:"last_error,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// UserErasureTombstone es la lápida de un borrado completado. Se guarda en la misma
+// transacción que el borrado para que los sistemas downstream puedan purgar sus copias
+// aunque no estuvieran escuchando cuando se publicó el evento. Sólo contiene los
+// identificadores necesarios para encontrar esas copias.
+type UserErasureTombstone struct {
+	ErasureID   string      `json:"erasure_id" gorm:"primaryKey;type:uuid"`
+	UserID      string      `json:"user_id" gorm:"type:uuid;not null;index"`
+	FirebaseIDs StringSlice `json:"firebase_ids" gorm:"type:jsonb"`
+	ErasedAt    time.Time   `json:"erased_at" gorm:"not null;index"`
+}
+
+// ListErasureTombstonesQuery pagina las lápidas por fecha de borrado
+type ListErasureTombstonesQuery struct {
+	Since *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit int        `form:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+// UserErasedEvent es la lápida que se emite al completar un borrado para que otros
+// sistemas eliminen sus copias de los datos del usuario
+type UserErasedEvent struct {
+	Type        string    `json:"type"`
+	ErasureID   string    `json:"erasure_id"`
+	UserID      string    `json:"user_id"`
+	FirebaseIDs []string  `json:"firebase_ids"`
+	ErasedAt    time.Time `json:"erased_at"`
+}
+
+// UserErasedEventType identifica la lápida de un usuario borrado
+const UserErasedEventType = "user.erased"
@@ -0,0 +1,106 @@
+package models
+
+import "time"
+
+// Permisos que exigen las rutas de administración de grupos y roles. Los usuarios
+// con User.Role admin tienen siempre todos los permisos.
+const (
+	PermissionGroupsRead  = "groups:read"
+	PermissionGroupsWrite = "groups:write"
+	PermissionRolesRead   = "roles:read"
+	PermissionRolesWrite  = "roles:write"
+)
+
+// UserGroup agrupa usuarios y otros grupos para asignarles roles en bloque. Los
+// grupos con TenantID vacío son globales; los de un tenant (slug de la organización)
+// sólo contienen grupos del mismo tenant. Los grupos SCIM del tenant son grupos de
+// este tipo, con el externalId del IdP. SCIMID es el id con el que el IdP conoce un
+// grupo existente al que se fusionó uno de sus grupos SCIM; si está vacío es ID.
+type UserGroup struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID    string    `json:"tenant_id" gorm:"size:64;not null;default:'';uniqueIndex:idx_user_groups_tenant_name"`
+	Name        string    `json:"name" gorm:"size:255;not null;uniqueIndex:idx_user_groups_tenant_name"`
+	Description string    `json:"description,omitempty"`
+	ExternalID  string    `json:"external_id,omitempty" gorm:"size:255"`
+	SCIMID      *string   `json:"scim_id,omitempty" gorm:"type:uuid;uniqueIndex"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// UserGroupMember es la pertenencia directa de un usuario a un grupo
+type UserGroupMember struct {
+	GroupID   string    `json:"group_id" gorm:"primaryKey;type:uuid"`
+	UserID    string    `json:"user_id" gorm:"primaryKey;type:uuid;index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// UserGroupNesting hace a un grupo miembro de otro: los usuarios del hijo heredan
+// los roles del padre
+type UserGroupNesting struct {
+	ParentID  string    `json:"parent_id" gorm:"primaryKey;type:uuid"`
+	ChildID   string    `json:"child_id" gorm:"primaryKey;type:uuid;index"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// UserGroupRole asigna un rol a todos los miembros, directos o heredados, del grupo
+type UserGroupRole struct {
+	GroupID   string    `json:"group_id" gorm:"primaryKey;type:uuid"`
+	Role      string    `json:"role" gorm:"primaryKey;size:64"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// RoleDefinition define los permisos de un rol. Un rol con el mismo nombre que
+// User.Role (user, admin) se aplica también a todos los usuarios con ese rol.
+type RoleDefinition struct {
+	Name        string      `json:"name" gorm:"primaryKey;size:64"`
+	Description string      `json:"description,omitempty"`
+	Permissions StringSlice `json:"permissions" gorm:"type:jsonb"`
+	CreatedAt   time.Time   `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time   `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// UserGroupDetail es un grupo con sus miembros directos, subgrupos y roles
+type UserGroupDetail struct {
+	*UserGroup
+	MemberIDs []string     `json:"member_ids"`
+	Children  []*UserGroup `json:"children"`
+	Roles     []string     `json:"roles"`
+}
+
+// EffectivePermissions es el resultado de resolver los grupos (incluidos los
+// heredados por anidamiento), roles y permisos de un usuario
+type EffectivePermissions struct {
+	UserID      string       `json:"user_id"`
+	Groups      []*UserGroup `json:"groups"`
+	Roles       []string     `json:"roles"`
+	Permissions []string     `json:"permissions"`
+}
+
+// UserGroupRequest crea o modifica un grupo; tenant_id sólo se usa al crearlo
+type UserGroupRequest struct {
+	TenantID    string `json:"tenant_id,omitempty" validate:"max=64"`
+	Name        string `json:"name" validate:"required,max=255"`
+	Description string `json:"description,omitempty" validate:"max=1000"`
+}
+
+// AddGroupMemberRequest añade un usuario a un grupo
+type AddGroupMemberRequest struct {
+	UserID string `json:"user_id" validate:"required,uuid"`
+}
+
+// AddGroupChildRequest anida un grupo dentro de otro
+type AddGroupChildRequest struct {
+	GroupID string `json:"group_id" validate:"required,uuid"`
+}
+
+// AssignGroupRoleRequest asigna un rol a un grupo
+type AssignGroupRoleRequest struct {
+	Role string `json:"role" validate:"required,max=64"`
+}
+
+// RoleDefinitionRequest crea o reemplaza un rol
+type RoleDefinitionRequest struct {
+	Name        string   `json:"name" validate:"required,max=64"`
+	Description string   `json:"description,omitempty" validate:"max=1000"`
+	Permissions []string `json:"permissions" validate:"dive,required,max=128"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// GuestLoginRequest inicia (o retoma) una sesión de invitado con un token anónimo de Firebase
+type GuestLoginRequest struct {
+	FirebaseToken string `json:"firebase_token" validate:"required"`
+}
+
+// GuestUpgradeRequest convierte al invitado autenticado en una cuenta completa
+// vinculándole una identidad real; el ID de usuario se conserva
+type GuestUpgradeRequest struct {
+	FirebaseToken string `json:"firebase_token" validate:"required"`
+	Provider      string `json:"provider" validate:"required,oneof=google.com facebook.com password"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// UserIdentity representa una identidad externa (provider, subject) vinculada a un usuario.
+// Un usuario puede autenticarse con varias identidades: Google, Facebook, email/contraseña...
+type UserIdentity struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID     string     `json:"user_id" gorm:"not null;index"`
+	Provider   string     `json:"provider" gorm:"size:64;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject    string     `json:"subject" gorm:"size:128;not null;uniqueIndex:idx_user_identities_provider_subject"` // Firebase UID
+	Email      string     `json:"email,omitempty"`
+	LinkedAt   time.Time  `json:"linked_at" gorm:"autoCreateTime"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// UnlinkedIdentity recuerda una identidad que el usuario desvinculó para que un login
+// posterior con ella no vuelva a vincularla por su cuenta. Se borra cuando el usuario
+// la vincula de nuevo de forma explícita.
+type UnlinkedIdentity struct {
+	UserID     string    `json:"user_id" gorm:"primaryKey;type:uuid"`
+	Subject    string    `json:"subject" gorm:"primaryKey;size:128;index"`
+	Provider   string    `json:"provider" gorm:"size:64;not null"`
+	UnlinkedAt time.Time `json:"unlinked_at"`
+}
+
+// LinkIdentityRequest vincula una nueva identidad al usuario autenticado
+type LinkIdentityRequest struct {
+	FirebaseToken string `json:"firebase_token" validate:"required"`
+}
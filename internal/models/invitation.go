@@ -0,0 +1,67 @@
+package models
+
+import "time"
+
+// Estados de una invitación; expired no se guarda, se deriva de ExpiresAt
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+	InvitationStatusExpired  = "expired"
+)
+
+// Invitation invita a una persona por email con un rol global y, opcionalmente, una
+// organización. Sólo se guarda el hash del nonce del token; reenviarla lo rota.
+type Invitation struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Email      string     `json:"email" gorm:"size:255;not null;index"`
+	Role       string     `json:"role" gorm:"size:16;not null"`
+	OrgID      *string    `json:"org_id,omitempty" gorm:"type:uuid;index"`
+	OrgRole    string     `json:"org_role,omitempty" gorm:"size:16"`
+	Status     string     `json:"status" gorm:"size:16;not null;index"`
+	NonceHash  string     `json:"-" gorm:"size:64;not null"`
+	InvitedBy  string     `json:"invited_by"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null"`
+	SentAt     *time.Time `json:"sent_at,omitempty"`
+	SendCount  int        `json:"send_count" gorm:"default:0"`
+	AcceptedBy *string    `json:"accepted_by,omitempty" gorm:"type:uuid"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt  time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// CreateInvitationRequest invita a un email con un rol global y/o una organización
+type CreateInvitationRequest struct {
+	Email   string `json:"email" validate:"required,email,max=255"`
+	Role    string `json:"role,omitempty" validate:"omitempty,oneof=user admin"`
+	OrgID   string `json:"org_id,omitempty" validate:"omitempty,uuid"`
+	OrgRole string `json:"org_role,omitempty" validate:"required_with=OrgID,omitempty,oneof=owner admin member"`
+}
+
+// InviteOrganizationMemberRequest invita a un email a una organización desde la propia organización
+type InviteOrganizationMemberRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Role  string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// ListInvitationsQuery filtra el listado de invitaciones; por defecto sólo las pendientes
+type ListInvitationsQuery struct {
+	Status string `form:"status" validate:"omitempty,oneof=pending accepted revoked expired"`
+	Email  string `form:"email" validate:"omitempty,max=255"`
+	OrgID  string `form:"org_id" validate:"omitempty,uuid"`
+}
+
+// InvitationPreviewRequest consulta una invitación antes de iniciar sesión
+type InvitationPreviewRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// InvitationPreview es lo que el invitado ve antes de aceptar
+type InvitationPreview struct {
+	Email            string    `json:"email"`
+	Role             string    `json:"role"`
+	OrganizationName string    `json:"organization_name,omitempty"`
+	OrgRole          string    `json:"org_role,omitempty"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// UserStatusChangedEvent se emite tras cada transición de estado de una cuenta
+type UserStatusChangedEvent struct {
+	Type        string     `json:"type"`
+	UserID      string     `json:"user_id"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	Reason      string     `json:"reason,omitempty"`
+	ActorID     string     `json:"actor_id,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	ChangedAt   time.Time  `json:"changed_at"`
+}
+
+// UserStatusChangedEventType identifica el evento de cambio de estado
+const UserStatusChangedEventType = "user.status_changed"
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// MFAFactor representa un factor TOTP registrado por un usuario
+type MFAFactor struct {
+	ID              string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID          string     `json:"user_id" gorm:"not null;uniqueIndex"`
+	EncryptedSecret string     `json:"-" gorm:"not null"` // Secreto TOTP cifrado, nunca se expone
+	Confirmed       bool       `json:"confirmed" gorm:"default:false"`
+	ConfirmedAt     *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep    int64      `json:"-" gorm:"default:0"`          // Evita reutilizar el mismo código
+	FailedAttempts  int        `json:"-" gorm:"not null;default:0"` // Códigos erróneos seguidos, en cualquier reto
+	LockedUntil     *time.Time `json:"-"`                           // Hasta cuándo se rechaza cualquier código
+	CreatedAt       time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// MFARecoveryCode representa un código de recuperación de un solo uso
+type MFARecoveryCode struct {
+	ID        string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID    string     `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"not null;uniqueIndex"` // Hash SHA256 del código
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// MFAChallenge representa la segunda fase pendiente de un login con MFA
+type MFAChallenge struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID     string     `json:"user_id" gorm:"not null;index"`
+	TokenHash  string     `json:"-" gorm:"not null;uniqueIndex"`
+	Provider   string     `json:"provider"`
+	Enrollment bool       `json:"enrollment" gorm:"default:false"` // El usuario debe registrar MFA antes de continuar
+	Attempts   int        `json:"attempts" gorm:"default:0"`
+	AuthTime   time.Time  `json:"auth_time" gorm:"not null;default:now()"` // Cuándo se superó el primer factor
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null;index"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// MFAEnrollRequest inicia el registro de TOTP; ChallengeToken se usa cuando
+// el registro es obligatorio y el usuario todavía no tiene JWT
+type MFAEnrollRequest struct {
+	ChallengeToken string `json:"challenge_token,omitempty"`
+}
+
+// MFAEnrollResponse contiene el secreto a mostrar al usuario (QR o manual)
+type MFAEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// MFAConfirmRequest confirma el registro con un primer código válido
+type MFAConfirmRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// MFAConfirmResponse devuelve los códigos de recuperación en texto plano (una sola vez)
+type MFAConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAVerifyRequest completa la segunda fase del login
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code,omitempty" validate:"omitempty,len=6,numeric"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+// MFADisableRequest desactiva MFA tras verificar un código vigente
+type MFADisableRequest struct {
+	Code         string `json:"code,omitempty" validate:"omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
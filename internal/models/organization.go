@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Roles de un usuario dentro de una organización
+const (
+	OrgRoleOwner  = "owner"  // Gestiona la organización y sus miembros, incluidos otros owners
+	OrgRoleAdmin  = "admin"  // Gestiona los miembros que no son owners
+	OrgRoleMember = "member" // Sólo pertenece a la organización
+)
+
+// Organization es un cliente (tenant) del servicio. Su slug es el identificador de
+// tenant que se usa en los JWT, en los tokens SCIM y en el esquema de atributos.
+type Organization struct {
+	ID   string `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Slug string `json:"slug" gorm:"size:64;not null;uniqueIndex"`
+	Name string `json:"name" gorm:"size:255;not null"`
+	// Los miembros no se avisan ni se desactivan por inactividad
+	DormancyExempt bool      `json:"dormancy_exempt" gorm:"default:false"`
+	RequireMFA     bool      `json:"require_mfa" gorm:"column:require_mfa;default:false"` // Los miembros deben usar MFA, sea cual sea su rol global
+	CreatedBy      string    `json:"created_by,omitempty"`
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// OrganizationMembership es la pertenencia de un usuario a una organización con un
+// rol propio de esa organización. Una misma cuenta puede pertenecer a varias.
+type OrganizationMembership struct {
+	OrgID        string        `json:"org_id" gorm:"primaryKey;type:uuid"`
+	UserID       string        `json:"user_id" gorm:"primaryKey;type:uuid;index"`
+	Role         string        `json:"role" gorm:"size:16;not null"`
+	CreatedAt    time.Time     `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+	Organization *Organization `json:"organization,omitempty" gorm:"foreignKey:OrgID"`
+}
+
+// CreateOrganizationRequest crea una organización y, opcionalmente, su primer owner
+type CreateOrganizationRequest struct {
+	Slug           string `json:"slug" validate:"required,max=64"`
+	Name           string `json:"name" validate:"required,max=255"`
+	OwnerUserID    string `json:"owner_user_id,omitempty" validate:"omitempty,uuid"`
+	DormancyExempt bool   `json:"dormancy_exempt,omitempty"`
+	RequireMFA     bool   `json:"require_mfa,omitempty"`
+}
+
+// UpdateOrganizationRequest renombra una organización; el slug no cambia. Sin
+// dormancy_exempt o require_mfa se conserva el valor actual.
+type UpdateOrganizationRequest struct {
+	Name           string `json:"name" validate:"required,max=255"`
+	DormancyExempt *bool  `json:"dormancy_exempt,omitempty"`
+	RequireMFA     *bool  `json:"require_mfa,omitempty"`
+}
+
+// AddOrganizationMemberRequest añade un usuario existente, por ID o por email
+type AddOrganizationMemberRequest struct {
+	UserID string `json:"user_id,omitempty" validate:"required_without=Email,omitempty,uuid"`
+	Email  string `json:"email,omitempty" validate:"required_without=UserID,omitempty,email"`
+	Role   string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// UpdateOrganizationMemberRequest cambia el rol de un miembro
+type UpdateOrganizationMemberRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner admin member"`
+}
+
+// SwitchOrganizationRequest elige la organización con la que se emite el token
+type SwitchOrganizationRequest struct {
+	OrgID string `json:"org_id" validate:"required,uuid"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// UpdateProfileRequest contiene los campos del perfil que puede modificar el propio
+// usuario. Los campos nil no se modifican; una cadena vacía borra el valor.
+type UpdateProfileRequest struct {
+	Username  *string `json:"username,omitempty" validate:"omitempty,username"`
+	FirstName *string `json:"first_name,omitempty" validate:"omitempty,max=100"`
+	LastName  *string `json:"last_name,omitempty" validate:"omitempty,max=100"`
+	PhotoURL  *string `json:"photo_url,omitempty" validate:"omitempty,max=2048,photourl"`
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// URNs de los esquemas SCIM 2.0 (RFC 7643 / RFC 7644)
+const (
+	SCIMSchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMSchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMSchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMSchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMSchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMSchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCIMSchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SCIMSchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// SCIMContentType es el media type de las respuestas SCIM
+const SCIMContentType = "application/scim+json"
+
+// SCIMToken es un bearer token con el que el IdP de un tenant llama a la API SCIM.
+// Sólo se guarda el hash; el token en claro se muestra una única vez al crearlo.
+type SCIMToken struct {
+	ID         string     `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	TenantID   string     `json:"tenant_id" gorm:"size:64;not null;index"`
+	Name       string     `json:"name" gorm:"size:100;not null"`
+	TokenHash  string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	CreatedBy  string     `json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// SCIMUserLink vincula un usuario con el tenant que lo aprovisionó. Cada tenant sólo
+// ve por SCIM los usuarios que ha creado, con su propio userName y externalId.
+type SCIMUserLink struct {
+	TenantID   string    `gorm:"primaryKey;size:64;uniqueIndex:idx_scim_user_links_tenant_user_name"`
+	UserID     string    `gorm:"primaryKey;type:uuid"`
+	UserName   string    `gorm:"size:320;not null;uniqueIndex:idx_scim_user_links_tenant_user_name"`
+	ExternalID string    `gorm:"size:255;index"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// CreateSCIMTokenRequest crea un token SCIM para un tenant
+type CreateSCIMTokenRequest struct {
+	TenantID string `json:"tenant_id" validate:"required,max=64"`
+	Name     string `json:"name" validate:"required,max=100"`
+}
+
+// CreateSCIMTokenResponse devuelve el token en claro junto con sus metadatos
+type CreateSCIMTokenResponse struct {
+	Token string     `json:"token"`
+	SCIM  *SCIMToken `json:"scim_token"`
+}
+
+// SCIMMeta son los metadatos comunes de un recurso SCIM
+type SCIMMeta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+	Version      string     `json:"version,omitempty"`
+}
+
+// SCIMName es el atributo complejo name del esquema User
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// SCIMMultiValue es un valor de un atributo multivaluado (emails, photos, members)
+type SCIMMultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// SCIMUserResource es la representación SCIM de models.User
+type SCIMUserResource struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	UserName    string           `json:"userName"`
+	Name        *SCIMName        `json:"name,omitempty"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Emails      []SCIMMultiValue `json:"emails,omitempty"`
+	Photos      []SCIMMultiValue `json:"photos,omitempty"`
+	Active      *bool            `json:"active,omitempty"`
+	Meta        *SCIMMeta        `json:"meta,omitempty"`
+}
+
+// SCIMGroupResource es la representación SCIM de un grupo
+type SCIMGroupResource struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	DisplayName string           `json:"displayName"`
+	Members     []SCIMMultiValue `json:"members,omitempty"`
+	Meta        *SCIMMeta        `json:"meta,omitempty"`
+}
+
+// SCIMListResponse es la respuesta paginada de una búsqueda SCIM
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	StartIndex   int         `json:"startIndex"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// SCIMPatchRequest es el cuerpo de un PATCH SCIM
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations" validate:"required,min=1"`
+}
+
+// SCIMPatchOperation es una operación add, replace o remove
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SCIMListQuery son los parámetros de búsqueda de una colección SCIM
+type SCIMListQuery struct {
+	Filter     string `form:"filter"`
+	StartIndex int    `form:"startIndex"`
+	Count      *int   `form:"count"`
+}
+
+// SCIMError es el cuerpo de error definido por SCIM
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
@@ -27,6 +27,9 @@ type UserSession struct {
 	IPAddress  string     `json:"ip_address"`
 	UserAgent  string     `json:"user_agent"`
 	Provider   string     `json:"provider"` // google.com, facebook.com, etc.
+	AuthTime   time.Time  `json:"auth_time"`
+	AMR        string     `json:"amr"` // Métodos separados por comas: pwd,otp,mfa
+	ACR        string     `json:"acr"` // aal1, aal2
 	IsActive   bool       `json:"is_active" gorm:"default:true"`
 	LastSeenAt time.Time  `json:"last_seen_at" gorm:"autoUpdateTime"`
 	
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Ordenaciones admitidas en el listado de usuarios; el prefijo "-" indica descendente
+const (
+	UserSortCreatedAt   = "created_at"
+	UserSortLastLoginAt = "last_login_at"
+	UserSortEmail       = "email"
+	UserSortUsername    = "username"
+)
+
+// Expresiones SQL del listado de usuarios. Deben coincidir exactamente con las de
+// los índices que crea la migración para que Postgres pueda usarlos.
+const (
+	// Búsqueda libre, indexada con pg_trgm (idx_users_search_trgm)
+	UserSearchExpression = "(email || ' ' || coalesce(username, '') || ' ' || coalesce(first_name, '') || ' ' || coalesce(last_name, ''))"
+	// Los usuarios sin login se ordenan como los más antiguos (idx_users_last_login_id)
+	UserLastLoginExpression = "coalesce(last_login_at, '1970-01-01T00:00:00Z'::timestamptz)"
+	// Dominio del email (idx_users_email_domain)
+	UserEmailDomainExpression = "lower(split_part(email, '@', 2))"
+)
+
+// ListUsersQuery son los filtros, la búsqueda y la paginación por cursor del listado de usuarios
+type ListUsersQuery struct {
+	Status          string     `form:"status"` // Uno o varios separados por comas; por defecto excluye pending_deletion y deleted
+	Provider        string     `form:"provider"`
+	EmailDomain     string     `form:"email_domain"`
+	EmailVerified   *bool      `form:"email_verified"`
+	CreatedAfter    *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore   *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	LastLoginAfter  *time.Time `form:"last_login_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	LastLoginBefore *time.Time `form:"last_login_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	OrgID           string     `form:"org_id" validate:"omitempty,uuid"` // Sólo miembros de la organización
+	ScopeOrgID      string     `form:"-"`                                // Organización impuesta por el servidor al administrador de un tenant; nunca viene de la petición
+	Query           string     `form:"q" validate:"max=100"`
+	Sort            string     `form:"sort" validate:"omitempty,oneof=created_at -created_at last_login_at -last_login_at email -email username -username"`
+	Limit           int        `form:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor          string     `form:"cursor"`
+	IncludeTotal    bool       `form:"include_total"`
+}
+
+// ListUsersResult es una página del listado; NextCursor vacío indica que no hay más
+type ListUsersResult struct {
+	Users      []*User `json:"users"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      *int64  `json:"total,omitempty"`
+}
+
+// OrganizationMemberList es una página de miembros de una organización con el rol
+// de cada uno, indexado por ID de usuario
+type OrganizationMemberList struct {
+	*ListUsersResult
+	Roles map[string]string `json:"roles"`
+}
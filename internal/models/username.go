@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// UsernameHistory guarda los usernames que ha dejado de usar una cuenta. Mientras no
+// pase HeldUntil nadie más puede tomarlos, para evitar suplantaciones.
+type UsernameHistory struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	UserID        string    `json:"user_id" gorm:"not null;index"`
+	Username      string    `json:"username" gorm:"not null"`
+	UsernameLower string    `json:"-" gorm:"not null;index"`
+	ChangedAt     time.Time `json:"changed_at" gorm:"autoCreateTime;index"`
+	HeldUntil     time.Time `json:"held_until" gorm:"not null;index"`
+}
+
+// ReservedUsername es un nombre bloqueado desde administración, además de la lista
+// fija del servicio (admin, support...)
+type ReservedUsername struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:64"` // En minúsculas
+	Reason    string    `json:"reason,omitempty"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// ReserveUsernameRequest bloquea un username desde administración
+type ReserveUsernameRequest struct {
+	Name   string `json:"name" validate:"required,max=64"`
+	Reason string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// UsernameAvailabilityResponse indica si un username puede usarse y, si no, por qué
+type UsernameAvailabilityResponse struct {
+	Username  string `json:"username"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"` // invalid, reserved, taken
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"it-auth-service/internal/logger"
+)
+
+// runPeriodic ejecuta fn cada interval hasta que se cancele el contexto
+func runPeriodic(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	log := logger.GetLogger().WithField("job", name)
+	if interval <= 0 {
+		log.Info("Background job disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := fn(ctx); err != nil {
+					log.WithError(err).Error("Background job failed")
+				}
+			}
+		}
+	}()
+
+	log.WithField("interval", interval.String()).Info("Background job scheduled")
+}
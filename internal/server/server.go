@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
 	"time"
 
@@ -10,7 +12,11 @@ import (
 	"it-auth-service/internal/database"
 	"it-auth-service/internal/handlers"
 	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
 	"it-auth-service/internal/services"
+	"it-auth-service/pkg/firebase"
+	"it-auth-service/pkg/mailer"
+	"it-auth-service/pkg/storage"
 )
 
 type Server struct {
@@ -19,6 +25,29 @@ type Server struct {
 	firebaseAuthService *services.FirebaseAuthService
 	userService         *services.UserService
 	tokenService        *services.TokenService
+	mfaService          *services.MFAService
+	identityService     *services.IdentityService
+	auditService        *services.AuditService
+	claimsSyncService   *services.ClaimsSyncService
+	guestService        *services.GuestService
+	userAdminService    *services.UserAdminService
+	userBulkService     *services.UserBulkService
+	scimService         *services.SCIMService
+	dataExportService   *services.DataExportService
+	erasureService      *services.ErasureService
+	usernameService     *services.UsernameService
+	attributeService    *services.AttributeService
+	organizationService *services.OrganizationService
+	groupService        *services.GroupService
+	invitationService   *services.InvitationService
+	emailChangeService  *services.EmailChangeService
+	avatarService       *services.AvatarService
+	dormancyService     *services.DormancyService
+	consentService      *services.ConsentService
+	domainPolicyService *services.DomainPolicyService
+	disposableEmails    *services.DisposableEmailService
+	blobStorage         storage.Storage
+	stopJobs            context.CancelFunc
 }
 
 func NewServer(cfg *config.Config) (*Server, error) {
@@ -45,14 +74,88 @@ func NewServer(cfg *config.Config) (*Server, error) {
 	db := database.GetDB()
 
 	// Inicializar servicios
-	userService := services.NewUserService(db)
+	usernameService := services.NewUsernameService(db, cfg)
+	userService := services.NewUserService(db, usernameService)
 	tokenService := services.NewTokenService(db)
-	firebaseAuthService, err := services.NewFirebaseAuthService(cfg, userService, tokenService)
+	mfaService, err := services.NewMFAService(db, cfg)
+	if err != nil {
+		log.WithError(err).Error("MFA service initialization failed")
+		return nil, fmt.Errorf("mfa service initialization failed: %w", err)
+	}
+	identityService := services.NewIdentityService(db)
+	auditService := services.NewAuditService(db)
+	lifecycleService := services.NewLifecycleService(db, userService, auditService)
+	mergeService := services.NewAccountMergeService(db, identityService, auditService)
+
+	// Cliente de administración de Firebase (custom claims, limpieza de invitados)
+	firebaseAdmin, err := firebase.NewAuthFromEnv()
+	if err != nil {
+		log.WithError(err).Error("Firebase admin client initialization failed")
+		return nil, fmt.Errorf("firebase admin client initialization failed: %w", err)
+	}
+
+	// Lista local de dominios de email desechables que se rechazan en las altas
+	disposableEmails, err := services.NewDisposableEmailService(cfg)
+	if err != nil {
+		log.WithError(err).Error("Disposable email list initialization failed")
+		return nil, fmt.Errorf("disposable email list initialization failed: %w", err)
+	}
+
+	guestService := services.NewGuestService(db, identityService, usernameService, lifecycleService, firebaseAdmin)
+	userAdminService := services.NewUserAdminService(db, userService, tokenService, identityService, auditService, lifecycleService, firebaseAdmin)
+	userBulkService := services.NewUserBulkService(db, userService, identityService, auditService, lifecycleService, firebaseAdmin, disposableEmails)
+	groupService := services.NewGroupService(db, auditService, cfg)
+	scimService := services.NewSCIMService(db, userService, userAdminService, identityService, auditService, groupService, lifecycleService, firebaseAdmin, disposableEmails, cfg)
+	dataExportService := services.NewDataExportService(db, auditService, cfg)
+	erasureService := services.NewErasureService(db, auditService, lifecycleService, firebaseAdmin, cfg)
+	attributeService := services.NewAttributeService(db, userService, auditService)
+	organizationService := services.NewOrganizationService(db, userService, auditService)
+	mail := mailer.NewMailer(cfg.MailConfig)
+	invitationService := services.NewInvitationService(db, userService, auditService, mail, cfg)
+	emailChangeService := services.NewEmailChangeService(db, userService, userAdminService, auditService, firebaseAdmin, mail, cfg)
+	dormancyService := services.NewDormancyService(db, lifecycleService, auditService, mail, cfg)
+	consentService := services.NewConsentService(db, auditService)
+	domainPolicyService := services.NewDomainPolicyService(db, auditService)
+
+	// Almacenamiento de ficheros; el backend local firma sus URLs con una clave propia
+	storageKey := sha256.Sum256([]byte("storage:" + cfg.JWTSecret))
+	blobStorage, err := storage.New(cfg.StorageConfig, storageKey[:])
+	if err != nil {
+		log.WithError(err).Error("Storage initialization failed")
+		return nil, fmt.Errorf("storage initialization failed: %w", err)
+	}
+	avatarService := services.NewAvatarService(db, userService, auditService, blobStorage, cfg)
+	firebaseAuthService, err := services.NewFirebaseAuthService(cfg, userService, tokenService, mfaService, identityService, mergeService, guestService, attributeService, organizationService, groupService, invitationService, lifecycleService, dormancyService, consentService, domainPolicyService, disposableEmails)
 	if err != nil {
 		log.WithError(err).Error("Firebase Auth service initialization failed")
 		return nil, fmt.Errorf("firebase auth service initialization failed: %w", err)
 	}
 
+	// Sincronización de roles y estado con los custom claims de Firebase
+	var claimsSyncService *services.ClaimsSyncService
+	if cfg.ClaimsSyncConfig.Enabled {
+		claimsSyncService = services.NewClaimsSyncService(db, firebaseAdmin, identityService, organizationService, cfg)
+		userService.OnUserChanged(claimsSyncService.OnUserChanged)
+	}
+
+	// Los permisos en caché dependen del rol del usuario
+	userService.OnUserChanged(groupService.OnUserChanged)
+
+	// Borrado definitivo de las cuentas eliminadas; la lápida se guarda con el borrado
+	// (GET /admin/erasures/tombstones) y además se publica en el log estructurado para
+	// que los consumidores downstream purguen sus copias
+	lifecycleService.OnStatusChanged(erasureService.OnStatusChanged)
+	erasureService.OnUserErased(avatarService.OnUserErased)
+	erasureService.OnUserErased(func(_ context.Context, event *models.UserErasedEvent) {
+		log.WithFields(map[string]interface{}{
+			"event":        event.Type,
+			"erasure_id":   event.ErasureID,
+			"user_id":      event.UserID,
+			"firebase_ids": event.FirebaseIDs,
+			"erased_at":    event.ErasedAt,
+		}).Info("User erased tombstone")
+	})
+
 	// Crear router de Gin
 	router := gin.New()
 	router.Use(gin.Logger())
@@ -70,7 +173,7 @@ func NewServer(cfg *config.Config) (*Server, error) {
 			},
 			AllowHeaders: []string{
 				"Origin", "Content-Type", "Accept", "Authorization", 
-				"X-Requested-With", "X-User-ID", "X-Is-Admin",
+				"X-Requested-With", "X-Is-Admin",
 			},
 			ExposeHeaders: []string{
 				"Content-Length", "Content-Type",
@@ -88,7 +191,7 @@ func NewServer(cfg *config.Config) (*Server, error) {
 			},
 			AllowHeaders: []string{
 				"Origin", "Content-Type", "Accept", "Authorization", 
-				"X-Requested-With", "X-User-ID", "X-Is-Admin",
+				"X-Requested-With", "X-Is-Admin",
 			},
 			ExposeHeaders: []string{
 				"Content-Length", "Content-Type",
@@ -106,15 +209,130 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		firebaseAuthService: firebaseAuthService,
 		userService:         userService,
 		tokenService:        tokenService,
+		mfaService:          mfaService,
+		identityService:     identityService,
+		auditService:        auditService,
+		claimsSyncService:   claimsSyncService,
+		guestService:        guestService,
+		userAdminService:    userAdminService,
+		userBulkService:     userBulkService,
+		scimService:         scimService,
+		dataExportService:   dataExportService,
+		erasureService:      erasureService,
+		usernameService:     usernameService,
+		attributeService:    attributeService,
+		organizationService: organizationService,
+		groupService:        groupService,
+		invitationService:   invitationService,
+		emailChangeService:  emailChangeService,
+		avatarService:       avatarService,
+		dormancyService:     dormancyService,
+		consentService:      consentService,
+		domainPolicyService: domainPolicyService,
+		disposableEmails:    disposableEmails,
+		blobStorage:         blobStorage,
 	}
 
 	server.setupRoutes()
+	server.startJobs()
 	return server, nil
 }
 
 func (s *Server) setupRoutes() {
 	// Configurar las rutas usando nuestros handlers de Gin
-	handlers.SetupRoutes(s.router, s.firebaseAuthService, s.userService, s.tokenService)
+	handlers.SetupRoutes(s.router, handlers.Services{
+		Config:           s.config,
+		FirebaseAuth:     s.firebaseAuthService,
+		User:             s.userService,
+		Token:            s.tokenService,
+		MFA:              s.mfaService,
+		Identity:         s.identityService,
+		UserAdmin:        s.userAdminService,
+		UserBulk:         s.userBulkService,
+		SCIM:             s.scimService,
+		DataExport:       s.dataExportService,
+		Erasure:          s.erasureService,
+		Username:         s.usernameService,
+		Attributes:       s.attributeService,
+		Organization:     s.organizationService,
+		Groups:           s.groupService,
+		Invitations:      s.invitationService,
+		EmailChange:      s.emailChangeService,
+		Avatars:          s.avatarService,
+		Dormancy:         s.dormancyService,
+		Consents:         s.consentService,
+		DomainPolicies:   s.domainPolicyService,
+		DisposableEmails: s.disposableEmails,
+		Blobs:            s.blobStorage,
+	})
+}
+
+// startJobs arranca los jobs periódicos en segundo plano; se detienen en Close
+func (s *Server) startJobs() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopJobs = cancel
+
+	if s.claimsSyncService != nil {
+		s.claimsSyncService.Start(ctx)
+		runPeriodic(ctx, "firebase_claims_reconcile", s.config.ClaimsSyncConfig.ReconcileInterval, func(ctx context.Context) error {
+			_, err := s.claimsSyncService.Reconcile(ctx)
+			return err
+		})
+	}
+
+	runPeriodic(ctx, "guest_gc", s.config.GuestConfig.GCInterval, func(ctx context.Context) error {
+		_, err := s.guestService.PurgeStaleGuests(ctx, s.config.GuestConfig.TTL)
+		return err
+	})
+
+	// Las exportaciones que estaban en marcha al reiniciar el servicio no terminarán nunca
+	go func() {
+		if _, err := s.dataExportService.FailStale(ctx); err != nil {
+			logger.GetLogger().WithError(err).Error("Failed to fail stale data exports")
+		}
+	}()
+	runPeriodic(ctx, "data_export_gc", s.config.DataExportConfig.GCInterval, func(ctx context.Context) error {
+		if _, err := s.dataExportService.FailStale(ctx); err != nil {
+			return err
+		}
+		_, err := s.dataExportService.PurgeExpired(ctx)
+		return err
+	})
+
+	// Los imports que estaban en marcha al reiniciar el servicio no pueden reanudarse
+	go func() {
+		if _, err := s.userBulkService.FailStaleImports(ctx, s.config.BulkImportConfig.StaleAfter); err != nil {
+			logger.GetLogger().WithError(err).Error("Failed to fail stale import jobs")
+		}
+	}()
+	runPeriodic(ctx, "user_import_sweep", s.config.BulkImportConfig.SweepInterval, func(ctx context.Context) error {
+		_, err := s.userBulkService.FailStaleImports(ctx, s.config.BulkImportConfig.StaleAfter)
+		return err
+	})
+
+	runPeriodic(ctx, "user_erasure", s.config.ErasureConfig.Interval, func(ctx context.Context) error {
+		_, err := s.erasureService.ProcessDue(ctx)
+		return err
+	})
+
+	runPeriodic(ctx, "user_dormancy", s.config.DormancyConfig.Interval, func(ctx context.Context) error {
+		_, err := s.dormancyService.Run(ctx)
+		return err
+	})
+
+	if s.config.DisposableEmailConfig.DomainsFile != "" {
+		runPeriodic(ctx, "disposable_email_reload", s.config.DisposableEmailConfig.ReloadInterval, func(context.Context) error {
+			_, err := s.disposableEmails.Reload()
+			return err
+		})
+	}
+
+	// Las cuentas anteriores al email canónico lo reciben una sola vez, en segundo plano
+	go func() {
+		if _, err := s.userService.BackfillCanonicalEmails(ctx); err != nil {
+			logger.GetLogger().WithError(err).Error("Failed to backfill canonical emails")
+		}
+	}()
 }
 
 func (s *Server) Start() error {
@@ -127,5 +345,8 @@ func (s *Server) Start() error {
 }
 
 func (s *Server) Close() error {
+	if s.stopJobs != nil {
+		s.stopJobs()
+	}
 	return database.Close()
 }
\ No newline at end of file
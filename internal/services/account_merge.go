@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+const accountMergeTTL = 15 * time.Minute
+
+var (
+	ErrInvalidMergeToken  = errors.New("invalid or expired merge token")
+	ErrMergeProofMismatch = errors.New("proof token does not belong to the account being merged")
+)
+
+// AccountMergeService gestiona las colisiones de email entre un login nuevo y una cuenta existente
+type AccountMergeService struct {
+	db         *gorm.DB
+	identities *IdentityService
+	audit      *AuditService
+	logger     *logrus.Logger
+}
+
+func NewAccountMergeService(db *gorm.DB, identityService *IdentityService, auditService *AuditService) *AccountMergeService {
+	return &AccountMergeService{
+		db:         db,
+		identities: identityService,
+		audit:      auditService,
+		logger:     logger.GetLogger(),
+	}
+}
+
+// LinkVerifiedIdentity vincula a la cuenta existente una identidad cuyo email ha
+// verificado el proveedor. La vinculación y su auditoría se hacen en la misma transacción.
+func (s *AccountMergeService) LinkVerifiedIdentity(ctx context.Context, user *models.User, provider, subject, email string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Una identidad que el usuario desvinculó sólo vuelve con una vinculación explícita
+		unlinked, err := isUnlinkedTx(tx, user.ID, subject)
+		if err != nil {
+			return err
+		}
+		if unlinked {
+			return ErrIdentityUnlinked
+		}
+		if _, err := s.identities.linkIdentityTx(tx, user.ID, provider, subject, email); err != nil {
+			return err
+		}
+
+		if !user.EmailVerified {
+			if err := tx.Model(user).Update("email_verified", true).Error; err != nil {
+				return err
+			}
+		}
+
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID: user.ID,
+			Action: models.AuditActionIdentityLinked,
+			Details: models.JSONMap{
+				"provider": provider,
+				"subject":  subject,
+				"email":    email,
+				"reason":   "email_verified",
+			},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrIdentityUnlinked) {
+			return err
+		}
+		s.logger.WithError(err).Error("Failed to link verified identity")
+		return fmt.Errorf("failed to link verified identity: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":  user.ID,
+		"provider": provider,
+	}).Info("Identity linked to existing account by verified email")
+
+	return nil
+}
+
+// CreateRequest registra una vinculación pendiente y devuelve el token opaco para el cliente
+func (s *AccountMergeService) CreateRequest(ctx context.Context, userID, provider, subject, email string) (string, error) {
+	request, token, err := newAccountMergeRequest(userID, provider, subject, email)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID: userID,
+			Action: models.AuditActionMergeRequested,
+			Details: models.JSONMap{
+				"provider": provider,
+				"subject":  subject,
+				"email":    email,
+			},
+		})
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create account merge request")
+		return "", fmt.Errorf("failed to create account merge request: %w", err)
+	}
+
+	return token, nil
+}
+
+// newAccountMergeRequest prepara la vinculación pendiente; sólo se guarda el hash del token
+func newAccountMergeRequest(userID, provider, subject, email string) (*models.AccountMergeRequest, string, error) {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &models.AccountMergeRequest{
+		UserID:    userID,
+		Subject:   subject,
+		Provider:  provider,
+		Email:     email,
+		TokenHash: sha256Hex(token),
+		ExpiresAt: time.Now().Add(accountMergeTTL),
+	}, token, nil
+}
+
+// GetPending devuelve una vinculación pendiente y vigente
+func (s *AccountMergeService) GetPending(ctx context.Context, token string) (*models.AccountMergeRequest, error) {
+	var request models.AccountMergeRequest
+	err := s.db.WithContext(ctx).
+		Where("token_hash = ? AND completed_at IS NULL AND expires_at > ?", sha256Hex(token), time.Now()).
+		First(&request).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMergeToken
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return &request, nil
+}
+
+// Complete vincula la identidad pendiente a la cuenta existente una vez demostrado su control
+func (s *AccountMergeService) Complete(ctx context.Context, request *models.AccountMergeRequest, proofSubject string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.AccountMergeRequest{}).
+			Where("id = ? AND completed_at IS NULL", request.ID).
+			Update("completed_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMergeToken
+		}
+
+		if _, err := s.identities.linkIdentityTx(tx, request.UserID, request.Provider, request.Subject, request.Email); err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:  request.UserID,
+			ActorID: request.UserID,
+			Action:  models.AuditActionAccountMerged,
+			Details: models.JSONMap{
+				"provider":      request.Provider,
+				"subject":       request.Subject,
+				"email":         request.Email,
+				"proof_subject": proofSubject,
+			},
+		})
+	})
+	if err != nil {
+		if !errors.Is(err, ErrInvalidMergeToken) && !errors.Is(err, ErrIdentityLinkedToOtherUser) {
+			s.logger.WithError(err).Error("Failed to complete account merge")
+		}
+		return err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":  request.UserID,
+		"provider": request.Provider,
+	}).Info("Account merge completed")
+
+	return nil
+}
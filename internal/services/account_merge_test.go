@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/models"
+)
+
+func TestNewAccountMergeRequest(t *testing.T) {
+	request, token, err := newAccountMergeRequest("user-1", "google.com", "uid-new", "victim@example.com")
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, token)
+	assert.Equal(t, sha256Hex(token), request.TokenHash, "only the hash of the token is stored")
+	assert.NotContains(t, request.TokenHash, token)
+	assert.Equal(t, "uid-new", request.Subject)
+	assert.WithinDuration(t, time.Now().Add(accountMergeTTL), request.ExpiresAt, time.Second)
+
+	_, other, err := newAccountMergeRequest("user-1", "google.com", "uid-new", "victim@example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestCheckMergeProof(t *testing.T) {
+	request := &models.AccountMergeRequest{UserID: "user-1"}
+	owner := &models.User{ID: "user-1", Status: models.StatusActive}
+	recent := models.AuthContext{AuthTime: time.Now().Add(-time.Minute)}
+	window := 5 * time.Minute
+
+	assert.NoError(t, checkMergeProof(request, owner, recent, window))
+
+	// El login de prueba tiene que ser de la cuenta que recibe la identidad
+	other := &models.User{ID: "user-2", Status: models.StatusActive}
+	assert.ErrorIs(t, checkMergeProof(request, other, recent, window), ErrMergeProofMismatch)
+
+	stale := models.AuthContext{AuthTime: time.Now().Add(-time.Hour)}
+	assert.ErrorIs(t, checkMergeProof(request, owner, stale, window), ErrStaleReauthentication)
+
+	suspended := &models.User{ID: "user-1", Status: models.StatusSuspended}
+	assert.ErrorIs(t, checkMergeProof(request, suspended, recent, window), ErrAccountSuspended)
+}
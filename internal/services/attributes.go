@@ -0,0 +1,467 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+// attributeKeyPattern: claves en snake_case, válidas también como nombre de claim
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+var (
+	ErrAttributeDefinitionNotFound = errors.New("attribute definition not found")
+	ErrAttributeDefinitionExists   = errors.New("an attribute with this key is already defined for the tenant")
+	ErrInvalidAttributeDefinition  = errors.New("invalid attribute definition")
+	ErrInvalidAttributes           = errors.New("invalid attributes")
+)
+
+// AttributeService gestiona los atributos personalizados de los usuarios y las
+// definiciones (tipo, validación y visibilidad) que los administradores crean para ellos
+type AttributeService struct {
+	db     *gorm.DB
+	users  *UserService
+	audit  *AuditService
+	logger *logrus.Logger
+}
+
+func NewAttributeService(db *gorm.DB, userService *UserService, auditService *AuditService) *AttributeService {
+	return &AttributeService{
+		db:     db,
+		users:  userService,
+		audit:  auditService,
+		logger: logger.GetLogger(),
+	}
+}
+
+// ListDefinitions devuelve las definiciones, opcionalmente sólo las de un tenant
+// ("" para las globales)
+func (s *AttributeService) ListDefinitions(ctx context.Context, tenantID *string) ([]*models.AttributeDefinition, error) {
+	query := s.db.WithContext(ctx).Order("tenant_id ASC, key ASC")
+	if tenantID != nil {
+		query = query.Where("tenant_id = ?", *tenantID)
+	}
+
+	var definitions []*models.AttributeDefinition
+	if err := query.Find(&definitions).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return definitions, nil
+}
+
+// CreateDefinition añade un atributo al esquema de un tenant (o al global)
+func (s *AttributeService) CreateDefinition(ctx context.Context, actor AdminActor, req *models.AttributeDefinitionRequest) (*models.AttributeDefinition, error) {
+	definition := &models.AttributeDefinition{}
+	if err := applyAttributeDefinition(definition, req); err != nil {
+		return nil, err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(definition).Error; err != nil {
+			return err
+		}
+		return s.recordSchemaChange(tx, actor, "created", definition)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrAttributeDefinitionExists
+		}
+		return nil, fmt.Errorf("failed to create attribute definition: %w", err)
+	}
+	return definition, nil
+}
+
+// UpdateDefinition reemplaza una definición. El tenant y la clave no pueden cambiar;
+// los valores ya guardados que no cumplan las nuevas reglas se conservan hasta el
+// siguiente cambio de ese usuario.
+func (s *AttributeService) UpdateDefinition(ctx context.Context, actor AdminActor, id string, req *models.AttributeDefinitionRequest) (*models.AttributeDefinition, error) {
+	var definition models.AttributeDefinition
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", id).First(&definition).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAttributeDefinitionNotFound
+			}
+			return err
+		}
+		if req.TenantID != definition.TenantID || req.Key != definition.Key {
+			return fmt.Errorf("%w: tenant_id and key cannot be changed", ErrInvalidAttributeDefinition)
+		}
+		if err := applyAttributeDefinition(&definition, req); err != nil {
+			return err
+		}
+		if err := tx.Save(&definition).Error; err != nil {
+			return err
+		}
+		return s.recordSchemaChange(tx, actor, "updated", &definition)
+	})
+	if err != nil {
+		if errors.Is(err, ErrAttributeDefinitionNotFound) || errors.Is(err, ErrInvalidAttributeDefinition) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update attribute definition: %w", err)
+	}
+	return &definition, nil
+}
+
+// DeleteDefinition elimina una definición. Los valores guardados dejan de exponerse
+// y se descartan la próxima vez que se editen los atributos del usuario.
+func (s *AttributeService) DeleteDefinition(ctx context.Context, actor AdminActor, id string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var definition models.AttributeDefinition
+		if err := tx.Where("id = ?", id).First(&definition).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAttributeDefinitionNotFound
+			}
+			return err
+		}
+		if err := tx.Delete(&definition).Error; err != nil {
+			return err
+		}
+		return s.recordSchemaChange(tx, actor, "deleted", &definition)
+	})
+}
+
+// GetAttributes devuelve los atributos del usuario y su esquema, filtrados según lo
+// que puede ver quien pregunta (el propio usuario o un administrador)
+func (s *AttributeService) GetAttributes(ctx context.Context, userID string, admin bool) (map[string]interface{}, []*models.AttributeDefinition, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrUserNotFound
+		}
+		return nil, nil, fmt.Errorf("database error: %w", err)
+	}
+
+	schema, err := s.schemaForUser(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]interface{})
+	var visible []*models.AttributeDefinition
+	for _, key := range sortedAttributeKeys(schema) {
+		definition := schema[key]
+		if !admin && definition.Visibility == models.AttributeVisibilityAdminOnly {
+			continue
+		}
+		visible = append(visible, definition)
+		if value, ok := user.Attributes[key]; ok {
+			values[key] = value
+		}
+	}
+	return values, visible, nil
+}
+
+// UpdateAttributes aplica un merge patch (null borra) a los atributos del usuario.
+// El propio usuario sólo puede tocar los atributos self_editable.
+func (s *AttributeService) UpdateAttributes(ctx context.Context, actor AdminActor, userID string, patch map[string]interface{}, admin bool) (map[string]interface{}, error) {
+	var user models.User
+	var changed []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", userID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		schema, err := s.schemaForUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		merged, keys, err := mergeAttributes(schema, user.Attributes, patch, admin)
+		if err != nil {
+			return err
+		}
+		changed = keys
+		if len(changed) == 0 {
+			return nil
+		}
+
+		user.Attributes = merged
+		if err := tx.Model(&user).Update("attributes", merged).Error; err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:    userID,
+			ActorID:   actor.UserID,
+			Action:    models.AuditActionAttributesUpdated,
+			IPAddress: actor.IPAddress,
+			UserAgent: actor.UserAgent,
+			Details:   models.JSONMap{"keys": changed},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrInvalidAttributes) {
+			return nil, err
+		}
+		s.logger.WithError(err).Error("Failed to update user attributes")
+		return nil, fmt.Errorf("failed to update attributes: %w", err)
+	}
+
+	if len(changed) > 0 {
+		s.users.notifyChanged(ctx, &user)
+	}
+
+	values, _, err := s.GetAttributes(ctx, userID, admin)
+	return values, err
+}
+
+// TokenClaims devuelve los atributos marcados para incluirse en los JWT emitidos
+func (s *AttributeService) TokenClaims(ctx context.Context, user *models.User) (map[string]interface{}, error) {
+	if len(user.Attributes) == 0 {
+		return nil, nil
+	}
+
+	schema, err := s.schemaForUser(s.db.WithContext(ctx), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	for key, definition := range schema {
+		if value, ok := user.Attributes[key]; ok && definition.ExposeInToken {
+			claims[key] = value
+		}
+	}
+	return claims, nil
+}
+
+// schemaForUser combina las definiciones globales con las de los tenants que
+// aprovisionaron al usuario o de cuyas organizaciones es miembro; las de un tenant
+// prevalecen sobre las globales
+func (s *AttributeService) schemaForUser(tx *gorm.DB, userID string) (map[string]*models.AttributeDefinition, error) {
+	var tenants []string
+	if err := tx.Model(&models.SCIMUserLink{}).
+		Where("user_id = ?", userID).
+		Pluck("tenant_id", &tenants).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	var orgTenants []string
+	if err := tx.Model(&models.Organization{}).
+		Joins("JOIN organization_memberships ON organization_memberships.org_id = organizations.id").
+		Where("organization_memberships.user_id = ?", userID).
+		Pluck("organizations.slug", &orgTenants).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	tenants = append(tenants, orgTenants...)
+
+	var definitions []*models.AttributeDefinition
+	if err := tx.Where("tenant_id IN ?", append([]string{""}, tenants...)).
+		Order("tenant_id ASC").
+		Find(&definitions).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	// "" se ordena primero, así las definiciones de tenant sobrescriben a las globales
+	schema := make(map[string]*models.AttributeDefinition, len(definitions))
+	for _, definition := range definitions {
+		schema[definition.Key] = definition
+	}
+	return schema, nil
+}
+
+func (s *AttributeService) recordSchemaChange(tx *gorm.DB, actor AdminActor, change string, definition *models.AttributeDefinition) error {
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		ActorID:   actor.UserID,
+		Action:    models.AuditActionAttributeSchemaChanged,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details: models.JSONMap{
+			"change":    change,
+			"tenant_id": definition.TenantID,
+			"key":       definition.Key,
+		},
+	})
+}
+
+// applyAttributeDefinition valida la petición y la copia en la definición
+func applyAttributeDefinition(definition *models.AttributeDefinition, req *models.AttributeDefinitionRequest) error {
+	if !attributeKeyPattern.MatchString(req.Key) {
+		return fmt.Errorf("%w: key must be snake_case, start with a letter and have at most 64 characters", ErrInvalidAttributeDefinition)
+	}
+	if req.Pattern != "" {
+		if req.Type != models.AttributeTypeString {
+			return fmt.Errorf("%w: pattern only applies to string attributes", ErrInvalidAttributeDefinition)
+		}
+		if _, err := regexp.Compile(req.Pattern); err != nil {
+			return fmt.Errorf("%w: invalid pattern: %v", ErrInvalidAttributeDefinition, err)
+		}
+	}
+	if req.MaxLength != nil && req.Type != models.AttributeTypeString {
+		return fmt.Errorf("%w: max_length only applies to string attributes", ErrInvalidAttributeDefinition)
+	}
+	if (req.Min != nil || req.Max != nil) && req.Type != models.AttributeTypeNumber && req.Type != models.AttributeTypeInteger {
+		return fmt.Errorf("%w: min and max only apply to numeric attributes", ErrInvalidAttributeDefinition)
+	}
+	if req.Min != nil && req.Max != nil && *req.Min > *req.Max {
+		return fmt.Errorf("%w: min cannot be greater than max", ErrInvalidAttributeDefinition)
+	}
+	if len(req.EnumValues) > 0 && req.Type != models.AttributeTypeEnum {
+		return fmt.Errorf("%w: enum_values only apply to enum attributes", ErrInvalidAttributeDefinition)
+	}
+
+	definition.TenantID = req.TenantID
+	definition.Key = req.Key
+	definition.DisplayName = req.DisplayName
+	definition.Type = req.Type
+	definition.Required = req.Required
+	definition.EnumValues = req.EnumValues
+	definition.Pattern = req.Pattern
+	definition.MaxLength = req.MaxLength
+	definition.Min = req.Min
+	definition.Max = req.Max
+	definition.Visibility = req.Visibility
+	definition.ExposeInToken = req.ExposeInToken
+	return nil
+}
+
+// mergeAttributes aplica el patch sobre los valores actuales y devuelve el resultado
+// (sin claves que ya no estén en el esquema) y las claves modificadas
+func mergeAttributes(schema map[string]*models.AttributeDefinition, current models.JSONMap, patch map[string]interface{}, admin bool) (models.JSONMap, []string, error) {
+	merged := models.JSONMap{}
+	for key, value := range current {
+		if _, defined := schema[key]; defined {
+			merged[key] = value
+		}
+	}
+
+	var changed []string
+	for key, value := range patch {
+		definition, defined := schema[key]
+		if !defined {
+			return nil, nil, fmt.Errorf("%w: unknown attribute '%s'", ErrInvalidAttributes, key)
+		}
+		if !admin && definition.Visibility != models.AttributeVisibilitySelfEditable {
+			return nil, nil, fmt.Errorf("%w: attribute '%s' is read-only", ErrInvalidAttributes, key)
+		}
+
+		if value == nil {
+			if _, exists := merged[key]; exists {
+				delete(merged, key)
+				changed = append(changed, key)
+			}
+			continue
+		}
+
+		normalized, err := validateAttributeValue(definition, value)
+		if err != nil {
+			return nil, nil, err
+		}
+		merged[key] = normalized
+		changed = append(changed, key)
+	}
+
+	// Sólo se exigen los obligatorios que quien edita puede rellenar
+	for _, key := range sortedAttributeKeys(schema) {
+		definition := schema[key]
+		if !definition.Required || (!admin && definition.Visibility != models.AttributeVisibilitySelfEditable) {
+			continue
+		}
+		if _, ok := merged[key]; !ok {
+			return nil, nil, fmt.Errorf("%w: attribute '%s' is required", ErrInvalidAttributes, key)
+		}
+	}
+
+	sort.Strings(changed)
+	return merged, changed, nil
+}
+
+// validateAttributeValue comprueba un valor decodificado de JSON contra su definición
+// y lo devuelve normalizado (los enteros como int64)
+func validateAttributeValue(definition *models.AttributeDefinition, value interface{}) (interface{}, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: attribute '%s' %s", ErrInvalidAttributes, definition.Key, reason)
+	}
+
+	switch definition.Type {
+	case models.AttributeTypeString:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid("must be a string")
+		}
+		if definition.MaxLength != nil && utf8.RuneCountInString(text) > *definition.MaxLength {
+			return nil, invalid(fmt.Sprintf("must have at most %d characters", *definition.MaxLength))
+		}
+		if definition.Pattern != "" {
+			pattern, err := regexp.Compile(`^(?:` + definition.Pattern + `)$`)
+			if err != nil || !pattern.MatchString(text) {
+				return nil, invalid("does not match the required format")
+			}
+		}
+		return text, nil
+
+	case models.AttributeTypeNumber, models.AttributeTypeInteger:
+		number, ok := value.(float64)
+		if !ok {
+			return nil, invalid("must be a number")
+		}
+		if definition.Min != nil && number < *definition.Min {
+			return nil, invalid(fmt.Sprintf("must be at least %v", *definition.Min))
+		}
+		if definition.Max != nil && number > *definition.Max {
+			return nil, invalid(fmt.Sprintf("must be at most %v", *definition.Max))
+		}
+		if definition.Type == models.AttributeTypeInteger {
+			if number != math.Trunc(number) || math.Abs(number) > 1<<53 {
+				return nil, invalid("must be an integer")
+			}
+			return int64(number), nil
+		}
+		return number, nil
+
+	case models.AttributeTypeBoolean:
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, invalid("must be a boolean")
+		}
+		return flag, nil
+
+	case models.AttributeTypeEnum:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid("must be a string")
+		}
+		for _, allowed := range definition.EnumValues {
+			if text == allowed {
+				return text, nil
+			}
+		}
+		return nil, invalid("must be one of the allowed values")
+
+	case models.AttributeTypeDate:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid("must be a date string")
+		}
+		if _, err := time.Parse("2006-01-02", text); err != nil {
+			return nil, invalid("must be a date in YYYY-MM-DD format")
+		}
+		return text, nil
+	}
+
+	return nil, invalid("has an unsupported type")
+}
+
+func sortedAttributeKeys(schema map[string]*models.AttributeDefinition) []string {
+	keys := make([]string, 0, len(schema))
+	for key := range schema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/models"
+)
+
+func TestValidateAttributeValue(t *testing.T) {
+	maxLength := 6
+	min, max := 1.0, 10.0
+	employeeID := &models.AttributeDefinition{Key: "employee_id", Type: models.AttributeTypeString, Pattern: `E\d+`, MaxLength: &maxLength}
+	seats := &models.AttributeDefinition{Key: "seats", Type: models.AttributeTypeInteger, Min: &min, Max: &max}
+	plan := &models.AttributeDefinition{Key: "plan", Type: models.AttributeTypeEnum, EnumValues: models.StringSlice{"free", "pro"}}
+	hired := &models.AttributeDefinition{Key: "hired_on", Type: models.AttributeTypeDate}
+
+	value, err := validateAttributeValue(employeeID, "E123")
+	require.NoError(t, err)
+	assert.Equal(t, "E123", value)
+	for _, bad := range []interface{}{"XE123", "E1234567", 42.0} {
+		_, err := validateAttributeValue(employeeID, bad)
+		assert.ErrorIs(t, err, ErrInvalidAttributes, bad)
+	}
+
+	value, err = validateAttributeValue(seats, 3.0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), value)
+	for _, bad := range []interface{}{3.5, 0.0, 11.0, "3"} {
+		_, err := validateAttributeValue(seats, bad)
+		assert.ErrorIs(t, err, ErrInvalidAttributes, bad)
+	}
+
+	_, err = validateAttributeValue(plan, "pro")
+	assert.NoError(t, err)
+	_, err = validateAttributeValue(plan, "enterprise")
+	assert.ErrorIs(t, err, ErrInvalidAttributes)
+
+	_, err = validateAttributeValue(hired, "2024-02-29")
+	assert.NoError(t, err)
+	_, err = validateAttributeValue(hired, "29/02/2024")
+	assert.ErrorIs(t, err, ErrInvalidAttributes)
+}
+
+func TestMergeAttributes(t *testing.T) {
+	schema := map[string]*models.AttributeDefinition{
+		"department":  {Key: "department", Type: models.AttributeTypeString, Required: true, Visibility: models.AttributeVisibilitySelfEditable},
+		"cost_center": {Key: "cost_center", Type: models.AttributeTypeString, Visibility: models.AttributeVisibilityAdminOnly},
+	}
+	current := models.JSONMap{"department": "Sales", "removed_key": "x"}
+
+	merged, changed, err := mergeAttributes(schema, current, map[string]interface{}{"department": "Support"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, models.JSONMap{"department": "Support"}, merged, "keys no longer in the schema are dropped")
+	assert.Equal(t, []string{"department"}, changed)
+
+	_, _, err = mergeAttributes(schema, current, map[string]interface{}{"cost_center": "CC1"}, false)
+	assert.ErrorIs(t, err, ErrInvalidAttributes, "users cannot edit admin-only attributes")
+
+	_, _, err = mergeAttributes(schema, current, map[string]interface{}{"department": nil}, true)
+	assert.ErrorIs(t, err, ErrInvalidAttributes, "required attributes cannot be removed")
+
+	_, _, err = mergeAttributes(schema, current, map[string]interface{}{"unknown": "x"}, true)
+	assert.ErrorIs(t, err, ErrInvalidAttributes)
+
+	merged, _, err = mergeAttributes(schema, current, map[string]interface{}{"cost_center": "CC1"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "CC1", merged["cost_center"])
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+type AuditService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+func NewAuditService(db *gorm.DB) *AuditService {
+	return &AuditService{
+		db:     db,
+		logger: logger.GetLogger(),
+	}
+}
+
+// Record guarda una entrada de auditoría
+func (s *AuditService) Record(ctx context.Context, entry *models.AuditLog) error {
+	return s.RecordTx(s.db.WithContext(ctx), entry)
+}
+
+// RecordTx guarda una entrada de auditoría dentro de una transacción existente,
+// de modo que la acción y su registro se confirman o se descartan juntos
+func (s *AuditService) RecordTx(tx *gorm.DB, entry *models.AuditLog) error {
+	if err := tx.Create(entry).Error; err != nil {
+		s.logger.WithError(err).WithField("action", entry.Action).Error("Failed to record audit log")
+		return fmt.Errorf("failed to record audit log: %w", err)
+	}
+	return nil
+}
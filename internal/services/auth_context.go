@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"it-auth-service/internal/models"
+)
+
+var (
+	ErrInvalidFirebaseToken     = errors.New("invalid Firebase token")
+	ErrReauthenticationMismatch = errors.New("reauthentication token belongs to a different user")
+	ErrStaleReauthentication    = errors.New("firebase sign-in is not recent, sign in again")
+)
+
+// authContextFromFirebase construye el contexto de autenticación de un login de un solo factor.
+// Se usa auth_time del token de Firebase, que refleja cuándo el usuario introdujo credenciales.
+func authContextFromFirebase(token *auth.Token, provider string) models.AuthContext {
+	authTime := time.Now()
+	if token.AuthTime > 0 {
+		authTime = time.Unix(token.AuthTime, 0)
+	}
+
+	return models.AuthContext{
+		AuthTime: authTime,
+		AMR:      []string{amrForProvider(provider)},
+		ACR:      models.ACRSingleFactor,
+	}
+}
+
+// multiFactorAuthContext construye el contexto tras verificar un segundo factor. auth_time
+// sigue siendo el del primer factor: completar el reto no renueva la autenticación.
+func multiFactorAuthContext(provider string, authTime time.Time) models.AuthContext {
+	return models.AuthContext{
+		AuthTime: authTime,
+		AMR:      []string{amrForProvider(provider), models.AMROTP, models.AMRMFA},
+		ACR:      models.ACRMultiFactor,
+	}
+}
+
+// authContextFromClaims recupera el contexto de autenticación de un JWT interno para
+// reemitir el token sin alterar cuándo ni cómo se autenticó el usuario
+func authContextFromClaims(claims map[string]interface{}) models.AuthContext {
+	authCtx := models.AuthContext{ACR: models.ACRSingleFactor}
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		authCtx.AuthTime = time.Unix(int64(authTime), 0)
+	}
+	if acr, ok := claims["acr"].(string); ok && acr != "" {
+		authCtx.ACR = acr
+	}
+	if amr, ok := claims["amr"].([]interface{}); ok {
+		for _, method := range amr {
+			if value, ok := method.(string); ok {
+				authCtx.AMR = append(authCtx.AMR, value)
+			}
+		}
+	}
+	return authCtx
+}
+
+func amrForProvider(provider string) string {
+	if provider == "password" {
+		return models.AMRPassword
+	}
+	return models.AMRFederated
+}
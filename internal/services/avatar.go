@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/storage"
+)
+
+const (
+	avatarIDBytes     = 16
+	avatarContentType = "image/jpeg"
+	// AvatarPath es la ruta pública estable de un avatar; redirige a una URL firmada
+	AvatarPath = "/api/v1/avatars/"
+)
+
+var (
+	ErrAvatarNotFound        = errors.New("avatar not found")
+	ErrAvatarUnsupportedType = errors.New("unsupported image type, use JPEG, PNG or GIF")
+	ErrAvatarInvalidImage    = errors.New("invalid image")
+	ErrAvatarTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// AvatarService procesa las imágenes de perfil subidas y las guarda en el
+// almacenamiento configurado. photo_url apunta a AvatarPath, que no caduca, y cada
+// petición se redirige a una URL firmada de corta duración.
+type AvatarService struct {
+	db      *gorm.DB
+	users   *UserService
+	audit   *AuditService
+	blobs   storage.Storage
+	logger  *logrus.Logger
+	cfg     config.AvatarConfig
+	baseURL string
+}
+
+func NewAvatarService(db *gorm.DB, userService *UserService, auditService *AuditService, blobs storage.Storage, cfg *config.Config) *AvatarService {
+	sizes := append([]int(nil), cfg.AvatarConfig.Sizes...)
+	sort.Ints(sizes)
+	avatarCfg := cfg.AvatarConfig
+	avatarCfg.Sizes = sizes
+
+	return &AvatarService{
+		db:      db,
+		users:   userService,
+		audit:   auditService,
+		blobs:   blobs,
+		logger:  logger.GetLogger(),
+		cfg:     avatarCfg,
+		baseURL: strings.TrimRight(cfg.StorageConfig.PublicBaseURL, "/"),
+	}
+}
+
+// MaxBytes es el tamaño máximo aceptado para el fichero subido
+func (s *AvatarService) MaxBytes() int64 {
+	return int64(s.cfg.MaxBytes)
+}
+
+// Upload valida la imagen, genera las versiones configuradas sin metadatos y
+// sustituye el avatar anterior del usuario
+func (s *AvatarService) Upload(ctx context.Context, actor AdminActor, data []byte) (*models.User, error) {
+	img, orientation, err := decodeAvatar(data, s.cfg.MaxPixels)
+	if err != nil {
+		return nil, err
+	}
+	square := squareAvatar(img, orientation)
+
+	avatarID, err := generateRandomToken(avatarIDBytes)
+	if err != nil {
+		return nil, err
+	}
+	avatar := &models.UserAvatar{ID: avatarID, UserID: actor.UserID, Sizes: models.IntSlice(s.cfg.Sizes)}
+
+	var stored []string
+	for _, size := range avatar.Sizes {
+		encoded, err := encodeAvatar(resizeSquare(square, size))
+		if err != nil {
+			s.deleteKeys(ctx, stored)
+			return nil, err
+		}
+		key := avatarKey(avatar, size)
+		if err := s.blobs.Put(ctx, key, avatarContentType, encoded); err != nil {
+			s.deleteKeys(ctx, stored)
+			return nil, fmt.Errorf("failed to store avatar: %w", err)
+		}
+		stored = append(stored, key)
+	}
+
+	var user models.User
+	var previous []*models.UserAvatar
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", actor.UserID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserAvatar{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(avatar).Error; err != nil {
+			return err
+		}
+
+		user.AvatarID = &avatar.ID
+		user.PhotoURL = s.photoURL(avatar.ID)
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"avatar_id": avatar.ID,
+			"photo_url": user.PhotoURL,
+		}).Error; err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:    user.ID,
+			ActorID:   actor.UserID,
+			Action:    models.AuditActionAvatarUpdated,
+			IPAddress: actor.IPAddress,
+			UserAgent: actor.UserAgent,
+			Details: models.JSONMap{
+				"avatar_id": avatar.ID,
+				"width":     img.Bounds().Dx(),
+				"height":    img.Bounds().Dy(),
+				"bytes":     len(data),
+			},
+		})
+	})
+	if err != nil {
+		s.deleteKeys(ctx, stored)
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to save avatar: %w", err)
+	}
+
+	for _, old := range previous {
+		s.deleteKeys(ctx, avatarKeys(old))
+	}
+	s.users.notifyChanged(ctx, &user)
+	return &user, nil
+}
+
+// Delete elimina el avatar subido; photo_url queda vacío hasta el próximo login,
+// que vuelve a tomar la foto del proveedor
+func (s *AvatarService) Delete(ctx context.Context, actor AdminActor) (*models.User, error) {
+	var user models.User
+	var previous []*models.UserAvatar
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", actor.UserID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if user.AvatarID == nil {
+			return ErrAvatarNotFound
+		}
+		if err := tx.Where("user_id = ?", user.ID).Find(&previous).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserAvatar{}).Error; err != nil {
+			return err
+		}
+
+		user.AvatarID = nil
+		user.PhotoURL = ""
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"avatar_id": nil,
+			"photo_url": "",
+		}).Error; err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:    user.ID,
+			ActorID:   actor.UserID,
+			Action:    models.AuditActionAvatarRemoved,
+			IPAddress: actor.IPAddress,
+			UserAgent: actor.UserAgent,
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrAvatarNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to delete avatar: %w", err)
+	}
+
+	for _, old := range previous {
+		s.deleteKeys(ctx, avatarKeys(old))
+	}
+	s.users.notifyChanged(ctx, &user)
+	return &user, nil
+}
+
+// GetURLs devuelve URLs firmadas de todas las versiones del avatar del usuario
+func (s *AvatarService) GetURLs(ctx context.Context, userID string) (*models.AvatarURLs, error) {
+	var avatar models.UserAvatar
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&avatar).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAvatarNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	result := &models.AvatarURLs{
+		PhotoURL:  s.photoURL(avatar.ID),
+		URLs:      make(map[string]string, len(avatar.Sizes)),
+		ExpiresAt: time.Now().Add(s.cfg.URLTTL),
+	}
+	for _, size := range avatar.Sizes {
+		signed, err := s.blobs.SignedURL(ctx, avatarKey(&avatar, size), s.cfg.URLTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign avatar URL: %w", err)
+		}
+		result.URLs[strconv.Itoa(size)] = signed
+	}
+	return result, nil
+}
+
+// SignedURL devuelve la URL firmada de la versión más pequeña que cubre el tamaño
+// pedido (la mayor si ninguna lo cubre); size 0 pide la mayor
+func (s *AvatarService) SignedURL(ctx context.Context, avatarID string, size int) (string, error) {
+	var avatar models.UserAvatar
+	if err := s.db.WithContext(ctx).Where("id = ?", avatarID).First(&avatar).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrAvatarNotFound
+		}
+		return "", fmt.Errorf("database error: %w", err)
+	}
+	if len(avatar.Sizes) == 0 {
+		return "", ErrAvatarNotFound
+	}
+
+	return s.blobs.SignedURL(ctx, avatarKey(&avatar, pickAvatarSize(avatar.Sizes, size)), s.cfg.URLTTL)
+}
+
+// OnUserErased borra los ficheros del avatar de un usuario eliminado definitivamente
+func (s *AvatarService) OnUserErased(ctx context.Context, event *models.UserErasedEvent) {
+	var avatars []*models.UserAvatar
+	if err := s.db.WithContext(ctx).Where("user_id = ?", event.UserID).Find(&avatars).Error; err != nil {
+		s.logger.WithError(err).WithField("user_id", event.UserID).Error("Failed to load avatars of erased user")
+		return
+	}
+	for _, avatar := range avatars {
+		s.deleteKeys(ctx, avatarKeys(avatar))
+	}
+	if err := s.db.WithContext(ctx).Where("user_id = ?", event.UserID).Delete(&models.UserAvatar{}).Error; err != nil {
+		s.logger.WithError(err).WithField("user_id", event.UserID).Error("Failed to delete avatars of erased user")
+	}
+}
+
+func (s *AvatarService) photoURL(avatarID string) string {
+	return s.baseURL + AvatarPath + avatarID
+}
+
+// deleteKeys borra objetos sin interrumpir la operación; un fallo sólo deja huérfanos
+func (s *AvatarService) deleteKeys(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			s.logger.WithError(err).WithField("key", key).Warn("Failed to delete avatar object")
+		}
+	}
+}
+
+func avatarKey(avatar *models.UserAvatar, size int) string {
+	return fmt.Sprintf("avatars/%s/%s/%d.jpg", avatar.UserID, avatar.ID, size)
+}
+
+func avatarKeys(avatar *models.UserAvatar) []string {
+	keys := make([]string, 0, len(avatar.Sizes))
+	for _, size := range avatar.Sizes {
+		keys = append(keys, avatarKey(avatar, size))
+	}
+	return keys
+}
+
+// pickAvatarSize elige entre los tamaños disponibles (ordenados de menor a mayor)
+func pickAvatarSize(sizes []int, requested int) int {
+	if requested > 0 {
+		for _, size := range sizes {
+			if size >= requested {
+				return size
+			}
+		}
+	}
+	return sizes[len(sizes)-1]
+}
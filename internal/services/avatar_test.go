@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestDecodeAvatarValidation(t *testing.T) {
+	_, _, err := decodeAvatar([]byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"), 1000)
+	assert.ErrorIs(t, err, ErrAvatarUnsupportedType)
+
+	_, _, err = decodeAvatar(encodeTestPNG(t, 40, 30), 1000)
+	assert.ErrorIs(t, err, ErrAvatarTooManyPixels)
+
+	img, orientation, err := decodeAvatar(encodeTestPNG(t, 40, 30), 1200)
+	require.NoError(t, err)
+	assert.Equal(t, 40, img.Bounds().Dx())
+	assert.Equal(t, 1, orientation)
+
+	truncated := encodeTestPNG(t, 40, 30)
+	_, _, err = decodeAvatar(truncated[:len(truncated)/2], 1200)
+	assert.ErrorIs(t, err, ErrAvatarInvalidImage)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	// SOI + APP1 con un TIFF big-endian cuyo IFD0 sólo tiene Orientation = 6
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+		0x00, 0x01,
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(segment) + 2
+	data := append([]byte{0xFF, 0xD8, 0xFF, 0xE1, byte(length >> 8), byte(length)}, segment...)
+	data = append(data, 0xFF, 0xDA)
+
+	assert.Equal(t, 6, jpegOrientation(data))
+	assert.Equal(t, 1, jpegOrientation([]byte{0xFF, 0xD8, 0xFF, 0xDA}))
+	assert.Equal(t, 1, jpegOrientation(data[:12]))
+}
+
+func TestSquareAvatarCropsAndOrients(t *testing.T) {
+	// 4x2: la mitad izquierda del recorte central es roja y la derecha azul
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		src.Set(1, y, color.RGBA{R: 255, A: 255})
+		src.Set(2, y, color.RGBA{B: 255, A: 255})
+	}
+
+	square := squareAvatar(src, 1)
+	assert.Equal(t, image.Rect(0, 0, 2, 2), square.Bounds())
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, square.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, square.RGBAAt(1, 0))
+
+	// Girada 90° en sentido horario, el rojo pasa arriba
+	rotated := squareAvatar(src, 6)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, rotated.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, rotated.RGBAAt(1, 0))
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, rotated.RGBAAt(0, 1))
+}
+
+func TestResizeSquareAverages(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if (x+y)%2 == 0 {
+				src.Set(x, y, color.RGBA{R: 200, G: 200, B: 200, A: 255})
+			} else {
+				src.Set(x, y, color.RGBA{A: 255})
+			}
+		}
+	}
+
+	small := resizeSquare(src, 2)
+	assert.Equal(t, image.Rect(0, 0, 2, 2), small.Bounds())
+	assert.Equal(t, color.RGBA{R: 100, G: 100, B: 100, A: 255}, small.RGBAAt(1, 1))
+
+	large := resizeSquare(src, 8)
+	assert.Equal(t, image.Rect(0, 0, 8, 8), large.Bounds())
+}
+
+func TestPickAvatarSize(t *testing.T) {
+	sizes := []int{64, 256, 512}
+	assert.Equal(t, 64, pickAvatarSize(sizes, 32))
+	assert.Equal(t, 256, pickAvatarSize(sizes, 100))
+	assert.Equal(t, 512, pickAvatarSize(sizes, 2000))
+	assert.Equal(t, 512, pickAvatarSize(sizes, 0))
+}
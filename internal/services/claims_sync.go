@@ -0,0 +1,376 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/firebase"
+)
+
+const (
+	// Firebase rechaza custom claims cuyo JSON supere 1000 bytes
+	firebaseClaimsMaxBytes = 1000
+	// Tamaño máximo de lote de GetUsers en el Admin SDK
+	firebaseGetUsersBatchSize = 100
+	claimsSyncTimeout         = 10 * time.Second
+)
+
+var ErrClaimsTooLarge = errors.New("custom claims exceed the 1000-byte Firebase limit")
+
+// managedClaimKeys son las claves que controla este servicio. El resto de custom
+// claims que existan en Firebase se preservan al sincronizar.
+var managedClaimKeys = []string{"role", "status", "org_id", "tenant_id", "org_role"}
+
+// ClaimsSyncStats resume una ejecución del job de reconciliación
+type ClaimsSyncStats struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+}
+
+// ClaimsSyncService replica rol, estado y organización activa de los usuarios en los
+// custom claims de Firebase para que las reglas de Firestore y Storage puedan usarlos
+type ClaimsSyncService struct {
+	db           *gorm.DB
+	firebaseAuth *firebase.Auth
+	identities   *IdentityService
+	orgs         *OrganizationService
+	queue        chan models.User
+	workers      int
+	logger       *logrus.Logger
+}
+
+func NewClaimsSyncService(db *gorm.DB, firebaseAuth *firebase.Auth, identityService *IdentityService, organizationService *OrganizationService, cfg *config.Config) *ClaimsSyncService {
+	return &ClaimsSyncService{
+		db:           db,
+		firebaseAuth: firebaseAuth,
+		identities:   identityService,
+		orgs:         organizationService,
+		queue:        make(chan models.User, cfg.ClaimsSyncConfig.QueueSize),
+		workers:      cfg.ClaimsSyncConfig.Workers,
+		logger:       logger.GetLogger(),
+	}
+}
+
+// BuildClaims devuelve los claims de autorización que deben verse en Firebase: los
+// mismos de organización que el JWT interno (org_id, tenant_id y org_role)
+func (s *ClaimsSyncService) BuildClaims(ctx context.Context, user *models.User) (map[string]interface{}, error) {
+	claims := map[string]interface{}{
+		"role":   user.Role,
+		"status": user.Status,
+	}
+
+	membership, err := s.orgs.ActiveMembership(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if membership != nil {
+		claims["org_id"] = membership.OrgID
+		claims["tenant_id"] = membership.Organization.Slug
+		claims["org_role"] = membership.Role
+	}
+	return claims, nil
+}
+
+// Start arranca los workers que sincronizan los cambios encolados por OnUserChanged;
+// se detienen al cancelar ctx
+func (s *ClaimsSyncService) Start(ctx context.Context) {
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case user := <-s.queue:
+					s.syncQueued(ctx, &user)
+				}
+			}
+		}()
+	}
+}
+
+// OnUserChanged encola la sincronización para no bloquear la petición. Si la cola está
+// llena el cambio se descarta: lo corregirá la reconciliación, igual que los fallos.
+func (s *ClaimsSyncService) OnUserChanged(_ context.Context, user *models.User) {
+	select {
+	case s.queue <- *user:
+	default:
+		s.logger.WithField("user_id", user.ID).Warn("Firebase claims sync queue is full, change left to reconciliation")
+	}
+}
+
+func (s *ClaimsSyncService) syncQueued(ctx context.Context, user *models.User) {
+	ctx, cancel := context.WithTimeout(ctx, claimsSyncTimeout)
+	defer cancel()
+
+	if err := s.SyncUser(ctx, user); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to sync Firebase custom claims")
+	}
+}
+
+// SyncUser envía los claims del usuario a todos sus Firebase UIDs si han cambiado
+// desde la última sincronización
+func (s *ClaimsSyncService) SyncUser(ctx context.Context, user *models.User) error {
+	desired, err := s.BuildClaims(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	hash, err := claimsHash(desired)
+	if err != nil {
+		return err
+	}
+
+	subjects, err := s.subjectsFor(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, subject := range subjects {
+		var state models.FirebaseClaimsSync
+		err := s.db.WithContext(ctx).Where("subject = ?", subject).First(&state).Error
+		if err == nil && state.ClaimsHash == hash && state.LastError == "" {
+			continue
+		}
+
+		if err := s.push(ctx, user.ID, subject, desired, hash, nil); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// Reconcile recorre todos los usuarios y corrige las diferencias entre los claims
+// esperados y los que tiene Firebase (cambios manuales, fallos de sincronización...)
+func (s *ClaimsSyncService) Reconcile(ctx context.Context) (*ClaimsSyncStats, error) {
+	stats := &ClaimsSyncStats{}
+	lastID := ""
+
+	for {
+		var users []*models.User
+		query := s.db.WithContext(ctx).Order("id ASC").Limit(firebaseGetUsersBatchSize)
+		if lastID != "" {
+			query = query.Where("id > ?", lastID)
+		}
+		if err := query.Find(&users).Error; err != nil {
+			return stats, fmt.Errorf("failed to list users for reconciliation: %w", err)
+		}
+		if len(users) == 0 {
+			break
+		}
+		lastID = users[len(users)-1].ID
+
+		if err := s.reconcileBatch(ctx, users, stats); err != nil {
+			return stats, err
+		}
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"checked": stats.Checked,
+		"updated": stats.Updated,
+		"failed":  stats.Failed,
+	}).Info("Firebase custom claims reconciliation completed")
+
+	return stats, nil
+}
+
+func (s *ClaimsSyncService) reconcileBatch(ctx context.Context, users []*models.User, stats *ClaimsSyncStats) error {
+	owners := make(map[string]*models.User)
+	var subjects []string
+	for _, user := range users {
+		userSubjects, err := s.subjectsFor(ctx, user)
+		if err != nil {
+			return err
+		}
+		for _, subject := range userSubjects {
+			owners[subject] = user
+			subjects = append(subjects, subject)
+		}
+	}
+
+	for start := 0; start < len(subjects); start += firebaseGetUsersBatchSize {
+		end := start + firebaseGetUsersBatchSize
+		if end > len(subjects) {
+			end = len(subjects)
+		}
+
+		records, err := s.firebaseAuth.GetUsers(ctx, subjects[start:end])
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			user := owners[record.UID]
+			stats.Checked++
+
+			desired, err := s.BuildClaims(ctx, user)
+			if err != nil {
+				stats.Failed++
+				continue
+			}
+			if managedClaimsEqual(record.CustomClaims, desired) {
+				continue
+			}
+
+			hash, err := claimsHash(desired)
+			if err != nil {
+				stats.Failed++
+				continue
+			}
+			if err := s.push(ctx, user.ID, record.UID, desired, hash, record.CustomClaims); err != nil {
+				stats.Failed++
+				continue
+			}
+			stats.Updated++
+		}
+	}
+
+	return nil
+}
+
+// push combina los claims gestionados con los existentes, aplica el límite de
+// tamaño y los envía a Firebase, registrando el resultado
+func (s *ClaimsSyncService) push(ctx context.Context, userID, subject string, desired map[string]interface{}, hash string, current map[string]interface{}) error {
+	if current == nil {
+		record, err := s.firebaseAuth.GetUser(ctx, subject)
+		if err != nil {
+			return s.recordResult(ctx, userID, subject, hash, err)
+		}
+		current = record.CustomClaims
+	}
+
+	merged := mergeManagedClaims(current, desired)
+	if size, err := claimsSize(merged); err != nil || size > firebaseClaimsMaxBytes {
+		if err == nil {
+			err = fmt.Errorf("%w: %d bytes", ErrClaimsTooLarge, size)
+		}
+		return s.recordResult(ctx, userID, subject, hash, err)
+	}
+
+	err := s.firebaseAuth.SetCustomUserClaims(ctx, subject, merged)
+	return s.recordResult(ctx, userID, subject, hash, err)
+}
+
+func (s *ClaimsSyncService) recordResult(ctx context.Context, userID, subject, hash string, syncErr error) error {
+	state := models.FirebaseClaimsSync{
+		Subject: subject,
+		UserID:  userID,
+	}
+	if syncErr != nil {
+		state.LastError = syncErr.Error()
+	} else {
+		now := time.Now()
+		state.ClaimsHash = hash
+		state.SyncedAt = &now
+	}
+
+	columns := []string{"user_id", "last_error", "updated_at"}
+	if syncErr == nil {
+		columns = append(columns, "claims_hash", "synced_at")
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&state).Error
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to record Firebase claims sync state")
+	}
+
+	if syncErr != nil {
+		s.logger.WithError(syncErr).WithFields(map[string]interface{}{
+			"user_id": userID,
+			"subject": subject,
+		}).Warn("Firebase custom claims sync failed")
+		return syncErr
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id": userID,
+		"subject": subject,
+	}).Info("Firebase custom claims synced")
+	return nil
+}
+
+// subjectsFor devuelve todos los Firebase UIDs del usuario (principal e identidades vinculadas)
+func (s *ClaimsSyncService) subjectsFor(ctx context.Context, user *models.User) ([]string, error) {
+	identities, err := s.identities.ListIdentities(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var subjects []string
+	if user.FirebaseID != "" {
+		seen[user.FirebaseID] = true
+		subjects = append(subjects, user.FirebaseID)
+	}
+	for _, identity := range identities {
+		if !seen[identity.Subject] {
+			seen[identity.Subject] = true
+			subjects = append(subjects, identity.Subject)
+		}
+	}
+
+	return subjects, nil
+}
+
+// mergeManagedClaims sustituye las claves gestionadas y conserva el resto
+func mergeManagedClaims(current, desired map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(current)+len(desired))
+	for key, value := range current {
+		merged[key] = value
+	}
+	for _, key := range managedClaimKeys {
+		delete(merged, key)
+	}
+	for key, value := range desired {
+		merged[key] = value
+	}
+	return merged
+}
+
+// managedClaimsEqual compara sólo las claves gestionadas, normalizando vía JSON
+// porque Firebase devuelve los números como float64 y los arrays como []interface{}
+func managedClaimsEqual(current, desired map[string]interface{}) bool {
+	pick := func(claims map[string]interface{}) map[string]interface{} {
+		subset := map[string]interface{}{}
+		for _, key := range managedClaimKeys {
+			if value, ok := claims[key]; ok {
+				subset[key] = value
+			}
+		}
+		return subset
+	}
+
+	a, errA := json.Marshal(pick(current))
+	b, errB := json.Marshal(pick(desired))
+	return errA == nil && errB == nil && string(a) == string(b)
+}
+
+func claimsSize(claims map[string]interface{}) (int, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal claims: %w", err)
+	}
+	return len(data), nil
+}
+
+func claimsHash(claims map[string]interface{}) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal claims: %w", err)
+	}
+	return sha256Hex(string(data)), nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeManagedClaimsPreservesForeignKeys(t *testing.T) {
+	current := map[string]interface{}{"role": "user", "premium": true}
+	desired := map[string]interface{}{"role": "admin", "status": "active"}
+
+	merged := mergeManagedClaims(current, desired)
+
+	assert.Equal(t, "admin", merged["role"])
+	assert.Equal(t, "active", merged["status"])
+	assert.Equal(t, true, merged["premium"])
+}
+
+func TestManagedClaimsEqualIgnoresForeignKeys(t *testing.T) {
+	current := map[string]interface{}{"role": "admin", "status": "active", "premium": true}
+
+	assert.True(t, managedClaimsEqual(current, map[string]interface{}{"role": "admin", "status": "active"}))
+	assert.False(t, managedClaimsEqual(current, map[string]interface{}{"role": "user", "status": "active"}))
+}
+
+func TestMergeManagedClaimsDropsStaleOrganization(t *testing.T) {
+	// Al salir de su última organización desaparecen los claims del tenant anterior
+	current := map[string]interface{}{"role": "user", "status": "active", "org_id": "org-1", "tenant_id": "acme", "org_role": "admin"}
+	desired := map[string]interface{}{"role": "user", "status": "active"}
+
+	merged := mergeManagedClaims(current, desired)
+
+	assert.NotContains(t, merged, "org_id")
+	assert.NotContains(t, merged, "tenant_id")
+	assert.NotContains(t, merged, "org_role")
+	assert.False(t, managedClaimsEqual(current, desired))
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+var (
+	ErrConsentDocumentNotFound = errors.New("consent document not found")
+	ErrConsentDocumentExists   = errors.New("this version of the document already exists")
+	ErrConsentNotCurrent       = errors.New("only the current published version of a document can be accepted")
+)
+
+// ConsentService gestiona las versiones de los términos de servicio y de la política
+// de privacidad y registra qué versión aceptó cada usuario y cuándo
+type ConsentService struct {
+	db     *gorm.DB
+	audit  *AuditService
+	logger *logrus.Logger
+}
+
+func NewConsentService(db *gorm.DB, auditService *AuditService) *ConsentService {
+	return &ConsentService{
+		db:     db,
+		audit:  auditService,
+		logger: logger.GetLogger(),
+	}
+}
+
+// CreateDocument registra una versión nueva; no está vigente hasta que se publica
+func (s *ConsentService) CreateDocument(ctx context.Context, actor AdminActor, req *models.CreateConsentDocumentRequest) (*models.ConsentDocument, error) {
+	document := &models.ConsentDocument{
+		Type:      req.Type,
+		Version:   strings.TrimSpace(req.Version),
+		Title:     strings.TrimSpace(req.Title),
+		URL:       req.URL,
+		Mandatory: req.Mandatory,
+		CreatedBy: actor.UserID,
+	}
+	if req.Publish {
+		now := time.Now()
+		document.PublishedAt = &now
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(document).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrConsentDocumentExists
+			}
+			return err
+		}
+		if err := s.record(tx, actor, models.AuditActionConsentDocumentCreated, document); err != nil {
+			return err
+		}
+		if document.PublishedAt != nil {
+			return s.record(tx, actor, models.AuditActionConsentPublished, document)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrConsentDocumentExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create consent document: %w", err)
+	}
+	return document, nil
+}
+
+// PublishDocument hace vigente una versión. Publicar una versión ya publicada no cambia nada.
+func (s *ConsentService) PublishDocument(ctx context.Context, actor AdminActor, documentID string) (*models.ConsentDocument, error) {
+	var document models.ConsentDocument
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", documentID).First(&document).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrConsentDocumentNotFound
+			}
+			return err
+		}
+		if document.PublishedAt != nil {
+			return nil
+		}
+
+		now := time.Now()
+		if err := tx.Model(&document).Update("published_at", now).Error; err != nil {
+			return err
+		}
+		document.PublishedAt = &now
+		return s.record(tx, actor, models.AuditActionConsentPublished, &document)
+	})
+	if err != nil {
+		if errors.Is(err, ErrConsentDocumentNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to publish consent document: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"document_id": document.ID,
+		"type":        document.Type,
+		"version":     document.Version,
+		"mandatory":   document.Mandatory,
+	}).Info("Consent document published")
+	return &document, nil
+}
+
+// ListDocuments devuelve todas las versiones, publicadas o no, de un tipo o de todos
+func (s *ConsentService) ListDocuments(ctx context.Context, documentType string) ([]*models.ConsentDocument, error) {
+	db := s.db.WithContext(ctx)
+	if documentType != "" {
+		db = db.Where("type = ?", documentType)
+	}
+
+	var documents []*models.ConsentDocument
+	if err := db.Order("type ASC, created_at DESC").Find(&documents).Error; err != nil {
+		return nil, fmt.Errorf("failed to list consent documents: %w", err)
+	}
+	return documents, nil
+}
+
+// Current devuelve la versión vigente (la última publicada) de cada tipo de documento
+func (s *ConsentService) Current(ctx context.Context) ([]*models.ConsentDocument, error) {
+	documents := []*models.ConsentDocument{}
+	if err := s.db.WithContext(ctx).
+		Select("DISTINCT ON (type) *").
+		Where("published_at IS NOT NULL").
+		Order("type ASC, published_at DESC").
+		Find(&documents).Error; err != nil {
+		return nil, fmt.Errorf("failed to load current consent documents: %w", err)
+	}
+	return documents, nil
+}
+
+// Pending devuelve los documentos vigentes obligatorios que el usuario no ha aceptado
+func (s *ConsentService) Pending(ctx context.Context, userID string) ([]*models.ConsentDocument, error) {
+	status, err := s.Status(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return status.Pending, nil
+}
+
+// Status devuelve los documentos vigentes, los pendientes y el historial del usuario
+func (s *ConsentService) Status(ctx context.Context, userID string) (*models.ConsentStatus, error) {
+	current, err := s.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := []*models.ConsentAcceptance{}
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("accepted_at DESC").
+		Find(&accepted).Error; err != nil {
+		return nil, fmt.Errorf("failed to load consent acceptances: %w", err)
+	}
+
+	return &models.ConsentStatus{
+		Current:  current,
+		Pending:  pendingConsents(current, accepted),
+		Accepted: accepted,
+	}, nil
+}
+
+// pendingConsents filtra los documentos obligatorios sin una aceptación de esa versión
+func pendingConsents(current []*models.ConsentDocument, accepted []*models.ConsentAcceptance) []*models.ConsentDocument {
+	acceptedIDs := make(map[string]bool, len(accepted))
+	for _, acceptance := range accepted {
+		acceptedIDs[acceptance.DocumentID] = true
+	}
+
+	pending := []*models.ConsentDocument{}
+	for _, document := range current {
+		if document.Mandatory && !acceptedIDs[document.ID] {
+			pending = append(pending, document)
+		}
+	}
+	return pending
+}
+
+// Accept registra la aceptación de documentos vigentes. Aceptar de nuevo una versión
+// ya aceptada conserva la fecha original.
+func (s *ConsentService) Accept(ctx context.Context, actor AdminActor, documentIDs []string) ([]*models.ConsentAcceptance, error) {
+	current, err := s.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currentByID := make(map[string]*models.ConsentDocument, len(current))
+	for _, document := range current {
+		currentByID[document.ID] = document
+	}
+
+	now := time.Now()
+	acceptances := make([]*models.ConsentAcceptance, 0, len(documentIDs))
+	seen := make(map[string]bool, len(documentIDs))
+	for _, id := range documentIDs {
+		document, ok := currentByID[id]
+		if !ok {
+			return nil, ErrConsentNotCurrent
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		acceptances = append(acceptances, &models.ConsentAcceptance{
+			UserID:     actor.UserID,
+			DocumentID: document.ID,
+			Type:       document.Type,
+			Version:    document.Version,
+			AcceptedAt: now,
+			IPAddress:  actor.IPAddress,
+			UserAgent:  actor.UserAgent,
+		})
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, acceptance := range acceptances {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(acceptance)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			if err := s.audit.RecordTx(tx, &models.AuditLog{
+				UserID:    actor.UserID,
+				ActorID:   actor.UserID,
+				Action:    models.AuditActionConsentAccepted,
+				IPAddress: actor.IPAddress,
+				UserAgent: actor.UserAgent,
+				Details: models.JSONMap{
+					"document_id": acceptance.DocumentID,
+					"type":        acceptance.Type,
+					"version":     acceptance.Version,
+				},
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record consent: %w", err)
+	}
+	return acceptances, nil
+}
+
+// Coverage calcula, para cada documento vigente, qué parte de las cuentas que pueden
+// iniciar sesión lo ha aceptado y cuántas aceptaron cada versión del mismo tipo
+func (s *ConsentService) Coverage(ctx context.Context) ([]*models.ConsentCoverage, error) {
+	current, err := s.Current(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	eligible := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&models.User{}).
+			Where("users.status NOT IN ? AND users.status <> ?", models.DeletedStatuses, models.StatusGuest)
+	}
+
+	var eligibleUsers int64
+	if err := eligible().Count(&eligibleUsers).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	report := make([]*models.ConsentCoverage, 0, len(current))
+	for _, document := range current {
+		coverage := &models.ConsentCoverage{
+			Document:      document,
+			EligibleUsers: eligibleUsers,
+			Versions:      []*models.ConsentVersionCount{},
+		}
+		if err := eligible().
+			Joins("JOIN consent_acceptances a ON a.user_id = users.id").
+			Where("a.type = ?", document.Type).
+			Select("a.version, COUNT(DISTINCT users.id) AS users").
+			Group("a.version").
+			Order("users DESC").
+			Scan(&coverage.Versions).Error; err != nil {
+			return nil, fmt.Errorf("failed to count consent acceptances: %w", err)
+		}
+		for _, version := range coverage.Versions {
+			if version.Version == document.Version {
+				coverage.AcceptedUsers = version.Users
+			}
+		}
+		coverage.Coverage = coverageRatio(coverage.AcceptedUsers, eligibleUsers)
+		report = append(report, coverage)
+	}
+	return report, nil
+}
+
+// coverageRatio devuelve accepted/eligible, o 1 si no hay cuentas a las que exigirlo
+func coverageRatio(accepted, eligible int64) float64 {
+	if eligible <= 0 {
+		return 1
+	}
+	return float64(accepted) / float64(eligible)
+}
+
+func (s *ConsentService) record(tx *gorm.DB, actor AdminActor, action string, document *models.ConsentDocument) error {
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		ActorID:   actor.UserID,
+		Action:    action,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details: models.JSONMap{
+			"document_id": document.ID,
+			"type":        document.Type,
+			"version":     document.Version,
+			"mandatory":   document.Mandatory,
+		},
+	})
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"it-auth-service/internal/models"
+)
+
+func TestPendingConsents(t *testing.T) {
+	terms := &models.ConsentDocument{ID: "terms-v2", Type: models.ConsentTypeTermsOfService, Version: "2", Mandatory: true}
+	privacy := &models.ConsentDocument{ID: "privacy-v1", Type: models.ConsentTypePrivacyPolicy, Version: "1", Mandatory: true}
+	optional := &models.ConsentDocument{ID: "privacy-v2", Type: models.ConsentTypePrivacyPolicy, Version: "2"}
+
+	// Aceptar una versión anterior no cubre la vigente
+	accepted := []*models.ConsentAcceptance{
+		{DocumentID: "terms-v1", Type: models.ConsentTypeTermsOfService, Version: "1"},
+		{DocumentID: "privacy-v1", Type: models.ConsentTypePrivacyPolicy, Version: "1"},
+	}
+	assert.Equal(t, []*models.ConsentDocument{terms}, pendingConsents([]*models.ConsentDocument{terms, privacy}, accepted))
+
+	// Las versiones no obligatorias nunca bloquean el login
+	assert.Empty(t, pendingConsents([]*models.ConsentDocument{optional}, nil))
+	assert.Empty(t, pendingConsents(nil, accepted))
+}
+
+func TestConsentRequired(t *testing.T) {
+	terms := &models.ConsentDocument{ID: "terms-v2", Type: models.ConsentTypeTermsOfService, Version: "2", Mandatory: true}
+
+	// Sin versiones pendientes se emite la sesión
+	assert.Nil(t, consentRequired(nil, false))
+
+	// Con versiones pendientes no hay token, sólo la lista de documentos que aceptar
+	response := consentRequired([]*models.ConsentDocument{terms}, true)
+	assert.True(t, response.ConsentRequired)
+	assert.True(t, response.IsNewUser)
+	assert.Empty(t, response.Token)
+	assert.Equal(t, []*models.ConsentDocument{terms}, response.RequiredConsents)
+}
+
+func TestCoverageRatio(t *testing.T) {
+	assert.Equal(t, 0.25, coverageRatio(1, 4))
+	assert.Equal(t, 1.0, coverageRatio(0, 0))
+	assert.Equal(t, 0.0, coverageRatio(0, 10))
+}
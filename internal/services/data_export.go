@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+// Ruta pública de descarga; la autoriza la firma del enlace, no un JWT
+const dataExportDownloadPath = "/api/v1/users/data-exports/%s/download"
+
+// Motivos de fallo que ve el usuario
+const (
+	dataExportErrorAssemble    = "failed to assemble export"
+	dataExportErrorInterrupted = "export was interrupted, request it again"
+)
+
+var (
+	ErrDataExportNotFound    = errors.New("data export not found")
+	ErrDataExportTooSoon     = errors.New("a data export was requested recently, try again later")
+	ErrDataExportLinkInvalid = errors.New("download link is invalid or has expired")
+)
+
+// DataExportService genera las exportaciones de datos personales que el usuario
+// solicita (derecho de acceso del RGPD) y las sirve mediante enlaces firmados
+type DataExportService struct {
+	db         *gorm.DB
+	audit      *AuditService
+	logger     *logrus.Logger
+	signingKey []byte
+	linkTTL    time.Duration
+	cooldown   time.Duration
+	runTimeout time.Duration
+}
+
+func NewDataExportService(db *gorm.DB, auditService *AuditService, cfg *config.Config) *DataExportService {
+	// Clave propia derivada del secreto JWT para no reutilizarlo tal cual en otro contexto
+	key := sha256.Sum256([]byte("data-export:" + cfg.JWTSecret))
+
+	return &DataExportService{
+		db:         db,
+		audit:      auditService,
+		logger:     logger.GetLogger(),
+		signingKey: key[:],
+		linkTTL:    cfg.DataExportConfig.LinkTTL,
+		cooldown:   cfg.DataExportConfig.Cooldown,
+		runTimeout: cfg.DataExportConfig.RunTimeout,
+	}
+}
+
+// RequestExport encola una exportación. Si ya hay una en curso se devuelve esa misma,
+// y no se admite una nueva hasta que pase el cooldown desde la anterior. Una exportación
+// que lleva más de runTimeout sin terminar se abandonó (p. ej. por un reinicio): se da
+// por fallida y no impide pedir otra.
+func (s *DataExportService) RequestExport(ctx context.Context, userID, ipAddress, userAgent string) (*models.DataExport, error) {
+	var latest models.DataExport
+	err := s.db.WithContext(ctx).Omit("bundle").Where("user_id = ?", userID).Order("requested_at DESC").First(&latest).Error
+	switch {
+	case err == nil:
+		if exportInFlight(&latest) {
+			if !exportStale(&latest, time.Now(), s.runTimeout) {
+				return &latest, nil
+			}
+			if err := s.failExport(ctx, latest.ID, dataExportErrorInterrupted); err != nil {
+				return nil, fmt.Errorf("database error: %w", err)
+			}
+			latest.Status = models.DataExportStatusFailed
+		}
+		if latest.Status != models.DataExportStatusFailed && time.Since(latest.RequestedAt) < s.cooldown {
+			return nil, ErrDataExportTooSoon
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	export := &models.DataExport{
+		UserID: userID,
+		Status: models.DataExportStatusPending,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(export).Error; err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:    userID,
+			ActorID:   userID,
+			Action:    models.AuditActionDataExportRequested,
+			IPAddress: ipAddress,
+			UserAgent: userAgent,
+			Details:   models.JSONMap{"export_id": export.ID},
+		})
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create data export")
+		return nil, fmt.Errorf("failed to create data export: %w", err)
+	}
+
+	go s.runExport(export.ID, userID)
+
+	return export, nil
+}
+
+// GetExport devuelve una exportación del usuario con el enlace de descarga si está lista
+func (s *DataExportService) GetExport(ctx context.Context, userID, exportID string) (*models.DataExport, error) {
+	var export models.DataExport
+	err := s.db.WithContext(ctx).Omit("bundle").
+		Where("id::text = ? AND user_id = ?", exportID, userID).
+		First(&export).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataExportNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if export.Status == models.DataExportStatusCompleted && export.ExpiresAt != nil && time.Now().Before(*export.ExpiresAt) {
+		export.DownloadURL = s.downloadURL(export.ID, *export.ExpiresAt)
+	}
+	return &export, nil
+}
+
+// Download valida el enlace firmado y devuelve el fichero de la exportación
+func (s *DataExportService) Download(ctx context.Context, exportID, expires, signature, ipAddress, userAgent string) (*models.DataExport, error) {
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() >= expiresUnix {
+		return nil, ErrDataExportLinkInvalid
+	}
+	expected := s.sign(exportID, expiresUnix)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, ErrDataExportLinkInvalid
+	}
+
+	var export models.DataExport
+	err = s.db.WithContext(ctx).
+		Where("id::text = ? AND status = ? AND expires_at > ?", exportID, models.DataExportStatusCompleted, time.Now()).
+		First(&export).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDataExportLinkInvalid
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Model(&export).Update("downloaded_at", now).Error; err != nil {
+		s.logger.WithError(err).Warn("Failed to record data export download")
+	}
+	if err := s.audit.Record(ctx, &models.AuditLog{
+		UserID:    export.UserID,
+		Action:    models.AuditActionDataExportDownloaded,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		Details:   models.JSONMap{"export_id": export.ID},
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit data export download")
+	}
+
+	return &export, nil
+}
+
+// PurgeExpired borra el contenido de las exportaciones caducadas
+func (s *DataExportService) PurgeExpired(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.DataExport{}).
+		Where("status = ? AND expires_at <= ?", models.DataExportStatusCompleted, time.Now()).
+		Updates(map[string]interface{}{
+			"status": models.DataExportStatusExpired,
+			"bundle": nil,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge expired data exports: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		s.logger.WithField("count", result.RowsAffected).Info("Expired data exports purged")
+	}
+	return result.RowsAffected, nil
+}
+
+// FailStale da por fallidas las exportaciones que llevan más de runTimeout pendientes o
+// en curso. Las que estaban en marcha al reiniciarse el servicio no van a terminar nunca.
+func (s *DataExportService) FailStale(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&models.DataExport{}).
+		Where("status IN ? AND requested_at < ?",
+			[]string{models.DataExportStatusPending, models.DataExportStatusRunning},
+			time.Now().Add(-s.runTimeout)).
+		Updates(map[string]interface{}{
+			"status": models.DataExportStatusFailed,
+			"error":  dataExportErrorInterrupted,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to fail stale data exports: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		s.logger.WithField("count", result.RowsAffected).Warn("Stale data exports marked as failed")
+	}
+	return result.RowsAffected, nil
+}
+
+// failExport marca una exportación sin terminar como fallida
+func (s *DataExportService) failExport(ctx context.Context, exportID, reason string) error {
+	return s.db.WithContext(ctx).Model(&models.DataExport{}).
+		Where("id = ? AND status IN ?", exportID, []string{models.DataExportStatusPending, models.DataExportStatusRunning}).
+		Updates(map[string]interface{}{
+			"status": models.DataExportStatusFailed,
+			"error":  reason,
+		}).Error
+}
+
+// exportInFlight indica si la exportación todavía no ha terminado
+func exportInFlight(export *models.DataExport) bool {
+	return export.Status == models.DataExportStatusPending || export.Status == models.DataExportStatusRunning
+}
+
+// exportStale indica si una exportación sin terminar se solicitó hace más de timeout
+func exportStale(export *models.DataExport, now time.Time, timeout time.Duration) bool {
+	return exportInFlight(export) && now.Sub(export.RequestedAt) > timeout
+}
+
+func (s *DataExportService) runExport(exportID, userID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.runTimeout)
+	defer cancel()
+	log := s.logger.WithFields(map[string]interface{}{"export_id": exportID, "user_id": userID})
+
+	// Un pánico no puede dejar la exportación en curso para siempre
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithField("panic", r).Error("Data export panicked")
+			if err := s.failExport(context.Background(), exportID, dataExportErrorAssemble); err != nil {
+				log.WithError(err).Error("Failed to mark data export as failed")
+			}
+		}
+	}()
+
+	if err := s.db.WithContext(ctx).Model(&models.DataExport{}).
+		Where("id = ?", exportID).
+		Update("status", models.DataExportStatusRunning).Error; err != nil {
+		log.WithError(err).Warn("Failed to mark data export as running")
+	}
+
+	updates := map[string]interface{}{}
+	data, err := s.buildBundle(ctx, exportID, userID)
+	if err == nil {
+		now := time.Now()
+		updates["status"] = models.DataExportStatusCompleted
+		updates["bundle"] = data
+		updates["size_bytes"] = len(data)
+		updates["completed_at"] = now
+		updates["expires_at"] = now.Add(s.linkTTL)
+	} else {
+		log.WithError(err).Error("Data export failed")
+		updates["status"] = models.DataExportStatusFailed
+		updates["error"] = dataExportErrorAssemble
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.DataExport{}).Where("id = ?", exportID).Updates(updates).Error; err != nil {
+		log.WithError(err).Error("Failed to save data export")
+		return
+	}
+	log.Info("Data export finished")
+}
+
+// buildBundle reúne todos los datos personales que el servicio guarda del usuario
+func (s *DataExportService) buildBundle(ctx context.Context, exportID, userID string) ([]byte, error) {
+	db := s.db.WithContext(ctx)
+	bundle := &models.DataExportBundle{
+		ExportID:    exportID,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	bundle.User = &user
+	bundle.Attributes = user.Attributes
+
+	if err := db.Where("user_id = ?", userID).Order("linked_at ASC").Find(&bundle.Identities).Error; err != nil {
+		return nil, fmt.Errorf("failed to load identities: %w", err)
+	}
+	if err := db.Preload("Organization").Where("user_id = ?", userID).Order("created_at ASC").Find(&bundle.Memberships).Error; err != nil {
+		return nil, fmt.Errorf("failed to load organization memberships: %w", err)
+	}
+	if err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&bundle.GroupMemberships).Error; err != nil {
+		return nil, fmt.Errorf("failed to load group memberships: %w", err)
+	}
+	if err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&bundle.EmailChanges).Error; err != nil {
+		return nil, fmt.Errorf("failed to load email changes: %w", err)
+	}
+	if err := db.Where("user_id = ?", userID).Order("accepted_at ASC").Find(&bundle.Consents).Error; err != nil {
+		return nil, fmt.Errorf("failed to load consents: %w", err)
+	}
+
+	// Las verificaciones de email y los resets de contraseña se asocian por Firebase UID o email
+	subjects := []string{user.FirebaseID}
+	for _, identity := range bundle.Identities {
+		subjects = append(subjects, identity.Subject)
+	}
+
+	queries := []struct {
+		name  string
+		query *gorm.DB
+		dest  interface{}
+	}{
+		{"sessions", db.Where("user_id = ?", userID).Order("login_at ASC"), &bundle.Sessions},
+		{"revoked tokens", db.Where("user_id = ?", userID).Order("revoked_at ASC"), &bundle.RevokedTokens},
+		{"email verifications", db.Where("firebase_id IN ? OR email = ?", subjects, user.Email).Order("created_at ASC"), &bundle.EmailVerifications},
+		{"password resets", db.Where("firebase_id IN ? OR email = ?", subjects, user.Email).Order("created_at ASC"), &bundle.PasswordResets},
+		// Sólo los eventos sobre el propio usuario: los que ejecutó como administrador
+		// contienen la IP, el user agent y los datos de otras personas
+		{"audit logs", db.Where("user_id = ?", userID).Order("created_at ASC"), &bundle.AuditLogs},
+	}
+	for _, q := range queries {
+		if err := q.query.Find(q.dest).Error; err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", q.name, err)
+		}
+	}
+
+	return json.MarshalIndent(bundle, "", "  ")
+}
+
+func (s *DataExportService) downloadURL(exportID string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	return fmt.Sprintf(dataExportDownloadPath+"?expires=%d&signature=%s", exportID, expires, s.sign(exportID, expires))
+}
+
+// sign firma el ID de la exportación junto con la caducidad del enlace
+func (s *DataExportService) sign(exportID string, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s.%d", exportID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
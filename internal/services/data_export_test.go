@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/models"
+)
+
+func TestDataExportLinkSignature(t *testing.T) {
+	s := NewDataExportService(nil, nil, &config.Config{JWTSecret: "secret"})
+	expires := time.Now().Add(time.Hour).Unix()
+
+	url := s.downloadURL("abc", time.Unix(expires, 0))
+	assert.Contains(t, url, "/api/v1/users/data-exports/abc/download?expires="+strconv.FormatInt(expires, 10))
+	assert.Contains(t, url, "signature="+s.sign("abc", expires))
+
+	assert.NotEqual(t, s.sign("abc", expires), s.sign("abd", expires))
+	assert.NotEqual(t, s.sign("abc", expires), s.sign("abc", expires+1))
+
+	other := NewDataExportService(nil, nil, &config.Config{JWTSecret: "other"})
+	assert.NotEqual(t, s.sign("abc", expires), other.sign("abc", expires))
+}
+
+func TestDataExportDownloadRejectsBadLinks(t *testing.T) {
+	s := NewDataExportService(nil, nil, &config.Config{JWTSecret: "secret"})
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute).Unix()
+	_, err := s.Download(ctx, "abc", strconv.FormatInt(past, 10), s.sign("abc", past), "", "")
+	require.ErrorIs(t, err, ErrDataExportLinkInvalid)
+
+	future := time.Now().Add(time.Hour).Unix()
+	_, err = s.Download(ctx, "abc", strconv.FormatInt(future, 10), "deadbeef", "", "")
+	require.ErrorIs(t, err, ErrDataExportLinkInvalid)
+
+	_, err = s.Download(ctx, "abc", "soon", s.sign("abc", future), "", "")
+	require.ErrorIs(t, err, ErrDataExportLinkInvalid)
+}
+
+func TestExportStale(t *testing.T) {
+	now := time.Now()
+	timeout := 30 * time.Minute
+	export := func(status string, age time.Duration) *models.DataExport {
+		return &models.DataExport{Status: status, RequestedAt: now.Add(-age)}
+	}
+
+	assert.False(t, exportStale(export(models.DataExportStatusRunning, time.Minute), now, timeout))
+	assert.True(t, exportStale(export(models.DataExportStatusRunning, time.Hour), now, timeout))
+	assert.True(t, exportStale(export(models.DataExportStatusPending, time.Hour), now, timeout))
+
+	// Las exportaciones terminadas no se dan por abandonadas
+	assert.False(t, exportStale(export(models.DataExportStatusCompleted, time.Hour), now, timeout))
+	assert.False(t, exportStale(export(models.DataExportStatusFailed, time.Hour), now, timeout))
+}
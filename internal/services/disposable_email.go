@@ -0,0 +1,140 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+var ErrDisposableEmail = errors.New("disposable email addresses are not allowed")
+
+// builtinDisposableDomains son los servicios de email desechable más usados; el fichero
+// DISPOSABLE_EMAIL_DOMAINS_FILE amplía la lista sin desplegar una versión nueva
+var builtinDisposableDomains = []string{
+	"10minutemail.com", "20minutemail.com", "33mail.com", "burnermail.io", "discard.email",
+	"dispostable.com", "emailondeck.com", "fakeinbox.com", "getairmail.com", "getnada.com",
+	"grr.la", "guerrillamail.com", "guerrillamail.net", "guerrillamail.org", "guerrillamailblock.com",
+	"inboxkitten.com", "mailcatch.com", "maildrop.cc", "mailinator.com", "mailnesia.com",
+	"mintemail.com", "mohmal.com", "moakt.com", "mytemp.email", "sharklasers.com",
+	"spamgourmet.com", "temp-mail.org", "tempail.com", "tempmail.com", "tempmail.net",
+	"tempr.email", "throwawaymail.com", "trashmail.com", "trashmail.de", "yopmail.com",
+	"yopmail.fr",
+}
+
+// DisposableEmailService detecta las direcciones de servicios de email desechable. La
+// lista se mantiene en memoria y se relee periódicamente del fichero configurado.
+type DisposableEmailService struct {
+	cfg    config.DisposableEmailConfig
+	logger *logrus.Logger
+
+	mu          sync.RWMutex
+	domains     map[string]bool
+	fileDomains int
+	loadedAt    time.Time
+}
+
+func NewDisposableEmailService(cfg *config.Config) (*DisposableEmailService, error) {
+	s := &DisposableEmailService{
+		cfg:    cfg.DisposableEmailConfig,
+		logger: logger.GetLogger(),
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload vuelve a leer el fichero de dominios. Si no puede leerse se conserva la lista anterior.
+func (s *DisposableEmailService) Reload() (*models.DisposableDomainsStatus, error) {
+	domains := make(map[string]bool, len(builtinDisposableDomains))
+	for _, domain := range builtinDisposableDomains {
+		domains[domain] = true
+	}
+
+	fileDomains := 0
+	if s.cfg.DomainsFile != "" {
+		file, err := os.Open(s.cfg.DomainsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open disposable domains file: %w", err)
+		}
+		defer file.Close()
+
+		if fileDomains, err = readDomainList(file, domains); err != nil {
+			return nil, fmt.Errorf("failed to read disposable domains file: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	changed := len(domains) != len(s.domains)
+	s.domains = domains
+	s.fileDomains = fileDomains
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	if changed {
+		s.logger.WithFields(map[string]interface{}{
+			"domains": len(domains),
+			"file":    s.cfg.DomainsFile,
+		}).Info("Disposable email domains loaded")
+	}
+	return s.Status(), nil
+}
+
+// readDomainList añade a domains los dominios de r, uno por línea, sin contar las
+// líneas vacías ni los comentarios (#). Devuelve cuántos dominios ha leído.
+func readDomainList(r io.Reader, domains map[string]bool) (int, error) {
+	count := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if domain := normalizeDomain(line); domain != "" {
+			domains[domain] = true
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
+
+// IsDisposable indica si el email pertenece a un dominio desechable o a uno de sus subdominios
+func (s *DisposableEmailService) IsDisposable(email string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, domain := range parentDomains(emailDomain(email)) {
+		if s.domains[domain] {
+			return true
+		}
+	}
+	return false
+}
+
+// Check rechaza los emails desechables si la configuración lo pide
+func (s *DisposableEmailService) Check(email string) error {
+	if s.cfg.Block && s.IsDisposable(email) {
+		return ErrDisposableEmail
+	}
+	return nil
+}
+
+// Status resume la lista cargada en esta instancia
+func (s *DisposableEmailService) Status() *models.DisposableDomainsStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &models.DisposableDomainsStatus{
+		Block:          s.cfg.Block,
+		Domains:        len(s.domains),
+		BuiltinDomains: len(builtinDisposableDomains),
+		File:           s.cfg.DomainsFile,
+		FileDomains:    s.fileDomains,
+		LoadedAt:       s.loadedAt,
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/config"
+)
+
+func TestReadDomainList(t *testing.T) {
+	domains := map[string]bool{}
+	count, err := readDomainList(strings.NewReader("# Lista local\nThrowaway.io\n\n  spam.example.  # dominio de pruebas\n"), domains)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, map[string]bool{"throwaway.io": true, "spam.example": true}, domains)
+}
+
+func TestDisposableEmailCheck(t *testing.T) {
+	service := &DisposableEmailService{
+		cfg:     config.DisposableEmailConfig{Block: true},
+		domains: map[string]bool{"mailinator.com": true},
+	}
+
+	assert.ErrorIs(t, service.Check("spam@Mailinator.com"), ErrDisposableEmail)
+	assert.ErrorIs(t, service.Check("spam@eu.mailinator.com"), ErrDisposableEmail)
+	assert.NoError(t, service.Check("jane@example.com"))
+	assert.NoError(t, service.Check("jane@notmailinator.com"))
+
+	// Con el rechazo desactivado sólo se detectan
+	service.cfg.Block = false
+	assert.True(t, service.IsDisposable("spam@mailinator.com"))
+	assert.NoError(t, service.Check("spam@mailinator.com"))
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+var (
+	ErrDomainPolicyNotFound = errors.New("domain policy not found")
+	ErrDomainPolicyExists   = errors.New("a policy for this domain already exists")
+	ErrInvalidDomainPolicy  = errors.New("invalid domain policy")
+	ErrSignupDomainBlocked  = errors.New("signups from this email domain are not allowed")
+)
+
+// DomainPolicyService gestiona las reglas por dominio de email que se aplican en el
+// registro y el login: bloquear altas, verificar automáticamente el email de los
+// dominios corporativos de confianza y unir las cuentas a una organización
+type DomainPolicyService struct {
+	db     *gorm.DB
+	audit  *AuditService
+	logger *logrus.Logger
+}
+
+func NewDomainPolicyService(db *gorm.DB, auditService *AuditService) *DomainPolicyService {
+	return &DomainPolicyService{
+		db:     db,
+		audit:  auditService,
+		logger: logger.GetLogger(),
+	}
+}
+
+// normalizeDomain pasa el dominio a minúsculas sin "@" inicial ni punto final
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	return strings.TrimSuffix(strings.TrimPrefix(domain, "@"), ".")
+}
+
+// emailDomain devuelve el dominio normalizado de un email, o "" si no tiene
+func emailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return normalizeDomain(email[at+1:])
+}
+
+// parentDomains devuelve el dominio y todos sus dominios padre, del más específico al más general
+func parentDomains(domain string) []string {
+	var domains []string
+	for domain != "" {
+		domains = append(domains, domain)
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok {
+			break
+		}
+		domain = parent
+	}
+	return domains
+}
+
+// matchDomainPolicy elige la regla del dominio: la exacta o, si no hay, la del
+// dominio padre más cercano que incluya subdominios
+func matchDomainPolicy(policies []*models.DomainPolicy, domain string) *models.DomainPolicy {
+	byDomain := make(map[string]*models.DomainPolicy, len(policies))
+	for _, policy := range policies {
+		byDomain[policy.Domain] = policy
+	}
+	for i, candidate := range parentDomains(domain) {
+		if policy, ok := byDomain[candidate]; ok && (i == 0 || policy.IncludeSubdomains) {
+			return policy
+		}
+	}
+	return nil
+}
+
+// checkSignupDomain rechaza el alta si el dominio la bloquea; una invitación la permite
+func checkSignupDomain(policy *models.DomainPolicy, invited bool) error {
+	if policy != nil && policy.BlockSignup && !invited {
+		return ErrSignupDomainBlocked
+	}
+	return nil
+}
+
+// Resolve devuelve la regla aplicable al email, o nil si ninguna coincide
+func (s *DomainPolicyService) Resolve(ctx context.Context, email string) (*models.DomainPolicy, error) {
+	domain := emailDomain(email)
+	if domain == "" {
+		return nil, nil
+	}
+
+	var policies []*models.DomainPolicy
+	if err := s.db.WithContext(ctx).Where("domain IN ?", parentDomains(domain)).Find(&policies).Error; err != nil {
+		return nil, fmt.Errorf("failed to load domain policies: %w", err)
+	}
+	return matchDomainPolicy(policies, domain), nil
+}
+
+// List devuelve todas las reglas ordenadas por dominio
+func (s *DomainPolicyService) List(ctx context.Context) ([]*models.DomainPolicy, error) {
+	policies := []*models.DomainPolicy{}
+	if err := s.db.WithContext(ctx).Order("domain ASC").Find(&policies).Error; err != nil {
+		return nil, fmt.Errorf("failed to list domain policies: %w", err)
+	}
+	return policies, nil
+}
+
+// Create añade la regla de un dominio
+func (s *DomainPolicyService) Create(ctx context.Context, actor AdminActor, req *models.DomainPolicyRequest) (*models.DomainPolicy, error) {
+	policy := &models.DomainPolicy{CreatedBy: actor.UserID}
+	applyDomainPolicyRequest(policy, req)
+	if err := validateDomainPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkAutoJoinOrgTx(tx, policy); err != nil {
+			return err
+		}
+		if err := tx.Create(policy).Error; err != nil {
+			return err
+		}
+		return s.record(tx, actor, "created", policy)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrDomainPolicyExists
+		}
+		if errors.Is(err, ErrOrganizationNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create domain policy: %w", err)
+	}
+	return policy, nil
+}
+
+// Update reemplaza la regla, incluido su dominio
+func (s *DomainPolicyService) Update(ctx context.Context, actor AdminActor, policyID string, req *models.DomainPolicyRequest) (*models.DomainPolicy, error) {
+	var policy models.DomainPolicy
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockDomainPolicy(tx, policyID, &policy); err != nil {
+			return err
+		}
+		applyDomainPolicyRequest(&policy, req)
+		if err := validateDomainPolicy(&policy); err != nil {
+			return err
+		}
+		if err := checkAutoJoinOrgTx(tx, &policy); err != nil {
+			return err
+		}
+		if err := tx.Model(&policy).Select("domain", "include_subdomains", "block_signup", "auto_verify",
+			"auto_join_org_id", "auto_join_role").Updates(&policy).Error; err != nil {
+			return err
+		}
+		return s.record(tx, actor, "updated", &policy)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrDomainPolicyExists
+		}
+		if errors.Is(err, ErrDomainPolicyNotFound) || errors.Is(err, ErrInvalidDomainPolicy) ||
+			errors.Is(err, ErrOrganizationNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update domain policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Delete elimina la regla; las cuentas ya creadas o unidas no cambian
+func (s *DomainPolicyService) Delete(ctx context.Context, actor AdminActor, policyID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var policy models.DomainPolicy
+		if err := lockDomainPolicy(tx, policyID, &policy); err != nil {
+			return err
+		}
+		if err := tx.Delete(&policy).Error; err != nil {
+			return err
+		}
+		return s.record(tx, actor, "deleted", &policy)
+	})
+	if err != nil {
+		if errors.Is(err, ErrDomainPolicyNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to delete domain policy: %w", err)
+	}
+	return nil
+}
+
+// AutoJoin une la cuenta a la organización de la regla si su email está verificado.
+// Si ya es miembro conserva su rol actual.
+func (s *DomainPolicyService) AutoJoin(ctx context.Context, policy *models.DomainPolicy, user *models.User) error {
+	if policy == nil || policy.AutoJoinOrgID == nil || !user.EmailVerified {
+		return nil
+	}
+
+	membership := &models.OrganizationMembership{OrgID: *policy.AutoJoinOrgID, UserID: user.ID, Role: policy.AutoJoinRole}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(membership)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID: user.ID,
+			Action: models.AuditActionOrgMemberAdded,
+			Details: models.JSONMap{
+				"org_id":           membership.OrgID,
+				"role":             membership.Role,
+				"domain_policy_id": policy.ID,
+				"domain":           policy.Domain,
+			},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to auto-join organization: %w", err)
+	}
+	return nil
+}
+
+func applyDomainPolicyRequest(policy *models.DomainPolicy, req *models.DomainPolicyRequest) {
+	policy.Domain = normalizeDomain(req.Domain)
+	policy.IncludeSubdomains = req.IncludeSubdomains
+	policy.BlockSignup = req.BlockSignup
+	policy.AutoVerify = req.AutoVerify
+	policy.TrustedProviders = nil
+	for _, provider := range req.TrustedProviders {
+		policy.TrustedProviders = append(policy.TrustedProviders, strings.ToLower(strings.TrimSpace(provider)))
+	}
+	policy.AutoJoinOrgID = req.AutoJoinOrgID
+	policy.AutoJoinRole = req.AutoJoinRole
+	if policy.AutoJoinOrgID == nil {
+		policy.AutoJoinRole = ""
+	}
+}
+
+// validateDomainPolicy rechaza las combinaciones contradictorias y los proveedores que
+// no demuestran el control del buzón
+func validateDomainPolicy(policy *models.DomainPolicy) error {
+	if policy.BlockSignup && (policy.AutoVerify || policy.AutoJoinOrgID != nil) {
+		return fmt.Errorf("%w: a domain that blocks signups cannot auto-verify or auto-join", ErrInvalidDomainPolicy)
+	}
+	if policy.AutoVerify && len(policy.TrustedProviders) == 0 {
+		return fmt.Errorf("%w: auto_verify requires trusted_providers", ErrInvalidDomainPolicy)
+	}
+	for _, provider := range policy.TrustedProviders {
+		if provider == "password" || provider == models.ProviderAnonymous {
+			return fmt.Errorf("%w: %s cannot be a trusted provider", ErrInvalidDomainPolicy, provider)
+		}
+	}
+	return nil
+}
+
+func checkAutoJoinOrgTx(tx *gorm.DB, policy *models.DomainPolicy) error {
+	if policy.AutoJoinOrgID == nil {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.Organization{}).Where("id = ?", *policy.AutoJoinOrgID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrOrganizationNotFound
+	}
+	return nil
+}
+
+func lockDomainPolicy(tx *gorm.DB, policyID string, policy *models.DomainPolicy) error {
+	if !uuidPattern.MatchString(policyID) {
+		return ErrDomainPolicyNotFound
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", policyID).First(policy).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDomainPolicyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *DomainPolicyService) record(tx *gorm.DB, actor AdminActor, change string, policy *models.DomainPolicy) error {
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		ActorID:   actor.UserID,
+		Action:    models.AuditActionDomainPolicyChanged,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details: models.JSONMap{
+			"change":             change,
+			"domain_policy_id":   policy.ID,
+			"domain":             policy.Domain,
+			"include_subdomains": policy.IncludeSubdomains,
+			"block_signup":       policy.BlockSignup,
+			"auto_verify":        policy.AutoVerify,
+			"trusted_providers":  []string(policy.TrustedProviders),
+			"auto_join_org_id":   policy.AutoJoinOrgID,
+			"auto_join_role":     policy.AutoJoinRole,
+		},
+	})
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/stretchr/testify/assert"
+	"it-auth-service/internal/models"
+)
+
+func TestEmailDomain(t *testing.T) {
+	assert.Equal(t, "example.com", emailDomain("Jane.Doe@Example.COM"))
+	assert.Equal(t, "example.com", emailDomain("odd@name@example.com."))
+	assert.Equal(t, "", emailDomain("no-domain"))
+}
+
+func TestMatchDomainPolicy(t *testing.T) {
+	exact := &models.DomainPolicy{Domain: "corp.example.com"}
+	parent := &models.DomainPolicy{Domain: "example.com", IncludeSubdomains: true}
+	other := &models.DomainPolicy{Domain: "other.com"}
+	policies := []*models.DomainPolicy{parent, exact, other}
+
+	// Gana la regla del dominio más específico
+	assert.Same(t, exact, matchDomainPolicy(policies, "corp.example.com"))
+	assert.Same(t, parent, matchDomainPolicy(policies, "eu.corp.example.com"))
+	assert.Same(t, parent, matchDomainPolicy(policies, "example.com"))
+
+	// Sin include_subdomains la regla sólo se aplica al dominio exacto
+	assert.Nil(t, matchDomainPolicy(policies, "mail.other.com"))
+	assert.Same(t, other, matchDomainPolicy(policies, "other.com"))
+	assert.Nil(t, matchDomainPolicy(policies, "example.org"))
+}
+
+func TestCheckSignupDomain(t *testing.T) {
+	blocked := &models.DomainPolicy{Domain: "mailinator.com", BlockSignup: true}
+
+	assert.ErrorIs(t, checkSignupDomain(blocked, false), ErrSignupDomainBlocked)
+	assert.NoError(t, checkSignupDomain(blocked, true))
+	assert.NoError(t, checkSignupDomain(&models.DomainPolicy{Domain: "example.com"}, false))
+	assert.NoError(t, checkSignupDomain(nil, false))
+}
+
+func TestEmailVerifiedByToken(t *testing.T) {
+	token := func(provider string, verified bool) *auth.Token {
+		return &auth.Token{
+			Firebase: auth.FirebaseInfo{SignInProvider: provider},
+			Claims:   map[string]interface{}{"email_verified": verified},
+		}
+	}
+	trusted := &models.DomainPolicy{Domain: "example.com", AutoVerify: true, TrustedProviders: []string{"saml.example"}}
+
+	assert.True(t, emailVerifiedByToken(token("password", true), nil))
+	assert.True(t, emailVerifiedByToken(token("saml.example", false), trusted))
+	assert.False(t, emailVerifiedByToken(token("saml.example", false), nil))
+
+	// Un proveedor federado que no figura en la regla no basta (p. ej. Facebook no verifica el email)
+	assert.False(t, emailVerifiedByToken(token("facebook.com", false), trusted))
+
+	// Con contraseña cualquiera puede registrar un email ajeno: nunca se verifica por dominio
+	assert.False(t, emailVerifiedByToken(token("password", false), trusted))
+	assert.False(t, emailVerifiedByToken(token(models.ProviderAnonymous, false), trusted))
+}
+
+func TestValidateDomainPolicy(t *testing.T) {
+	orgID := "5b1f3c2e-8a4d-4e6f-9b7a-1c2d3e4f5a6b"
+
+	assert.NoError(t, validateDomainPolicy(&models.DomainPolicy{BlockSignup: true}))
+	assert.NoError(t, validateDomainPolicy(&models.DomainPolicy{AutoVerify: true, TrustedProviders: []string{"saml.example"}, AutoJoinOrgID: &orgID}))
+	assert.ErrorIs(t, validateDomainPolicy(&models.DomainPolicy{AutoVerify: true}), ErrInvalidDomainPolicy)
+	assert.ErrorIs(t, validateDomainPolicy(&models.DomainPolicy{AutoVerify: true, TrustedProviders: []string{"password"}}), ErrInvalidDomainPolicy)
+	assert.ErrorIs(t, validateDomainPolicy(&models.DomainPolicy{BlockSignup: true, AutoVerify: true}), ErrInvalidDomainPolicy)
+	assert.ErrorIs(t, validateDomainPolicy(&models.DomainPolicy{BlockSignup: true, AutoJoinOrgID: &orgID}), ErrInvalidDomainPolicy)
+}
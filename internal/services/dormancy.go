@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/mailer"
+)
+
+const reactivationCodeDigits = 6
+
+// Acciones del job de inactividad sobre una cuenta
+const (
+	dormancyWarn       = "warn"
+	dormancyDeactivate = "deactivate"
+)
+
+var (
+	ErrNotDormant              = errors.New("account is not deactivated due to inactivity")
+	ErrReactivationNotFound    = errors.New("no pending reactivation code")
+	ErrReactivationInvalidCode = errors.New("invalid reactivation code")
+	ErrReactivationExpired     = errors.New("reactivation code has expired or too many attempts, request a new one")
+	ErrReactivationTooSoon     = errors.New("too many reactivation requests, try again later")
+)
+
+// DormancyService detecta las cuentas sin actividad: avisa por email al superar
+// WarnAfter y, si tras el aviso siguen sin actividad, las desactiva al superar
+// DeactivateAfter. Las cuentas de organizaciones exentas nunca se tocan. Una cuenta
+// desactivada vuelve a estar activa confirmando un código enviado a su email.
+type DormancyService struct {
+	db        *gorm.DB
+	lifecycle *LifecycleService
+	audit     *AuditService
+	mailer    mailer.Mailer
+	logger    *logrus.Logger
+	cfg       config.DormancyConfig
+}
+
+func NewDormancyService(db *gorm.DB, lifecycleService *LifecycleService, auditService *AuditService, mail mailer.Mailer, cfg *config.Config) *DormancyService {
+	return &DormancyService{
+		db:        db,
+		lifecycle: lifecycleService,
+		audit:     auditService,
+		mailer:    mail,
+		logger:    logger.GetLogger(),
+		cfg:       cfg.DormancyConfig,
+	}
+}
+
+// lastActivity replica UserLastActivityExpression sobre un usuario ya cargado
+func lastActivity(user *models.User) time.Time {
+	if user.LastSeenAt != nil {
+		return *user.LastSeenAt
+	}
+	if user.LastLoginAt != nil {
+		return *user.LastLoginAt
+	}
+	return user.CreatedAt
+}
+
+// lastSeenResolution es cada cuánto se guarda como mucho la última petición de una cuenta
+const lastSeenResolution = time.Hour
+
+// TouchLastSeen registra una petición autenticada del usuario para que la inactividad no
+// dependa sólo de los logins. Para no escribir en cada petición sólo actualiza
+// last_seen_at si ha pasado lastSeenResolution desde la última vez.
+func (s *UserService) TouchLastSeen(ctx context.Context, user *models.User) {
+	now := time.Now()
+	if user.LastSeenAt != nil && now.Sub(*user.LastSeenAt) < lastSeenResolution {
+		return
+	}
+	err := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)", user.ID, now.Add(-lastSeenResolution)).
+		UpdateColumn("last_seen_at", now).Error
+	if err != nil {
+		s.logger.WithError(err).Debug("Failed to update user last seen")
+		return
+	}
+	user.LastSeenAt = &now
+}
+
+// dormancyNotice es el tiempo mínimo entre el aviso y la desactivación
+func dormancyNotice(cfg config.DormancyConfig) time.Duration {
+	return cfg.DeactivateAfter - cfg.WarnAfter
+}
+
+// dormancyAction decide qué corresponde a una cuenta según su inactividad: avisar si
+// no se le ha avisado desde su última actividad y desactivar si ya se le avisó con
+// la antelación configurada. Devuelve "" si no hay nada que hacer.
+func dormancyAction(user *models.User, now time.Time, cfg config.DormancyConfig) string {
+	if user.Status != models.StatusActive {
+		return ""
+	}
+	activity := lastActivity(user)
+	warned := user.DormancyWarnedAt != nil && user.DormancyWarnedAt.After(activity)
+	switch {
+	case warned && activity.Before(now.Add(-cfg.DeactivateAfter)) &&
+		!user.DormancyWarnedAt.After(now.Add(-dormancyNotice(cfg))):
+		return dormancyDeactivate
+	case !warned && activity.Before(now.Add(-cfg.WarnAfter)):
+		return dormancyWarn
+	}
+	return ""
+}
+
+// dormancyDeadline es la fecha a partir de la cual se desactivará una cuenta avisada en now
+func dormancyDeadline(activity, now time.Time, cfg config.DormancyConfig) time.Time {
+	deadline := activity.Add(cfg.DeactivateAfter)
+	if notice := now.Add(dormancyNotice(cfg)); notice.After(deadline) {
+		return notice
+	}
+	return deadline
+}
+
+// isDormant indica si la cuenta fue desactivada por inactividad
+func isDormant(user *models.User) bool {
+	return user.Status == models.StatusPendingVerification && user.StatusReason == statusReasonDormant
+}
+
+// Report enumera, sin cambiar nada, las cuentas que la próxima ejecución avisaría y desactivaría
+func (s *DormancyService) Report(ctx context.Context, limit int) (*models.DormancyReport, error) {
+	return s.plan(ctx, time.Now(), limit, true)
+}
+
+// Run ejecuta una pasada del job: desactiva las cuentas ya avisadas y avisa a las
+// nuevas. En modo dry-run sólo registra en el log lo que haría.
+func (s *DormancyService) Run(ctx context.Context) (*models.DormancyReport, error) {
+	now := time.Now()
+	report, err := s.plan(ctx, now, s.cfg.BatchSize, s.cfg.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	log := s.logger.WithFields(map[string]interface{}{
+		"to_warn":       len(report.ToWarn),
+		"to_deactivate": len(report.ToDeactivate),
+	})
+	if report.DryRun {
+		log.Info("Dormancy dry run")
+		return report, nil
+	}
+
+	for _, dormant := range report.ToDeactivate {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		deactivated, err := s.deactivate(ctx, dormant)
+		if err != nil {
+			s.logger.WithError(err).WithField("user_id", dormant.UserID).Error("Failed to deactivate dormant user")
+			continue
+		}
+		if deactivated {
+			report.Deactivated++
+		}
+	}
+	for _, dormant := range report.ToWarn {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		warned, err := s.warn(ctx, dormant, now)
+		if err != nil {
+			s.logger.WithError(err).WithField("user_id", dormant.UserID).Error("Failed to warn dormant user")
+			continue
+		}
+		if warned {
+			report.Warned++
+		}
+	}
+
+	if report.Warned > 0 || report.Deactivated > 0 {
+		s.logger.WithFields(map[string]interface{}{
+			"warned":      report.Warned,
+			"deactivated": report.Deactivated,
+		}).Info("Dormant users processed")
+	}
+	return report, nil
+}
+
+func (s *DormancyService) plan(ctx context.Context, now time.Time, limit int, dryRun bool) (*models.DormancyReport, error) {
+	report := &models.DormancyReport{
+		DryRun:           dryRun,
+		GeneratedAt:      now,
+		WarnBefore:       now.Add(-s.cfg.WarnAfter),
+		DeactivateBefore: now.Add(-s.cfg.DeactivateAfter),
+		ExemptTenants:    []string{},
+		ToWarn:           []*models.DormantUser{},
+		ToDeactivate:     []*models.DormantUser{},
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Organization{}).
+		Where("dormancy_exempt").
+		Order("slug").
+		Pluck("slug", &report.ExemptTenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dormancy exempt organizations: %w", err)
+	}
+
+	activity := models.UserLastActivityExpression
+	if err := s.candidates(ctx, limit).
+		Where(activity+" < ?", report.DeactivateBefore).
+		Where("users.dormancy_warned_at > "+activity+" AND users.dormancy_warned_at <= ?", now.Add(-dormancyNotice(s.cfg))).
+		Scan(&report.ToDeactivate).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dormant users: %w", err)
+	}
+	if err := s.candidates(ctx, limit).
+		Where(activity+" < ?", report.WarnBefore).
+		Where("users.dormancy_warned_at IS NULL OR users.dormancy_warned_at <= " + activity).
+		Scan(&report.ToWarn).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dormant users: %w", err)
+	}
+	return report, nil
+}
+
+// candidates selecciona las cuentas activas que no pertenecen a ninguna organización exenta
+func (s *DormancyService) candidates(ctx context.Context, limit int) *gorm.DB {
+	activity := models.UserLastActivityExpression
+	return s.db.WithContext(ctx).Model(&models.User{}).
+		Select("users.id AS user_id, users.email, "+activity+" AS last_activity_at, users.dormancy_warned_at AS warned_at").
+		Where("users.status = ?", models.StatusActive).
+		Where(`NOT EXISTS (SELECT 1 FROM organization_memberships m
+			JOIN organizations o ON o.id = m.org_id
+			WHERE m.user_id = users.id AND o.dormancy_exempt)`).
+		Order(activity).
+		Limit(limit)
+}
+
+// warn envía el aviso y lo registra. El email sale antes de guardar el aviso: si
+// falla el registro, la próxima ejecución lo repite en lugar de perderlo.
+func (s *DormancyService) warn(ctx context.Context, dormant *models.DormantUser, now time.Time) (bool, error) {
+	deadline := dormancyDeadline(dormant.LastActivityAt, now, s.cfg)
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      dormant.Email,
+		Subject: "Your account will be deactivated due to inactivity",
+		Body: fmt.Sprintf("We have not seen any activity on your account since %s.\n\nSign in before %s to keep it active. After that date the account will be deactivated and you will need to confirm your email address to reactivate it.\n",
+			dormant.LastActivityAt.UTC().Format(time.RFC1123), deadline.UTC().Format(time.RFC1123)),
+	}); err != nil {
+		return false, err
+	}
+
+	warned := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", dormant.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		if dormancyAction(&user, now, s.cfg) != dormancyWarn {
+			return nil
+		}
+		if err := tx.Model(&user).Update("dormancy_warned_at", now).Error; err != nil {
+			return err
+		}
+		warned = true
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID: user.ID,
+			Action: models.AuditActionDormancyWarned,
+			Details: models.JSONMap{
+				"last_activity_at": lastActivity(&user),
+				"deactivate_at":    deadline,
+			},
+		})
+	})
+	return warned, err
+}
+
+// deactivate pasa la cuenta a pending_verification si sigue cumpliendo las
+// condiciones (pudo iniciar sesión después de generar el informe) y avisa al usuario
+func (s *DormancyService) deactivate(ctx context.Context, dormant *models.DormantUser) (bool, error) {
+	deactivated := false
+	user, err := s.lifecycle.transition(ctx, dormant.UserID, func(_ *gorm.DB, user *models.User) (*StatusChange, error) {
+		if dormancyAction(user, time.Now(), s.cfg) != dormancyDeactivate {
+			return nil, nil
+		}
+		deactivated = true
+		return &StatusChange{To: models.StatusPendingVerification, Reason: statusReasonDormant}, nil
+	})
+	if err != nil || !deactivated {
+		return false, err
+	}
+
+	body := "Your account was deactivated because it has not been used for a long time.\n\nTo reactivate it, sign in and confirm the code we will send to this email address."
+	if s.cfg.ReactivateURL != "" {
+		body += "\n\n" + s.cfg.ReactivateURL
+	}
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your account was deactivated due to inactivity",
+		Body:    body + "\n",
+	}); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to notify deactivated user")
+	}
+	return true, nil
+}
+
+// RequestReactivation envía un código al email de una cuenta desactivada por
+// inactividad. Un código nuevo anula los pendientes anteriores. Las solicitudes se
+// limitan por cuenta y por IP para que no sirva para enviar correo sin control.
+func (s *DormancyService) RequestReactivation(ctx context.Context, actor AdminActor, user *models.User) (*models.AccountReactivation, error) {
+	if !isDormant(user) {
+		return nil, ErrNotDormant
+	}
+
+	code, err := generateNumericCode(reactivationCodeDigits)
+	if err != nil {
+		return nil, err
+	}
+
+	reactivation := &models.AccountReactivation{
+		UserID:    user.ID,
+		CodeHash:  sha256Hex(code),
+		ExpiresAt: time.Now().Add(s.cfg.CodeTTL),
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.checkReactivationRateTx(tx, actor); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND consumed_at IS NULL", user.ID).
+			Delete(&models.AccountReactivation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(reactivation).Error; err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:    user.ID,
+			ActorID:   actor.UserID,
+			Action:    models.AuditActionReactivationRequested,
+			IPAddress: actor.IPAddress,
+			UserAgent: actor.UserAgent,
+			Details: models.JSONMap{
+				"reactivation_id": reactivation.ID,
+			},
+		})
+	})
+	if err != nil {
+		if errors.Is(err, ErrReactivationTooSoon) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to request reactivation: %w", err)
+	}
+
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reactivate your account",
+		Body: fmt.Sprintf("Your reactivation code is %s. It expires in %s.\n\nIf you did not request it, ignore this email.\n",
+			code, s.cfg.CodeTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return reactivation, nil
+}
+
+// checkReactivationRateTx cuenta las solicitudes recientes en el registro de auditoría:
+// una por cuenta cada RequestCooldown y MaxRequests por cuenta y por IP en una hora
+func (s *DormancyService) checkReactivationRateTx(tx *gorm.DB, actor AdminActor) error {
+	now := time.Now()
+	limits := []struct {
+		column, value string
+		since         time.Time
+		max           int64
+	}{
+		{"user_id", actor.UserID, now.Add(-s.cfg.RequestCooldown), 1},
+		{"user_id", actor.UserID, now.Add(-time.Hour), int64(s.cfg.MaxRequests)},
+		{"ip_address", actor.IPAddress, now.Add(-time.Hour), int64(s.cfg.MaxRequests)},
+	}
+	for _, limit := range limits {
+		if limit.value == "" {
+			continue
+		}
+		var count int64
+		if err := tx.Model(&models.AuditLog{}).
+			Where("action = ? AND created_at > ?", models.AuditActionReactivationRequested, limit.since).
+			Where(limit.column+" = ?", limit.value).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= limit.max {
+			return ErrReactivationTooSoon
+		}
+	}
+	return nil
+}
+
+// ConfirmReactivation comprueba el código y vuelve a activar la cuenta. El código
+// llegó al email de la cuenta, así que éste queda verificado de nuevo.
+func (s *DormancyService) ConfirmReactivation(ctx context.Context, actor AdminActor, code string) (*models.User, error) {
+	var codeErr error
+	user, err := s.lifecycle.transition(ctx, actor.UserID, func(tx *gorm.DB, user *models.User) (*StatusChange, error) {
+		if !isDormant(user) {
+			return nil, ErrNotDormant
+		}
+
+		var reactivation models.AccountReactivation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND consumed_at IS NULL", user.ID).
+			Order("created_at DESC").
+			First(&reactivation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrReactivationNotFound
+			}
+			return nil, err
+		}
+		if reactivation.ExpiresAt.Before(time.Now()) || reactivation.Attempts >= s.cfg.MaxAttempts {
+			return nil, ErrReactivationExpired
+		}
+
+		// Un código incorrecto consume un intento; el contador se guarda aunque falle
+		if subtle.ConstantTimeCompare([]byte(reactivation.CodeHash), []byte(sha256Hex(code))) != 1 {
+			codeErr = ErrReactivationInvalidCode
+			return nil, tx.Model(&reactivation).Update("attempts", reactivation.Attempts+1).Error
+		}
+
+		if err := tx.Model(&reactivation).Update("consumed_at", time.Now()).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(user).Update("dormancy_warned_at", nil).Error; err != nil {
+			return nil, err
+		}
+		user.DormancyWarnedAt = nil
+		return &StatusChange{
+			To:            models.StatusActive,
+			Reason:        statusReasonReactivated,
+			Actor:         actor,
+			VerifiedEmail: true,
+		}, nil
+	})
+	if err == nil {
+		err = codeErr
+	}
+	if err != nil {
+		if errors.Is(err, ErrNotDormant) || errors.Is(err, ErrReactivationNotFound) ||
+			errors.Is(err, ErrReactivationExpired) || errors.Is(err, ErrReactivationInvalidCode) ||
+			errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to reactivate account: %w", err)
+	}
+	return user, nil
+}
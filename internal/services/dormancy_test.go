@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/models"
+)
+
+func TestDormancyAction(t *testing.T) {
+	cfg := config.DormancyConfig{WarnAfter: 30 * 24 * time.Hour, DeactivateAfter: 37 * 24 * time.Hour}
+	now := time.Now()
+	days := func(n int) *time.Time {
+		at := now.Add(-time.Duration(n) * 24 * time.Hour)
+		return &at
+	}
+
+	tests := []struct {
+		name   string
+		user   models.User
+		action string
+	}{
+		{"recent login", models.User{Status: models.StatusActive, LastLoginAt: days(5)}, ""},
+		{"never logged in, recent signup", models.User{Status: models.StatusActive, CreatedAt: *days(10)}, ""},
+		{"never logged in, old signup", models.User{Status: models.StatusActive, CreatedAt: *days(31)}, dormancyWarn},
+		{"inactive, not warned", models.User{Status: models.StatusActive, LastLoginAt: days(40)}, dormancyWarn},
+		{"warned before last login", models.User{Status: models.StatusActive, LastLoginAt: days(31), DormancyWarnedAt: days(60)}, dormancyWarn},
+		{"warned, notice not elapsed", models.User{Status: models.StatusActive, LastLoginAt: days(40), DormancyWarnedAt: days(3)}, ""},
+		{"warned, notice elapsed", models.User{Status: models.StatusActive, LastLoginAt: days(40), DormancyWarnedAt: days(8)}, dormancyDeactivate},
+		{"warned, not yet past deactivation", models.User{Status: models.StatusActive, LastLoginAt: days(35), DormancyWarnedAt: days(8)}, ""},
+		{"old login, recent requests", models.User{Status: models.StatusActive, LastLoginAt: days(40), LastSeenAt: days(2)}, ""},
+		{"warned, then seen", models.User{Status: models.StatusActive, LastLoginAt: days(40), LastSeenAt: days(1), DormancyWarnedAt: days(8)}, ""},
+		{"suspended", models.User{Status: models.StatusSuspended, LastLoginAt: days(400)}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.action, dormancyAction(&tt.user, now, cfg))
+		})
+	}
+}
+
+func TestDormancyDeadline(t *testing.T) {
+	cfg := config.DormancyConfig{WarnAfter: 30 * 24 * time.Hour, DeactivateAfter: 37 * 24 * time.Hour}
+	now := time.Now()
+
+	// Aviso puntual: la desactivación llega al cumplirse DeactivateAfter
+	activity := now.Add(-30 * 24 * time.Hour)
+	assert.Equal(t, activity.Add(cfg.DeactivateAfter), dormancyDeadline(activity, now, cfg))
+
+	// Aviso tardío (p. ej. el job estuvo parado): se respeta el preaviso completo
+	activity = now.Add(-100 * 24 * time.Hour)
+	assert.Equal(t, now.Add(7*24*time.Hour), dormancyDeadline(activity, now, cfg))
+}
+
+func TestDormantAccountStatus(t *testing.T) {
+	dormant := &models.User{Status: models.StatusPendingVerification, StatusReason: statusReasonDormant}
+
+	assert.True(t, isDormant(dormant))
+	assert.False(t, isDormant(&models.User{Status: models.StatusPendingVerification}))
+	assert.ErrorIs(t, CheckAccountStatus(dormant), ErrAccountDormant)
+	assert.True(t, IsAccountStatusError(ErrAccountDormant))
+
+	// Un login con el email verificado no reactiva la cuenta: hace falta el código
+	assert.Nil(t, loginTransition(dormant, true, time.Now()))
+	assert.NoError(t, checkTransition(dormant, StatusChange{To: models.StatusActive, Reason: statusReasonReactivated, VerifiedEmail: true}))
+}
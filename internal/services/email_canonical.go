@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"it-auth-service/internal/models"
+)
+
+// canonicalEmailBatchSize es el número de cuentas que se completan por consulta al
+// calcular el email canónico de las cuentas existentes
+const canonicalEmailBatchSize = 500
+
+// mailboxRules describe cómo entrega un proveedor las variantes de una dirección
+type mailboxRules struct {
+	Domain         string // Dominio principal del proveedor
+	IgnoreDots     bool   // Los puntos de la parte local no cambian el buzón
+	PlusAddressing bool   // Lo que sigue a "+" en la parte local no cambia el buzón
+}
+
+// mailboxProviders son los proveedores cuyas variantes se sabe que llegan al mismo
+// buzón. En el resto de dominios sólo se ignoran las mayúsculas.
+var mailboxProviders = map[string]mailboxRules{
+	"gmail.com":      {Domain: "gmail.com", IgnoreDots: true, PlusAddressing: true},
+	"googlemail.com": {Domain: "gmail.com", IgnoreDots: true, PlusAddressing: true},
+	"outlook.com":    {Domain: "outlook.com", PlusAddressing: true},
+	"hotmail.com":    {Domain: "hotmail.com", PlusAddressing: true},
+	"live.com":       {Domain: "live.com", PlusAddressing: true},
+	"icloud.com":     {Domain: "icloud.com", PlusAddressing: true},
+	"me.com":         {Domain: "icloud.com", PlusAddressing: true},
+	"mac.com":        {Domain: "icloud.com", PlusAddressing: true},
+	"fastmail.com":   {Domain: "fastmail.com", PlusAddressing: true},
+	"protonmail.com": {Domain: "proton.me", PlusAddressing: true},
+	"proton.me":      {Domain: "proton.me", PlusAddressing: true},
+	"pm.me":          {Domain: "proton.me", PlusAddressing: true},
+}
+
+// canonicalEmail identifica el buzón de una dirección para detectar cuentas duplicadas:
+// John.Doe+x@Gmail.com y johndoe@gmail.com son el mismo. No sustituye al email de la
+// cuenta, que se conserva tal como lo dio el proveedor.
+func canonicalEmail(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email
+	}
+
+	local, domain := email[:at], normalizeDomain(email[at+1:])
+	rules, ok := mailboxProviders[domain]
+	if !ok {
+		return local + "@" + domain
+	}
+	if rules.PlusAddressing {
+		if tag := strings.Index(local, "+"); tag > 0 {
+			local = local[:tag]
+		}
+	}
+	if rules.IgnoreDots {
+		if stripped := strings.ReplaceAll(local, ".", ""); stripped != "" {
+			local = stripped
+		}
+	}
+	return local + "@" + rules.Domain
+}
+
+// whereEmailOwner filtra las cuentas que ya usan el email o una variante del mismo
+// buzón; compara también el email exacto por las cuentas aún sin email canónico
+func whereEmailOwner(db *gorm.DB, email string) *gorm.DB {
+	return db.Where("(users.canonical_email = ? OR users.email = ?)", canonicalEmail(email), email)
+}
+
+// BackfillCanonicalEmails calcula el email canónico de las cuentas creadas antes de que
+// existiera. Las que resultan ser variantes de otra cuenta se dejan sin él y se
+// registran en el log para resolverlas a mano.
+func (s *UserService) BackfillCanonicalEmails(ctx context.Context) (int, error) {
+	updated, lastID := 0, ""
+	for {
+		query := s.db.WithContext(ctx).Select("id", "email").Where("canonical_email = ''")
+		if lastID != "" {
+			query = query.Where("id > ?", lastID)
+		}
+		var users []*models.User
+		if err := query.Order("id ASC").Limit(canonicalEmailBatchSize).Find(&users).Error; err != nil {
+			return updated, err
+		}
+		if len(users) == 0 {
+			break
+		}
+
+		for _, user := range users {
+			lastID = user.ID
+			err := s.db.WithContext(ctx).Model(user).UpdateColumn("canonical_email", canonicalEmail(user.Email)).Error
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				s.logger.WithField("user_id", user.ID).Warn("Account shares its mailbox with another account, canonical email not set")
+				continue
+			}
+			if err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+
+	if updated > 0 {
+		s.logger.WithField("users", updated).Info("Canonical emails backfilled")
+	}
+	return updated, nil
+}
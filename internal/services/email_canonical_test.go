@@ -0,0 +1,24 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalEmail(t *testing.T) {
+	// Gmail ignora los puntos y la etiqueta "+", y googlemail.com es el mismo dominio
+	assert.Equal(t, "johndoe@gmail.com", canonicalEmail(" John.Doe+x@Gmail.com "))
+	assert.Equal(t, "johndoe@gmail.com", canonicalEmail("johndoe@googlemail.com"))
+
+	// Outlook admite etiquetas pero los puntos sí distinguen buzones
+	assert.Equal(t, "john.doe@outlook.com", canonicalEmail("John.Doe+news@outlook.com"))
+	assert.Equal(t, "jane@icloud.com", canonicalEmail("jane+shop@me.com"))
+
+	// En el resto de dominios sólo se ignoran las mayúsculas
+	assert.Equal(t, "john.doe+x@example.com", canonicalEmail("John.Doe+x@Example.com."))
+
+	// Una parte local que quedaría vacía se conserva
+	assert.Equal(t, "+tag@gmail.com", canonicalEmail("+tag@gmail.com"))
+	assert.Equal(t, "not-an-email", canonicalEmail("Not-An-Email"))
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/firebase"
+	"it-auth-service/pkg/mailer"
+)
+
+const (
+	emailChangeCodeDigits  = 6
+	emailChangeRevertBytes = 32
+)
+
+// uuidPattern valida los IDs que llegan dentro de tokens antes de consultarlos
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var (
+	ErrEmailUnchanged           = errors.New("new email is the current email")
+	ErrEmailChangeNotFound      = errors.New("no pending email change")
+	ErrEmailChangeInvalidCode   = errors.New("invalid confirmation code")
+	ErrEmailChangeExpired       = errors.New("confirmation code has expired or too many attempts, request a new one")
+	ErrEmailChangeRevertInvalid = errors.New("revert link is invalid, expired or already used")
+)
+
+// EmailChangeService gestiona el cambio de email del propio usuario: la nueva dirección
+// se confirma con un código y la anterior recibe un enlace para deshacer el cambio
+// si no lo pidió su dueño
+type EmailChangeService struct {
+	db           *gorm.DB
+	users        *UserService
+	admin        *UserAdminService
+	audit        *AuditService
+	firebaseAuth *firebase.Auth
+	mailer       mailer.Mailer
+	logger       *logrus.Logger
+	cfg          config.EmailChangeConfig
+}
+
+func NewEmailChangeService(db *gorm.DB, userService *UserService, userAdminService *UserAdminService, auditService *AuditService, firebaseAuth *firebase.Auth, mail mailer.Mailer, cfg *config.Config) *EmailChangeService {
+	return &EmailChangeService{
+		db:           db,
+		users:        userService,
+		admin:        userAdminService,
+		audit:        auditService,
+		firebaseAuth: firebaseAuth,
+		mailer:       mail,
+		logger:       logger.GetLogger(),
+		cfg:          cfg.EmailChangeConfig,
+	}
+}
+
+// Request inicia el cambio de email y envía el código a la nueva dirección. Una
+// solicitud nueva anula la pendiente anterior.
+func (s *EmailChangeService) Request(ctx context.Context, actor AdminActor, req *models.RequestEmailChangeRequest) (*models.EmailChangeRequest, error) {
+	user, err := s.admin.GetUser(ctx, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	newEmail := strings.ToLower(strings.TrimSpace(req.NewEmail))
+	if strings.EqualFold(newEmail, user.Email) {
+		return nil, ErrEmailUnchanged
+	}
+
+	code, err := generateNumericCode(emailChangeCodeDigits)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &models.EmailChangeRequest{
+		UserID:    user.ID,
+		OldEmail:  user.Email,
+		NewEmail:  newEmail,
+		Status:    models.EmailChangeStatusPending,
+		CodeHash:  sha256Hex(code),
+		ExpiresAt: time.Now().Add(s.cfg.CodeTTL),
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkEmailAvailableTx(tx, newEmail, user.ID); err != nil {
+			return err
+		}
+		if err := cancelPendingEmailChangesTx(tx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Create(change).Error; err != nil {
+			return err
+		}
+		return s.record(tx, actor, user.ID, models.AuditActionEmailChangeRequested, change)
+	})
+	if err != nil {
+		if errors.Is(err, ErrEmailAlreadyRegistered) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to request email change: %w", err)
+	}
+
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Your confirmation code is %s. It expires in %s.\n\nIf you did not request this change, ignore this email.\n",
+			code, s.cfg.CodeTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// Confirm aplica el cambio si el código es correcto: actualiza el email en la base de
+// datos y en Firebase y avisa a la dirección anterior con un enlace de reversión. La base
+// de datos se confirma primero; si Firebase rechaza después el cambio, se deshace con
+// una transacción compensatoria para que ambos sistemas no queden en desacuerdo.
+func (s *EmailChangeService) Confirm(ctx context.Context, actor AdminActor, code string) (*models.User, error) {
+	revertSecret, err := generateRandomToken(emailChangeRevertBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	var change models.EmailChangeRequest
+	var previousVerified bool
+	var codeErr error
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ?", actor.UserID, models.EmailChangeStatusPending).
+			Order("created_at DESC").
+			First(&change).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmailChangeNotFound
+			}
+			return err
+		}
+
+		// Un código incorrecto consume un intento; el contador se guarda aunque falle
+		attempt, err := checkEmailChangeCode(&change, code, s.cfg.MaxAttempts, time.Now())
+		if attempt != nil {
+			codeErr = err
+			return tx.Model(&change).Updates(attempt).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", change.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		if err := checkEmailAvailableTx(tx, change.NewEmail, user.ID); err != nil {
+			return err
+		}
+		previousVerified = user.EmailVerified
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":           change.NewEmail,
+			"canonical_email": canonicalEmail(change.NewEmail),
+			"email_verified":  true,
+		}).Error; err != nil {
+			return err
+		}
+		user.Email = change.NewEmail
+		user.CanonicalEmail = canonicalEmail(change.NewEmail)
+		user.EmailVerified = true
+
+		now := time.Now()
+		revertExpiresAt := now.Add(s.cfg.RevertTTL)
+		if err := tx.Model(&change).Updates(map[string]interface{}{
+			"status":            models.EmailChangeStatusConfirmed,
+			"confirmed_at":      now,
+			"revert_token_hash": sha256Hex(revertSecret),
+			"revert_expires_at": revertExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		change.RevertExpiresAt = &revertExpiresAt
+		return s.record(tx, actor, user.ID, models.AuditActionEmailChanged, &change)
+	})
+	if err == nil {
+		err = codeErr
+	}
+	if err != nil {
+		if errors.Is(err, ErrEmailChangeNotFound) || errors.Is(err, ErrEmailChangeExpired) ||
+			errors.Is(err, ErrEmailChangeInvalidCode) || errors.Is(err, ErrEmailAlreadyRegistered) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to confirm email change: %w", err)
+	}
+
+	if err := s.updateFirebaseEmail(ctx, &user, change.NewEmail); err != nil {
+		if rollbackErr := s.rollbackConfirm(ctx, actor, &change, previousVerified, err); rollbackErr != nil {
+			// Queda registrado como cambiado sin estarlo en Firebase; Revert sigue disponible
+			s.logger.WithError(rollbackErr).WithField("user_id", user.ID).Error("Failed to roll back email change after Firebase error")
+		}
+		if errors.Is(err, ErrEmailAlreadyRegistered) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to confirm email change: %w", err)
+	}
+
+	link := s.cfg.RevertURL + "?" + url.Values{"token": {change.ID + "." + revertSecret}}.Encode()
+	if err := s.mailer.Send(ctx, mailer.Message{
+		To:      change.OldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("The email address of your account was changed to %s.\n\nIf you did not make this change, restore your previous address and sign out every session before %s:\n\n%s\n",
+			change.NewEmail, change.RevertExpiresAt.UTC().Format(time.RFC1123), link),
+	}); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to notify previous email address")
+	}
+
+	s.users.notifyChanged(ctx, &user)
+	return &user, nil
+}
+
+// rollbackConfirm deshace en la base de datos una confirmación que Firebase no aceptó.
+// Sólo restaura el email si nadie lo ha cambiado entretanto; la solicitud vuelve a quedar
+// pendiente para reintentar con el mismo código, salvo que Firebase ya tenga ese email.
+func (s *EmailChangeService) rollbackConfirm(ctx context.Context, actor AdminActor, change *models.EmailChangeRequest, previousVerified bool, firebaseErr error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", change.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		if !strings.EqualFold(user.Email, change.NewEmail) {
+			return nil
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":           change.OldEmail,
+			"canonical_email": canonicalEmail(change.OldEmail),
+			"email_verified":  previousVerified,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(change).Updates(emailChangeRollback(firebaseErr)).Error; err != nil {
+			return err
+		}
+		return s.record(tx, actor, user.ID, models.AuditActionEmailChangeRolledBack, change)
+	})
+}
+
+// checkEmailChangeCode valida el código de una solicitud pendiente. Si el código es
+// incorrecto devuelve, junto al error, los cambios que consumen el intento (y anulan la
+// solicitud al agotarlos), que deben guardarse aunque la confirmación falle.
+func checkEmailChangeCode(change *models.EmailChangeRequest, code string, maxAttempts int, now time.Time) (map[string]interface{}, error) {
+	if change.ExpiresAt.Before(now) || change.Attempts >= maxAttempts {
+		return nil, ErrEmailChangeExpired
+	}
+	if subtle.ConstantTimeCompare([]byte(change.CodeHash), []byte(sha256Hex(code))) == 1 {
+		return nil, nil
+	}
+
+	updates := map[string]interface{}{"attempts": change.Attempts + 1}
+	if change.Attempts+1 >= maxAttempts {
+		updates["status"] = models.EmailChangeStatusCancelled
+	}
+	return updates, ErrEmailChangeInvalidCode
+}
+
+// checkEmailChangeRevert valida el enlace de reversión de un cambio ya confirmado
+func checkEmailChangeRevert(change *models.EmailChangeRequest, secret string, now time.Time) error {
+	if change.Status != models.EmailChangeStatusConfirmed ||
+		change.RevertExpiresAt == nil || change.RevertExpiresAt.Before(now) ||
+		subtle.ConstantTimeCompare([]byte(change.RevertTokenHash), []byte(sha256Hex(secret))) != 1 {
+		return ErrEmailChangeRevertInvalid
+	}
+	return nil
+}
+
+// emailChangeRollback devuelve la solicitud a pendiente, sin enlace de reversión. Si
+// Firebase ya tiene esa dirección en otra cuenta, reintentar no serviría y se anula.
+func emailChangeRollback(firebaseErr error) map[string]interface{} {
+	status := models.EmailChangeStatusPending
+	if errors.Is(firebaseErr, ErrEmailAlreadyRegistered) {
+		status = models.EmailChangeStatusCancelled
+	}
+	return map[string]interface{}{
+		"status":            status,
+		"confirmed_at":      nil,
+		"revert_token_hash": "",
+		"revert_expires_at": nil,
+	}
+}
+
+// Revert restaura el email anterior desde el enlace enviado a esa dirección. Como
+// indica que la cuenta pudo estar comprometida, cierra todas las sesiones antes de
+// alinear Firebase.
+func (s *EmailChangeService) Revert(ctx context.Context, token, ipAddress, userAgent string) (*models.User, error) {
+	changeID, secret, ok := strings.Cut(token, ".")
+	if !ok || !uuidPattern.MatchString(changeID) || secret == "" {
+		return nil, ErrEmailChangeRevertInvalid
+	}
+
+	var user models.User
+	var change models.EmailChangeRequest
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", changeID).First(&change).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEmailChangeRevertInvalid
+			}
+			return err
+		}
+		if err := checkEmailChangeRevert(&change, secret, time.Now()); err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", change.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		// Si el email ha vuelto a cambiar después, este enlace ya no corresponde
+		if !strings.EqualFold(user.Email, change.NewEmail) {
+			return ErrEmailChangeRevertInvalid
+		}
+		if err := checkEmailAvailableTx(tx, change.OldEmail, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":           change.OldEmail,
+			"canonical_email": canonicalEmail(change.OldEmail),
+		}).Error; err != nil {
+			return err
+		}
+		user.Email = change.OldEmail
+		user.CanonicalEmail = canonicalEmail(change.OldEmail)
+		if err := cancelPendingEmailChangesTx(tx, user.ID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Model(&change).Updates(map[string]interface{}{
+			"status":      models.EmailChangeStatusReverted,
+			"reverted_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		actor := AdminActor{UserID: user.ID, IPAddress: ipAddress, UserAgent: userAgent}
+		return s.record(tx, actor, user.ID, models.AuditActionEmailChangeReverted, &change)
+	})
+	if err != nil {
+		if errors.Is(err, ErrEmailChangeRevertInvalid) || errors.Is(err, ErrEmailAlreadyRegistered) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to revert email change: %w", err)
+	}
+
+	actor := AdminActor{IPAddress: ipAddress, UserAgent: userAgent}
+	if _, err := s.admin.revokeEverything(ctx, actor, &user, "email_change_reverted"); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to revoke sessions after email change revert")
+	}
+
+	// Firebase va después de confirmar la base de datos. Si falla no se deshace la
+	// reversión: devolver el email a quien pudo robar la cuenta sería peor.
+	if err := s.updateFirebaseEmail(ctx, &user, change.OldEmail); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to restore previous email in Firebase after revert")
+	}
+
+	s.users.notifyChanged(ctx, &user)
+	s.logger.WithField("user_id", user.ID).Warn("Email change reverted from previous address")
+	return &user, nil
+}
+
+// updateFirebaseEmail alinea el email de la cuenta principal de Firebase
+func (s *EmailChangeService) updateFirebaseEmail(ctx context.Context, user *models.User, email string) error {
+	if s.firebaseAuth == nil {
+		return nil
+	}
+	if _, err := s.firebaseAuth.UpdateUser(ctx, user.FirebaseID, (&auth.UserToUpdate{}).Email(email).EmailVerified(true)); err != nil {
+		if errors.Is(err, firebase.ErrEmailAlreadyExists) {
+			return ErrEmailAlreadyRegistered
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *EmailChangeService) record(tx *gorm.DB, actor AdminActor, userID, action string, change *models.EmailChangeRequest) error {
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		UserID:    userID,
+		ActorID:   actor.UserID,
+		Action:    action,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details: models.JSONMap{
+			"email_change_id": change.ID,
+			"old_email":       change.OldEmail,
+			"new_email":       change.NewEmail,
+		},
+	})
+}
+
+// checkEmailAvailableTx comprueba que ninguna otra cuenta use ya el email o una
+// variante del mismo buzón
+func checkEmailAvailableTx(tx *gorm.DB, email, userID string) error {
+	var owners int64
+	if err := whereEmailOwner(tx.Model(&models.User{}), email).
+		Where("users.id <> ?", userID).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners > 0 {
+		return ErrEmailAlreadyRegistered
+	}
+	return nil
+}
+
+func cancelPendingEmailChangesTx(tx *gorm.DB, userID string) error {
+	return tx.Model(&models.EmailChangeRequest{}).
+		Where("user_id = ? AND status = ?", userID, models.EmailChangeStatusPending).
+		Update("status", models.EmailChangeStatusCancelled).Error
+}
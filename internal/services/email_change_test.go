@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/mailer"
+)
+
+func TestGenerateNumericCode(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		code, err := generateNumericCode(emailChangeCodeDigits)
+		require.NoError(t, err)
+		assert.Len(t, code, emailChangeCodeDigits)
+		assert.Regexp(t, `^[0-9]+$`, code)
+	}
+}
+
+func TestUUIDPattern(t *testing.T) {
+	assert.True(t, uuidPattern.MatchString("3f1c2d4e-0000-4000-8000-000000000001"))
+	assert.False(t, uuidPattern.MatchString("3f1c2d4e"))
+	assert.False(t, uuidPattern.MatchString("3f1c2d4e-0000-4000-8000-000000000001' OR 1=1"))
+}
+
+func TestCheckEmailChangeCode(t *testing.T) {
+	now := time.Now()
+	pending := func(attempts int) *models.EmailChangeRequest {
+		return &models.EmailChangeRequest{
+			Status:    models.EmailChangeStatusPending,
+			CodeHash:  sha256Hex("123456"),
+			Attempts:  attempts,
+			ExpiresAt: now.Add(time.Minute),
+		}
+	}
+
+	// Código correcto: nada que guardar como intento
+	attempt, err := checkEmailChangeCode(pending(0), "123456", 5, now)
+	assert.NoError(t, err)
+	assert.Nil(t, attempt)
+
+	// Código incorrecto: consume un intento sin anular la solicitud
+	attempt, err = checkEmailChangeCode(pending(1), "654321", 5, now)
+	assert.ErrorIs(t, err, ErrEmailChangeInvalidCode)
+	assert.Equal(t, map[string]interface{}{"attempts": 2}, attempt)
+
+	// El último intento fallido anula la solicitud
+	attempt, err = checkEmailChangeCode(pending(4), "654321", 5, now)
+	assert.ErrorIs(t, err, ErrEmailChangeInvalidCode)
+	assert.Equal(t, models.EmailChangeStatusCancelled, attempt["status"])
+
+	// Agotados los intentos o caducado, ni siquiera el código correcto sirve
+	_, err = checkEmailChangeCode(pending(5), "123456", 5, now)
+	assert.ErrorIs(t, err, ErrEmailChangeExpired)
+	_, err = checkEmailChangeCode(pending(0), "123456", 5, now.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrEmailChangeExpired)
+}
+
+func TestEmailChangeRollback(t *testing.T) {
+	// Un fallo transitorio de Firebase deja la solicitud pendiente para reintentar
+	updates := emailChangeRollback(errors.New("firebase unavailable"))
+	assert.Equal(t, models.EmailChangeStatusPending, updates["status"])
+	assert.Equal(t, "", updates["revert_token_hash"])
+	assert.Nil(t, updates["revert_expires_at"])
+
+	// Si Firebase ya tiene el email en otra cuenta no tiene sentido reintentar
+	updates = emailChangeRollback(ErrEmailAlreadyRegistered)
+	assert.Equal(t, models.EmailChangeStatusCancelled, updates["status"])
+}
+
+func TestCheckEmailChangeRevert(t *testing.T) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	change := &models.EmailChangeRequest{
+		Status:          models.EmailChangeStatusConfirmed,
+		RevertTokenHash: sha256Hex("secret"),
+		RevertExpiresAt: &expires,
+	}
+
+	assert.NoError(t, checkEmailChangeRevert(change, "secret", now))
+	assert.ErrorIs(t, checkEmailChangeRevert(change, "other", now), ErrEmailChangeRevertInvalid)
+	assert.ErrorIs(t, checkEmailChangeRevert(change, "secret", expires.Add(time.Second)), ErrEmailChangeRevertInvalid)
+
+	// Un enlace ya usado, o de un cambio deshecho por Firebase, no revierte nada
+	change.Status = models.EmailChangeStatusReverted
+	assert.ErrorIs(t, checkEmailChangeRevert(change, "secret", now), ErrEmailChangeRevertInvalid)
+	change.Status = models.EmailChangeStatusPending
+	assert.ErrorIs(t, checkEmailChangeRevert(change, "secret", now), ErrEmailChangeRevertInvalid)
+}
+
+// recordingMailer guarda los emails en lugar de enviarlos
+type recordingMailer struct {
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(_ context.Context, msg mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func newTestEmailChangeService(t *testing.T) (*EmailChangeService, sqlmock.Sqlmock, *recordingMailer) {
+	db, mock := newMockDB(t)
+	admin := newTestUserAdminService(db)
+	mail := &recordingMailer{}
+	return &EmailChangeService{
+		db:     db,
+		users:  admin.users,
+		admin:  admin,
+		audit:  admin.audit,
+		mailer: mail,
+		logger: logger.GetLogger(),
+		cfg: config.EmailChangeConfig{
+			CodeTTL:     15 * time.Minute,
+			MaxAttempts: 5,
+			RevertTTL:   7 * 24 * time.Hour,
+			RevertURL:   "https://app.example.com/revert",
+		},
+	}, mock, mail
+}
+
+const testEmailChangeID = "3f1c2d4e-0000-4000-8000-000000000001"
+
+var emailChangeColumns = []string{"id", "user_id", "old_email", "new_email", "status", "code_hash", "attempts", "expires_at", "revert_token_hash", "revert_expires_at"}
+
+func expectPendingEmailChange(mock sqlmock.Sqlmock, attempts int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "email_change_requests" WHERE user_id = \$1 AND status = \$2 .*FOR UPDATE`).
+		WithArgs("user-id", models.EmailChangeStatusPending).
+		WillReturnRows(sqlmock.NewRows(emailChangeColumns).AddRow(
+			testEmailChangeID, "user-id", "old@example.com", "new@example.com", models.EmailChangeStatusPending,
+			sha256Hex("123456"), attempts, time.Now().Add(time.Minute), "", nil))
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	s, mock, mail := newTestEmailChangeService(t)
+
+	expectPendingEmailChange(mock, 0)
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+		WithArgs("user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "status"}).AddRow("user-id", "old@example.com", models.StatusActive))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`UPDATE "users" SET "canonical_email"=\$1,"email"=\$2,"email_verified"=\$3`).
+		WithArgs("new@example.com", "new@example.com", true, sqlmock.AnyArg(), "user-id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "email_change_requests" SET .*"status"=`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+
+	user, err := s.Confirm(context.Background(), AdminActor{UserID: "user-id"}, "123456")
+	require.NoError(t, err)
+	assert.Equal(t, "new@example.com", user.Email)
+	assert.True(t, user.EmailVerified)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// La dirección anterior recibe el enlace para deshacer el cambio
+	require.Len(t, mail.sent, 1)
+	assert.Equal(t, "old@example.com", mail.sent[0].To)
+	assert.Contains(t, mail.sent[0].Body, "https://app.example.com/revert?token="+testEmailChangeID+".")
+}
+
+func TestConfirmEmailChangeWrongCode(t *testing.T) {
+	s, mock, mail := newTestEmailChangeService(t)
+
+	expectPendingEmailChange(mock, 1)
+	mock.ExpectExec(`UPDATE "email_change_requests" SET "attempts"=\$1,"updated_at"=\$2 WHERE`).
+		WithArgs(2, sqlmock.AnyArg(), testEmailChangeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err := s.Confirm(context.Background(), AdminActor{UserID: "user-id"}, "654321")
+	assert.ErrorIs(t, err, ErrEmailChangeInvalidCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, mail.sent)
+}
+
+func TestConfirmEmailChangeAttemptExhaustion(t *testing.T) {
+	s, mock, _ := newTestEmailChangeService(t)
+
+	// El último intento fallido anula la solicitud...
+	expectPendingEmailChange(mock, 4)
+	mock.ExpectExec(`UPDATE "email_change_requests" SET "attempts"=\$1,"status"=\$2,"updated_at"=\$3 WHERE`).
+		WithArgs(5, models.EmailChangeStatusCancelled, sqlmock.AnyArg(), testEmailChangeID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err := s.Confirm(context.Background(), AdminActor{UserID: "user-id"}, "654321")
+	assert.ErrorIs(t, err, ErrEmailChangeInvalidCode)
+
+	// ...y ni siquiera el código correcto la confirma después
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "email_change_requests"`).
+		WillReturnRows(sqlmock.NewRows(emailChangeColumns))
+	mock.ExpectRollback()
+
+	_, err = s.Confirm(context.Background(), AdminActor{UserID: "user-id"}, "123456")
+	assert.ErrorIs(t, err, ErrEmailChangeNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRevertEmailChangeRevokesSessions(t *testing.T) {
+	s, mock, _ := newTestEmailChangeService(t)
+	revertExpiresAt := time.Now().Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "email_change_requests" WHERE id = \$1 .*FOR UPDATE`).
+		WithArgs(testEmailChangeID).
+		WillReturnRows(sqlmock.NewRows(emailChangeColumns).AddRow(
+			testEmailChangeID, "user-id", "old@example.com", "new@example.com", models.EmailChangeStatusConfirmed,
+			sha256Hex("123456"), 0, time.Now(), sha256Hex("secret"), revertExpiresAt))
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+		WithArgs("user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "status", "firebase_id"}).
+			AddRow("user-id", "new@example.com", models.StatusActive, "firebase-uid"))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`UPDATE "users" SET "canonical_email"=\$1,"email"=\$2`).
+		WithArgs("old@example.com", "old@example.com", sqlmock.AnyArg(), "user-id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "email_change_requests" SET "status"=\$1,"updated_at"=\$2 WHERE user_id = \$3 AND status = \$4`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE "email_change_requests" SET "reverted_at"=\$1,"status"=\$2`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+	// La cuenta pudo estar comprometida: se cierran todas sus sesiones
+	expectRevokeEverything(mock, "user-id")
+
+	user, err := s.Revert(context.Background(), testEmailChangeID+".secret", "203.0.113.1", "test")
+	require.NoError(t, err)
+	assert.Equal(t, "old@example.com", user.Email)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/firebase"
+)
+
+const (
+	erasureBatchSize         = 50
+	defaultErasureTombstones = 100
+	// Dominio reservado (RFC 2606) para los emails de las cuentas anonimizadas
+	erasedEmailDomain = "erased.invalid"
+)
+
+var (
+	ErrErasureNotFound     = errors.New("no erasure found for this user")
+	ErrErasureNotScheduled = errors.New("user is not scheduled for erasure")
+)
+
+// UserErasedListener recibe la lápida de cada usuario borrado definitivamente
+type UserErasedListener func(ctx context.Context, event *models.UserErasedEvent)
+
+// ErasureService ejecuta el derecho de supresión: las cuentas en pending_deletion
+// quedan programadas durante un periodo de gracia en el que aún pueden restaurarse y,
+// al vencer, se borran o anonimizan de forma irreversible todos sus datos personales
+// y pasan al estado final deleted.
+type ErasureService struct {
+	db           *gorm.DB
+	audit        *AuditService
+	lifecycle    *LifecycleService
+	firebaseAuth *firebase.Auth
+	logger       *logrus.Logger
+	gracePeriod  time.Duration
+	listeners    []UserErasedListener
+}
+
+func NewErasureService(db *gorm.DB, auditService *AuditService, lifecycleService *LifecycleService, firebaseAuth *firebase.Auth, cfg *config.Config) *ErasureService {
+	return &ErasureService{
+		db:           db,
+		audit:        auditService,
+		lifecycle:    lifecycleService,
+		firebaseAuth: firebaseAuth,
+		logger:       logger.GetLogger(),
+		gracePeriod:  cfg.ErasureConfig.GracePeriod,
+	}
+}
+
+// OnUserErased registra un listener para las lápidas. Debe llamarse durante el arranque.
+func (s *ErasureService) OnUserErased(listener UserErasedListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// OnStatusChanged programa el borrado cuando una cuenta pasa a pending_deletion (por
+// administración o SCIM) y lo cancela si se restaura
+func (s *ErasureService) OnStatusChanged(ctx context.Context, event *models.UserStatusChangedEvent) {
+	var err error
+	schedule, cancel := erasureScheduleChange(event)
+	switch {
+	case schedule:
+		err = s.schedule(ctx, event.UserID, event.Reason)
+	case cancel:
+		err = s.cancel(ctx, event.UserID)
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", event.UserID).Error("Failed to update user erasure schedule")
+	}
+}
+
+// GetErasure devuelve el último borrado programado o ejecutado de la cuenta
+func (s *ErasureService) GetErasure(ctx context.Context, userID string) (*models.UserErasure, error) {
+	var erasure models.UserErasure
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").First(&erasure).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrErasureNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &erasure, nil
+}
+
+// ListTombstones devuelve las lápidas de los borrados completados desde since, en orden
+// cronológico, para que los consumidores downstream recuperen las que no recibieron
+func (s *ErasureService) ListTombstones(ctx context.Context, query *models.ListErasureTombstonesQuery) ([]*models.UserErasureTombstone, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultErasureTombstones
+	}
+
+	db := s.db.WithContext(ctx).Order("erased_at ASC, erasure_id ASC").Limit(limit)
+	if query.Since != nil {
+		db = db.Where("erased_at > ?", *query.Since)
+	}
+
+	var tombstones []*models.UserErasureTombstone
+	if err := db.Find(&tombstones).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return tombstones, nil
+}
+
+// EraseNow ejecuta sin esperar al periodo de gracia un borrado ya programado
+func (s *ErasureService) EraseNow(ctx context.Context, actor AdminActor, userID string) (*models.UserErasure, error) {
+	var erasure models.UserErasure
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND status = ?", userID, models.ErasureStatusScheduled).
+		First(&erasure).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrErasureNotScheduled
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if err := s.erase(ctx, &erasure, actor.UserID); err != nil {
+		if !errors.Is(err, ErrErasureNotScheduled) {
+			s.recordFailure(ctx, &erasure, err)
+		}
+		return nil, err
+	}
+	return &erasure, nil
+}
+
+// ProcessDue ejecuta los borrados cuyo periodo de gracia ha vencido. Un fallo en una
+// cuenta no detiene el resto; se reintenta en la siguiente ejecución.
+func (s *ErasureService) ProcessDue(ctx context.Context) (int, error) {
+	var due []*models.UserErasure
+	err := s.db.WithContext(ctx).
+		Where("status = ? AND scheduled_for <= ?", models.ErasureStatusScheduled, time.Now()).
+		Order("scheduled_for ASC").
+		Limit(erasureBatchSize).
+		Find(&due).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to load due erasures: %w", err)
+	}
+
+	erased := 0
+	for _, erasure := range due {
+		if err := ctx.Err(); err != nil {
+			return erased, err
+		}
+		if err := s.erase(ctx, erasure, ""); err != nil {
+			if !errors.Is(err, ErrErasureNotScheduled) {
+				s.recordFailure(ctx, erasure, err)
+			}
+			continue
+		}
+		erased++
+	}
+
+	if erased > 0 {
+		s.logger.WithField("count", erased).Info("User erasures completed")
+	}
+	return erased, nil
+}
+
+func (s *ErasureService) schedule(ctx context.Context, userID, reason string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.UserErasure{}).
+			Where("user_id = ? AND status = ?", userID, models.ErasureStatusScheduled).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		erasure := &models.UserErasure{
+			UserID:       userID,
+			Status:       models.ErasureStatusScheduled,
+			Reason:       reason,
+			ScheduledFor: time.Now().Add(s.gracePeriod),
+		}
+		if err := tx.Create(erasure).Error; err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID: userID,
+			Action: models.AuditActionErasureScheduled,
+			Details: models.JSONMap{
+				"erasure_id":    erasure.ID,
+				"scheduled_for": erasure.ScheduledFor,
+			},
+		})
+	})
+}
+
+func (s *ErasureService) cancel(ctx context.Context, userID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.UserErasure{}).
+			Where("user_id = ? AND status = ?", userID, models.ErasureStatusScheduled).
+			Updates(map[string]interface{}{
+				"status":       models.ErasureStatusCancelled,
+				"cancelled_at": now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID: userID,
+			Action: models.AuditActionErasureCancelled,
+		})
+	})
+}
+
+// erase borra la cuenta en Firebase y después elimina o anonimiza sus datos en la
+// base de datos. Firebase va primero: si falla, el borrado se reintenta entero.
+func (s *ErasureService) erase(ctx context.Context, erasure *models.UserErasure, actorID string) error {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("id = ?", erasure.UserID).First(&user).Error; err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+	// La cuenta se restauró sin pasar por el listener (p. ej. un UPDATE manual)
+	if user.Status != models.StatusPendingDeletion {
+		if err := s.cancel(ctx, user.ID); err != nil {
+			return err
+		}
+		return ErrErasureNotScheduled
+	}
+
+	var subjects []string
+	if err := s.db.WithContext(ctx).Model(&models.UserIdentity{}).
+		Where("user_id = ?", user.ID).
+		Pluck("subject", &subjects).Error; err != nil {
+		return fmt.Errorf("failed to load identities: %w", err)
+	}
+	subjects = appendUnique(subjects, user.FirebaseID)
+
+	if s.firebaseAuth != nil {
+		for _, subject := range subjects {
+			if err := s.firebaseAuth.DeleteUser(ctx, subject); err != nil && !errors.Is(err, firebase.ErrUserNotFound) {
+				return fmt.Errorf("failed to delete Firebase user %s: %w", subject, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	tombstone := &models.UserErasureTombstone{
+		ErasureID:   erasure.ID,
+		UserID:      user.ID,
+		FirebaseIDs: subjects,
+		ErasedAt:    now.UTC(),
+	}
+	var statusEvent *models.UserStatusChangedEvent
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Bloquear el borrado para que dos ejecuciones no lo completen a la vez
+		var locked models.UserErasure
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", erasure.ID, models.ErasureStatusScheduled).
+			First(&locked).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", user.ID, models.StatusPendingDeletion).
+			First(&user).Error; err != nil {
+			return err
+		}
+
+		if err := eraseUserData(tx, &user, subjects); err != nil {
+			return err
+		}
+		var err error
+		statusEvent, err = s.lifecycle.transitionTx(tx, &user, StatusChange{
+			To:     models.StatusDeleted,
+			Reason: statusReasonErased,
+			Actor:  AdminActor{UserID: actorID},
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&locked).Updates(map[string]interface{}{
+			"status":       models.ErasureStatusCompleted,
+			"completed_at": now,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"last_error":   "",
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(tombstone).Error; err != nil {
+			return err
+		}
+
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:  user.ID,
+			ActorID: actorID,
+			Action:  models.AuditActionUserErased,
+			Details: models.JSONMap{"erasure_id": erasure.ID},
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to erase user data: %w", err)
+	}
+
+	erasure.Status = models.ErasureStatusCompleted
+	erasure.CompletedAt = &now
+	erasure.LastError = ""
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":    user.ID,
+		"erasure_id": erasure.ID,
+	}).Info("User erased")
+
+	s.lifecycle.publish(ctx, statusEvent)
+
+	event := &models.UserErasedEvent{
+		Type:        models.UserErasedEventType,
+		ErasureID:   tombstone.ErasureID,
+		UserID:      tombstone.UserID,
+		FirebaseIDs: tombstone.FirebaseIDs,
+		ErasedAt:    tombstone.ErasedAt,
+	}
+	for _, listener := range s.listeners {
+		listener(ctx, event)
+	}
+
+	return nil
+}
+
+// eraseUserData borra las filas que sólo tienen sentido con la cuenta viva y
+// anonimiza las que se conservan (tokens revocados hasta su caducidad, consentimientos,
+// auditoría y la propia fila del usuario, que sigue referenciada por ID)
+func eraseUserData(tx *gorm.DB, user *models.User, subjects []string) error {
+	for _, model := range []interface{}{
+		&models.UserIdentity{},
+		&models.UnlinkedIdentity{},
+		&models.UserSession{},
+		&models.MFAFactor{},
+		&models.MFARecoveryCode{},
+		&models.MFAChallenge{},
+		&models.AccountMergeRequest{},
+		&models.FirebaseClaimsSync{},
+		&models.DataExport{},
+		&models.SCIMUserLink{},
+		&models.UsernameHistory{},
+		&models.OrganizationMembership{},
+		&models.UserGroupMember{},
+		&models.EmailChangeRequest{},
+		&models.AccountReactivation{},
+	} {
+		if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, model := range []interface{}{&models.EmailVerification{}, &models.PasswordResetToken{}} {
+		if err := tx.Where("firebase_id IN ? OR email = ?", subjects, user.Email).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("accepted_by = ? OR email = ?", user.ID, user.Email).Delete(&models.Invitation{}).Error; err != nil {
+		return err
+	}
+
+	// Los errores de importación sólo se relacionan con la cuenta por el email de la fila
+	if err := tx.Where("lower(email) = lower(?)", user.Email).Delete(&models.UserImportRowError{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.RevokedToken{}).Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"ip_address": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+
+	// Las aceptaciones de los documentos legales se conservan como prueba de consentimiento
+	if err := tx.Model(&models.ConsentAcceptance{}).Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"ip_address": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+
+	// Las entradas de auditoría se conservan, pero sin IP, user agent ni detalles
+	if err := tx.Model(&models.AuditLog{}).Where("user_id = ?", user.ID).
+		Updates(map[string]interface{}{"ip_address": "", "user_agent": "", "details": nil}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.AuditLog{}).Where("actor_id = ?", user.ID).
+		Updates(map[string]interface{}{"ip_address": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(user).Updates(map[string]interface{}{
+		"firebase_id":     "erased:" + user.ID,
+		"email":           fmt.Sprintf("%s@%s", user.ID, erasedEmailDomain),
+		"canonical_email": "",
+		"username":        "erased-" + user.ID,
+		"first_name":      "",
+		"last_name":       "",
+		"photo_url":       "",
+		"avatar_id":       nil,
+		"attributes":      nil,
+		"active_org_id":   nil,
+		"email_verified":  false,
+		"mfa_enabled":     false,
+	}).Error
+}
+
+func (s *ErasureService) recordFailure(ctx context.Context, erasure *models.UserErasure, cause error) {
+	s.logger.WithError(cause).WithField("erasure_id", erasure.ID).Error("User erasure failed")
+
+	if err := s.db.WithContext(ctx).Model(&models.UserErasure{}).
+		Where("id = ? AND status = ?", erasure.ID, models.ErasureStatusScheduled).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": cause.Error(),
+		}).Error; err != nil {
+		s.logger.WithError(err).Warn("Failed to record user erasure failure")
+	}
+}
+
+// erasureScheduleChange indica si un cambio de estado programa el borrado (la cuenta
+// pasa a pending_deletion) o lo cancela (se restaura antes de completarlo)
+func erasureScheduleChange(event *models.UserStatusChangedEvent) (schedule, cancel bool) {
+	switch {
+	case event.To == models.StatusPendingDeletion:
+		return true, false
+	case event.From == models.StatusPendingDeletion && event.To != models.StatusDeleted:
+		return false, true
+	}
+	return false, false
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
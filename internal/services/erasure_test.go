@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+// sqlRecorder guarda las sentencias que genera gorm en modo DryRun
+type sqlRecorder struct {
+	gormlogger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// newDryRunDB devuelve una conexión que construye el SQL sin ejecutarlo
+func newDryRunDB(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	recorder := &sqlRecorder{Interface: gormlogger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 recorder,
+	})
+	require.NoError(t, err)
+	return db, recorder
+}
+
+func (r *sqlRecorder) find(fragment string) string {
+	for _, statement := range r.statements {
+		if strings.Contains(statement, fragment) {
+			return statement
+		}
+	}
+	return ""
+}
+
+func TestErasureScheduleChange(t *testing.T) {
+	event := func(from, to string) *models.UserStatusChangedEvent {
+		return &models.UserStatusChangedEvent{From: from, To: to}
+	}
+
+	schedule, cancel := erasureScheduleChange(event(models.StatusSuspended, models.StatusPendingDeletion))
+	assert.True(t, schedule)
+	assert.False(t, cancel)
+
+	// Restaurar la cuenta dentro del periodo de gracia cancela el borrado
+	schedule, cancel = erasureScheduleChange(event(models.StatusPendingDeletion, models.StatusActive))
+	assert.False(t, schedule)
+	assert.True(t, cancel)
+
+	// Completar el borrado no lo cancela
+	schedule, cancel = erasureScheduleChange(event(models.StatusPendingDeletion, models.StatusDeleted))
+	assert.False(t, schedule)
+	assert.False(t, cancel)
+
+	schedule, cancel = erasureScheduleChange(event(models.StatusActive, models.StatusSuspended))
+	assert.False(t, schedule)
+	assert.False(t, cancel)
+}
+
+func TestEraseUserData(t *testing.T) {
+	db, recorder := newDryRunDB(t)
+	user := &models.User{ID: "3f1c2d4e-0000-4000-8000-000000000001", Email: "Victim@Example.com"}
+
+	require.NoError(t, eraseUserData(db, user, []string{"firebase-uid"}))
+
+	// Los errores de importación con su email no sobreviven al borrado
+	rowErrors := recorder.find(`DELETE FROM "user_import_row_errors"`)
+	require.NotEmpty(t, rowErrors)
+	assert.Contains(t, rowErrors, "Victim@Example.com")
+
+	for _, table := range []string{"user_identities", "user_sessions", "mfa_factors", "email_change_requests", "invitations"} {
+		assert.NotEmpty(t, recorder.find(`DELETE FROM "`+table+`"`), table)
+	}
+
+	// La fila del usuario se conserva anonimizada
+	anonymized := recorder.find(`UPDATE "users"`)
+	require.NotEmpty(t, anonymized)
+	assert.Contains(t, anonymized, user.ID+"@"+erasedEmailDomain)
+	assert.NotContains(t, anonymized, "Victim@Example.com")
+}
+
+func newTestErasureService(t *testing.T) (*ErasureService, sqlmock.Sqlmock) {
+	db, mock := newMockDB(t)
+	users := &UserService{db: db, logger: logger.GetLogger()}
+	audit := NewAuditService(db)
+	return &ErasureService{
+		db:          db,
+		audit:       audit,
+		lifecycle:   NewLifecycleService(db, users, audit),
+		logger:      logger.GetLogger(),
+		gracePeriod: 30 * 24 * time.Hour,
+	}, mock
+}
+
+func TestErasureScheduledOnPendingDeletion(t *testing.T) {
+	s, mock := newTestErasureService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "user_erasures" WHERE user_id = \$1 AND status = \$2`).
+		WithArgs("user-id", models.ErasureStatusScheduled).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`INSERT INTO "user_erasures"`).
+		WithArgs("user-id", models.ErasureStatusScheduled, "gdpr", sqlmock.AnyArg(), 0, "", nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("erasure-id"))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+
+	s.OnStatusChanged(context.Background(), &models.UserStatusChangedEvent{
+		UserID: "user-id",
+		From:   models.StatusActive,
+		To:     models.StatusPendingDeletion,
+		Reason: "gdpr",
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestErasureCancelledOnRestore(t *testing.T) {
+	s, mock := newTestErasureService(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "user_erasures" SET "cancelled_at"=\$1,"status"=\$2,"updated_at"=\$3 WHERE user_id = \$4 AND status = \$5`).
+		WithArgs(sqlmock.AnyArg(), models.ErasureStatusCancelled, sqlmock.AnyArg(), "user-id", models.ErasureStatusScheduled).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+
+	s.OnStatusChanged(context.Background(), &models.UserStatusChangedEvent{
+		UserID: "user-id",
+		From:   models.StatusPendingDeletion,
+		To:     models.StatusActive,
+	})
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEraseNow(t *testing.T) {
+	s, mock := newTestErasureService(t)
+	var tombstones []*models.UserErasedEvent
+	s.OnUserErased(func(_ context.Context, event *models.UserErasedEvent) {
+		tombstones = append(tombstones, event)
+	})
+
+	erasureRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "user_id", "status"}).AddRow("erasure-id", "user-id", models.ErasureStatusScheduled)
+	}
+	userRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "email", "firebase_id", "status"}).
+			AddRow("user-id", "victim@example.com", "firebase-uid", models.StatusPendingDeletion)
+	}
+
+	mock.ExpectQuery(`SELECT \* FROM "user_erasures" WHERE user_id = \$1 AND status = \$2`).
+		WillReturnRows(erasureRows())
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+		WillReturnRows(userRows())
+	mock.ExpectQuery(`SELECT "subject" FROM "user_identities"`).
+		WillReturnRows(sqlmock.NewRows([]string{"subject"}).AddRow("google-uid"))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "user_erasures" WHERE \(?id = \$1 AND status = \$2.*FOR UPDATE`).
+		WillReturnRows(erasureRows())
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(?id = \$1 AND status = \$2.*FOR UPDATE`).
+		WillReturnRows(userRows())
+	for i := 0; i < 19; i++ {
+		mock.ExpectExec(`DELETE FROM`).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	for i := 0; i < 5; i++ {
+		mock.ExpectExec(`UPDATE`).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	// Transición a deleted
+	mock.ExpectExec(`UPDATE "users" SET .*"status"=`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectExec(`UPDATE "user_erasures" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	// La lápida se guarda en la misma transacción que el borrado
+	mock.ExpectExec(`INSERT INTO "user_erasure_tombstones"`).
+		WithArgs("erasure-id", "user-id", `["google-uid","firebase-uid"]`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+
+	erasure, err := s.EraseNow(context.Background(), AdminActor{UserID: "admin-id"}, "user-id")
+	require.NoError(t, err)
+	assert.Equal(t, models.ErasureStatusCompleted, erasure.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	require.Len(t, tombstones, 1)
+	assert.Equal(t, []string{"google-uid", "firebase-uid"}, tombstones[0].FirebaseIDs)
+}
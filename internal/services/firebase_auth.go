@@ -4,11 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"firebase.google.com/go/v4/auth"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 	"it-auth-service/internal/config"
 	"it-auth-service/internal/logger"
 	"it-auth-service/internal/models"
@@ -16,34 +18,62 @@ import (
 )
 
 type FirebaseAuthService struct {
-	firebaseClient *auth.Client
+	firebaseAuth   *firebase.Auth
 	config         *config.Config
 	userService    *UserService
 	tokenService   *TokenService
+	mfaService     *MFAService
+	identities     *IdentityService
+	merges         *AccountMergeService
+	guests         *GuestService
+	attributes     *AttributeService
+	orgs           *OrganizationService
+	groups         *GroupService
+	invitations    *InvitationService
+	lifecycle      *LifecycleService
+	dormancy       *DormancyService
+	consents       *ConsentService
+	domains        *DomainPolicyService
+	disposable     *DisposableEmailService
 	logger         *logrus.Logger
 }
 
-func NewFirebaseAuthService(cfg *config.Config, userService *UserService, tokenService *TokenService) (*FirebaseAuthService, error) {
-	firebaseClient, err := firebase.GetAuthClient()
+func NewFirebaseAuthService(cfg *config.Config, userService *UserService, tokenService *TokenService, mfaService *MFAService, identityService *IdentityService, mergeService *AccountMergeService, guestService *GuestService, attributeService *AttributeService, organizationService *OrganizationService, groupService *GroupService, invitationService *InvitationService, lifecycleService *LifecycleService, dormancyService *DormancyService, consentService *ConsentService, domainPolicyService *DomainPolicyService, disposableEmailService *DisposableEmailService) (*FirebaseAuthService, error) {
+	firebaseAuth, err := firebase.NewAuthFromEnv()
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Firebase client: %w", err)
 	}
 
 	return &FirebaseAuthService{
-		firebaseClient: firebaseClient,
+		firebaseAuth:   firebaseAuth,
 		config:         cfg,
 		userService:    userService,
 		tokenService:   tokenService,
+		mfaService:     mfaService,
+		identities:     identityService,
+		merges:         mergeService,
+		guests:         guestService,
+		attributes:     attributeService,
+		orgs:           organizationService,
+		groups:         groupService,
+		invitations:    invitationService,
+		lifecycle:      lifecycleService,
+		dormancy:       dormancyService,
+		consents:       consentService,
+		domains:        domainPolicyService,
+		disposable:     disposableEmailService,
 		logger:         logger.GetLogger(),
 	}, nil
 }
 
-// VerifyFirebaseToken verifica un token de Firebase y extrae la información del usuario
+// VerifyFirebaseToken verifica un token de Firebase y extrae la información del usuario.
+// Rechaza los tokens emitidos antes de revocar las sesiones del usuario, para que
+// tras un cierre de sesión forzado no se pueda canjear un token aún vigente
 func (s *FirebaseAuthService) VerifyFirebaseToken(ctx context.Context, idToken string) (*auth.Token, error) {
-	token, err := s.firebaseClient.VerifyIDToken(ctx, idToken)
+	token, err := s.firebaseAuth.VerifyIDTokenAndCheckRevoked(ctx, idToken)
 	if err != nil {
 		s.logger.WithError(err).Error("Failed to verify Firebase token")
-		return nil, fmt.Errorf("invalid Firebase token: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFirebaseToken, err)
 	}
 
 	return token, nil
@@ -57,8 +87,19 @@ func (s *FirebaseAuthService) FirebaseLogin(ctx context.Context, req *models.Fir
 		return nil, err
 	}
 
-	// Buscar usuario existente por Firebase ID
-	user, err := s.userService.GetUserByFirebaseID(ctx, token.UID)
+	// La invitación se valida antes de crear o vincular nada
+	invitation, err := s.checkInvitation(ctx, token, req.InvitationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s.domains.Resolve(ctx, getStringFromClaims(token.Claims, "email"))
+	if err != nil {
+		return nil, err
+	}
+
+	// Buscar usuario existente por cualquiera de sus identidades vinculadas
+	user, err := s.resolveUserByFirebaseUID(ctx, token.UID)
 	isNewUser := false
 
 	if err != nil {
@@ -67,20 +108,29 @@ func (s *FirebaseAuthService) FirebaseLogin(ctx context.Context, req *models.Fir
 		if email != "" {
 			existingUser, emailErr := s.userService.GetUserByEmail(ctx, email)
 			if emailErr == nil && existingUser != nil {
-				// Usuario existe con el mismo email, actualizar Firebase ID
-				existingUser.FirebaseID = token.UID
-				if updateErr := s.userService.UpdateUser(ctx, existingUser); updateErr != nil {
-					s.logger.WithError(updateErr).Warn("Failed to update user Firebase ID")
+				// Colisión de email: la cuenta existente nunca se reasigna en silencio.
+				// En otro caso hay que demostrar el control de la cuenta existente.
+				if !canAutoLinkIdentity(existingUser, token) {
+					return s.requestAccountMerge(ctx, existingUser, token, req.Provider)
+				}
+
+				provider := signInProvider(token, req.Provider)
+				if err := s.merges.LinkVerifiedIdentity(ctx, existingUser, provider, token.UID, email); err != nil {
+					return nil, err
 				}
+				existingUser.EmailVerified = true
 				user = existingUser
 			} else {
 				// Usuario no existe, crear uno nuevo (autoprovisionamiento)
+				if err := s.checkSignup(email, policy, invitation != nil); err != nil {
+					return nil, err
+				}
 				s.logger.WithField("firebase_id", token.UID).Info("User not found, creating new user")
 				
-				user, err = s.createUserFromFirebaseToken(ctx, token, req.Provider)
+				user, err = s.createUserFromFirebaseToken(ctx, token, req.Provider, policy)
 				if err != nil {
 					// Si falla por duplicado, intentar obtener el usuario existente
-					if existingUser, getErr := s.userService.GetUserByFirebaseID(ctx, token.UID); getErr == nil {
+					if existingUser, getErr := s.resolveUserByFirebaseUID(ctx, token.UID); getErr == nil {
 						user = existingUser
 					} else {
 						return nil, fmt.Errorf("failed to create user: %w", err)
@@ -94,20 +144,493 @@ func (s *FirebaseAuthService) FirebaseLogin(ctx context.Context, req *models.Fir
 		}
 	}
 
+	// La unión automática por dominio se aplica la primera vez que el email está verificado
+	joinByDomain := isNewUser || !user.EmailVerified
+
+	// Sólo los estados que admiten autenticación pueden iniciar sesión; el login activa
+	// las cuentas cuyo email ya está verificado y levanta los bloqueos vencidos
+	if err := s.lifecycle.ResumeOnLogin(ctx, user, verifiesAccountEmail(user, token, policy)); err != nil {
+		return nil, err
+	}
+
+	// Un invitado cuyas credenciales se vincularon en Firebase (mismo UID) pasa a ser cuenta completa
+	if user.Status == models.StatusGuest && signInProvider(token, "") != models.ProviderAnonymous {
+		if err := s.upgradeGuestFromToken(ctx, user, token, req.Provider); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.acceptInvitation(ctx, invitation, user, token); err != nil {
+		return nil, err
+	}
+
 	// Actualizar información del usuario si es necesario
-	if err := s.updateUserFromFirebaseToken(ctx, user, token, req.Provider); err != nil {
+	if err := s.updateUserFromFirebaseToken(ctx, user, token, policy); err != nil {
 		s.logger.WithError(err).Warn("Failed to update user information")
 	}
 
-	// Generar JWT interno
-	jwtToken, err := s.generateInternalJWT(user)
+	if joinByDomain {
+		s.autoJoin(ctx, policy, user)
+	}
+
+	// Registrar la identidad usada sin sobrescribir el provider principal del usuario
+	s.recordIdentity(ctx, user, token, req.Provider)
+
+	// Las versiones obligatorias de los documentos legales se aceptan antes de emitir la sesión
+	if consent, err := s.requireConsents(ctx, user, isNewUser); consent != nil || err != nil {
+		return consent, err
+	}
+
+	// Con MFA el login se completa en una segunda fase
+	authCtx := authContextFromFirebase(token, req.Provider)
+	if s.needsMFA(ctx, user) {
+		return s.createMFAChallenge(ctx, user, req.Provider, authCtx.AuthTime, isNewUser)
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, req.Provider, authCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponseData{
+		Token:     jwtToken,
+		User:      user,
+		IsNewUser: isNewUser,
+	}, nil
+}
+
+// CompleteMFALogin completa la segunda fase del login verificando el código TOTP
+// o un código de recuperación. Si el reto exige registro, confirma el factor pendiente.
+func (s *FirebaseAuthService) CompleteMFALogin(ctx context.Context, req *models.MFAVerifyRequest) (*models.AuthResponseData, error) {
+	challenge, err := s.mfaService.GetChallenge(ctx, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if challenge.Enrollment {
+		recoveryCodes, err = s.mfaService.ConfirmEnrollment(ctx, user.ID, req.Code)
+		if err == nil {
+			user.MFAEnabled = true
+		}
+	} else {
+		err = s.mfaService.Verify(ctx, user.ID, req.Code, req.RecoveryCode)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.mfaService.RecordFailedAttempt(ctx, challenge)
+		}
+		return nil, err
+	}
+
+	if err := s.mfaService.ConsumeChallenge(ctx, challenge); err != nil {
+		return nil, err
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, challenge.Provider, multiFactorAuthContext(challenge.Provider, challenge.AuthTime))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponseData{
+		Token:         jwtToken,
+		User:          user,
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+// Reauthenticate renueva la autenticación de la sesión actual (step-up). Exige un
+// login reciente en Firebase del mismo usuario y, si tiene MFA, un código válido
+// para elevar la sesión a aal2. El token anterior queda revocado.
+func (s *FirebaseAuthService) Reauthenticate(ctx context.Context, currentToken, userID string, req *models.ReauthenticateRequest, ipAddress, userAgent string) (*models.AuthResponseData, error) {
+	token, err := s.VerifyFirebaseToken(ctx, req.FirebaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	if token.UID != user.FirebaseID {
+		linked, err := s.identities.BelongsTo(ctx, user.ID, token.UID)
+		if err != nil {
+			return nil, err
+		}
+		if !linked {
+			return nil, ErrReauthenticationMismatch
+		}
+	}
+
+	authCtx := authContextFromFirebase(token, user.Provider)
+	if time.Since(authCtx.AuthTime) > s.config.StepUpConfig.ReauthWindow {
+		return nil, ErrStaleReauthentication
+	}
+	if err := checkReauthFactors(user, req); err != nil {
+		return nil, err
+	}
+
+	if req.Code != "" || req.RecoveryCode != "" {
+		if err := s.mfaService.Verify(ctx, user.ID, req.Code, req.RecoveryCode); err != nil {
+			return nil, err
+		}
+		authCtx = multiFactorAuthContext(user.Provider, authCtx.AuthTime)
+	}
+
+	jwtToken, err := s.generateInternalJWT(ctx, user, authCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
+	if err := s.tokenService.UpgradeSession(ctx, currentToken, jwtToken, user.ID, authCtx); err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenService.RevokeToken(ctx, currentToken, user.ID, "step_up", ipAddress, userAgent); err != nil {
+		s.logger.WithError(err).Warn("Failed to revoke token replaced by step-up")
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id": user.ID,
+		"acr":     authCtx.ACR,
+	}).Info("User reauthenticated")
+
+	return &models.AuthResponseData{
+		Token: jwtToken,
+		User:  user,
+	}, nil
+}
+
+// SwitchOrganization emite un token para otra organización del usuario conservando el
+// contexto de autenticación de la sesión actual. El token anterior queda revocado.
+func (s *FirebaseAuthService) SwitchOrganization(ctx context.Context, currentToken string, claims map[string]interface{}, actor AdminActor, orgID string) (*models.AuthResponseData, error) {
+	if _, err := s.orgs.GetMembership(ctx, orgID, actor.UserID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.GetUserByID(ctx, actor.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// El token se emite ya con la nueva organización; el cambio y la sesión se guardan
+	// juntos para que la organización activa nunca difiera de la del token en uso
+	user.ActiveOrgID = &orgID
+	authCtx := authContextFromClaims(claims)
+	jwtToken, err := s.generateInternalJWT(ctx, user, authCtx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate JWT: %w", err)
 	}
 
+	if _, err := s.orgs.SetActiveOrganization(ctx, actor, orgID, func(tx *gorm.DB) error {
+		return s.tokenService.UpgradeSessionTx(tx, currentToken, jwtToken, user.ID, authCtx)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenService.RevokeToken(ctx, currentToken, user.ID, "org_switch", actor.IPAddress, actor.UserAgent); err != nil {
+		s.logger.WithError(err).Warn("Failed to revoke token replaced by organization switch")
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id": user.ID,
+		"org_id":  orgID,
+	}).Info("User switched organization")
+
+	return &models.AuthResponseData{
+		Token: jwtToken,
+		User:  user,
+	}, nil
+}
+
+// canAutoLinkIdentity indica si una identidad nueva puede vincularse sin más a la cuenta
+// existente con la que colisiona su email. El proveedor tiene que haber verificado
+// exactamente el email de la cuenta, y la cuenta tenerlo verificado también: una cuenta
+// creada con un email ajeno sin verificar no puede quedarse con el login de su dueño,
+// y una variante del buzón (p. ej. con +etiqueta) no demuestra el control de la dirección.
+func canAutoLinkIdentity(existingUser *models.User, token *auth.Token) bool {
+	return existingUser.EmailVerified &&
+		getBoolFromClaims(token.Claims, "email_verified") &&
+		sameEmail(getStringFromClaims(token.Claims, "email"), existingUser.Email)
+}
+
+// checkMergeProof comprueba que el login de prueba es reciente y de la cuenta que se vincula
+func checkMergeProof(request *models.AccountMergeRequest, user *models.User, authCtx models.AuthContext, window time.Duration) error {
+	if user.ID != request.UserID {
+		return ErrMergeProofMismatch
+	}
+	if err := CheckAccountStatus(user); err != nil {
+		return err
+	}
+	if time.Since(authCtx.AuthTime) > window {
+		return ErrStaleReauthentication
+	}
+	return nil
+}
+
+// requestAccountMerge devuelve la respuesta account_link_required para una colisión de email
+func (s *FirebaseAuthService) requestAccountMerge(ctx context.Context, existingUser *models.User, token *auth.Token, provider string) (*models.AuthResponseData, error) {
+	email := getStringFromClaims(token.Claims, "email")
+	mergeToken, err := s.merges.CreateRequest(ctx, existingUser.ID, signInProvider(token, provider), token.UID, email)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":     existingUser.ID,
+		"firebase_id": token.UID,
+	}).Warn("Email collision with unverified email, account merge required")
+
+	return &models.AuthResponseData{
+		AccountLinkRequired: true,
+		MergeToken:          mergeToken,
+	}, nil
+}
+
+// ConfirmAccountMerge completa una vinculación pendiente. El cliente demuestra el
+// control de la cuenta existente con un login reciente de una de sus identidades.
+func (s *FirebaseAuthService) ConfirmAccountMerge(ctx context.Context, req *models.ConfirmAccountMergeRequest) (*models.AuthResponseData, error) {
+	request, err := s.merges.GetPending(ctx, req.MergeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	proof, err := s.VerifyFirebaseToken(ctx, req.FirebaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUserByFirebaseUID(ctx, proof.UID)
+	if err != nil {
+		return nil, ErrMergeProofMismatch
+	}
+	authCtx := authContextFromFirebase(proof, user.Provider)
+	if err := checkMergeProof(request, user, authCtx, s.config.StepUpConfig.ReauthWindow); err != nil {
+		return nil, err
+	}
+
+	if err := s.merges.Complete(ctx, request, proof.UID); err != nil {
+		return nil, err
+	}
+
+	if consent, err := s.requireConsents(ctx, user, false); consent != nil || err != nil {
+		return consent, err
+	}
+
+	if s.needsMFA(ctx, user) {
+		return s.createMFAChallenge(ctx, user, request.Provider, authCtx.AuthTime, false)
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, request.Provider, authCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponseData{
+		Token: jwtToken,
+		User:  user,
+	}, nil
+}
+
+// RequestReactivation envía el código de reactivación a una cuenta desactivada por
+// inactividad. El token de Firebase demuestra el control de una de sus identidades.
+func (s *FirebaseAuthService) RequestReactivation(ctx context.Context, req *models.RequestReactivationRequest, ipAddress, userAgent string) (*models.AccountReactivation, error) {
+	token, err := s.VerifyFirebaseToken(ctx, req.FirebaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUserByFirebaseUID(ctx, token.UID)
+	if err != nil {
+		return nil, ErrNotDormant
+	}
+
+	actor := AdminActor{UserID: user.ID, IPAddress: ipAddress, UserAgent: userAgent}
+	return s.dormancy.RequestReactivation(ctx, actor, user)
+}
+
+// ConfirmReactivation reactiva la cuenta con el código recibido por email y completa el login
+func (s *FirebaseAuthService) ConfirmReactivation(ctx context.Context, req *models.ConfirmReactivationRequest, ipAddress, userAgent string) (*models.AuthResponseData, error) {
+	token, err := s.VerifyFirebaseToken(ctx, req.FirebaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUserByFirebaseUID(ctx, token.UID)
+	if err != nil {
+		return nil, ErrNotDormant
+	}
+
+	actor := AdminActor{UserID: user.ID, IPAddress: ipAddress, UserAgent: userAgent}
+	user, err = s.dormancy.ConfirmReactivation(ctx, actor, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if consent, err := s.requireConsents(ctx, user, false); consent != nil || err != nil {
+		return consent, err
+	}
+
+	provider := signInProvider(token, user.Provider)
+	authCtx := authContextFromFirebase(token, provider)
+	if s.needsMFA(ctx, user) {
+		return s.createMFAChallenge(ctx, user, provider, authCtx.AuthTime, false)
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, provider, authCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponseData{
+		Token: jwtToken,
+		User:  user,
+	}, nil
+}
+
+// AcceptLoginConsents registra la aceptación de los documentos que bloqueaban el login
+// (consent_required) y lo completa. El token de Firebase identifica al usuario.
+func (s *FirebaseAuthService) AcceptLoginConsents(ctx context.Context, req *models.LoginConsentRequest, ipAddress, userAgent string) (*models.AuthResponseData, error) {
+	token, err := s.VerifyFirebaseToken(ctx, req.FirebaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveUserByFirebaseUID(ctx, token.UID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := CheckAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	actor := AdminActor{UserID: user.ID, IPAddress: ipAddress, UserAgent: userAgent}
+	if _, err := s.consents.Accept(ctx, actor, req.DocumentIDs); err != nil {
+		return nil, err
+	}
+	if consent, err := s.requireConsents(ctx, user, false); consent != nil || err != nil {
+		return consent, err
+	}
+
+	provider := signInProvider(token, user.Provider)
+	authCtx := authContextFromFirebase(token, provider)
+	if s.needsMFA(ctx, user) {
+		return s.createMFAChallenge(ctx, user, provider, authCtx.AuthTime, false)
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, provider, authCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponseData{
+		Token: jwtToken,
+		User:  user,
+	}, nil
+}
+
+// BeginChallengeEnrollment inicia el registro de TOTP para un usuario al que la
+// política obliga a usar MFA y que todavía no tiene JWT
+func (s *FirebaseAuthService) BeginChallengeEnrollment(ctx context.Context, challengeToken string) (*models.MFAEnrollResponse, error) {
+	challenge, err := s.mfaService.GetChallenge(ctx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.Enrollment {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	user, err := s.userService.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mfaService.BeginEnrollment(ctx, user)
+}
+
+// needsMFA indica si el login del usuario requiere segunda fase
+func (s *FirebaseAuthService) needsMFA(ctx context.Context, user *models.User) bool {
+	return user.MFAEnabled || s.mfaService.IsRequired(ctx, user)
+}
+
+// checkReauthFactors exige el segundo factor a quien tiene MFA activado: reautenticarse
+// sólo con Firebase rebajaría su sesión a aal1 en lugar de renovarla en aal2
+func checkReauthFactors(user *models.User, req *models.ReauthenticateRequest) error {
+	if user.MFAEnabled && req.Code == "" && req.RecoveryCode == "" {
+		return ErrMFACodeRequired
+	}
+	return nil
+}
+
+// createMFAChallenge devuelve la respuesta mfa_required en lugar del JWT. authTime es
+// el momento del primer factor y pasa tal cual a la sesión que complete el reto.
+func (s *FirebaseAuthService) createMFAChallenge(ctx context.Context, user *models.User, provider string, authTime time.Time, isNewUser bool) (*models.AuthResponseData, error) {
+	enrollment := !user.MFAEnabled
+	challengeToken, err := s.mfaService.CreateChallenge(ctx, user.ID, provider, enrollment, authTime)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":    user.ID,
+		"enrollment": enrollment,
+	}).Info("MFA challenge issued")
+
+	return &models.AuthResponseData{
+		IsNewUser:             isNewUser,
+		MFARequired:           true,
+		MFAEnrollmentRequired: enrollment,
+		ChallengeToken:        challengeToken,
+	}, nil
+}
+
+// requireConsents devuelve la respuesta consent_required si el usuario no ha aceptado
+// alguna versión obligatoria vigente; nil si el login puede continuar
+func (s *FirebaseAuthService) requireConsents(ctx context.Context, user *models.User, isNewUser bool) (*models.AuthResponseData, error) {
+	pending, err := s.consents.Pending(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return consentRequired(pending, isNewUser), nil
+}
+
+// consentRequired es la respuesta que sustituye a la sesión mientras queden versiones
+// obligatorias por aceptar; nil si no queda ninguna
+func consentRequired(pending []*models.ConsentDocument, isNewUser bool) *models.AuthResponseData {
+	if len(pending) == 0 {
+		return nil
+	}
+	return &models.AuthResponseData{
+		IsNewUser:        isNewUser,
+		ConsentRequired:  true,
+		RequiredConsents: pending,
+	}
+}
+
+// issueSession genera el JWT interno, actualiza el último login y registra la sesión.
+// Todo token de acceso debe emitirse por aquí: sin sesión no podría revocarse.
+func (s *FirebaseAuthService) issueSession(ctx context.Context, user *models.User, provider string, authCtx models.AuthContext) (string, error) {
+	// Generar JWT interno
+	jwtToken, err := s.generateInternalJWT(ctx, user, authCtx)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate JWT: %w", err)
+	}
+
 	// Actualizar timestamp de último login
 	now := time.Now()
 	user.LastLoginAt = &now
+	user.LastSeenAt = &now
 	if err := s.userService.UpdateUser(ctx, user); err != nil {
 		s.logger.WithError(err).Warn("Failed to update user last login timestamp")
 	}
@@ -115,10 +638,40 @@ func (s *FirebaseAuthService) FirebaseLogin(ctx context.Context, req *models.Fir
 	// Crear sesión de usuario para auditoría
 	// Nota: En un handler real, obtendrías IP y User-Agent del contexto HTTP
 	// Por ahora, usamos valores por defecto
-	_, err = s.tokenService.CreateSession(ctx, user.ID, jwtToken, "unknown", "unknown", req.Provider)
+	_, err = s.tokenService.CreateSession(ctx, user.ID, jwtToken, "unknown", "unknown", provider, authCtx)
+	if err != nil {
+		return "", err
+	}
+
+	return jwtToken, nil
+}
+
+// GuestLogin inicia o retoma la sesión de un invitado a partir de un login anónimo de Firebase.
+// Los invitados reciben tokens de corta duración con scope limitado.
+func (s *FirebaseAuthService) GuestLogin(ctx context.Context, req *models.GuestLoginRequest) (*models.AuthResponseData, error) {
+	token, err := s.VerifyFirebaseToken(ctx, req.FirebaseToken)
 	if err != nil {
-		s.logger.WithError(err).Warn("Failed to create user session")
-		// No es un error crítico, continuamos
+		return nil, err
+	}
+	if signInProvider(token, "") != models.ProviderAnonymous {
+		return nil, ErrNotAnonymousToken
+	}
+
+	isNewUser := false
+	user, err := s.resolveUserByFirebaseUID(ctx, token.UID)
+	if err != nil {
+		user, err = s.guests.CreateGuest(ctx, token.UID)
+		if err != nil {
+			return nil, err
+		}
+		isNewUser = true
+	} else if user.Status != models.StatusGuest {
+		return nil, ErrNotGuest
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, models.ProviderAnonymous, authContextFromFirebase(token, models.ProviderAnonymous))
+	if err != nil {
+		return nil, err
 	}
 
 	return &models.AuthResponseData{
@@ -128,6 +681,76 @@ func (s *FirebaseAuthService) FirebaseLogin(ctx context.Context, req *models.Fir
 	}, nil
 }
 
+// UpgradeGuest vincula una identidad real al invitado autenticado conservando su ID de usuario.
+// El token de invitado se revoca y se emite uno de cuenta completa.
+func (s *FirebaseAuthService) UpgradeGuest(ctx context.Context, currentToken, userID string, req *models.GuestUpgradeRequest, ipAddress, userAgent string) (*models.AuthResponseData, error) {
+	token, err := s.VerifyFirebaseToken(ctx, req.FirebaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.upgradeGuestFromToken(ctx, user, token, req.Provider); err != nil {
+		return nil, err
+	}
+
+	if err := s.tokenService.RevokeToken(ctx, currentToken, userID, "guest_upgrade", ipAddress, userAgent); err != nil {
+		s.logger.WithError(err).Warn("Failed to revoke guest token after upgrade")
+	}
+	if err := s.tokenService.EndSession(ctx, currentToken, userID); err != nil {
+		s.logger.WithError(err).Warn("Failed to end guest session after upgrade")
+	}
+
+	authCtx := authContextFromFirebase(token, req.Provider)
+	if s.needsMFA(ctx, user) {
+		return s.createMFAChallenge(ctx, user, req.Provider, authCtx.AuthTime, false)
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, req.Provider, authCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponseData{
+		Token: jwtToken,
+		User:  user,
+	}, nil
+}
+
+// upgradeGuestFromToken convierte al invitado con los datos de la identidad del token
+func (s *FirebaseAuthService) upgradeGuestFromToken(ctx context.Context, user *models.User, token *auth.Token, provider string) error {
+	email := getStringFromClaims(token.Claims, "email")
+
+	// Convertir un invitado equivale a un alta: se aplican las reglas de su dominio
+	policy, err := s.domains.Resolve(ctx, email)
+	if err != nil {
+		return err
+	}
+	if err := s.checkSignup(email, policy, false); err != nil {
+		return err
+	}
+
+	err = s.guests.Upgrade(ctx, user, GuestUpgrade{
+		Provider:      signInProvider(token, provider),
+		Subject:       token.UID,
+		Email:         email,
+		EmailVerified: emailVerifiedByToken(token, policy),
+		Name:          getStringFromClaims(token.Claims, "name"),
+		PhotoURL:      getStringFromClaims(token.Claims, "picture"),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.autoJoin(ctx, policy, user)
+	s.userService.notifyChanged(ctx, user)
+	return nil
+}
+
 // FirebaseRegister maneja el registro con token de Firebase
 func (s *FirebaseAuthService) FirebaseRegister(ctx context.Context, req *models.FirebaseRegisterRequest) (*models.AuthResponseData, error) {
 	// Verificar el token de Firebase
@@ -137,21 +760,60 @@ func (s *FirebaseAuthService) FirebaseRegister(ctx context.Context, req *models.
 	}
 
 	// Verificar si el usuario ya existe
-	existingUser, err := s.userService.GetUserByFirebaseID(ctx, token.UID)
+	existingUser, err := s.resolveUserByFirebaseUID(ctx, token.UID)
 	if err == nil && existingUser != nil {
 		return nil, errors.New("user already exists")
 	}
 
+	invitation, err := s.checkInvitation(ctx, token, req.InvitationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	email := getStringFromClaims(token.Claims, "email")
+	policy, err := s.domains.Resolve(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSignup(email, policy, invitation != nil); err != nil {
+		return nil, err
+	}
+
 	// Crear nuevo usuario con datos adicionales
-	user, err := s.createUserFromFirebaseTokenWithData(ctx, token, req.Provider, req.RegistrationData)
+	user, err := s.createUserFromFirebaseTokenWithData(ctx, token, req.Provider, req.RegistrationData, policy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
+	s.autoJoin(ctx, policy, user)
 
-	// Generar JWT interno
-	jwtToken, err := s.generateInternalJWT(user)
+	if err := s.acceptInvitation(ctx, invitation, user, token); err != nil {
+		return nil, err
+	}
+
+	s.recordIdentity(ctx, user, token, req.Provider)
+
+	// La cuenta queda creada aunque aún no pueda autenticarse (pendiente de verificación)
+	if err := CheckAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	if len(req.AcceptedConsents) > 0 {
+		if _, err := s.consents.Accept(ctx, AdminActor{UserID: user.ID}, req.AcceptedConsents); err != nil {
+			return nil, err
+		}
+	}
+	if consent, err := s.requireConsents(ctx, user, true); consent != nil || err != nil {
+		return consent, err
+	}
+
+	authCtx := authContextFromFirebase(token, req.Provider)
+	if s.needsMFA(ctx, user) {
+		return s.createMFAChallenge(ctx, user, req.Provider, authCtx.AuthTime, true)
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, req.Provider, authCtx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+		return nil, err
 	}
 
 	return &models.AuthResponseData{
@@ -170,20 +832,37 @@ func (s *FirebaseAuthService) RefreshToken(ctx context.Context, req *models.Fire
 	}
 
 	// Buscar usuario
-	user, err := s.userService.GetUserByFirebaseID(ctx, token.UID)
+	user, err := s.resolveUserByFirebaseUID(ctx, token.UID)
 	if err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
+	policy, err := s.domains.Resolve(ctx, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lifecycle.ResumeOnLogin(ctx, user, verifiesAccountEmail(user, token, policy)); err != nil {
+		return nil, err
+	}
 
 	// Actualizar información del usuario
-	if err := s.updateUserFromFirebaseToken(ctx, user, token, user.Provider); err != nil {
+	if err := s.updateUserFromFirebaseToken(ctx, user, token, policy); err != nil {
 		s.logger.WithError(err).Warn("Failed to update user information")
 	}
 
-	// Generar nuevo JWT interno
-	jwtToken, err := s.generateInternalJWT(user)
+	// Una versión obligatoria publicada después del login también bloquea la renovación
+	if consent, err := s.requireConsents(ctx, user, false); consent != nil || err != nil {
+		return consent, err
+	}
+
+	// Un token de Firebase por sí solo no basta para renovar si el usuario tiene MFA
+	authCtx := authContextFromFirebase(token, user.Provider)
+	if s.needsMFA(ctx, user) {
+		return s.createMFAChallenge(ctx, user, user.Provider, authCtx.AuthTime, false)
+	}
+
+	jwtToken, err := s.issueSession(ctx, user, user.Provider, authCtx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate JWT: %w", err)
+		return nil, err
 	}
 
 	return &models.AuthResponseData{
@@ -193,42 +872,47 @@ func (s *FirebaseAuthService) RefreshToken(ctx context.Context, req *models.Fire
 	}, nil
 }
 
+// initialStatus es el estado de una cuenta nueva: si la configuración lo exige, sin
+// email verificado queda pendiente de verificación
+func (s *FirebaseAuthService) initialStatus(token *auth.Token, policy *models.DomainPolicy) string {
+	if s.config.LifecycleConfig.RequireVerifiedEmail && !emailVerifiedByToken(token, policy) {
+		return models.StatusPendingVerification
+	}
+	return models.StatusActive
+}
+
 // createUserFromFirebaseToken crea un usuario desde un token de Firebase
-func (s *FirebaseAuthService) createUserFromFirebaseToken(ctx context.Context, token *auth.Token, provider string) (*models.User, error) {
+func (s *FirebaseAuthService) createUserFromFirebaseToken(ctx context.Context, token *auth.Token, provider string, policy *models.DomainPolicy) (*models.User, error) {
 	user := &models.User{
 		FirebaseID:    token.UID,
 		Email:         getStringFromClaims(token.Claims, "email"),
-		EmailVerified: getBoolFromClaims(token.Claims, "email_verified"),
+		EmailVerified: emailVerifiedByToken(token, policy),
 		FirstName:     getStringFromClaims(token.Claims, "name"),
 		Provider:      provider,
 		PhotoURL:      getStringFromClaims(token.Claims, "picture"),
-		Status:        "active",
-	}
-
-	// Generar username si no existe
-	if user.Username == "" {
-		user.Username = s.generateUsernameFromEmail(user.Email)
+		Status:        s.initialStatus(token, policy),
+		Role:          models.RoleUser,
 	}
 
+	// El username se genera a partir del email al crear el usuario
 	return s.userService.CreateUser(ctx, user)
 }
 
 // createUserFromFirebaseTokenWithData crea un usuario con datos adicionales
-func (s *FirebaseAuthService) createUserFromFirebaseTokenWithData(ctx context.Context, token *auth.Token, provider string, registrationData map[string]interface{}) (*models.User, error) {
+func (s *FirebaseAuthService) createUserFromFirebaseTokenWithData(ctx context.Context, token *auth.Token, provider string, registrationData map[string]interface{}, policy *models.DomainPolicy) (*models.User, error) {
 	user := &models.User{
 		FirebaseID:    token.UID,
 		Email:         getStringFromClaims(token.Claims, "email"),
-		EmailVerified: getBoolFromClaims(token.Claims, "email_verified"),
+		EmailVerified: emailVerifiedByToken(token, policy),
 		Provider:      provider,
 		PhotoURL:      getStringFromClaims(token.Claims, "picture"),
-		Status:        "active",
+		Status:        s.initialStatus(token, policy),
+		Role:          models.RoleUser,
 	}
 
-	// Aplicar datos adicionales del registro
+	// Aplicar datos adicionales del registro; sin username se genera uno libre
 	if username, ok := registrationData["username"].(string); ok && username != "" {
 		user.Username = username
-	} else {
-		user.Username = s.generateUsernameFromEmail(user.Email)
 	}
 
 	if firstName, ok := registrationData["first_name"].(string); ok {
@@ -244,30 +928,28 @@ func (s *FirebaseAuthService) createUserFromFirebaseTokenWithData(ctx context.Co
 	return s.userService.CreateUser(ctx, user)
 }
 
-// updateUserFromFirebaseToken actualiza la información del usuario desde Firebase
-func (s *FirebaseAuthService) updateUserFromFirebaseToken(ctx context.Context, user *models.User, token *auth.Token, provider string) error {
+// updateUserFromFirebaseToken actualiza la información del usuario desde Firebase.
+// El provider no se toca: cada método de login se registra como identidad vinculada.
+func (s *FirebaseAuthService) updateUserFromFirebaseToken(ctx context.Context, user *models.User, token *auth.Token, policy *models.DomainPolicy) error {
 	updated := false
 
-	// Actualizar email verificado
-	emailVerified := getBoolFromClaims(token.Claims, "email_verified")
-	if user.EmailVerified != emailVerified {
-		user.EmailVerified = emailVerified
-		updated = true
+	// Actualizar email verificado sólo si el token es del mismo email que la cuenta: una
+	// identidad vinculada con otro email no dice nada sobre la dirección de la cuenta
+	if sameEmail(getStringFromClaims(token.Claims, "email"), user.Email) {
+		emailVerified := emailVerifiedByToken(token, policy)
+		if user.EmailVerified != emailVerified {
+			user.EmailVerified = emailVerified
+			updated = true
+		}
 	}
 
-	// Actualizar foto de perfil
+	// Actualizar foto de perfil, salvo que el usuario haya subido su propio avatar
 	photoURL := getStringFromClaims(token.Claims, "picture")
-	if user.PhotoURL != photoURL && photoURL != "" {
+	if user.AvatarID == nil && user.PhotoURL != photoURL && photoURL != "" {
 		user.PhotoURL = photoURL
 		updated = true
 	}
 
-	// Actualizar provider si es diferente
-	if user.Provider != provider {
-		user.Provider = provider
-		updated = true
-	}
-
 	if updated {
 		return s.userService.UpdateUser(ctx, user)
 	}
@@ -275,42 +957,192 @@ func (s *FirebaseAuthService) updateUserFromFirebaseToken(ctx context.Context, u
 	return nil
 }
 
+// emailVerifiedByToken indica si el email del token está verificado: lo confirma el
+// proveedor o, si la regla de su dominio lo permite, lo asegura uno de los proveedores
+// federados en los que confía la regla. Las cuentas con contraseña o anónimas nunca se
+// verifican por dominio.
+func emailVerifiedByToken(token *auth.Token, policy *models.DomainPolicy) bool {
+	if getBoolFromClaims(token.Claims, "email_verified") {
+		return true
+	}
+	if policy == nil || !policy.AutoVerify {
+		return false
+	}
+	provider := signInProvider(token, "")
+	if provider == "" || provider == "password" || provider == models.ProviderAnonymous {
+		return false
+	}
+	for _, trusted := range policy.TrustedProviders {
+		if provider == trusted {
+			return true
+		}
+	}
+	return false
+}
+
+// verifiesAccountEmail indica si el token demuestra el control del email de la cuenta:
+// tiene que estar verificado y ser el mismo email, no el de otra identidad vinculada
+func verifiesAccountEmail(user *models.User, token *auth.Token, policy *models.DomainPolicy) bool {
+	return sameEmail(getStringFromClaims(token.Claims, "email"), user.Email) && emailVerifiedByToken(token, policy)
+}
+
+// sameEmail compara dos direcciones sin distinguir mayúsculas; un email vacío no coincide con nada
+func sameEmail(a, b string) bool {
+	return a != "" && strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+// checkSignup aplica a un alta las reglas de su dominio y el rechazo de los emails
+// desechables. Un dominio con regla propia queda fuera de la lista de desechables: si
+// no bloquea las altas, el administrador lo ha aceptado expresamente.
+func (s *FirebaseAuthService) checkSignup(email string, policy *models.DomainPolicy, invited bool) error {
+	if policy != nil {
+		return checkSignupDomain(policy, invited)
+	}
+	if invited {
+		return nil
+	}
+	return s.disposable.Check(email)
+}
+
+// autoJoin aplica la unión automática por dominio; un fallo no impide el login
+func (s *FirebaseAuthService) autoJoin(ctx context.Context, policy *models.DomainPolicy, user *models.User) {
+	if err := s.domains.AutoJoin(ctx, policy, user); err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to join organization by email domain")
+	}
+}
+
+// resolveUserByFirebaseUID busca al usuario por sus identidades vinculadas y,
+// para usuarios anteriores a la tabla de identidades, por su Firebase ID principal
+func (s *FirebaseAuthService) resolveUserByFirebaseUID(ctx context.Context, uid string) (*models.User, error) {
+	userID, err := s.identities.FindUserIDBySubject(ctx, uid)
+	if err == nil {
+		return s.userService.GetUserByID(ctx, userID)
+	}
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return nil, err
+	}
+
+	return s.userService.GetUserByFirebaseID(ctx, uid)
+}
+
+// recordIdentity registra la identidad del token como usada por el usuario
+func (s *FirebaseAuthService) recordIdentity(ctx context.Context, user *models.User, token *auth.Token, provider string) {
+	email := getStringFromClaims(token.Claims, "email")
+	if err := s.identities.RecordIdentity(ctx, user.ID, signInProvider(token, provider), token.UID, email); err != nil {
+		s.logger.WithError(err).Warn("Failed to record user identity")
+	}
+}
+
+// LinkIdentity vincula al usuario autenticado la identidad del token de Firebase recibido
+func (s *FirebaseAuthService) LinkIdentity(ctx context.Context, userID string, req *models.LinkIdentityRequest) (*models.UserIdentity, error) {
+	token, err := s.VerifyFirebaseToken(ctx, req.FirebaseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.GetUserProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identities.EnsurePrimaryIdentity(ctx, user); err != nil {
+		return nil, err
+	}
+
+	email := getStringFromClaims(token.Claims, "email")
+	return s.identities.LinkIdentity(ctx, user.ID, signInProvider(token, ""), token.UID, email)
+}
+
+// checkInvitation valida el token de invitación contra el email verificado de la
+// identidad de Firebase. Devuelve nil si no se envió invitación.
+func (s *FirebaseAuthService) checkInvitation(ctx context.Context, token *auth.Token, invitationToken string) (*models.Invitation, error) {
+	if invitationToken == "" {
+		return nil, nil
+	}
+	return s.invitations.Check(ctx, invitationToken, getStringFromClaims(token.Claims, "email"),
+		getBoolFromClaims(token.Claims, "email_verified"))
+}
+
+// acceptInvitation vincula la invitación validada a la cuenta que inicia sesión
+func (s *FirebaseAuthService) acceptInvitation(ctx context.Context, invitation *models.Invitation, user *models.User, token *auth.Token) error {
+	if invitation == nil {
+		return nil
+	}
+	return s.invitations.Accept(ctx, invitation.ID, user, getStringFromClaims(token.Claims, "email"),
+		getBoolFromClaims(token.Claims, "email_verified"))
+}
+
+// signInProvider devuelve el provider con el que se emitió el token de Firebase
+func signInProvider(token *auth.Token, fallback string) string {
+	if token.Firebase.SignInProvider != "" {
+		return token.Firebase.SignInProvider
+	}
+	return fallback
+}
+
 // generateInternalJWT genera un JWT interno para el usuario
-func (s *FirebaseAuthService) generateInternalJWT(user *models.User) (string, error) {
+func (s *FirebaseAuthService) generateInternalJWT(ctx context.Context, user *models.User, authCtx models.AuthContext) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":     user.ID, // Ahora es string (UUID)
 		"firebase_id": user.FirebaseID,
 		"email":       user.Email,
 		"username":    user.Username,
 		"provider":    user.Provider,
-		"exp":         time.Now().Add(24 * time.Hour).Unix(),
+		"role":        user.Role,
+		"auth_time":   authCtx.AuthTime.Unix(),
+		"amr":         authCtx.AMR,
+		"acr":         authCtx.ACR,
 		"iat":         time.Now().Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.JWTSecret))
-}
+	scope, expiresIn := tokenLifetime(user, s.config.GuestConfig.TokenTTL)
+	if scope != "" {
+		claims["scope"] = scope
+	}
+	claims["exp"] = time.Now().Add(expiresIn).Unix()
 
-// generateUsernameFromEmail genera un username desde un email
-func (s *FirebaseAuthService) generateUsernameFromEmail(email string) string {
-	if email == "" {
-		return fmt.Sprintf("user_%d", time.Now().Unix())
+	// Atributos personalizados marcados para exponerse en el token
+	attributes, err := s.attributes.TokenClaims(ctx, user)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to load token attributes")
+	} else if len(attributes) > 0 {
+		claims["attributes"] = attributes
 	}
 
-	// Extraer la parte antes del @
-	at := 0
-	for i, char := range email {
-		if char == '@' {
-			at = i
-			break
-		}
+	// Organización (tenant) con la que se emite el token y rol del usuario en ella
+	membership, err := s.orgs.ActiveMembership(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	tenantID := ""
+	if membership != nil {
+		tenantID = membership.Organization.Slug
+		claims["org_id"] = membership.OrgID
+		claims["tenant_id"] = tenantID
+		claims["org_role"] = membership.Role
 	}
 
-	if at > 0 {
-		return email[:at]
+	// Grupos globales y del tenant; si son demasiados sólo se indica el desbordamiento
+	groups, overflow, err := s.groups.TokenGroups(ctx, user, tenantID)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to load token groups")
+	} else if overflow {
+		claims["groups_overflow"] = true
+	} else if len(groups) > 0 {
+		claims["groups"] = groups
 	}
 
-	return fmt.Sprintf("user_%d", time.Now().Unix())
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(s.config.JWTSecret))
+}
+
+// tokenLifetime devuelve el scope y la duración del JWT interno: los invitados reciben
+// tokens de corta duración con scope limitado
+func tokenLifetime(user *models.User, guestTTL time.Duration) (string, time.Duration) {
+	if user.Status == models.StatusGuest {
+		return models.ScopeGuest, guestTTL
+	}
+	return "", internalTokenTTL
 }
 
 // Funciones auxiliares para extraer datos de claims
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/stretchr/testify/assert"
+	"it-auth-service/internal/models"
+)
+
+func TestVerifiesAccountEmail(t *testing.T) {
+	token := func(email string, verified bool) *auth.Token {
+		return &auth.Token{
+			Firebase: auth.FirebaseInfo{SignInProvider: "google.com"},
+			Claims:   map[string]interface{}{"email": email, "email_verified": verified},
+		}
+	}
+	user := &models.User{Email: "Victim@Example.com"}
+
+	assert.True(t, verifiesAccountEmail(user, token("victim@example.com", true), nil))
+	assert.False(t, verifiesAccountEmail(user, token("victim@example.com", false), nil))
+
+	// Una identidad vinculada con otro email verificado no verifica el email de la cuenta
+	assert.False(t, verifiesAccountEmail(user, token("attacker@gmail.com", true), nil))
+	assert.False(t, verifiesAccountEmail(user, token("", true), nil))
+}
+
+func TestUpdateUserFromFirebaseTokenIgnoresOtherEmail(t *testing.T) {
+	s := &FirebaseAuthService{}
+	user := &models.User{Email: "victim@example.com"}
+	token := &auth.Token{
+		Firebase: auth.FirebaseInfo{SignInProvider: "google.com"},
+		Claims:   map[string]interface{}{"email": "attacker@gmail.com", "email_verified": true},
+	}
+
+	// Sin cambios no se guarda nada: el servicio no tiene base de datos
+	assert.NoError(t, s.updateUserFromFirebaseToken(context.Background(), user, token, nil))
+	assert.False(t, user.EmailVerified)
+
+	// Tampoco se retira la verificación de la cuenta por un email ajeno sin verificar
+	user.EmailVerified = true
+	token.Claims["email_verified"] = false
+	assert.NoError(t, s.updateUserFromFirebaseToken(context.Background(), user, token, nil))
+	assert.True(t, user.EmailVerified)
+}
+
+func TestCanAutoLinkIdentity(t *testing.T) {
+	token := func(email string, verified bool) *auth.Token {
+		return &auth.Token{
+			Firebase: auth.FirebaseInfo{SignInProvider: "google.com"},
+			Claims:   map[string]interface{}{"email": email, "email_verified": verified},
+		}
+	}
+	victim := &models.User{Email: "victim@example.com", EmailVerified: true}
+
+	assert.True(t, canAutoLinkIdentity(victim, token("Victim@Example.com", true)))
+
+	// Sin verificar por el proveedor hay que demostrar el control de la cuenta existente
+	assert.False(t, canAutoLinkIdentity(victim, token("victim@example.com", false)))
+
+	// Una cuenta creada con el email de la víctima sin verificarlo no recibe su login
+	squatted := &models.User{Email: "victim@example.com"}
+	assert.False(t, canAutoLinkIdentity(squatted, token("victim@example.com", true)))
+
+	// Una variante del buzón encontrada por el email canónico tampoco basta
+	gmail := &models.User{Email: "john.doe@gmail.com", EmailVerified: true}
+	assert.False(t, canAutoLinkIdentity(gmail, token("johndoe+x@gmail.com", true)))
+}
+
+func TestCheckReauthFactors(t *testing.T) {
+	req := &models.ReauthenticateRequest{FirebaseToken: "token"}
+
+	assert.NoError(t, checkReauthFactors(&models.User{}, req))
+
+	// Con MFA activado la reautenticación sólo con Firebase no eleva la sesión a aal2
+	mfaUser := &models.User{MFAEnabled: true}
+	assert.ErrorIs(t, checkReauthFactors(mfaUser, req), ErrMFACodeRequired)
+
+	req.Code = "123456"
+	assert.NoError(t, checkReauthFactors(mfaUser, req))
+}
@@ -0,0 +1,745 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+// groupNestingLockKey serializa los cambios de anidamiento para que dos cambios
+// concurrentes no puedan formar un ciclo entre los dos
+const groupNestingLockKey = "user_group_nesting"
+
+var (
+	// Nombres de rol en minúsculas, válidos también como valor de claim
+	roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_.:-]{0,63}$`)
+	// Permisos recurso:acción; "*" concede todo y "recurso:*" todas las acciones del recurso
+	permissionPattern = regexp.MustCompile(`^(\*|[a-z0-9_.-]+(:[a-z0-9_.-]+)*(:\*)?)$`)
+)
+
+var (
+	ErrGroupNotFound       = errors.New("group not found")
+	ErrGroupExists         = errors.New("a group with this name already exists for the tenant")
+	ErrGroupMemberExists   = errors.New("already a member of the group")
+	ErrGroupMemberNotFound = errors.New("not a member of the group")
+	ErrGroupCycle          = errors.New("nesting would create a cycle")
+	ErrGroupTenantMismatch = errors.New("nested groups must belong to the same tenant")
+	ErrRoleNotFound        = errors.New("role not found")
+	ErrRoleExists          = errors.New("role already exists")
+	ErrInvalidRole         = errors.New("invalid role definition")
+)
+
+type permissionCacheEntry struct {
+	permissions *models.EffectivePermissions
+	generation  uint64
+	expiresAt   time.Time
+}
+
+// GroupService gestiona los grupos (anidables), los roles que se les asignan y la
+// resolución de los permisos efectivos de cada usuario. Los permisos resueltos se
+// guardan en una caché en memoria que se invalida con cada cambio de pertenencia,
+// anidamiento o rol; entre instancias, el TTL acota cuánto tarda en verse un cambio.
+type GroupService struct {
+	db           *gorm.DB
+	audit        *AuditService
+	logger       *logrus.Logger
+	cacheTTL     time.Duration
+	claimEnabled bool
+	claimMax     int
+
+	mu         sync.Mutex
+	cache      map[string]*permissionCacheEntry
+	generation uint64
+}
+
+func NewGroupService(db *gorm.DB, auditService *AuditService, cfg *config.Config) *GroupService {
+	return &GroupService{
+		db:           db,
+		audit:        auditService,
+		logger:       logger.GetLogger(),
+		cacheTTL:     cfg.GroupsConfig.PermissionCacheTTL,
+		claimEnabled: cfg.GroupsConfig.ClaimEnabled,
+		claimMax:     cfg.GroupsConfig.ClaimMaxGroups,
+		cache:        make(map[string]*permissionCacheEntry),
+	}
+}
+
+// ListGroups devuelve los grupos, opcionalmente sólo los de un tenant ("" para los globales)
+func (s *GroupService) ListGroups(ctx context.Context, tenantID *string) ([]*models.UserGroup, error) {
+	query := s.db.WithContext(ctx).Order("tenant_id ASC, name ASC")
+	if tenantID != nil {
+		query = query.Where("tenant_id = ?", *tenantID)
+	}
+
+	var groups []*models.UserGroup
+	if err := query.Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return groups, nil
+}
+
+// GetGroup devuelve el grupo con sus miembros directos, subgrupos y roles
+func (s *GroupService) GetGroup(ctx context.Context, groupID string) (*models.UserGroupDetail, error) {
+	db := s.db.WithContext(ctx)
+	group, err := loadGroup(db, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := &models.UserGroupDetail{
+		UserGroup: group,
+		MemberIDs: []string{},
+		Children:  []*models.UserGroup{},
+		Roles:     []string{},
+	}
+	if err := db.Model(&models.UserGroupMember{}).Where("group_id = ?", group.ID).
+		Order("user_id ASC").Pluck("user_id", &detail.MemberIDs).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if err := db.Where("id IN (?)",
+		db.Model(&models.UserGroupNesting{}).Select("child_id").Where("parent_id = ?", group.ID),
+	).Order("name ASC").Find(&detail.Children).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if err := db.Model(&models.UserGroupRole{}).Where("group_id = ?", group.ID).
+		Order("role ASC").Pluck("role", &detail.Roles).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return detail, nil
+}
+
+// CreateGroup crea un grupo global o de un tenant
+func (s *GroupService) CreateGroup(ctx context.Context, actor AdminActor, req *models.UserGroupRequest) (*models.UserGroup, error) {
+	group := &models.UserGroup{
+		TenantID:    strings.TrimSpace(req.TenantID),
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		return s.recordGroupChange(tx, actor, "created", group, "", nil)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrGroupExists
+		}
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+	return group, nil
+}
+
+// UpdateGroup cambia el nombre y la descripción de un grupo; el tenant no cambia
+func (s *GroupService) UpdateGroup(ctx context.Context, actor AdminActor, groupID string, req *models.UserGroupRequest) (*models.UserGroup, error) {
+	var group *models.UserGroup
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if group, err = loadGroup(tx, groupID); err != nil {
+			return err
+		}
+		group.Name = strings.TrimSpace(req.Name)
+		group.Description = req.Description
+		if err := tx.Model(group).Updates(map[string]interface{}{
+			"name":        group.Name,
+			"description": group.Description,
+		}).Error; err != nil {
+			return err
+		}
+		return s.recordGroupChange(tx, actor, "updated", group, "", nil)
+	})
+	if err != nil {
+		return nil, groupError("failed to update group", err)
+	}
+	// El nombre del grupo aparece en el claim groups
+	s.invalidateAll()
+	return group, nil
+}
+
+// DeleteGroup elimina el grupo con sus pertenencias, anidamientos y roles
+func (s *GroupService) DeleteGroup(ctx context.Context, actor AdminActor, groupID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group, err := loadGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+		if err := deleteGroupTx(tx, group); err != nil {
+			return err
+		}
+		return s.recordGroupChange(tx, actor, "deleted", group, "", nil)
+	})
+	if err != nil {
+		return groupError("failed to delete group", err)
+	}
+	s.invalidateAll()
+	return nil
+}
+
+// AddMember añade un usuario al grupo
+func (s *GroupService) AddMember(ctx context.Context, actor AdminActor, groupID, userID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group, err := loadGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("id = ? AND status NOT IN ?", userID, models.DeletedStatuses).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrUserNotFound
+		}
+
+		if err := tx.Create(&models.UserGroupMember{GroupID: group.ID, UserID: userID}).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrGroupMemberExists
+			}
+			return err
+		}
+		return s.recordGroupChange(tx, actor, "member_added", group, userID, nil)
+	})
+	if err != nil {
+		return groupError("failed to add group member", err)
+	}
+	s.InvalidateUser(userID)
+	return nil
+}
+
+// RemoveMember quita a un usuario del grupo
+func (s *GroupService) RemoveMember(ctx context.Context, actor AdminActor, groupID, userID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group, err := loadGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("group_id = ? AND user_id = ?", group.ID, userID).Delete(&models.UserGroupMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrGroupMemberNotFound
+		}
+		return s.recordGroupChange(tx, actor, "member_removed", group, userID, nil)
+	})
+	if err != nil {
+		return groupError("failed to remove group member", err)
+	}
+	s.InvalidateUser(userID)
+	return nil
+}
+
+// AddChild anida childID dentro de parentID. Rechaza los ciclos y el anidamiento
+// entre tenants distintos.
+func (s *GroupService) AddChild(ctx context.Context, actor AdminActor, parentID, childID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", groupNestingLockKey).Error; err != nil {
+			return err
+		}
+
+		parent, err := loadGroup(tx, parentID)
+		if err != nil {
+			return err
+		}
+		child, err := loadGroup(tx, childID)
+		if err != nil {
+			return err
+		}
+		if parent.TenantID != child.TenantID {
+			return ErrGroupTenantMismatch
+		}
+
+		// Hay ciclo si el padre es el propio hijo o uno de sus descendientes
+		var cycle int64
+		if err := tx.Raw(`WITH RECURSIVE descendants(group_id) AS (
+				SELECT CAST(? AS uuid)
+				UNION
+				SELECT n.child_id FROM user_group_nestings n JOIN descendants d ON n.parent_id = d.group_id
+			)
+			SELECT count(*) FROM descendants WHERE group_id = ?`, child.ID, parent.ID).
+			Scan(&cycle).Error; err != nil {
+			return err
+		}
+		if cycle > 0 {
+			return ErrGroupCycle
+		}
+
+		if err := tx.Create(&models.UserGroupNesting{ParentID: parent.ID, ChildID: child.ID}).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrGroupMemberExists
+			}
+			return err
+		}
+		return s.recordGroupChange(tx, actor, "child_added", parent, "", models.JSONMap{"child_id": child.ID})
+	})
+	if err != nil {
+		return groupError("failed to nest group", err)
+	}
+	s.invalidateAll()
+	return nil
+}
+
+// RemoveChild deshace el anidamiento de childID en parentID
+func (s *GroupService) RemoveChild(ctx context.Context, actor AdminActor, parentID, childID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		parent, err := loadGroup(tx, parentID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("parent_id = ? AND child_id = ?", parent.ID, childID).Delete(&models.UserGroupNesting{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrGroupMemberNotFound
+		}
+		return s.recordGroupChange(tx, actor, "child_removed", parent, "", models.JSONMap{"child_id": childID})
+	})
+	if err != nil {
+		return groupError("failed to remove nested group", err)
+	}
+	s.invalidateAll()
+	return nil
+}
+
+// AssignRole asigna un rol existente al grupo
+func (s *GroupService) AssignRole(ctx context.Context, actor AdminActor, groupID, role string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group, err := loadGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+		if _, err := loadRole(tx, role); err != nil {
+			return err
+		}
+		if err := tx.Create(&models.UserGroupRole{GroupID: group.ID, Role: role}).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return nil
+			}
+			return err
+		}
+		return s.recordGroupChange(tx, actor, "role_assigned", group, "", models.JSONMap{"role": role})
+	})
+	if err != nil {
+		return groupError("failed to assign role", err)
+	}
+	s.invalidateAll()
+	return nil
+}
+
+// UnassignRole quita un rol del grupo
+func (s *GroupService) UnassignRole(ctx context.Context, actor AdminActor, groupID, role string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group, err := loadGroup(tx, groupID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("group_id = ? AND role = ?", group.ID, role).Delete(&models.UserGroupRole{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleNotFound
+		}
+		return s.recordGroupChange(tx, actor, "role_unassigned", group, "", models.JSONMap{"role": role})
+	})
+	if err != nil {
+		return groupError("failed to unassign role", err)
+	}
+	s.invalidateAll()
+	return nil
+}
+
+// ListRoles devuelve todos los roles definidos
+func (s *GroupService) ListRoles(ctx context.Context) ([]*models.RoleDefinition, error) {
+	var roles []*models.RoleDefinition
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&roles).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return roles, nil
+}
+
+// CreateRole define un rol con sus permisos
+func (s *GroupService) CreateRole(ctx context.Context, actor AdminActor, req *models.RoleDefinitionRequest) (*models.RoleDefinition, error) {
+	role, err := buildRoleDefinition(req.Name, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(role).Error; err != nil {
+			return err
+		}
+		return s.recordRoleChange(tx, actor, "created", role)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrRoleExists
+		}
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+	s.invalidateAll()
+	return role, nil
+}
+
+// UpdateRole reemplaza la descripción y los permisos de un rol; el nombre no cambia
+func (s *GroupService) UpdateRole(ctx context.Context, actor AdminActor, name string, req *models.RoleDefinitionRequest) (*models.RoleDefinition, error) {
+	role, err := buildRoleDefinition(name, req)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := loadRole(tx, name); err != nil {
+			return err
+		}
+		if err := tx.Model(role).Updates(map[string]interface{}{
+			"description": role.Description,
+			"permissions": role.Permissions,
+		}).Error; err != nil {
+			return err
+		}
+		return s.recordRoleChange(tx, actor, "updated", role)
+	})
+	if err != nil {
+		return nil, groupError("failed to update role", err)
+	}
+	s.invalidateAll()
+	return role, nil
+}
+
+// DeleteRole elimina el rol y sus asignaciones a grupos
+func (s *GroupService) DeleteRole(ctx context.Context, actor AdminActor, name string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		role, err := loadRole(tx, name)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("role = ?", role.Name).Delete(&models.UserGroupRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(role).Error; err != nil {
+			return err
+		}
+		return s.recordRoleChange(tx, actor, "deleted", role)
+	})
+	if err != nil {
+		return groupError("failed to delete role", err)
+	}
+	s.invalidateAll()
+	return nil
+}
+
+// EffectivePermissions resuelve los grupos del usuario (directos y heredados por
+// anidamiento), los roles de esos grupos más User.Role y la unión de sus permisos
+func (s *GroupService) EffectivePermissions(ctx context.Context, user *models.User) (*models.EffectivePermissions, error) {
+	if cached := s.cached(user.ID); cached != nil {
+		return cached, nil
+	}
+
+	s.mu.Lock()
+	generation := s.generation
+	s.mu.Unlock()
+
+	resolved, err := s.resolve(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	s.store(user.ID, generation, resolved)
+	return resolved, nil
+}
+
+// HasPermission indica si el usuario tiene el permiso por alguno de sus roles
+func (s *GroupService) HasPermission(ctx context.Context, user *models.User, permission string) (bool, error) {
+	effective, err := s.EffectivePermissions(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return permissionGranted(effective.Permissions, permission), nil
+}
+
+// TokenGroups devuelve los nombres de los grupos globales y del tenant del token para
+// el claim groups. Si superan el máximo configurado devuelve overflow en lugar de la
+// lista, y el cliente debe consultar /users/permissions.
+func (s *GroupService) TokenGroups(ctx context.Context, user *models.User, tenantID string) ([]string, bool, error) {
+	if !s.claimEnabled {
+		return nil, false, nil
+	}
+
+	effective, err := s.EffectivePermissions(ctx, user)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var names []string
+	for _, group := range effective.Groups {
+		if group.TenantID == "" || group.TenantID == tenantID {
+			names = append(names, group.Name)
+		}
+	}
+	names = uniqueSortedStrings(names)
+	if len(names) > s.claimMax {
+		return nil, true, nil
+	}
+	return names, false, nil
+}
+
+// OnUserChanged invalida los permisos en caché cuando cambia el rol o el estado del usuario
+func (s *GroupService) OnUserChanged(_ context.Context, user *models.User) {
+	s.InvalidateUser(user.ID)
+}
+
+// InvalidateUser descarta los permisos en caché de un usuario
+func (s *GroupService) InvalidateUser(userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, userID)
+	// Una resolución en curso no debe guardar un resultado previo al cambio
+	s.generation++
+}
+
+// invalidateAll descarta toda la caché tras un cambio que puede afectar a muchos usuarios
+func (s *GroupService) invalidateAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cache = make(map[string]*permissionCacheEntry)
+	s.generation++
+}
+
+func (s *GroupService) cached(userID string) *models.EffectivePermissions {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[userID]
+	if !ok || entry.generation != s.generation || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+	return entry.permissions
+}
+
+func (s *GroupService) store(userID string, generation uint64, permissions *models.EffectivePermissions) {
+	if s.cacheTTL <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		return
+	}
+	s.cache[userID] = &permissionCacheEntry{
+		permissions: permissions,
+		generation:  generation,
+		expiresAt:   time.Now().Add(s.cacheTTL),
+	}
+}
+
+func (s *GroupService) resolve(ctx context.Context, user *models.User) (*models.EffectivePermissions, error) {
+	db := s.db.WithContext(ctx)
+
+	// UNION (y no UNION ALL) descarta los grupos ya visitados
+	var groups []*models.UserGroup
+	if err := db.Raw(`WITH RECURSIVE effective(group_id) AS (
+			SELECT group_id FROM user_group_members WHERE user_id = ?
+			UNION
+			SELECT n.parent_id FROM user_group_nestings n JOIN effective e ON n.child_id = e.group_id
+		)
+		SELECT g.* FROM user_groups g JOIN effective e ON e.group_id = g.id
+		ORDER BY g.tenant_id ASC, g.name ASC`, user.ID).
+		Scan(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to resolve groups: %w", err)
+	}
+
+	roles := []string{user.Role}
+	if len(groups) > 0 {
+		groupIDs := make([]string, 0, len(groups))
+		for _, group := range groups {
+			groupIDs = append(groupIDs, group.ID)
+		}
+		var groupRoles []string
+		if err := db.Model(&models.UserGroupRole{}).Where("group_id IN ?", groupIDs).
+			Pluck("role", &groupRoles).Error; err != nil {
+			return nil, fmt.Errorf("failed to resolve roles: %w", err)
+		}
+		roles = append(roles, groupRoles...)
+	}
+	roles = uniqueSortedStrings(roles)
+
+	var definitions []*models.RoleDefinition
+	if err := db.Where("name IN ?", roles).Find(&definitions).Error; err != nil {
+		return nil, fmt.Errorf("failed to resolve permissions: %w", err)
+	}
+	var permissions []string
+	if user.Role == models.RoleAdmin {
+		// Los administradores conservan todos los permisos aunque no se haya definido el rol admin
+		permissions = append(permissions, "*")
+	}
+	for _, definition := range definitions {
+		permissions = append(permissions, definition.Permissions...)
+	}
+
+	if groups == nil {
+		groups = []*models.UserGroup{}
+	}
+	permissions = uniqueSortedStrings(permissions)
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return &models.EffectivePermissions{
+		UserID:      user.ID,
+		Groups:      groups,
+		Roles:       roles,
+		Permissions: permissions,
+	}, nil
+}
+
+func (s *GroupService) recordGroupChange(tx *gorm.DB, actor AdminActor, change string, group *models.UserGroup, userID string, extra models.JSONMap) error {
+	details := models.JSONMap{
+		"change":    change,
+		"group_id":  group.ID,
+		"tenant_id": group.TenantID,
+		"name":      group.Name,
+	}
+	for key, value := range extra {
+		details[key] = value
+	}
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		UserID:    userID,
+		ActorID:   actor.UserID,
+		Action:    models.AuditActionGroupChanged,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details:   details,
+	})
+}
+
+func (s *GroupService) recordRoleChange(tx *gorm.DB, actor AdminActor, change string, role *models.RoleDefinition) error {
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		ActorID:   actor.UserID,
+		Action:    models.AuditActionRoleChanged,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details: models.JSONMap{
+			"change":      change,
+			"role":        role.Name,
+			"permissions": []string(role.Permissions),
+		},
+	})
+}
+
+// deleteGroupTx elimina el grupo con sus pertenencias, anidamientos y roles
+func deleteGroupTx(tx *gorm.DB, group *models.UserGroup) error {
+	if err := tx.Where("group_id = ?", group.ID).Delete(&models.UserGroupMember{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("parent_id = ? OR child_id = ?", group.ID, group.ID).Delete(&models.UserGroupNesting{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("group_id = ?", group.ID).Delete(&models.UserGroupRole{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(group).Error
+}
+
+func loadGroup(tx *gorm.DB, groupID string) (*models.UserGroup, error) {
+	var group models.UserGroup
+	if err := tx.Where("id = ?", groupID).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &group, nil
+}
+
+func loadRole(tx *gorm.DB, name string) (*models.RoleDefinition, error) {
+	var role models.RoleDefinition
+	if err := tx.Where("name = ?", name).First(&role).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &role, nil
+}
+
+// buildRoleDefinition valida el nombre y los permisos de un rol
+func buildRoleDefinition(name string, req *models.RoleDefinitionRequest) (*models.RoleDefinition, error) {
+	if !roleNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: name must be lowercase and start with a letter", ErrInvalidRole)
+	}
+	for _, permission := range req.Permissions {
+		if !permissionPattern.MatchString(permission) {
+			return nil, fmt.Errorf("%w: invalid permission %q", ErrInvalidRole, permission)
+		}
+	}
+
+	permissions := uniqueSortedStrings(req.Permissions)
+	if permissions == nil {
+		permissions = []string{}
+	}
+	return &models.RoleDefinition{
+		Name:        name,
+		Description: req.Description,
+		Permissions: models.StringSlice(permissions),
+	}, nil
+}
+
+// permissionGranted comprueba required contra los permisos concedidos, admitiendo
+// "*" y comodines de recurso ("users:*")
+func permissionGranted(granted []string, required string) bool {
+	for _, permission := range granted {
+		if permission == "*" || permission == required {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(permission, "*"); ok && strings.HasPrefix(required, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueSortedStrings(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	var unique []string
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		unique = append(unique, value)
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// groupError conserva los errores de dominio y envuelve el resto
+func groupError(message string, err error) error {
+	for _, known := range []error{
+		ErrGroupNotFound, ErrGroupExists, ErrGroupMemberExists, ErrGroupMemberNotFound,
+		ErrGroupCycle, ErrGroupTenantMismatch, ErrRoleNotFound, ErrUserNotFound,
+	} {
+		if errors.Is(err, known) {
+			return err
+		}
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrGroupExists
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/models"
+)
+
+func TestPermissionGranted(t *testing.T) {
+	granted := []string{"users:read", "groups:*"}
+	assert.True(t, permissionGranted(granted, "users:read"))
+	assert.True(t, permissionGranted(granted, "groups:write"))
+	assert.False(t, permissionGranted(granted, "users:write"))
+	assert.False(t, permissionGranted(granted, "groupsx:read"))
+	assert.False(t, permissionGranted(nil, "users:read"))
+	assert.True(t, permissionGranted([]string{"*"}, "anything:at_all"))
+}
+
+func TestBuildRoleDefinition(t *testing.T) {
+	role, err := buildRoleDefinition("support", &models.RoleDefinitionRequest{
+		Permissions: []string{"users:read", "users:read", "audit:*"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.StringSlice{"audit:*", "users:read"}, role.Permissions)
+
+	_, err = buildRoleDefinition("Support", &models.RoleDefinitionRequest{})
+	assert.ErrorIs(t, err, ErrInvalidRole)
+	for _, bad := range []string{"users:", "users read", "*:read", "USERS:read"} {
+		_, err = buildRoleDefinition("support", &models.RoleDefinitionRequest{Permissions: []string{bad}})
+		assert.ErrorIs(t, err, ErrInvalidRole, bad)
+	}
+}
+
+func TestGroupPermissionCacheInvalidation(t *testing.T) {
+	service := &GroupService{cacheTTL: time.Minute, cache: make(map[string]*permissionCacheEntry)}
+	effective := &models.EffectivePermissions{UserID: "u1"}
+
+	service.store("u1", service.generation, effective)
+	assert.Same(t, effective, service.cached("u1"))
+
+	service.InvalidateUser("u1")
+	assert.Nil(t, service.cached("u1"))
+
+	// Un resultado resuelto antes de una invalidación no se guarda
+	generation := service.generation
+	service.invalidateAll()
+	service.store("u1", generation, effective)
+	assert.Nil(t, service.cached("u1"))
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/firebase"
+)
+
+const (
+	// Los invitados no tienen email; se usa uno sintético en un TLD reservado (RFC 2606)
+	// para respetar el índice único de users.email
+	guestEmailDomain  = "guest.invalid"
+	guestPurgeBatch   = 100
+	guestUsernameSize = 4
+)
+
+var (
+	ErrNotAnonymousToken    = errors.New("firebase token is not an anonymous sign-in")
+	ErrNotGuest             = errors.New("user is not a guest")
+	ErrUpgradeRequiresEmail = errors.New("identity used to upgrade must have an email")
+	ErrEmailAlreadyInUse    = errors.New("email is already registered to another account")
+)
+
+// GuestUpgrade contiene los datos de la identidad real que se vincula al invitado
+type GuestUpgrade struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	PhotoURL      string
+}
+
+// GuestService gestiona los usuarios invitados: alta, conversión a cuenta completa y limpieza
+type GuestService struct {
+	db           *gorm.DB
+	identities   *IdentityService
+	usernames    *UsernameService
+	lifecycle    *LifecycleService
+	firebaseAuth *firebase.Auth
+	logger       *logrus.Logger
+}
+
+func NewGuestService(db *gorm.DB, identityService *IdentityService, usernameService *UsernameService, lifecycleService *LifecycleService, firebaseAuth *firebase.Auth) *GuestService {
+	return &GuestService{
+		db:           db,
+		identities:   identityService,
+		usernames:    usernameService,
+		lifecycle:    lifecycleService,
+		firebaseAuth: firebaseAuth,
+		logger:       logger.GetLogger(),
+	}
+}
+
+// CreateGuest crea un usuario invitado para un UID anónimo de Firebase
+func (s *GuestService) CreateGuest(ctx context.Context, subject string) (*models.User, error) {
+	suffix, err := generateRandomToken(guestUsernameSize)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		FirebaseID:     subject,
+		Email:          guestEmail(subject),
+		CanonicalEmail: canonicalEmail(guestEmail(subject)),
+		Username:       guestUsernamePrefix + suffix,
+		Provider:       models.ProviderAnonymous,
+		Status:         models.StatusGuest,
+		Role:           models.RoleUser,
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		_, err := s.identities.linkIdentityTx(tx, user.ID, models.ProviderAnonymous, subject, "")
+		return err
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create guest user")
+		return nil, fmt.Errorf("failed to create guest user: %w", err)
+	}
+
+	s.logger.WithField("user_id", user.ID).Info("Guest user created")
+	return user, nil
+}
+
+// Upgrade convierte al invitado en una cuenta completa conservando su ID. La identidad
+// anónima se sustituye por la real, salvo que Firebase haya vinculado las credenciales
+// al mismo UID, en cuyo caso sólo cambia el provider de la identidad.
+func (s *GuestService) Upgrade(ctx context.Context, user *models.User, upgrade GuestUpgrade) error {
+	if user.Status != models.StatusGuest {
+		return ErrNotGuest
+	}
+	if upgrade.Email == "" {
+		return ErrUpgradeRequiresEmail
+	}
+
+	var event *models.UserStatusChangedEvent
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serializar con la limpieza de invitados y con otras conversiones concurrentes
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", user.ID, models.StatusGuest).
+			First(&models.User{}).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotGuest
+		}
+		if err != nil {
+			return err
+		}
+
+		var emailOwners int64
+		if err := whereEmailOwner(tx.Model(&models.User{}), upgrade.Email).
+			Where("id <> ?", user.ID).
+			Count(&emailOwners).Error; err != nil {
+			return err
+		}
+		if emailOwners > 0 {
+			return ErrEmailAlreadyInUse
+		}
+
+		if err := tx.Where("user_id = ? AND provider = ?", user.ID, models.ProviderAnonymous).
+			Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		if _, err := s.identities.linkIdentityTx(tx, user.ID, upgrade.Provider, upgrade.Subject, upgrade.Email); err != nil {
+			return err
+		}
+
+		user.FirebaseID = upgrade.Subject
+		user.Email = upgrade.Email
+		user.CanonicalEmail = canonicalEmail(upgrade.Email)
+		user.EmailVerified = upgrade.EmailVerified
+		user.Provider = upgrade.Provider
+		// El username provisional del invitado se sustituye por uno derivado del email
+		if strings.HasPrefix(user.Username, guestUsernamePrefix) {
+			username, err := s.usernames.generateTx(tx, upgrade.Email)
+			if err != nil {
+				return err
+			}
+			user.Username = username
+		}
+		if user.FirstName == "" {
+			user.FirstName = upgrade.Name
+		}
+		if upgrade.PhotoURL != "" {
+			user.PhotoURL = upgrade.PhotoURL
+		}
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+
+		event, err = s.lifecycle.transitionTx(tx, user, StatusChange{
+			To:     models.StatusActive,
+			Reason: statusReasonGuestUpgrade,
+			Actor:  AdminActor{UserID: user.ID},
+		})
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrNotGuest) && !errors.Is(err, ErrEmailAlreadyInUse) && !errors.Is(err, ErrIdentityLinkedToOtherUser) {
+			s.logger.WithError(err).Error("Failed to upgrade guest user")
+		}
+		return err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":  user.ID,
+		"provider": upgrade.Provider,
+	}).Info("Guest user upgraded to full account")
+
+	s.lifecycle.publish(ctx, event)
+	return nil
+}
+
+// PurgeStaleGuests elimina los invitados sin actividad desde hace más de ttl, junto con
+// sus sesiones e identidades y, si es posible, su cuenta anónima de Firebase
+func (s *GuestService) PurgeStaleGuests(ctx context.Context, ttl time.Duration) (int, error) {
+	cutoff := time.Now().Add(-ttl)
+	purged := 0
+
+	for {
+		var guests []*models.User
+		err := s.db.WithContext(ctx).
+			Where("status = ? AND COALESCE(last_login_at, created_at) < ?", models.StatusGuest, cutoff).
+			Limit(guestPurgeBatch).
+			Find(&guests).Error
+		if err != nil {
+			return purged, fmt.Errorf("failed to list stale guests: %w", err)
+		}
+		if len(guests) == 0 {
+			break
+		}
+
+		for _, guest := range guests {
+			if err := s.purgeGuest(ctx, guest); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+	}
+
+	if purged > 0 {
+		s.logger.WithField("count", purged).Info("Stale guest users purged")
+	}
+	return purged, nil
+}
+
+func (s *GuestService) purgeGuest(ctx context.Context, guest *models.User) error {
+	var subjects []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Bloquear la fila y comprobar que sigue siendo invitado: no debe borrarse
+		// un usuario que se haya convertido en cuenta completa mientras tanto
+		var locked models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", guest.ID, models.StatusGuest).
+			First(&locked).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.UserIdentity{}).
+			Where("user_id = ?", guest.ID).
+			Pluck("subject", &subjects).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.UserIdentity{},
+			&models.UserSession{},
+			&models.RevokedToken{},
+			&models.MFAChallenge{},
+			&models.FirebaseClaimsSync{},
+		} {
+			if err := tx.Where("user_id = ?", guest.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&locked).Error
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", guest.ID).Error("Failed to purge guest user")
+		return fmt.Errorf("failed to purge guest user: %w", err)
+	}
+
+	if s.firebaseAuth != nil {
+		for _, subject := range subjects {
+			if err := s.firebaseAuth.DeleteUser(ctx, subject); err != nil {
+				s.logger.WithError(err).WithField("subject", subject).Warn("Failed to delete anonymous Firebase user")
+			}
+		}
+	}
+
+	return nil
+}
+
+func guestEmail(subject string) string {
+	return fmt.Sprintf("%s@%s", subject, guestEmailDomain)
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+// newMockDB devuelve una conexión gorm sobre sqlmock para recorrer flujos que
+// consultan la base de datos sin necesitar PostgreSQL
+func newMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{Logger: gormlogger.Discard})
+	require.NoError(t, err)
+	return db, mock
+}
+
+// before comprueba que un argumento es un instante anterior a limit
+type before struct{ limit time.Time }
+
+func (b before) Match(value driver.Value) bool {
+	t, ok := value.(time.Time)
+	return ok && t.Before(b.limit)
+}
+
+func TestGuestUpgradeRejectsEmailInUse(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := &GuestService{db: db, logger: logger.GetLogger()}
+	guest := &models.User{ID: "guest-id", Status: models.StatusGuest, Username: guestUsernamePrefix + "ab12"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 AND status = \$2 .*FOR UPDATE`).
+		WithArgs("guest-id", models.StatusGuest).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow("guest-id", models.StatusGuest))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "users"`).
+		WithArgs("owner@example.com", "owner@example.com", "guest-id").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	err := s.Upgrade(context.Background(), guest, GuestUpgrade{
+		Provider:      "google.com",
+		Subject:       "google-uid",
+		Email:         "owner@example.com",
+		EmailVerified: true,
+	})
+	assert.ErrorIs(t, err, ErrEmailAlreadyInUse)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// El invitado sigue intacto: no se ha vinculado la identidad ni cambiado el email
+	assert.Equal(t, models.StatusGuest, guest.Status)
+	assert.Empty(t, guest.Email)
+}
+
+func TestGuestUpgradeChecks(t *testing.T) {
+	s := &GuestService{}
+
+	err := s.Upgrade(context.Background(), &models.User{Status: models.StatusActive}, GuestUpgrade{Email: "user@example.com"})
+	assert.ErrorIs(t, err, ErrNotGuest)
+
+	err = s.Upgrade(context.Background(), &models.User{Status: models.StatusGuest}, GuestUpgrade{Provider: "phone"})
+	assert.ErrorIs(t, err, ErrUpgradeRequiresEmail)
+}
+
+func TestPurgeStaleGuests(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := &GuestService{db: db, logger: logger.GetLogger()}
+	ttl := 30 * 24 * time.Hour
+
+	// Sólo los invitados inactivos desde antes del TTL
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE status = \$1 AND COALESCE\(last_login_at, created_at\) < \$2`).
+		WithArgs(models.StatusGuest, before{time.Now().Add(-ttl + time.Minute)}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow("guest-id", models.StatusGuest))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 AND status = \$2 .*FOR UPDATE`).
+		WithArgs("guest-id", models.StatusGuest).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow("guest-id", models.StatusGuest))
+	mock.ExpectQuery(`SELECT "subject" FROM "user_identities"`).
+		WithArgs("guest-id").
+		WillReturnRows(sqlmock.NewRows([]string{"subject"}).AddRow("anonymous-uid"))
+	for _, table := range []string{"user_identities", "user_sessions", "revoked_tokens", "mfa_challenges", "firebase_claims_syncs"} {
+		mock.ExpectExec(`DELETE FROM "` + table + `" WHERE user_id = \$1`).
+			WithArgs("guest-id").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec(`DELETE FROM "users" WHERE "users"."id" = \$1`).
+		WithArgs("guest-id").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE status = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	purged, err := s.PurgeStaleGuests(context.Background(), ttl)
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGuestTokenLifetime(t *testing.T) {
+	scope, ttl := tokenLifetime(&models.User{Status: models.StatusGuest}, time.Hour)
+	assert.Equal(t, models.ScopeGuest, scope)
+	assert.Equal(t, time.Hour, ttl)
+
+	// Al convertirse en cuenta completa el token deja de ser de invitado
+	scope, ttl = tokenLifetime(&models.User{Status: models.StatusActive}, time.Hour)
+	assert.Empty(t, scope)
+	assert.Equal(t, internalTokenTTL, ttl)
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+var (
+	ErrIdentityNotFound          = errors.New("identity not found")
+	ErrIdentityLinkedToOtherUser = errors.New("identity is already linked to another account")
+	ErrLastIdentity              = errors.New("cannot remove the last identity of an account")
+	ErrIdentityUnlinked          = errors.New("identity was unlinked from the account, link it again to use it")
+)
+
+type IdentityService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+func NewIdentityService(db *gorm.DB) *IdentityService {
+	return &IdentityService{
+		db:     db,
+		logger: logger.GetLogger(),
+	}
+}
+
+// FindUserIDBySubject devuelve el usuario dueño de un Firebase UID, sea cual sea el provider
+func (s *IdentityService) FindUserIDBySubject(ctx context.Context, subject string) (string, error) {
+	var identity models.UserIdentity
+	err := s.db.WithContext(ctx).Where("subject = ?", subject).First(&identity).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrIdentityNotFound
+		}
+		s.logger.WithError(err).Error("Failed to get identity by subject")
+		return "", fmt.Errorf("database error: %w", err)
+	}
+
+	return identity.UserID, nil
+}
+
+// BelongsTo indica si el Firebase UID es una identidad del usuario
+func (s *IdentityService) BelongsTo(ctx context.Context, userID, subject string) (bool, error) {
+	ownerID, err := s.FindUserIDBySubject(ctx, subject)
+	if errors.Is(err, ErrIdentityNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return ownerID == userID, nil
+}
+
+// RecordIdentity registra (o marca como usada) la identidad con la que el usuario acaba
+// de autenticarse. Una identidad que el usuario desvinculó no se vuelve a registrar.
+func (s *IdentityService) RecordIdentity(ctx context.Context, userID, provider, subject, email string) error {
+	var identity models.UserIdentity
+	err := s.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+
+	now := time.Now()
+	switch {
+	case err == nil:
+		if identity.UserID != userID {
+			return ErrIdentityLinkedToOtherUser
+		}
+		return s.db.WithContext(ctx).Model(&identity).Update("last_used_at", &now).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		unlinked, err := isUnlinkedTx(s.db.WithContext(ctx), userID, subject)
+		if err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if unlinked {
+			return ErrIdentityUnlinked
+		}
+		identity = models.UserIdentity{
+			UserID:     userID,
+			Provider:   provider,
+			Subject:    subject,
+			Email:      email,
+			LastUsedAt: &now,
+		}
+		if err := s.db.WithContext(ctx).Create(&identity).Error; err != nil {
+			s.logger.WithError(err).Error("Failed to record user identity")
+			return fmt.Errorf("failed to record identity: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("database error: %w", err)
+	}
+}
+
+// EnsurePrimaryIdentity registra el Firebase ID principal como identidad para
+// usuarios creados antes de que existiera la tabla de identidades
+func (s *IdentityService) EnsurePrimaryIdentity(ctx context.Context, user *models.User) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.UserIdentity{}).
+		Where("user_id = ?", user.ID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	identity := &models.UserIdentity{
+		UserID:   user.ID,
+		Provider: user.Provider,
+		Subject:  user.FirebaseID,
+		Email:    user.Email,
+	}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(identity).Error; err != nil {
+		s.logger.WithError(err).Error("Failed to backfill primary identity")
+		return fmt.Errorf("failed to backfill primary identity: %w", err)
+	}
+
+	return nil
+}
+
+// ListIdentities lista las identidades vinculadas al usuario
+func (s *IdentityService) ListIdentities(ctx context.Context, userID string) ([]*models.UserIdentity, error) {
+	var identities []*models.UserIdentity
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("linked_at ASC").
+		Find(&identities).Error
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list user identities")
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+// LinkIdentity vincula una identidad nueva al usuario. Falla si ya pertenece a otra cuenta,
+// ya sea como identidad vinculada o como Firebase ID principal de otro usuario.
+func (s *IdentityService) LinkIdentity(ctx context.Context, userID, provider, subject, email string) (*models.UserIdentity, error) {
+	var identity *models.UserIdentity
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		identity, err = s.linkIdentityTx(tx, userID, provider, subject, email)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, ErrIdentityLinkedToOtherUser) {
+			s.logger.WithError(err).Error("Failed to link identity")
+		}
+		return nil, err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":  userID,
+		"provider": provider,
+	}).Info("Identity linked")
+
+	return identity, nil
+}
+
+// linkIdentityTx vincula la identidad dentro de una transacción existente. Es una
+// vinculación explícita, así que la identidad deja de constar como desvinculada.
+func (s *IdentityService) linkIdentityTx(tx *gorm.DB, userID, provider, subject, email string) (*models.UserIdentity, error) {
+	var existing models.UserIdentity
+	err := tx.Where("subject = ?", subject).First(&existing).Error
+	if err == nil {
+		if existing.UserID != userID {
+			return nil, ErrIdentityLinkedToOtherUser
+		}
+		if existing.Provider == provider {
+			return &existing, nil
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var owners int64
+	if err := tx.Model(&models.User{}).
+		Where("firebase_id = ? AND id <> ?", subject, userID).
+		Count(&owners).Error; err != nil {
+		return nil, err
+	}
+	if owners > 0 {
+		return nil, ErrIdentityLinkedToOtherUser
+	}
+
+	identity := &models.UserIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	}
+	if err := tx.Create(identity).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ? AND subject = ?", userID, subject).Delete(&models.UnlinkedIdentity{}).Error; err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// UnlinkIdentity desvincula una identidad. La última identidad de la cuenta no se puede
+// eliminar; si se elimina la identidad principal, otra pasa a ser el Firebase ID del usuario.
+// La identidad queda registrada como desvinculada hasta que el usuario la vincule de nuevo.
+func (s *IdentityService) UnlinkIdentity(ctx context.Context, userID, identityID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Bloquear la fila del usuario para serializar desvinculaciones concurrentes
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+
+		var identities []models.UserIdentity
+		if err := tx.Where("user_id = ?", userID).Order("linked_at ASC").Find(&identities).Error; err != nil {
+			return err
+		}
+
+		var target *models.UserIdentity
+		var remaining []models.UserIdentity
+		for i := range identities {
+			if identities[i].ID == identityID {
+				target = &identities[i]
+			} else {
+				remaining = append(remaining, identities[i])
+			}
+		}
+		if target == nil {
+			return ErrIdentityNotFound
+		}
+		if len(remaining) == 0 {
+			return ErrLastIdentity
+		}
+
+		if err := tx.Delete(target).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.UnlinkedIdentity{
+			UserID:     userID,
+			Subject:    target.Subject,
+			Provider:   target.Provider,
+			UnlinkedAt: time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		if user.FirebaseID == target.Subject && !hasSubject(remaining, target.Subject) {
+			if err := tx.Model(&user).Update("firebase_id", remaining[0].Subject).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, ErrIdentityNotFound) && !errors.Is(err, ErrLastIdentity) {
+			s.logger.WithError(err).Error("Failed to unlink identity")
+		}
+		return err
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":     userID,
+		"identity_id": identityID,
+	}).Info("Identity unlinked")
+
+	return nil
+}
+
+// isUnlinkedTx indica si el usuario desvinculó la identidad y no la ha vuelto a vincular
+func isUnlinkedTx(tx *gorm.DB, userID, subject string) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.UnlinkedIdentity{}).
+		Where("user_id = ? AND subject = ?", userID, subject).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func hasSubject(identities []models.UserIdentity, subject string) bool {
+	for _, identity := range identities {
+		if identity.Subject == subject {
+			return true
+		}
+	}
+	return false
+}
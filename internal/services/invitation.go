@@ -0,0 +1,496 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/mailer"
+)
+
+const invitationNonceBytes = 24
+
+var (
+	ErrInvitationNotFound        = errors.New("invitation not found")
+	ErrInvitationInvalid         = errors.New("invitation is invalid, expired or no longer pending")
+	ErrInvitationEmailMismatch   = errors.New("invitation was sent to a different email address")
+	ErrInvitationEmailUnverified = errors.New("verify the invited email address before accepting the invitation")
+	ErrInvitationExists          = errors.New("a pending invitation already exists for this email, resend it instead")
+	ErrInvitationNotPending      = errors.New("invitation is not pending")
+	ErrEmailAlreadyRegistered    = errors.New("email is already registered")
+)
+
+// InvitationService gestiona las invitaciones por email. El token enviado es
+// "<id>.<nonce>.<firma>": la firma HMAC descarta tokens manipulados sin consultar la
+// base de datos y el hash del nonce permite invalidar los enlaces anteriores al reenviar.
+type InvitationService struct {
+	db         *gorm.DB
+	users      *UserService
+	audit      *AuditService
+	mailer     mailer.Mailer
+	logger     *logrus.Logger
+	signingKey []byte
+	ttl        time.Duration
+	acceptURL  string
+}
+
+func NewInvitationService(db *gorm.DB, userService *UserService, auditService *AuditService, mail mailer.Mailer, cfg *config.Config) *InvitationService {
+	// Clave propia derivada del secreto JWT, como en las exportaciones de datos
+	key := sha256.Sum256([]byte("invitation:" + cfg.JWTSecret))
+
+	return &InvitationService{
+		db:         db,
+		users:      userService,
+		audit:      auditService,
+		mailer:     mail,
+		logger:     logger.GetLogger(),
+		signingKey: key[:],
+		ttl:        cfg.InvitationConfig.TTL,
+		acceptURL:  cfg.InvitationConfig.AcceptURL,
+	}
+}
+
+// Create invita a un email y le envía el enlace. Si el envío falla la invitación se
+// conserva sin sent_at y puede reenviarse.
+func (s *InvitationService) Create(ctx context.Context, actor AdminActor, req *models.CreateInvitationRequest) (*models.Invitation, error) {
+	email := normalizeInvitationEmail(req.Email)
+	invitation := &models.Invitation{
+		Email:     email,
+		Role:      req.Role,
+		OrgRole:   req.OrgRole,
+		Status:    models.InvitationStatusPending,
+		InvitedBy: actor.UserID,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if invitation.Role == "" {
+		invitation.Role = models.RoleUser
+	}
+	if req.OrgID != "" {
+		orgID := req.OrgID
+		invitation.OrgID = &orgID
+	}
+
+	nonce, err := generateRandomToken(invitationNonceBytes)
+	if err != nil {
+		return nil, err
+	}
+	invitation.NonceHash = sha256Hex(nonce)
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.checkInvitableTx(tx, invitation); err != nil {
+			return err
+		}
+		if err := tx.Create(invitation).Error; err != nil {
+			return err
+		}
+		return s.record(tx, actor, models.AuditActionInvitationCreated, invitation)
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvitationExists) || errors.Is(err, ErrEmailAlreadyRegistered) ||
+			errors.Is(err, ErrOrganizationNotFound) || errors.Is(err, ErrOrgMemberExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	s.send(ctx, invitation, nonce)
+	return invitation, nil
+}
+
+// CreateForOrganization invita a la organización desde ella misma. actorRole es el rol
+// en la organización de quien invita; la invitación nunca concede un rol global.
+func (s *InvitationService) CreateForOrganization(ctx context.Context, actor AdminActor, actorRole, orgID string, req *models.InviteOrganizationMemberRequest) (*models.Invitation, error) {
+	if !canManageOrgRole(actorRole, req.Role) {
+		return nil, ErrOrgRoleForbidden
+	}
+	return s.Create(ctx, actor, &models.CreateInvitationRequest{
+		Email:   req.Email,
+		Role:    models.RoleUser,
+		OrgID:   orgID,
+		OrgRole: req.Role,
+	})
+}
+
+// Resend rota el token (los enlaces anteriores dejan de valer), renueva la caducidad
+// y vuelve a enviar el email
+func (s *InvitationService) Resend(ctx context.Context, actor AdminActor, invitationID string) (*models.Invitation, error) {
+	nonce, err := generateRandomToken(invitationNonceBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var invitation models.Invitation
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.lockPending(tx, invitationID, &invitation); err != nil {
+			return err
+		}
+		invitation.NonceHash = sha256Hex(nonce)
+		invitation.ExpiresAt = time.Now().Add(s.ttl)
+		if err := tx.Model(&invitation).Updates(map[string]interface{}{
+			"nonce_hash": invitation.NonceHash,
+			"expires_at": invitation.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		return s.record(tx, actor, models.AuditActionInvitationResent, &invitation)
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) || errors.Is(err, ErrInvitationNotPending) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to resend invitation: %w", err)
+	}
+
+	s.send(ctx, &invitation, nonce)
+	return &invitation, nil
+}
+
+// Revoke anula una invitación pendiente
+func (s *InvitationService) Revoke(ctx context.Context, actor AdminActor, invitationID string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.lockPending(tx, invitationID, &invitation); err != nil {
+			return err
+		}
+		now := time.Now()
+		invitation.Status = models.InvitationStatusRevoked
+		invitation.RevokedAt = &now
+		if err := tx.Model(&invitation).Updates(map[string]interface{}{
+			"status":     invitation.Status,
+			"revoked_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return s.record(tx, actor, models.AuditActionInvitationRevoked, &invitation)
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) || errors.Is(err, ErrInvitationNotPending) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+	return &invitation, nil
+}
+
+// List devuelve las invitaciones filtradas; sin estado, las pendientes no caducadas
+func (s *InvitationService) List(ctx context.Context, query *models.ListInvitationsQuery) ([]*models.Invitation, error) {
+	db := s.db.WithContext(ctx).Order("created_at DESC")
+	now := time.Now()
+	switch query.Status {
+	case "", models.InvitationStatusPending:
+		db = db.Where("status = ? AND expires_at > ?", models.InvitationStatusPending, now)
+	case models.InvitationStatusExpired:
+		db = db.Where("status = ? AND expires_at <= ?", models.InvitationStatusPending, now)
+	default:
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.Email != "" {
+		db = db.Where("email = ?", normalizeInvitationEmail(query.Email))
+	}
+	if query.OrgID != "" {
+		db = db.Where("org_id = ?", query.OrgID)
+	}
+
+	var invitations []*models.Invitation
+	if err := db.Find(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	for _, invitation := range invitations {
+		markExpired(invitation, now)
+	}
+	return invitations, nil
+}
+
+// Preview devuelve los datos de una invitación válida para mostrarlos antes del login
+func (s *InvitationService) Preview(ctx context.Context, token string) (*models.InvitationPreview, error) {
+	invitation, err := s.load(s.db.WithContext(ctx), token)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &models.InvitationPreview{
+		Email:     invitation.Email,
+		Role:      invitation.Role,
+		OrgRole:   invitation.OrgRole,
+		ExpiresAt: invitation.ExpiresAt,
+	}
+	if invitation.OrgID != nil {
+		var org models.Organization
+		if err := s.db.WithContext(ctx).Where("id = ?", *invitation.OrgID).First(&org).Error; err == nil {
+			preview.OrganizationName = org.Name
+		}
+	}
+	return preview, nil
+}
+
+// Check valida el token y que la identidad con la que se inicia sesión tenga el
+// email invitado, verificado. Se llama antes de crear o vincular la cuenta.
+func (s *InvitationService) Check(ctx context.Context, token, email string, emailVerified bool) (*models.Invitation, error) {
+	invitation, err := s.load(s.db.WithContext(ctx), token)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkInvitationEmail(invitation, email, emailVerified); err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// Accept aplica la invitación a la cuenta que ha iniciado sesión: le da el rol
+// invitado (nunca lo rebaja) y la membresía de la organización
+func (s *InvitationService) Accept(ctx context.Context, invitationID string, user *models.User, email string, emailVerified bool) error {
+	roleChanged := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		if err := s.lockPending(tx, invitationID, &invitation); err != nil {
+			return err
+		}
+		if invitation.ExpiresAt.Before(time.Now()) {
+			return ErrInvitationInvalid
+		}
+		if err := checkInvitationEmail(&invitation, email, emailVerified); err != nil {
+			return err
+		}
+
+		if invitation.Role == models.RoleAdmin && user.Role != models.RoleAdmin {
+			if err := tx.Model(user).Update("role", models.RoleAdmin).Error; err != nil {
+				return err
+			}
+			user.Role = models.RoleAdmin
+			roleChanged = true
+		}
+		if invitation.OrgID != nil {
+			membership := &models.OrganizationMembership{OrgID: *invitation.OrgID, UserID: user.ID, Role: invitation.OrgRole}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(membership).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Model(&invitation).Updates(map[string]interface{}{
+			"status":      models.InvitationStatusAccepted,
+			"accepted_by": user.ID,
+			"accepted_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return s.record(tx, AdminActor{UserID: user.ID}, models.AuditActionInvitationAccepted, &invitation)
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvitationNotFound) || errors.Is(err, ErrInvitationNotPending) {
+			return ErrInvitationInvalid
+		}
+		if errors.Is(err, ErrInvitationInvalid) || errors.Is(err, ErrInvitationEmailMismatch) ||
+			errors.Is(err, ErrInvitationEmailUnverified) {
+			return err
+		}
+		return fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	if roleChanged {
+		s.users.notifyChanged(ctx, user)
+	}
+	s.logger.WithFields(map[string]interface{}{
+		"invitation_id": invitationID,
+		"user_id":       user.ID,
+	}).Info("Invitation accepted")
+	return nil
+}
+
+// load valida la firma del token y devuelve la invitación si sigue pendiente y vigente
+func (s *InvitationService) load(db *gorm.DB, token string) (*models.Invitation, error) {
+	invitationID, nonce, ok := parseInvitationToken(s.signingKey, token)
+	if !ok || !uuidPattern.MatchString(invitationID) {
+		return nil, ErrInvitationInvalid
+	}
+
+	var invitation models.Invitation
+	if err := db.Where("id = ?", invitationID).First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationInvalid
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if invitation.Status != models.InvitationStatusPending ||
+		invitation.ExpiresAt.Before(time.Now()) ||
+		!hmac.Equal([]byte(invitation.NonceHash), []byte(sha256Hex(nonce))) {
+		return nil, ErrInvitationInvalid
+	}
+	return &invitation, nil
+}
+
+func (s *InvitationService) lockPending(tx *gorm.DB, invitationID string, invitation *models.Invitation) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", invitationID).First(invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvitationNotFound
+		}
+		return err
+	}
+	if invitation.Status != models.InvitationStatusPending {
+		return ErrInvitationNotPending
+	}
+	return nil
+}
+
+// checkInvitableTx rechaza invitaciones duplicadas o a cuentas que ya tienen lo que
+// se les ofrece. Un usuario existente sólo puede invitarse a una organización.
+func (s *InvitationService) checkInvitableTx(tx *gorm.DB, invitation *models.Invitation) error {
+	// Serializa las invitaciones al mismo email para que no haya dos pendientes
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "invitation:"+invitation.Email).Error; err != nil {
+		return err
+	}
+
+	pending := tx.Model(&models.Invitation{}).
+		Where("email = ? AND status = ? AND expires_at > ?", invitation.Email, models.InvitationStatusPending, time.Now())
+	if invitation.OrgID != nil {
+		pending = pending.Where("org_id = ?", *invitation.OrgID)
+	} else {
+		pending = pending.Where("org_id IS NULL")
+	}
+	var count int64
+	if err := pending.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrInvitationExists
+	}
+
+	if invitation.OrgID != nil {
+		var orgs int64
+		if err := tx.Model(&models.Organization{}).Where("id = ?", *invitation.OrgID).Count(&orgs).Error; err != nil {
+			return err
+		}
+		if orgs == 0 {
+			return ErrOrganizationNotFound
+		}
+	}
+
+	var existing models.User
+	err := whereEmailOwner(tx, invitation.Email).Where("status NOT IN ?", models.DeletedStatuses).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if invitation.OrgID == nil {
+		return ErrEmailAlreadyRegistered
+	}
+
+	var members int64
+	if err := tx.Model(&models.OrganizationMembership{}).
+		Where("org_id = ? AND user_id = ?", *invitation.OrgID, existing.ID).
+		Count(&members).Error; err != nil {
+		return err
+	}
+	if members > 0 {
+		return ErrOrgMemberExists
+	}
+	return nil
+}
+
+// send envía el email de la invitación; un fallo se registra y la invitación queda
+// pendiente de reenvío
+func (s *InvitationService) send(ctx context.Context, invitation *models.Invitation, nonce string) {
+	link := s.acceptURL + "?" + url.Values{"token": {signInvitationToken(s.signingKey, invitation.ID, nonce)}}.Encode()
+	err := s.mailer.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited",
+		Body: fmt.Sprintf("You have been invited to join. Accept the invitation before %s:\n\n%s\n",
+			invitation.ExpiresAt.UTC().Format(time.RFC1123), link),
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("invitation_id", invitation.ID).Warn("Failed to send invitation email")
+		return
+	}
+
+	now := time.Now()
+	invitation.SentAt = &now
+	invitation.SendCount++
+	if err := s.db.WithContext(ctx).Model(invitation).Updates(map[string]interface{}{
+		"sent_at":    now,
+		"send_count": gorm.Expr("send_count + 1"),
+	}).Error; err != nil {
+		s.logger.WithError(err).WithField("invitation_id", invitation.ID).Warn("Failed to record invitation delivery")
+	}
+}
+
+func (s *InvitationService) record(tx *gorm.DB, actor AdminActor, action string, invitation *models.Invitation) error {
+	details := models.JSONMap{
+		"invitation_id": invitation.ID,
+		"email":         invitation.Email,
+		"role":          invitation.Role,
+	}
+	if invitation.OrgID != nil {
+		details["org_id"] = *invitation.OrgID
+		details["org_role"] = invitation.OrgRole
+	}
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		UserID:    actor.UserID,
+		ActorID:   actor.UserID,
+		Action:    action,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details:   details,
+	})
+}
+
+// signInvitationToken construye el token "<id>.<nonce>.<firma>"
+func signInvitationToken(key []byte, invitationID, nonce string) string {
+	return invitationID + "." + nonce + "." + invitationSignature(key, invitationID, nonce)
+}
+
+// parseInvitationToken comprueba la firma y devuelve el ID y el nonce del token
+func parseInvitationToken(key []byte, token string) (string, string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	expected := invitationSignature(key, parts[0], parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func invitationSignature(key []byte, invitationID, nonce string) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s.%s", invitationID, nonce)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkInvitationEmail exige que la identidad tenga el email invitado y que el proveedor
+// lo haya verificado: quien registra la dirección sin controlarla no recibe el rol
+func checkInvitationEmail(invitation *models.Invitation, email string, emailVerified bool) error {
+	if normalizeInvitationEmail(email) != invitation.Email {
+		return ErrInvitationEmailMismatch
+	}
+	if !emailVerified {
+		return ErrInvitationEmailUnverified
+	}
+	return nil
+}
+
+func normalizeInvitationEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// markExpired muestra como expired las invitaciones pendientes ya caducadas
+func markExpired(invitation *models.Invitation, now time.Time) {
+	if invitation.Status == models.InvitationStatusPending && !invitation.ExpiresAt.After(now) {
+		invitation.Status = models.InvitationStatusExpired
+	}
+}
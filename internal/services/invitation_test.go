@@ -0,0 +1,61 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"it-auth-service/internal/models"
+)
+
+func TestInvitationTokenRoundTrip(t *testing.T) {
+	key := []byte("test-key")
+	token := signInvitationToken(key, "3f1c2d4e-0000-4000-8000-000000000001", "nonce-value")
+
+	id, nonce, ok := parseInvitationToken(key, token)
+	assert.True(t, ok)
+	assert.Equal(t, "3f1c2d4e-0000-4000-8000-000000000001", id)
+	assert.Equal(t, "nonce-value", nonce)
+}
+
+func TestParseInvitationTokenRejectsTampering(t *testing.T) {
+	key := []byte("test-key")
+	signature := invitationSignature(key, "invitation-id", "nonce-value")
+
+	cases := map[string]string{
+		"other key":       signInvitationToken([]byte("other-key"), "invitation-id", "nonce-value"),
+		"changed id":      "other-id.nonce-value." + signature,
+		"changed nonce":   "invitation-id.other-nonce." + signature,
+		"missing parts":   "invitation-id.nonce-value",
+		"empty id":        ".nonce-value." + signature,
+		"extra separator": "invitation-id.nonce-value." + signature + ".extra",
+	}
+	for name, token := range cases {
+		_, _, ok := parseInvitationToken(key, token)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestMarkExpired(t *testing.T) {
+	now := time.Now()
+
+	pending := &models.Invitation{Status: models.InvitationStatusPending, ExpiresAt: now.Add(-time.Minute)}
+	markExpired(pending, now)
+	assert.Equal(t, models.InvitationStatusExpired, pending.Status)
+
+	valid := &models.Invitation{Status: models.InvitationStatusPending, ExpiresAt: now.Add(time.Hour)}
+	markExpired(valid, now)
+	assert.Equal(t, models.InvitationStatusPending, valid.Status)
+
+	accepted := &models.Invitation{Status: models.InvitationStatusAccepted, ExpiresAt: now.Add(-time.Minute)}
+	markExpired(accepted, now)
+	assert.Equal(t, models.InvitationStatusAccepted, accepted.Status)
+}
+
+func TestCheckInvitationEmail(t *testing.T) {
+	invitation := &models.Invitation{Email: "new.admin@example.com"}
+
+	assert.NoError(t, checkInvitationEmail(invitation, " New.Admin@Example.com", true))
+	assert.ErrorIs(t, checkInvitationEmail(invitation, "new.admin@example.com", false), ErrInvitationEmailUnverified)
+	assert.ErrorIs(t, checkInvitationEmail(invitation, "other@example.com", true), ErrInvitationEmailMismatch)
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+// Motivos de las transiciones que ejecuta el propio servicio
+const (
+	statusReasonEmailVerified = "email_verified"
+	statusReasonLockExpired   = "lock_expired"
+	statusReasonGuestUpgrade  = "guest_upgrade"
+	statusReasonErased        = "erased"
+	statusReasonDormant       = "dormant"
+	statusReasonReactivated   = "reactivated"
+	statusReasonImported      = "imported"
+)
+
+var (
+	ErrAccountLocked              = errors.New("account is locked")
+	ErrAccountPendingVerification = errors.New("email address must be verified before signing in")
+	ErrAccountInactive            = errors.New("account is not active")
+	ErrAccountDormant             = errors.New("account was deactivated due to inactivity and must be reactivated")
+)
+
+// StatusChange describe una transición de estado solicitada
+type StatusChange struct {
+	To          string
+	Reason      string
+	Actor       AdminActor
+	LockedUntil *time.Time // Sólo al pasar a locked; nil bloquea indefinidamente
+	// El proveedor acaba de confirmar el email; se guarda junto con la transición
+	VerifiedEmail bool
+}
+
+// transitionGuard comprueba las condiciones adicionales de una transición permitida
+type transitionGuard func(user *models.User, change StatusChange) error
+
+// userTransitions es la máquina de estados de las cuentas: para cada estado, los
+// estados a los que puede pasar y la guarda que debe cumplirse (nil si no hay).
+// deleted es final y sólo se alcanza desde el borrado definitivo.
+var userTransitions = map[string]map[string]transitionGuard{
+	models.StatusPendingVerification: {
+		models.StatusActive:          requireVerifiedEmail,
+		models.StatusLocked:          requireFutureLock,
+		models.StatusSuspended:       nil,
+		models.StatusPendingDeletion: nil,
+	},
+	models.StatusGuest: {
+		models.StatusActive:          requireRealIdentity,
+		models.StatusLocked:          requireFutureLock,
+		models.StatusSuspended:       nil,
+		models.StatusPendingDeletion: nil,
+	},
+	models.StatusActive: {
+		models.StatusPendingVerification: nil,
+		models.StatusLocked:              requireFutureLock,
+		models.StatusSuspended:           nil,
+		models.StatusPendingDeletion:     nil,
+	},
+	models.StatusLocked: {
+		models.StatusActive:          nil,
+		models.StatusLocked:          requireFutureLock, // Ampliar o acortar el bloqueo
+		models.StatusSuspended:       nil,
+		models.StatusPendingDeletion: nil,
+	},
+	models.StatusSuspended: {
+		models.StatusActive:          nil,
+		models.StatusLocked:          requireFutureLock,
+		models.StatusPendingDeletion: nil,
+	},
+	models.StatusPendingDeletion: {
+		models.StatusActive:  nil,
+		models.StatusDeleted: nil,
+	},
+	models.StatusDeleted: {},
+}
+
+func requireVerifiedEmail(user *models.User, change StatusChange) error {
+	if !user.EmailVerified && !change.VerifiedEmail {
+		return fmt.Errorf("%w: email address is not verified", ErrInvalidStatusValue)
+	}
+	return nil
+}
+
+// requireRealIdentity impide activar a un invitado sin vincular antes una identidad real
+func requireRealIdentity(user *models.User, _ StatusChange) error {
+	if user.Provider == models.ProviderAnonymous {
+		return fmt.Errorf("%w: guests become active by linking a real identity", ErrInvalidStatusValue)
+	}
+	return nil
+}
+
+func requireFutureLock(_ *models.User, change StatusChange) error {
+	if change.LockedUntil != nil && !change.LockedUntil.After(time.Now()) {
+		return fmt.Errorf("%w: locked_until must be in the future", ErrInvalidStatusValue)
+	}
+	return nil
+}
+
+// checkTransition valida una transición contra la máquina de estados
+func checkTransition(user *models.User, change StatusChange) error {
+	targets, ok := userTransitions[user.Status]
+	if !ok {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidStatusValue, user.Status)
+	}
+	guard, ok := targets[change.To]
+	if !ok {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusValue, user.Status, change.To)
+	}
+	if change.LockedUntil != nil && change.To != models.StatusLocked {
+		return fmt.Errorf("%w: locked_until only applies to locked accounts", ErrInvalidStatusValue)
+	}
+	if guard != nil {
+		return guard(user, change)
+	}
+	return nil
+}
+
+// CheckAccountStatus devuelve un error si la cuenta no puede autenticarse. Sólo las
+// cuentas activas, los invitados y los bloqueos ya vencidos pueden hacerlo.
+func CheckAccountStatus(user *models.User) error {
+	switch user.Status {
+	case models.StatusActive, models.StatusGuest:
+		return nil
+	case models.StatusLocked:
+		if lockExpired(user, time.Now()) {
+			return nil
+		}
+		return ErrAccountLocked
+	case models.StatusPendingVerification:
+		if user.StatusReason == statusReasonDormant {
+			return ErrAccountDormant
+		}
+		return ErrAccountPendingVerification
+	case models.StatusSuspended:
+		return ErrAccountSuspended
+	case models.StatusPendingDeletion, models.StatusDeleted:
+		return ErrAccountDeleted
+	}
+	return ErrAccountInactive
+}
+
+// IsAccountStatusError indica si el error procede de CheckAccountStatus
+func IsAccountStatusError(err error) bool {
+	return errors.Is(err, ErrAccountLocked) || errors.Is(err, ErrAccountPendingVerification) ||
+		errors.Is(err, ErrAccountInactive) || errors.Is(err, ErrAccountDormant) || errors.Is(err, ErrAccountSuspended) ||
+		errors.Is(err, ErrAccountDeleted)
+}
+
+func lockExpired(user *models.User, now time.Time) bool {
+	return user.LockedUntil != nil && !now.Before(*user.LockedUntil)
+}
+
+// loginTransition decide la transición que desencadena un login válido: la cuenta
+// pendiente de verificación se activa cuando el proveedor confirma el email y un
+// bloqueo vencido se levanta. Las cuentas desactivadas por inactividad no se activan
+// solas: requieren el código de reactivación. Devuelve nil si no hay nada que cambiar.
+func loginTransition(user *models.User, emailVerified bool, now time.Time) *StatusChange {
+	switch {
+	case user.Status == models.StatusPendingVerification && emailVerified && user.StatusReason != statusReasonDormant:
+		return &StatusChange{To: models.StatusActive, Reason: statusReasonEmailVerified, VerifiedEmail: true}
+	case user.Status == models.StatusLocked && lockExpired(user, now):
+		return &StatusChange{To: models.StatusActive, Reason: statusReasonLockExpired}
+	}
+	return nil
+}
+
+// UserStatusListener recibe los cambios de estado ya confirmados
+type UserStatusListener func(ctx context.Context, event *models.UserStatusChangedEvent)
+
+// LifecycleService es el único punto por el que cambia el estado de una cuenta. Cada
+// transición se valida contra la máquina de estados, se audita y se publica a los
+// listeners registrados.
+type LifecycleService struct {
+	db        *gorm.DB
+	users     *UserService
+	audit     *AuditService
+	logger    *logrus.Logger
+	listeners []UserStatusListener
+}
+
+func NewLifecycleService(db *gorm.DB, userService *UserService, auditService *AuditService) *LifecycleService {
+	return &LifecycleService{
+		db:     db,
+		users:  userService,
+		audit:  auditService,
+		logger: logger.GetLogger(),
+	}
+}
+
+// OnStatusChanged registra un listener de cambios de estado. Debe llamarse durante el arranque.
+func (s *LifecycleService) OnStatusChanged(listener UserStatusListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+// Transition cambia el estado de la cuenta si la máquina de estados lo permite
+func (s *LifecycleService) Transition(ctx context.Context, userID string, change StatusChange) (*models.User, error) {
+	return s.transition(ctx, userID, func(*gorm.DB, *models.User) (*StatusChange, error) {
+		return &change, nil
+	})
+}
+
+// ResumeOnLogin aplica las transiciones automáticas de un login o una renovación y
+// comprueba después que la cuenta puede autenticarse. Actualiza user en su sitio.
+func (s *LifecycleService) ResumeOnLogin(ctx context.Context, user *models.User, emailVerified bool) error {
+	if loginTransition(user, emailVerified, time.Now()) != nil {
+		updated, err := s.transition(ctx, user.ID, func(_ *gorm.DB, current *models.User) (*StatusChange, error) {
+			return loginTransition(current, emailVerified, time.Now()), nil
+		})
+		if err != nil {
+			return err
+		}
+		*user = *updated
+	}
+	return CheckAccountStatus(user)
+}
+
+// transition bloquea la fila del usuario, decide la transición con el estado actual
+// (plan puede devolver nil si ya no hay nada que cambiar) y la aplica en la misma
+// transacción que los cambios que haga plan
+func (s *LifecycleService) transition(ctx context.Context, userID string, plan func(tx *gorm.DB, user *models.User) (*StatusChange, error)) (*models.User, error) {
+	var user models.User
+	var event *models.UserStatusChangedEvent
+	var planErr error
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", userID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+
+		change, err := plan(tx, &user)
+		if err != nil {
+			planErr = err
+			return err
+		}
+		if change == nil {
+			return nil
+		}
+		event, err = s.transitionTx(tx, &user, *change)
+		return err
+	})
+	if err != nil {
+		// Los errores de plan los interpreta el llamante
+		if err != planErr && !errors.Is(err, ErrUserNotFound) && !errors.Is(err, ErrInvalidStatusValue) {
+			s.logger.WithError(err).Error("Failed to change user status")
+		}
+		return nil, err
+	}
+
+	if event != nil {
+		s.users.notifyChanged(ctx, &user)
+		s.publish(ctx, event)
+	}
+	return &user, nil
+}
+
+// transitionTx valida y aplica la transición dentro de la transacción del llamante,
+// que debe tener bloqueada la fila del usuario. El evento devuelto se publica con
+// publish una vez confirmada la transacción.
+func (s *LifecycleService) transitionTx(tx *gorm.DB, user *models.User, change StatusChange) (*models.UserStatusChangedEvent, error) {
+	if err := checkTransition(user, change); err != nil {
+		return nil, err
+	}
+
+	from := user.Status
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            change.To,
+		"status_reason":     change.Reason,
+		"status_changed_at": now,
+		"locked_until":      change.LockedUntil,
+	}
+	if change.VerifiedEmail {
+		updates["email_verified"] = true
+		user.EmailVerified = true
+	}
+	if err := tx.Model(user).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	user.Status = change.To
+	user.StatusReason = change.Reason
+	user.StatusChangedAt = &now
+	user.LockedUntil = change.LockedUntil
+
+	details := models.JSONMap{
+		"from":   from,
+		"to":     change.To,
+		"reason": change.Reason,
+	}
+	if change.LockedUntil != nil {
+		details["locked_until"] = change.LockedUntil
+	}
+	if err := s.audit.RecordTx(tx, &models.AuditLog{
+		UserID:    user.ID,
+		ActorID:   change.Actor.UserID,
+		Action:    models.AuditActionStatusChanged,
+		IPAddress: change.Actor.IPAddress,
+		UserAgent: change.Actor.UserAgent,
+		Details:   details,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &models.UserStatusChangedEvent{
+		Type:        models.UserStatusChangedEventType,
+		UserID:      user.ID,
+		From:        from,
+		To:          change.To,
+		Reason:      change.Reason,
+		ActorID:     change.Actor.UserID,
+		LockedUntil: change.LockedUntil,
+		ChangedAt:   now.UTC(),
+	}, nil
+}
+
+func (s *LifecycleService) publish(ctx context.Context, event *models.UserStatusChangedEvent) {
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":  event.UserID,
+		"actor_id": event.ActorID,
+		"from":     event.From,
+		"to":       event.To,
+		"reason":   event.Reason,
+	}).Info("User status changed")
+
+	for _, listener := range s.listeners {
+		listener(ctx, event)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/models"
+)
+
+func TestCheckTransition(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		user    models.User
+		change  StatusChange
+		allowed bool
+	}{
+		{"suspend active", models.User{Status: models.StatusActive}, StatusChange{To: models.StatusSuspended}, true},
+		{"lock until future", models.User{Status: models.StatusActive}, StatusChange{To: models.StatusLocked, LockedUntil: &future}, true},
+		{"lock indefinitely", models.User{Status: models.StatusSuspended}, StatusChange{To: models.StatusLocked}, true},
+		{"lock until past", models.User{Status: models.StatusActive}, StatusChange{To: models.StatusLocked, LockedUntil: &past}, false},
+		{"locked_until without lock", models.User{Status: models.StatusActive}, StatusChange{To: models.StatusSuspended, LockedUntil: &future}, false},
+		{"activate unverified", models.User{Status: models.StatusPendingVerification}, StatusChange{To: models.StatusActive}, false},
+		{"activate verified", models.User{Status: models.StatusPendingVerification, EmailVerified: true}, StatusChange{To: models.StatusActive}, true},
+		{"activate on verification", models.User{Status: models.StatusPendingVerification}, StatusChange{To: models.StatusActive, VerifiedEmail: true}, true},
+		{"activate anonymous guest", models.User{Status: models.StatusGuest, Provider: models.ProviderAnonymous}, StatusChange{To: models.StatusActive}, false},
+		{"upgrade guest", models.User{Status: models.StatusGuest, Provider: "google.com"}, StatusChange{To: models.StatusActive}, true},
+		{"restore pending deletion", models.User{Status: models.StatusPendingDeletion}, StatusChange{To: models.StatusActive}, true},
+		{"erase pending deletion", models.User{Status: models.StatusPendingDeletion}, StatusChange{To: models.StatusDeleted}, true},
+		{"erase active", models.User{Status: models.StatusActive}, StatusChange{To: models.StatusDeleted}, false},
+		{"suspend pending deletion", models.User{Status: models.StatusPendingDeletion}, StatusChange{To: models.StatusSuspended}, false},
+		{"restore deleted", models.User{Status: models.StatusDeleted}, StatusChange{To: models.StatusActive}, false},
+		{"same status", models.User{Status: models.StatusSuspended}, StatusChange{To: models.StatusSuspended}, false},
+		{"unknown status", models.User{Status: "inactive"}, StatusChange{To: models.StatusActive}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTransition(&tt.user, tt.change)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidStatusValue)
+			}
+		})
+	}
+}
+
+func TestCheckAccountStatus(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	assert.NoError(t, CheckAccountStatus(&models.User{Status: models.StatusActive}))
+	assert.NoError(t, CheckAccountStatus(&models.User{Status: models.StatusGuest}))
+	assert.NoError(t, CheckAccountStatus(&models.User{Status: models.StatusLocked, LockedUntil: &past}))
+	assert.ErrorIs(t, CheckAccountStatus(&models.User{Status: models.StatusLocked, LockedUntil: &future}), ErrAccountLocked)
+	assert.ErrorIs(t, CheckAccountStatus(&models.User{Status: models.StatusLocked}), ErrAccountLocked)
+	assert.ErrorIs(t, CheckAccountStatus(&models.User{Status: models.StatusPendingVerification}), ErrAccountPendingVerification)
+	assert.ErrorIs(t, CheckAccountStatus(&models.User{Status: models.StatusSuspended}), ErrAccountSuspended)
+	assert.ErrorIs(t, CheckAccountStatus(&models.User{Status: models.StatusPendingDeletion}), ErrAccountDeleted)
+	assert.ErrorIs(t, CheckAccountStatus(&models.User{Status: models.StatusDeleted}), ErrAccountDeleted)
+	assert.ErrorIs(t, CheckAccountStatus(&models.User{Status: "inactive"}), ErrAccountInactive)
+}
+
+func TestLoginTransition(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Second)
+	future := now.Add(time.Hour)
+
+	change := loginTransition(&models.User{Status: models.StatusPendingVerification}, true, now)
+	require.NotNil(t, change)
+	assert.Equal(t, models.StatusActive, change.To)
+	assert.True(t, change.VerifiedEmail)
+
+	assert.Nil(t, loginTransition(&models.User{Status: models.StatusPendingVerification}, false, now))
+
+	change = loginTransition(&models.User{Status: models.StatusLocked, LockedUntil: &expired}, false, now)
+	require.NotNil(t, change)
+	assert.Equal(t, statusReasonLockExpired, change.Reason)
+
+	assert.Nil(t, loginTransition(&models.User{Status: models.StatusLocked, LockedUntil: &future}, false, now))
+	assert.Nil(t, loginTransition(&models.User{Status: models.StatusLocked}, false, now))
+	assert.Nil(t, loginTransition(&models.User{Status: models.StatusActive}, true, now))
+}
@@ -0,0 +1,495 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+const (
+	mfaChallengeTTL         = 5 * time.Minute
+	mfaChallengeMaxAttempts = 5
+	mfaRecoveryCodeCount    = 10
+
+	// Cada mfaLockoutThreshold códigos erróneos seguidos del mismo usuario, sea cual sea
+	// el reto, se bloquea la verificación; el bloqueo se duplica en cada nueva tanda
+	mfaLockoutThreshold = 5
+	mfaLockoutBase      = 15 * time.Minute
+	mfaLockoutMax       = 24 * time.Hour
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("mfa already enabled")
+	ErrMFANotEnrolled      = errors.New("mfa not enrolled")
+	ErrInvalidMFACode      = errors.New("invalid mfa code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa challenge")
+	ErrMFALocked           = errors.New("too many invalid mfa codes, try again later")
+	ErrMFACodeRequired     = errors.New("mfa code required")
+)
+
+type MFAService struct {
+	db     *gorm.DB
+	config *config.Config
+	box    *secretBox
+	logger *logrus.Logger
+}
+
+func NewMFAService(db *gorm.DB, cfg *config.Config) (*MFAService, error) {
+	// Si no hay clave dedicada se deriva del secreto JWT para no bloquear entornos de desarrollo
+	key := cfg.MFAConfig.EncryptionKey
+	if key == "" {
+		key = cfg.JWTSecret
+	}
+
+	box, err := newSecretBox(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize MFA secret encryption: %w", err)
+	}
+
+	return &MFAService{
+		db:     db,
+		config: cfg,
+		box:    box,
+		logger: logger.GetLogger(),
+	}, nil
+}
+
+// IsRequired indica si la política obliga al usuario a usar MFA: por su rol global o
+// porque pertenece a una organización (tenant) que lo exige. Cuenta cualquier
+// organización y no sólo la activa, porque se puede cambiar a otra sin nuevo login.
+// Si no puede comprobarse se exige.
+func (s *MFAService) IsRequired(ctx context.Context, user *models.User) bool {
+	for _, role := range s.config.MFAConfig.RequiredRoles {
+		if strings.EqualFold(role, user.Role) {
+			return true
+		}
+	}
+
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.OrganizationMembership{}).
+		Joins("JOIN organizations ON organizations.id = organization_memberships.org_id").
+		Where("organization_memberships.user_id = ? AND organizations.require_mfa = ?", user.ID, true).
+		Count(&count).Error
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to check organization MFA policy")
+		return true
+	}
+	return count > 0
+}
+
+// BeginEnrollment genera un nuevo secreto TOTP pendiente de confirmación
+func (s *MFAService) BeginEnrollment(ctx context.Context, user *models.User) (*models.MFAEnrollResponse, error) {
+	var factor models.MFAFactor
+	err := s.db.WithContext(ctx).Where("user_id = ?", user.ID).First(&factor).Error
+	if err == nil && factor.Confirmed {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.WithError(err).Error("Failed to get MFA factor")
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.box.Seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt TOTP secret: %w", err)
+	}
+
+	// Reemplazar cualquier registro previo sin confirmar
+	factor.UserID = user.ID
+	factor.EncryptedSecret = encrypted
+	factor.Confirmed = false
+	factor.ConfirmedAt = nil
+	factor.LastUsedStep = 0
+	if err := s.db.WithContext(ctx).Save(&factor).Error; err != nil {
+		s.logger.WithError(err).Error("Failed to save MFA factor")
+		return nil, fmt.Errorf("failed to save MFA factor: %w", err)
+	}
+
+	s.logger.WithField("user_id", user.ID).Info("MFA enrollment started")
+
+	return &models.MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: buildOTPAuthURI(s.config.MFAConfig.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment activa el factor pendiente y devuelve los códigos de recuperación
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.checkLockout(ctx, userID); err != nil {
+		return nil, err
+	}
+	codes, err := s.confirmEnrollment(ctx, userID, code)
+	s.recordOutcome(ctx, userID, err)
+	return codes, err
+}
+
+func (s *MFAService) confirmEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	var factor models.MFAFactor
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&factor).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if factor.Confirmed {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.box.Open(factor.EncryptedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := validateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	var codes []string
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&factor).Updates(map[string]interface{}{
+			"confirmed":      true,
+			"confirmed_at":   &now,
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("mfa_enabled", true).Error; err != nil {
+			return err
+		}
+
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to confirm MFA enrollment")
+		return nil, fmt.Errorf("failed to confirm MFA enrollment: %w", err)
+	}
+
+	s.logger.WithField("user_id", userID).Info("MFA enrollment confirmed")
+	return codes, nil
+}
+
+// Verify valida un código TOTP o un código de recuperación del usuario. Los fallos se
+// cuentan por usuario y no por reto: pedir otro reto no da más intentos.
+func (s *MFAService) Verify(ctx context.Context, userID, code, recoveryCode string) error {
+	if err := s.checkLockout(ctx, userID); err != nil {
+		return err
+	}
+	err := s.verify(ctx, userID, code, recoveryCode)
+	s.recordOutcome(ctx, userID, err)
+	return err
+}
+
+func (s *MFAService) verify(ctx context.Context, userID, code, recoveryCode string) error {
+	if code != "" {
+		return s.verifyTOTP(ctx, userID, code)
+	}
+	if recoveryCode != "" {
+		return s.consumeRecoveryCode(ctx, userID, recoveryCode)
+	}
+	return ErrInvalidMFACode
+}
+
+func (s *MFAService) verifyTOTP(ctx context.Context, userID, code string) error {
+	var factor models.MFAFactor
+	err := s.db.WithContext(ctx).Where("user_id = ? AND confirmed = ?", userID, true).First(&factor).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMFANotEnrolled
+		}
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	secret, err := s.box.Open(factor.EncryptedSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := validateTOTP(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	// Actualización condicional: un código ya usado (misma ventana o anterior) se rechaza
+	result := s.db.WithContext(ctx).
+		Model(&models.MFAFactor{}).
+		Where("id = ? AND last_used_step < ?", factor.ID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return fmt.Errorf("failed to record MFA code usage: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *MFAService) consumeRecoveryCode(ctx context.Context, userID, recoveryCode string) error {
+	result := s.db.WithContext(ctx).
+		Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, sha256Hex(normalizeRecoveryCode(recoveryCode))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to consume recovery code: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+
+	s.logger.WithField("user_id", userID).Warn("MFA recovery code used")
+	return nil
+}
+
+// checkLockout rechaza la verificación mientras dure el bloqueo por códigos erróneos
+func (s *MFAService) checkLockout(ctx context.Context, userID string) error {
+	var factor models.MFAFactor
+	err := s.db.WithContext(ctx).Select("locked_until").Where("user_id = ?", userID).First(&factor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if factor.LockedUntil != nil && time.Now().Before(*factor.LockedUntil) {
+		return ErrMFALocked
+	}
+	return nil
+}
+
+// recordOutcome cuenta un código erróneo o, tras un código válido, pone el contador a cero
+func (s *MFAService) recordOutcome(ctx context.Context, userID string, verifyErr error) {
+	var err error
+	switch {
+	case verifyErr == nil:
+		err = s.db.WithContext(ctx).Model(&models.MFAFactor{}).
+			Where("user_id = ? AND failed_attempts > 0", userID).
+			Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil}).Error
+	case errors.Is(verifyErr, ErrInvalidMFACode):
+		err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var factor models.MFAFactor
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user_id = ?", userID).First(&factor).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return err
+			}
+			failures := factor.FailedAttempts + 1
+			updates := map[string]interface{}{"failed_attempts": failures}
+			if lockout := mfaLockoutFor(failures); lockout > 0 {
+				updates["locked_until"] = time.Now().Add(lockout)
+				s.logger.WithFields(map[string]interface{}{
+					"user_id":  userID,
+					"failures": failures,
+					"lockout":  lockout.String(),
+				}).Warn("MFA verification locked after repeated invalid codes")
+			}
+			return tx.Model(&factor).Updates(updates).Error
+		})
+	}
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to record MFA verification outcome")
+	}
+}
+
+// mfaLockoutFor devuelve el bloqueo que corresponde a failures códigos erróneos seguidos:
+// ninguno salvo al completar una tanda de mfaLockoutThreshold, y el doble en cada tanda
+func mfaLockoutFor(failures int) time.Duration {
+	if failures <= 0 || failures%mfaLockoutThreshold != 0 {
+		return 0
+	}
+	lockout := mfaLockoutBase
+	for i := failures / mfaLockoutThreshold; i > 1 && lockout < mfaLockoutMax; i-- {
+		lockout *= 2
+	}
+	if lockout > mfaLockoutMax {
+		lockout = mfaLockoutMax
+	}
+	return lockout
+}
+
+// RegenerateRecoveryCodes invalida los códigos anteriores y genera un nuevo lote
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.MFAFactor{}).
+		Where("user_id = ? AND confirmed = ?", userID, true).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrMFANotEnrolled
+	}
+
+	var codes []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to regenerate recovery codes")
+		return nil, fmt.Errorf("failed to regenerate recovery codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// Disable elimina el factor y los códigos de recuperación del usuario
+func (s *MFAService) Disable(ctx context.Context, userID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFAFactor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("mfa_enabled", false).Error
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to disable MFA")
+		return fmt.Errorf("failed to disable MFA: %w", err)
+	}
+
+	s.logger.WithField("user_id", userID).Info("MFA disabled")
+	return nil
+}
+
+// CreateChallenge registra un reto de segunda fase y devuelve el token opaco para el
+// cliente. authTime es el momento del primer factor, que conservará la sesión emitida.
+func (s *MFAService) CreateChallenge(ctx context.Context, userID, provider string, enrollment bool, authTime time.Time) (string, error) {
+	token, err := generateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := &models.MFAChallenge{
+		UserID:     userID,
+		TokenHash:  sha256Hex(token),
+		Provider:   provider,
+		Enrollment: enrollment,
+		AuthTime:   authTime,
+		ExpiresAt:  time.Now().Add(mfaChallengeTTL),
+	}
+	if err := s.db.WithContext(ctx).Create(challenge).Error; err != nil {
+		s.logger.WithError(err).Error("Failed to create MFA challenge")
+		return "", fmt.Errorf("failed to create MFA challenge: %w", err)
+	}
+
+	return token, nil
+}
+
+// GetChallenge devuelve un reto vigente a partir del token entregado al cliente
+func (s *MFAService) GetChallenge(ctx context.Context, token string) (*models.MFAChallenge, error) {
+	var challenge models.MFAChallenge
+	err := s.db.WithContext(ctx).
+		Where("token_hash = ? AND consumed_at IS NULL AND expires_at > ? AND attempts < ?",
+			sha256Hex(token), time.Now(), mfaChallengeMaxAttempts).
+		First(&challenge).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFAChallenge
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return &challenge, nil
+}
+
+// RecordFailedAttempt incrementa el contador de intentos fallidos del reto
+func (s *MFAService) RecordFailedAttempt(ctx context.Context, challenge *models.MFAChallenge) {
+	err := s.db.WithContext(ctx).Model(challenge).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to record MFA challenge attempt")
+	}
+}
+
+// ConsumeChallenge marca el reto como usado; sólo la primera llamada tiene éxito
+func (s *MFAService) ConsumeChallenge(ctx context.Context, challenge *models.MFAChallenge) error {
+	result := s.db.WithContext(ctx).
+		Model(&models.MFAChallenge{}).
+		Where("id = ? AND consumed_at IS NULL", challenge.ID).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to consume MFA challenge: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFAChallenge
+	}
+	return nil
+}
+
+// CleanupExpiredChallenges elimina retos caducados o consumidos
+func (s *MFAService) CleanupExpiredChallenges(ctx context.Context) error {
+	result := s.db.WithContext(ctx).
+		Where("expires_at < ? OR consumed_at IS NOT NULL", time.Now()).
+		Delete(&models.MFAChallenge{})
+	if result.Error != nil {
+		s.logger.WithError(result.Error).Error("Failed to cleanup MFA challenges")
+		return fmt.Errorf("failed to cleanup MFA challenges: %w", result.Error)
+	}
+	return nil
+}
+
+// replaceRecoveryCodes borra los códigos existentes y guarda hashes de un lote nuevo
+func (s *MFAService) replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, mfaRecoveryCodeCount)
+	records := make([]models.MFARecoveryCode, 0, mfaRecoveryCodeCount)
+	for i := 0; i < mfaRecoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, models.MFARecoveryCode{
+			UserID:   userID,
+			CodeHash: sha256Hex(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCode genera un código legible del tipo "abcde-fghij" (50 bits)
+func generateRecoveryCode() (string, error) {
+	raw, err := generateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	code := strings.ToLower(raw[:10])
+	return code[:5] + "-" + code[5:], nil
+}
+
+// normalizeRecoveryCode permite introducir el código con o sin guion y en cualquier caso
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
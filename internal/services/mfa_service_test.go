@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMFALockoutFor(t *testing.T) {
+	assert.Zero(t, mfaLockoutFor(0))
+	assert.Zero(t, mfaLockoutFor(mfaLockoutThreshold-1))
+	assert.Equal(t, mfaLockoutBase, mfaLockoutFor(mfaLockoutThreshold))
+	assert.Zero(t, mfaLockoutFor(mfaLockoutThreshold+1), "only a full batch of failures locks again")
+
+	// Cada tanda dobla el bloqueo: un reto nuevo no devuelve los intentos
+	assert.Equal(t, 2*mfaLockoutBase, mfaLockoutFor(2*mfaLockoutThreshold))
+	assert.Equal(t, 4*mfaLockoutBase, mfaLockoutFor(3*mfaLockoutThreshold))
+	assert.Equal(t, mfaLockoutMax, mfaLockoutFor(100*mfaLockoutThreshold))
+
+	// Un atacante con la contraseña no puede probar más de unos pocos códigos al día
+	var total time.Duration
+	failures := 0
+	for total < 24*time.Hour {
+		failures++
+		total += mfaLockoutFor(failures)
+	}
+	assert.Less(t, failures, 50)
+}
+
+func TestMultiFactorAuthContextKeepsFirstFactorTime(t *testing.T) {
+	firstFactor := time.Now().Add(-20 * time.Minute)
+	authCtx := multiFactorAuthContext("password", firstFactor)
+
+	// Completar el reto no hace que la sesión parezca recién autenticada
+	assert.True(t, authCtx.AuthTime.Equal(firstFactor))
+	assert.Contains(t, authCtx.AMR, "mfa")
+}
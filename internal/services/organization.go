@@ -0,0 +1,614 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+)
+
+// orgSlugPattern: minúsculas, dígitos y guiones, sin guion al principio ni al final
+var orgSlugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,62}[a-z0-9])?$`)
+
+var (
+	ErrOrganizationNotFound  = errors.New("organization not found")
+	ErrOrganizationExists    = errors.New("an organization with this slug already exists")
+	ErrInvalidOrgSlug        = errors.New("slug must contain only lowercase letters, digits and hyphens")
+	ErrNotOrgMember          = errors.New("user is not a member of the organization")
+	ErrOrgMemberExists       = errors.New("user is already a member of the organization")
+	ErrOrgRoleForbidden      = errors.New("insufficient organization role for this change")
+	ErrLastOrgOwner          = errors.New("an organization must keep at least one owner")
+	ErrOrgInvitationRequired = errors.New("user does not belong to the organization's tenant and must be invited")
+)
+
+// OrganizationService gestiona las organizaciones (tenants) y las membresías de los
+// usuarios con su rol en cada una
+type OrganizationService struct {
+	db     *gorm.DB
+	users  *UserService
+	audit  *AuditService
+	logger *logrus.Logger
+}
+
+func NewOrganizationService(db *gorm.DB, userService *UserService, auditService *AuditService) *OrganizationService {
+	return &OrganizationService{
+		db:     db,
+		users:  userService,
+		audit:  auditService,
+		logger: logger.GetLogger(),
+	}
+}
+
+// CreateOrganization crea una organización y, si se indica, añade a su primer owner
+func (s *OrganizationService) CreateOrganization(ctx context.Context, actor AdminActor, req *models.CreateOrganizationRequest) (*models.Organization, error) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !orgSlugPattern.MatchString(slug) {
+		return nil, ErrInvalidOrgSlug
+	}
+
+	org := &models.Organization{
+		Slug:           slug,
+		Name:           strings.TrimSpace(req.Name),
+		DormancyExempt: req.DormancyExempt,
+		RequireMFA:     req.RequireMFA,
+		CreatedBy:      actor.UserID,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		if req.OwnerUserID != "" {
+			if err := s.addMemberTx(tx, actor, org, req.OwnerUserID, models.OrgRoleOwner); err != nil {
+				return err
+			}
+		}
+		return s.recordOrgChange(tx, actor, models.AuditActionOrgCreated, org)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrOrganizationExists
+		}
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+	if req.OwnerUserID != "" {
+		s.notifyMembersChanged(ctx, req.OwnerUserID)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"org_id": org.ID,
+		"slug":   org.Slug,
+	}).Info("Organization created")
+	return org, nil
+}
+
+// ListOrganizations devuelve todas las organizaciones ordenadas por slug
+func (s *OrganizationService) ListOrganizations(ctx context.Context) ([]*models.Organization, error) {
+	var orgs []*models.Organization
+	if err := s.db.WithContext(ctx).Order("slug ASC").Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return orgs, nil
+}
+
+// GetOrganization busca una organización por su ID
+func (s *OrganizationService) GetOrganization(ctx context.Context, orgID string) (*models.Organization, error) {
+	return s.loadOrganization(s.db.WithContext(ctx), orgID)
+}
+
+// UpdateOrganization cambia el nombre y las políticas de una organización
+func (s *OrganizationService) UpdateOrganization(ctx context.Context, actor AdminActor, orgID string, req *models.UpdateOrganizationRequest) (*models.Organization, error) {
+	var org *models.Organization
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if org, err = s.loadOrganization(tx, orgID); err != nil {
+			return err
+		}
+		org.Name = strings.TrimSpace(req.Name)
+		if req.DormancyExempt != nil {
+			org.DormancyExempt = *req.DormancyExempt
+		}
+		if req.RequireMFA != nil {
+			org.RequireMFA = *req.RequireMFA
+		}
+		if err := tx.Model(org).Updates(map[string]interface{}{
+			"name":            org.Name,
+			"dormancy_exempt": org.DormancyExempt,
+			"require_mfa":     org.RequireMFA,
+		}).Error; err != nil {
+			return err
+		}
+		return s.recordOrgChange(tx, actor, models.AuditActionOrgUpdated, org)
+	})
+	if err != nil {
+		if errors.Is(err, ErrOrganizationNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update organization: %w", err)
+	}
+	return org, nil
+}
+
+// DeleteOrganization elimina la organización y todas sus membresías. Las cuentas
+// de los miembros no se tocan: siguen existiendo en sus otras organizaciones.
+func (s *OrganizationService) DeleteOrganization(ctx context.Context, actor AdminActor, orgID string) error {
+	var memberIDs []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		org, err := s.loadOrganization(tx, orgID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.OrganizationMembership{}).Where("org_id = ?", org.ID).
+			Pluck("user_id", &memberIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("active_org_id = ?", org.ID).
+			Update("active_org_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("org_id = ?", org.ID).Delete(&models.OrganizationMembership{}).Error; err != nil {
+			return err
+		}
+		// Las reglas de dominio que unían a esta organización dejan de hacerlo
+		if err := tx.Model(&models.DomainPolicy{}).Where("auto_join_org_id = ?", org.ID).
+			Updates(map[string]interface{}{"auto_join_org_id": nil, "auto_join_role": ""}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(org).Error; err != nil {
+			return err
+		}
+		return s.recordOrgChange(tx, actor, models.AuditActionOrgDeleted, org)
+	})
+	if err != nil {
+		if errors.Is(err, ErrOrganizationNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to delete organization: %w", err)
+	}
+	s.notifyMembersChanged(ctx, memberIDs...)
+	return nil
+}
+
+// ListMemberships devuelve las organizaciones a las que pertenece el usuario
+func (s *OrganizationService) ListMemberships(ctx context.Context, userID string) ([]*models.OrganizationMembership, error) {
+	var memberships []*models.OrganizationMembership
+	err := s.db.WithContext(ctx).Preload("Organization").
+		Where("user_id = ?", userID).
+		Order("created_at ASC, org_id ASC").
+		Find(&memberships).Error
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return memberships, nil
+}
+
+// GetMembership devuelve la membresía del usuario en la organización
+func (s *OrganizationService) GetMembership(ctx context.Context, orgID, userID string) (*models.OrganizationMembership, error) {
+	var membership models.OrganizationMembership
+	err := s.db.WithContext(ctx).Where("org_id = ? AND user_id = ?", orgID, userID).First(&membership).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrgMember
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &membership, nil
+}
+
+// ActiveMembership devuelve la membresía con la que se emiten los tokens del usuario:
+// la organización activa si sigue siendo miembro o, si no, la más antigua. Devuelve
+// nil si el usuario no pertenece a ninguna organización.
+func (s *OrganizationService) ActiveMembership(ctx context.Context, user *models.User) (*models.OrganizationMembership, error) {
+	query := s.db.WithContext(ctx).Preload("Organization").Where("user_id = ?", user.ID).Session(&gorm.Session{})
+
+	var memberships []*models.OrganizationMembership
+	if user.ActiveOrgID != nil {
+		if err := query.Where("org_id = ?", *user.ActiveOrgID).
+			Limit(1).Find(&memberships).Error; err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		if len(memberships) > 0 {
+			return memberships[0], nil
+		}
+	}
+
+	if err := query.Order("created_at ASC, org_id ASC").Limit(1).Find(&memberships).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if len(memberships) == 0 {
+		return nil, nil
+	}
+	return memberships[0], nil
+}
+
+// SetActiveOrganization fija la organización con la que se emitirán los tokens del usuario.
+// bindSession se ejecuta en la misma transacción para asociar la sesión al token emitido
+// para la nueva organización: si falla, la organización activa no cambia.
+func (s *OrganizationService) SetActiveOrganization(ctx context.Context, actor AdminActor, orgID string, bindSession func(tx *gorm.DB) error) (*models.OrganizationMembership, error) {
+	var membership models.OrganizationMembership
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Organization").
+			Where("org_id = ? AND user_id = ?", orgID, actor.UserID).
+			First(&membership).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotOrgMember
+			}
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", actor.UserID).
+			Update("active_org_id", orgID).Error; err != nil {
+			return err
+		}
+		if err := s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:    actor.UserID,
+			ActorID:   actor.UserID,
+			Action:    models.AuditActionOrgSwitched,
+			IPAddress: actor.IPAddress,
+			UserAgent: actor.UserAgent,
+			Details:   models.JSONMap{"org_id": orgID},
+		}); err != nil {
+			return err
+		}
+		return bindSession(tx)
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotOrgMember) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to switch organization: %w", err)
+	}
+	s.notifyMembersChanged(ctx, actor.UserID)
+	return &membership, nil
+}
+
+// ListMembers lista los usuarios de la organización con los mismos filtros y
+// paginación que el listado de administración, junto con su rol en ella
+func (s *OrganizationService) ListMembers(ctx context.Context, orgID string, query *models.ListUsersQuery) (*models.OrganizationMemberList, error) {
+	if _, err := s.GetOrganization(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	query.OrgID = orgID
+	result, err := s.users.SearchUsers(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(result.Users))
+	for _, user := range result.Users {
+		userIDs = append(userIDs, user.ID)
+	}
+
+	roles := make(map[string]string, len(userIDs))
+	if len(userIDs) > 0 {
+		var memberships []*models.OrganizationMembership
+		if err := s.db.WithContext(ctx).
+			Where("org_id = ? AND user_id IN ?", orgID, userIDs).
+			Find(&memberships).Error; err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		for _, membership := range memberships {
+			roles[membership.UserID] = membership.Role
+		}
+	}
+
+	return &models.OrganizationMemberList{ListUsersResult: result, Roles: roles}, nil
+}
+
+// AddMember añade un usuario existente a la organización. actorRole es el rol en la
+// organización de quien hace el cambio (owner para los administradores globales). Sólo
+// los administradores globales añaden a cualquier cuenta; los owners y admins de la
+// organización sólo a las que ya pertenecen a su tenant, y al resto deben invitarlas.
+func (s *OrganizationService) AddMember(ctx context.Context, actor AdminActor, actorRole, orgID string, req *models.AddOrganizationMemberRequest) (*models.OrganizationMembership, error) {
+	if !canManageOrgRole(actorRole, req.Role) {
+		return nil, ErrOrgRoleForbidden
+	}
+
+	var membership *models.OrganizationMembership
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		org, err := s.loadOrganization(tx, orgID)
+		if err != nil {
+			return err
+		}
+
+		globalAdmin, err := isGlobalAdminTx(tx, actor.UserID)
+		if err != nil {
+			return err
+		}
+
+		userID, err := resolveMemberTx(tx, req)
+		if !globalAdmin {
+			// Se responde lo mismo exista o no la cuenta para no revelar usuarios de otros tenants
+			if errors.Is(err, ErrUserNotFound) {
+				return ErrOrgInvitationRequired
+			}
+			if err == nil {
+				err = checkTenantUserTx(tx, org, userID)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.addMemberTx(tx, actor, org, userID, req.Role); err != nil {
+			return err
+		}
+		membership = &models.OrganizationMembership{OrgID: org.ID, UserID: userID, Role: req.Role}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrOrgMemberExists
+		}
+		if errors.Is(err, ErrOrganizationNotFound) || errors.Is(err, ErrUserNotFound) || errors.Is(err, ErrOrgInvitationRequired) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to add organization member: %w", err)
+	}
+	s.notifyMembersChanged(ctx, membership.UserID)
+	return membership, nil
+}
+
+// UpdateMemberRole cambia el rol de un miembro; la organización nunca se queda sin owner
+func (s *OrganizationService) UpdateMemberRole(ctx context.Context, actor AdminActor, actorRole, orgID, userID string, req *models.UpdateOrganizationMemberRequest) (*models.OrganizationMembership, error) {
+	var membership models.OrganizationMembership
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		previous, err := s.lockMembership(tx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if !canManageOrgRole(actorRole, previous.Role) || !canManageOrgRole(actorRole, req.Role) {
+			return ErrOrgRoleForbidden
+		}
+		if previous.Role == models.OrgRoleOwner && req.Role != models.OrgRoleOwner {
+			if err := s.checkOtherOwnersTx(tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+
+		membership = *previous
+		membership.Role = req.Role
+		if err := tx.Model(&membership).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return s.recordMemberChange(tx, actor, models.AuditActionOrgMemberUpdated, &membership, previous.Role)
+	})
+	if err != nil {
+		if isOrgMembershipError(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to update organization member: %w", err)
+	}
+	s.notifyMembersChanged(ctx, userID)
+	return &membership, nil
+}
+
+// RemoveMember saca al usuario de la organización; su cuenta no se modifica
+func (s *OrganizationService) RemoveMember(ctx context.Context, actor AdminActor, actorRole, orgID, userID string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		membership, err := s.lockMembership(tx, orgID, userID)
+		if err != nil {
+			return err
+		}
+		if !canManageOrgRole(actorRole, membership.Role) {
+			return ErrOrgRoleForbidden
+		}
+		if membership.Role == models.OrgRoleOwner {
+			if err := s.checkOtherOwnersTx(tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(membership).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ? AND active_org_id = ?", userID, orgID).
+			Update("active_org_id", nil).Error; err != nil {
+			return err
+		}
+		return s.recordMemberChange(tx, actor, models.AuditActionOrgMemberRemoved, membership, membership.Role)
+	})
+	if err != nil {
+		if isOrgMembershipError(err) {
+			return err
+		}
+		return fmt.Errorf("failed to remove organization member: %w", err)
+	}
+	s.notifyMembersChanged(ctx, userID)
+	return nil
+}
+
+// notifyMembersChanged avisa a los listeners de usuario (custom claims de Firebase,
+// caché de permisos) de que ha cambiado la organización o el rol de estas cuentas
+func (s *OrganizationService) notifyMembersChanged(ctx context.Context, userIDs ...string) {
+	for _, userID := range userIDs {
+		user, err := s.users.GetUserByID(ctx, userID)
+		if err != nil {
+			s.logger.WithError(err).WithField("user_id", userID).Warn("Failed to notify organization membership change")
+			continue
+		}
+		s.users.notifyChanged(ctx, user)
+	}
+}
+
+// resolveMemberTx devuelve el ID de la cuenta indicada por ID o por email
+func resolveMemberTx(tx *gorm.DB, req *models.AddOrganizationMemberRequest) (string, error) {
+	var user models.User
+	query := tx.Select("id").Where("status NOT IN ?", models.DeletedStatuses)
+	if req.UserID != "" {
+		query = query.Where("id = ?", req.UserID)
+	} else {
+		query = whereEmailOwner(query, strings.ToLower(strings.TrimSpace(req.Email)))
+	}
+	if err := query.First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+	return user.ID, nil
+}
+
+func isGlobalAdminTx(tx *gorm.DB, userID string) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.User{}).Where("id = ? AND role = ?", userID, models.RoleAdmin).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// checkTenantUserTx comprueba que la cuenta pertenece al tenant de la organización:
+// la aprovisionó su IdP por SCIM (el tenant SCIM es el slug de la organización)
+func checkTenantUserTx(tx *gorm.DB, org *models.Organization, userID string) error {
+	var count int64
+	if err := tx.Model(&models.SCIMUserLink{}).Where("tenant_id = ? AND user_id = ?", org.Slug, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrOrgInvitationRequired
+	}
+	return nil
+}
+
+func (s *OrganizationService) loadOrganization(tx *gorm.DB, orgID string) (*models.Organization, error) {
+	var org models.Organization
+	if err := tx.Where("id = ?", orgID).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &org, nil
+}
+
+func (s *OrganizationService) lockMembership(tx *gorm.DB, orgID, userID string) (*models.OrganizationMembership, error) {
+	if _, err := s.loadOrganization(tx, orgID); err != nil {
+		return nil, err
+	}
+
+	var membership models.OrganizationMembership
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("org_id = ? AND user_id = ?", orgID, userID).
+		First(&membership).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrgMember
+		}
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// checkOtherOwnersTx bloquea los owners de la organización para que dos cambios
+// concurrentes no puedan dejarla sin ninguno
+func (s *OrganizationService) checkOtherOwnersTx(tx *gorm.DB, orgID, userID string) error {
+	var owners []*models.OrganizationMembership
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("org_id = ? AND role = ? AND user_id <> ?", orgID, models.OrgRoleOwner, userID).
+		Find(&owners).Error; err != nil {
+		return err
+	}
+	if len(owners) == 0 {
+		return ErrLastOrgOwner
+	}
+	return nil
+}
+
+func (s *OrganizationService) addMemberTx(tx *gorm.DB, actor AdminActor, org *models.Organization, userID, role string) error {
+	var count int64
+	if err := tx.Model(&models.User{}).Where("id = ? AND status NOT IN ?", userID, models.DeletedStatuses).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUserNotFound
+	}
+
+	membership := &models.OrganizationMembership{OrgID: org.ID, UserID: userID, Role: role}
+	if err := tx.Create(membership).Error; err != nil {
+		return err
+	}
+	return s.recordMemberChange(tx, actor, models.AuditActionOrgMemberAdded, membership, "")
+}
+
+func (s *OrganizationService) recordOrgChange(tx *gorm.DB, actor AdminActor, action string, org *models.Organization) error {
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		ActorID:   actor.UserID,
+		Action:    action,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details: models.JSONMap{
+			"org_id":          org.ID,
+			"slug":            org.Slug,
+			"name":            org.Name,
+			"dormancy_exempt": org.DormancyExempt,
+			"require_mfa":     org.RequireMFA,
+		},
+	})
+}
+
+func (s *OrganizationService) recordMemberChange(tx *gorm.DB, actor AdminActor, action string, membership *models.OrganizationMembership, previousRole string) error {
+	details := models.JSONMap{
+		"org_id": membership.OrgID,
+		"role":   membership.Role,
+	}
+	if previousRole != "" && previousRole != membership.Role {
+		details["previous_role"] = previousRole
+	}
+	return s.audit.RecordTx(tx, &models.AuditLog{
+		UserID:    membership.UserID,
+		ActorID:   actor.UserID,
+		Action:    action,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details:   details,
+	})
+}
+
+// joinTenantOrganizationTx añade al usuario como miembro de la organización cuyo slug
+// coincide con el tenant que lo aprovisiona. No hace nada si no existe o ya es miembro.
+func joinTenantOrganizationTx(tx *gorm.DB, tenantID, userID string) error {
+	return tx.Exec(`INSERT INTO organization_memberships (org_id, user_id, role, created_at, updated_at)
+		SELECT id, ?, ?, now(), now() FROM organizations WHERE slug = ?
+		ON CONFLICT DO NOTHING`, userID, models.OrgRoleMember, tenantID).Error
+}
+
+// leaveTenantOrganizationTx quita la membresía de la organización del tenant que
+// desaprovisiona al usuario
+func leaveTenantOrganizationTx(tx *gorm.DB, tenantID, userID string) error {
+	orgIDs := tx.Model(&models.Organization{}).Select("id").Where("slug = ?", tenantID)
+	if err := tx.Model(&models.User{}).Where("id = ? AND active_org_id IN (?)", userID, orgIDs).
+		Update("active_org_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ? AND org_id IN (?)", userID, orgIDs).
+		Delete(&models.OrganizationMembership{}).Error
+}
+
+// canManageOrgRole indica si quien tiene actorRole puede asignar, cambiar o quitar el
+// rol target: los owners gestionan cualquier rol y los admins sólo los que no son owner
+func canManageOrgRole(actorRole, targetRole string) bool {
+	switch actorRole {
+	case models.OrgRoleOwner:
+		return true
+	case models.OrgRoleAdmin:
+		return targetRole == models.OrgRoleAdmin || targetRole == models.OrgRoleMember
+	}
+	return false
+}
+
+func isOrgMembershipError(err error) bool {
+	return errors.Is(err, ErrOrganizationNotFound) ||
+		errors.Is(err, ErrNotOrgMember) ||
+		errors.Is(err, ErrOrgRoleForbidden) ||
+		errors.Is(err, ErrLastOrgOwner)
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"it-auth-service/internal/models"
+)
+
+func TestCanManageOrgRole(t *testing.T) {
+	for _, role := range []string{models.OrgRoleOwner, models.OrgRoleAdmin, models.OrgRoleMember} {
+		assert.True(t, canManageOrgRole(models.OrgRoleOwner, role), role)
+		assert.False(t, canManageOrgRole(models.OrgRoleMember, role), role)
+		assert.False(t, canManageOrgRole("", role), role)
+	}
+
+	assert.True(t, canManageOrgRole(models.OrgRoleAdmin, models.OrgRoleAdmin))
+	assert.True(t, canManageOrgRole(models.OrgRoleAdmin, models.OrgRoleMember))
+	assert.False(t, canManageOrgRole(models.OrgRoleAdmin, models.OrgRoleOwner))
+}
+
+func TestOrgSlugPattern(t *testing.T) {
+	for _, slug := range []string{"acme", "acme-corp", "a", "team42"} {
+		assert.True(t, orgSlugPattern.MatchString(slug), slug)
+	}
+	for _, slug := range []string{"", "-acme", "acme-", "Acme", "acme corp", "acme_corp"} {
+		assert.False(t, orgSlugPattern.MatchString(slug), slug)
+	}
+}
+
+func TestAuthContextFromClaims(t *testing.T) {
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	authCtx := authContextFromClaims(map[string]interface{}{
+		"auth_time": float64(authTime.Unix()),
+		"amr":       []interface{}{models.AMRFederated, models.AMROTP, models.AMRMFA},
+		"acr":       models.ACRMultiFactor,
+	})
+	assert.True(t, authTime.Equal(authCtx.AuthTime))
+	assert.Equal(t, []string{models.AMRFederated, models.AMROTP, models.AMRMFA}, authCtx.AMR)
+	assert.Equal(t, models.ACRMultiFactor, authCtx.ACR)
+
+	// Un token sin contexto no puede reemitirse con un nivel mayor que aal1
+	authCtx = authContextFromClaims(map[string]interface{}{})
+	assert.True(t, authCtx.AuthTime.IsZero())
+	assert.Equal(t, models.ACRSingleFactor, authCtx.ACR)
+}
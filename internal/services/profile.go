@@ -0,0 +1,95 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/validator"
+)
+
+var (
+	ErrUsernameTaken       = errors.New("username is already taken")
+	ErrInvalidProfilePatch = errors.New("invalid profile patch")
+)
+
+// profileFieldPermission describe qué puede hacer el usuario con un campo de su perfil
+type profileFieldPermission struct {
+	editable  bool
+	clearable bool // admite null en un merge patch
+}
+
+// profileFieldPermissions es la lista explícita de campos del perfil. Cualquier campo
+// que no aparezca aquí (email, role, status...) se rechaza en los merge patch.
+var profileFieldPermissions = map[string]profileFieldPermission{
+	"username":   {editable: true, clearable: false},
+	"first_name": {editable: true, clearable: true},
+	"last_name":  {editable: true, clearable: true},
+	"photo_url":  {editable: true, clearable: true},
+	"email":      {editable: false},
+	"role":       {editable: false},
+	"status":     {editable: false},
+}
+
+// ParseProfileMergePatch interpreta un JSON Merge Patch (RFC 7396) sobre el perfil:
+// los miembros ausentes no cambian y null borra el valor. El resultado ya está validado.
+func ParseProfileMergePatch(body []byte) (*models.UpdateProfileRequest, error) {
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, fmt.Errorf("%w: body must be a JSON object", ErrInvalidProfilePatch)
+	}
+
+	req := &models.UpdateProfileRequest{}
+	targets := map[string]**string{
+		"username":   &req.Username,
+		"first_name": &req.FirstName,
+		"last_name":  &req.LastName,
+		"photo_url":  &req.PhotoURL,
+	}
+
+	for field, raw := range patch {
+		permission, known := profileFieldPermissions[field]
+		if !known {
+			return nil, fmt.Errorf("%w: unknown field '%s'", ErrInvalidProfilePatch, field)
+		}
+		if !permission.editable {
+			return nil, fmt.Errorf("%w: field '%s' is read-only", ErrInvalidProfilePatch, field)
+		}
+
+		var value string
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			if !permission.clearable {
+				return nil, fmt.Errorf("%w: field '%s' cannot be removed", ErrInvalidProfilePatch, field)
+			}
+		} else if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("%w: field '%s' must be a string", ErrInvalidProfilePatch, field)
+		}
+		*targets[field] = &value
+	}
+
+	if err := validator.ValidateStruct(req); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfilePatch, err)
+	}
+
+	return req, nil
+}
+
+// profileUpdates traduce la petición a columnas, dejando fuera los campos no enviados
+func profileUpdates(req *models.UpdateProfileRequest) map[string]interface{} {
+	updates := make(map[string]interface{})
+	if req.Username != nil {
+		updates["username"] = *req.Username
+	}
+	if req.FirstName != nil {
+		updates["first_name"] = *req.FirstName
+	}
+	if req.LastName != nil {
+		updates["last_name"] = *req.LastName
+	}
+	if req.PhotoURL != nil {
+		updates["photo_url"] = *req.PhotoURL
+	}
+	return updates
+}
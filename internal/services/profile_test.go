@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProfileMergePatch(t *testing.T) {
+	req, err := ParseProfileMergePatch([]byte(`{"first_name":"Ana","photo_url":null}`))
+	require.NoError(t, err)
+
+	require.NotNil(t, req.FirstName)
+	assert.Equal(t, "Ana", *req.FirstName)
+	require.NotNil(t, req.PhotoURL)
+	assert.Equal(t, "", *req.PhotoURL)
+	assert.Nil(t, req.Username)
+	assert.Nil(t, req.LastName)
+}
+
+func TestParseProfileMergePatchRejectsInvalidFields(t *testing.T) {
+	cases := map[string]string{
+		"read-only field":     `{"email":"a@b.com"}`,
+		"unknown field":       `{"nickname":"x"}`,
+		"null username":       `{"username":null}`,
+		"non-string value":    `{"first_name":42}`,
+		"invalid username":    `{"username":"a b"}`,
+		"invalid photo URL":   `{"photo_url":"javascript:alert(1)"}`,
+		"not a JSON object":   `["username"]`,
+		"first name too long": `{"first_name":"` + strings.Repeat("a", 101) + `"}`,
+	}
+
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseProfileMergePatch([]byte(body))
+			assert.True(t, errors.Is(err, ErrInvalidProfilePatch), "got %v", err)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"it-auth-service/internal/models"
+)
+
+// scimFilterClause es una comparación "atributo operador valor" de un filtro SCIM
+type scimFilterClause struct {
+	Attr  string // en minúsculas, sin el prefijo del esquema
+	Op    string // eq, co o sw
+	Value interface{}
+}
+
+// scimFilterColumn indica cómo se traduce un atributo SCIM a SQL
+type scimFilterColumn struct {
+	expression string
+	caseExact  bool
+	// activeStatus indica que el atributo es el booleano active, derivado de users.status
+	activeStatus bool
+}
+
+// parseSCIMFilter interpreta el subconjunto de filtros SCIM (RFC 7644 §3.4.2.2) que
+// soporta el servicio: comparaciones eq, co y sw unidas con "and"
+func parseSCIMFilter(filter string, schema string) ([]scimFilterClause, error) {
+	tokens, err := tokenizeSCIMFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	var clauses []scimFilterClause
+	for i := 0; i < len(tokens); {
+		if len(tokens)-i < 3 {
+			return nil, fmt.Errorf("%w: incomplete expression", ErrSCIMInvalidFilter)
+		}
+
+		attr := normalizeSCIMAttr(tokens[i].text, schema)
+		op := strings.ToLower(tokens[i+1].text)
+		if tokens[i].quoted || tokens[i+1].quoted {
+			return nil, fmt.Errorf("%w: unexpected string", ErrSCIMInvalidFilter)
+		}
+		switch op {
+		case "eq", "co", "sw":
+		default:
+			return nil, fmt.Errorf("%w: unsupported operator %q", ErrSCIMInvalidFilter, tokens[i+1].text)
+		}
+
+		value, err := tokens[i+2].value()
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, scimFilterClause{Attr: attr, Op: op, Value: value})
+		i += 3
+
+		if i < len(tokens) {
+			if tokens[i].quoted || !strings.EqualFold(tokens[i].text, "and") {
+				return nil, fmt.Errorf("%w: only 'and' is supported between expressions", ErrSCIMInvalidFilter)
+			}
+			i++
+			if i == len(tokens) {
+				return nil, fmt.Errorf("%w: dangling 'and'", ErrSCIMInvalidFilter)
+			}
+		}
+	}
+
+	return clauses, nil
+}
+
+type scimFilterToken struct {
+	text   string
+	quoted bool
+}
+
+func (t scimFilterToken) value() (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported value %q", ErrSCIMInvalidFilter, t.text)
+}
+
+func tokenizeSCIMFilter(filter string) ([]scimFilterToken, error) {
+	var tokens []scimFilterToken
+	runes := []rune(filter)
+
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '[' || r == ']':
+			return nil, fmt.Errorf("%w: grouping is not supported", ErrSCIMInvalidFilter)
+		case r == '"':
+			// Los valores son cadenas JSON: se busca la comilla de cierre respetando escapes
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				if runes[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrSCIMInvalidFilter)
+			}
+			var text string
+			if err := json.Unmarshal([]byte(string(runes[i:end+1])), &text); err != nil {
+				return nil, fmt.Errorf("%w: invalid string", ErrSCIMInvalidFilter)
+			}
+			tokens = append(tokens, scimFilterToken{text: text, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			tokens = append(tokens, scimFilterToken{text: string(runes[i:end])})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+// normalizeSCIMAttr pasa el atributo a minúsculas y quita el prefijo URN del esquema
+func normalizeSCIMAttr(attr, schema string) string {
+	lower := strings.ToLower(attr)
+	if prefix := strings.ToLower(schema) + ":"; strings.HasPrefix(lower, prefix) {
+		lower = strings.TrimPrefix(lower, prefix)
+	}
+	return lower
+}
+
+// applySCIMFilter añade las condiciones del filtro a la consulta
+func applySCIMFilter(db *gorm.DB, clauses []scimFilterClause, columns map[string]scimFilterColumn) (*gorm.DB, error) {
+	for _, clause := range clauses {
+		column, ok := columns[clause.Attr]
+		if !ok {
+			return nil, fmt.Errorf("%w: filtering by %q is not supported", ErrSCIMInvalidFilter, clause.Attr)
+		}
+
+		if column.activeStatus {
+			active, ok := clause.Value.(bool)
+			if !ok || clause.Op != "eq" {
+				return nil, fmt.Errorf("%w: %q only supports 'eq' with a boolean", ErrSCIMInvalidFilter, clause.Attr)
+			}
+			if active {
+				db = db.Where(column.expression+" = ?", models.StatusActive)
+			} else {
+				db = db.Where(column.expression+" <> ?", models.StatusActive)
+			}
+			continue
+		}
+
+		value, ok := clause.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q must be compared with a string", ErrSCIMInvalidFilter, clause.Attr)
+		}
+
+		like := "ILIKE"
+		if column.caseExact {
+			like = "LIKE"
+		}
+		switch clause.Op {
+		case "eq":
+			if column.caseExact {
+				db = db.Where(column.expression+" = ?", value)
+			} else {
+				db = db.Where("LOWER("+column.expression+") = LOWER(?)", value)
+			}
+		case "co":
+			db = db.Where(column.expression+" "+like+" ?", "%"+escapeLike(value)+"%")
+		case "sw":
+			db = db.Where(column.expression+" "+like+" ?", escapeLike(value)+"%")
+		}
+	}
+	return db, nil
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/models"
+)
+
+// Los grupos SCIM son los grupos de usuarios del tenant (models.UserGroup): displayName
+// es su nombre, y los roles asignados al grupo se aplican a los miembros aprovisionados
+var scimGroupFilterColumns = map[string]scimFilterColumn{
+	"id":          {expression: "COALESCE(user_groups.scim_id, user_groups.id)::text", caseExact: true},
+	"displayname": {expression: "user_groups.name"},
+	"externalid":  {expression: "user_groups.external_id", caseExact: true},
+}
+
+// scimGroupMemberRow es un miembro de grupo con el email que se muestra como display
+type scimGroupMemberRow struct {
+	GroupID string
+	UserID  string
+	Email   string
+}
+
+// ListGroups busca los grupos del tenant con un filtro SCIM y paginación por índice
+func (s *SCIMService) ListGroups(ctx context.Context, caller SCIMCaller, query *models.SCIMListQuery) (*models.SCIMListResponse, error) {
+	clauses, err := parseSCIMFilter(query.Filter, models.SCIMSchemaGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx).Model(&models.UserGroup{}).Where("tenant_id = ?", caller.tenantID())
+	db, err = applySCIMFilter(db, clauses, scimGroupFilterColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	startIndex, count := scimPage(query)
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count groups: %w", err)
+	}
+
+	resources := []*models.SCIMGroupResource{}
+	if count > 0 {
+		var groups []*models.UserGroup
+		if err := db.Session(&gorm.Session{}).
+			Order("created_at ASC, id ASC").
+			Offset(startIndex - 1).
+			Limit(count).
+			Find(&groups).Error; err != nil {
+			return nil, fmt.Errorf("failed to list groups: %w", err)
+		}
+
+		members, err := s.groupMembers(ctx, groups...)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			resources = append(resources, toSCIMGroup(group, members[group.ID]))
+		}
+	}
+
+	return scimListResponse(total, startIndex, len(resources), resources), nil
+}
+
+// GetGroup devuelve un grupo del tenant con sus miembros
+func (s *SCIMService) GetGroup(ctx context.Context, caller SCIMCaller, groupID string) (*models.SCIMGroupResource, error) {
+	group, err := s.loadGroup(s.db.WithContext(ctx), caller.tenantID(), groupID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.groupMembers(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMGroup(group, members[group.ID]), nil
+}
+
+// CreateGroup crea un grupo; los miembros deben ser usuarios aprovisionados por el tenant
+func (s *SCIMService) CreateGroup(ctx context.Context, caller SCIMCaller, resource *models.SCIMGroupResource) (*models.SCIMGroupResource, error) {
+	if resource.DisplayName == "" {
+		return nil, fmt.Errorf("%w: displayName is required", ErrSCIMInvalidValue)
+	}
+
+	group := &models.UserGroup{
+		TenantID:   caller.tenantID(),
+		Name:       resource.DisplayName,
+		ExternalID: resource.ExternalID,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		if err := s.groups.recordGroupChange(tx, caller.actor(), "created", group, "", nil); err != nil {
+			return err
+		}
+		return s.syncGroupMembers(tx, caller, group, resource.Members)
+	})
+	if err != nil {
+		return nil, s.groupWriteError(err)
+	}
+	s.groups.invalidateAll()
+
+	return s.GetGroup(ctx, caller, group.ID)
+}
+
+// ReplaceGroup sustituye el grupo por la representación recibida (PUT)
+func (s *SCIMService) ReplaceGroup(ctx context.Context, caller SCIMCaller, groupID, ifMatch string, resource *models.SCIMGroupResource) (*models.SCIMGroupResource, error) {
+	return s.modifyGroup(ctx, caller, groupID, ifMatch, func(*models.SCIMGroupResource) (*models.SCIMGroupResource, error) {
+		return resource, nil
+	})
+}
+
+// PatchGroup aplica operaciones PATCH sobre el grupo, típicamente altas y bajas de miembros
+func (s *SCIMService) PatchGroup(ctx context.Context, caller SCIMCaller, groupID, ifMatch string, operations []models.SCIMPatchOperation) (*models.SCIMGroupResource, error) {
+	return s.modifyGroup(ctx, caller, groupID, ifMatch, func(current *models.SCIMGroupResource) (*models.SCIMGroupResource, error) {
+		if err := applySCIMGroupPatch(current, operations); err != nil {
+			return nil, err
+		}
+		return current, nil
+	})
+}
+
+// DeleteGroup elimina el grupo con sus pertenencias, anidamientos y roles
+func (s *SCIMService) DeleteGroup(ctx context.Context, caller SCIMCaller, groupID, ifMatch string) error {
+	if ifMatch != "" {
+		current, err := s.GetGroup(ctx, caller, groupID)
+		if err != nil {
+			return err
+		}
+		if err := checkSCIMVersion(ifMatch, current); err != nil {
+			return err
+		}
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group, err := s.loadGroup(tx, caller.tenantID(), groupID)
+		if err != nil {
+			return err
+		}
+		if err := deleteGroupTx(tx, group); err != nil {
+			return err
+		}
+		return s.groups.recordGroupChange(tx, caller.actor(), "deleted", group, "", nil)
+	})
+	if err != nil {
+		return s.groupWriteError(err)
+	}
+	s.groups.invalidateAll()
+	return nil
+}
+
+// modifyGroup serializa las modificaciones del grupo, comprueba If-Match sobre la
+// versión bloqueada y persiste la representación resultante de change
+func (s *SCIMService) modifyGroup(ctx context.Context, caller SCIMCaller, groupID, ifMatch string, change func(current *models.SCIMGroupResource) (*models.SCIMGroupResource, error)) (*models.SCIMGroupResource, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		group, err := s.loadGroup(tx.Clauses(clause.Locking{Strength: "UPDATE"}), caller.tenantID(), groupID)
+		if err != nil {
+			return err
+		}
+		members, err := s.groupMembersTx(tx, group)
+		if err != nil {
+			return err
+		}
+
+		current := toSCIMGroup(group, members[group.ID])
+		if err := checkSCIMVersion(ifMatch, current); err != nil {
+			return err
+		}
+
+		desired, err := change(current)
+		if err != nil {
+			return err
+		}
+		if desired.DisplayName == "" {
+			return fmt.Errorf("%w: displayName is required", ErrSCIMInvalidValue)
+		}
+
+		if err := tx.Model(group).Updates(map[string]interface{}{
+			"name":        desired.DisplayName,
+			"external_id": desired.ExternalID,
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		if err := s.groups.recordGroupChange(tx, caller.actor(), "updated", group, "", nil); err != nil {
+			return err
+		}
+		return s.syncGroupMembers(tx, caller, group, desired.Members)
+	})
+	if err != nil {
+		return nil, s.groupWriteError(err)
+	}
+	// Cambian los permisos de los miembros y el nombre que aparece en el claim groups
+	s.groups.invalidateAll()
+
+	return s.GetGroup(ctx, caller, groupID)
+}
+
+// syncGroupMembers deja en el grupo exactamente los miembros indicados, añadiendo y
+// quitando sólo la diferencia para no reescribir grupos grandes. Cada alta y baja se
+// audita porque concede o retira los roles del grupo.
+func (s *SCIMService) syncGroupMembers(tx *gorm.DB, caller SCIMCaller, group *models.UserGroup, members []models.SCIMMultiValue) error {
+	desired := make(map[string]bool, len(members))
+	for _, member := range members {
+		if member.Value == "" {
+			return fmt.Errorf("%w: member value is required", ErrSCIMInvalidValue)
+		}
+		desired[member.Value] = true
+	}
+
+	var current []string
+	if err := tx.Model(&models.UserGroupMember{}).Where("group_id = ?", group.ID).Pluck("user_id", &current).Error; err != nil {
+		return err
+	}
+
+	var removed []string
+	for _, userID := range current {
+		if desired[userID] {
+			delete(desired, userID)
+		} else {
+			removed = append(removed, userID)
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := tx.Where("group_id = ? AND user_id IN ?", group.ID, removed).Delete(&models.UserGroupMember{}).Error; err != nil {
+			return err
+		}
+		for _, userID := range removed {
+			if err := s.groups.recordGroupChange(tx, caller.actor(), "member_removed", group, userID, nil); err != nil {
+				return err
+			}
+		}
+	}
+	if len(desired) == 0 {
+		return nil
+	}
+
+	added := make([]string, 0, len(desired))
+	for userID := range desired {
+		added = append(added, userID)
+	}
+
+	// Sólo se admiten usuarios aprovisionados por el mismo tenant
+	var known int64
+	if err := tx.Model(&models.SCIMUserLink{}).
+		Where("tenant_id = ? AND user_id::text IN ?", group.TenantID, added).
+		Count(&known).Error; err != nil {
+		return err
+	}
+	if int(known) != len(added) {
+		return fmt.Errorf("%w: members must be users provisioned by this tenant", ErrSCIMInvalidValue)
+	}
+
+	rows := make([]models.UserGroupMember, len(added))
+	for i, userID := range added {
+		rows[i] = models.UserGroupMember{GroupID: group.ID, UserID: userID}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return err
+	}
+	for _, userID := range added {
+		if err := s.groups.recordGroupChange(tx, caller.actor(), "member_added", group, userID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SCIMService) loadGroup(db *gorm.DB, tenantID, groupID string) (*models.UserGroup, error) {
+	var group models.UserGroup
+	err := db.Where("tenant_id = ? AND (id::text = ? OR scim_id::text = ?)", tenantID, groupID, groupID).First(&group).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSCIMNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &group, nil
+}
+
+func (s *SCIMService) groupMembers(ctx context.Context, groups ...*models.UserGroup) (map[string][]scimGroupMemberRow, error) {
+	return s.groupMembersTx(s.db.WithContext(ctx), groups...)
+}
+
+func (s *SCIMService) groupMembersTx(db *gorm.DB, groups ...*models.UserGroup) (map[string][]scimGroupMemberRow, error) {
+	ids := make([]string, len(groups))
+	for i, group := range groups {
+		ids[i] = group.ID
+	}
+
+	var rows []scimGroupMemberRow
+	err := db.Table("user_group_members").
+		Select("user_group_members.group_id, user_group_members.user_id, users.email").
+		Joins("JOIN users ON users.id = user_group_members.user_id").
+		Where("user_group_members.group_id IN ?", ids).
+		Order("users.email ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load group members: %w", err)
+	}
+
+	byGroup := make(map[string][]scimGroupMemberRow, len(groups))
+	for _, row := range rows {
+		byGroup[row.GroupID] = append(byGroup[row.GroupID], row)
+	}
+	return byGroup, nil
+}
+
+func (s *SCIMService) groupWriteError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%w: displayName is already in use", ErrSCIMUniqueness)
+	}
+	switch {
+	case errors.Is(err, ErrSCIMNotFound), errors.Is(err, ErrSCIMPreconditionFailed),
+		errors.Is(err, ErrSCIMInvalidValue), errors.Is(err, ErrSCIMInvalidPath),
+		errors.Is(err, ErrSCIMInvalidSyntax), errors.Is(err, ErrSCIMNoTarget),
+		errors.Is(err, ErrSCIMMutability), errors.Is(err, ErrSCIMInvalidFilter):
+		return err
+	}
+	s.logger.WithError(err).Error("Failed to write SCIM group")
+	return fmt.Errorf("failed to write group: %w", err)
+}
+
+func toSCIMGroup(group *models.UserGroup, members []scimGroupMemberRow) *models.SCIMGroupResource {
+	resource := &models.SCIMGroupResource{
+		Schemas:     []string{models.SCIMSchemaGroup},
+		ID:          group.ID,
+		ExternalID:  group.ExternalID,
+		DisplayName: group.Name,
+	}
+	// Un grupo fusionado durante la migración conserva el id que guarda el IdP
+	if group.SCIMID != nil {
+		resource.ID = *group.SCIMID
+	}
+	for _, member := range members {
+		resource.Members = append(resource.Members, models.SCIMMultiValue{
+			Value:   member.UserID,
+			Display: member.Email,
+		})
+	}
+
+	created := group.CreatedAt
+	modified := group.UpdatedAt
+	resource.Meta = &models.SCIMMeta{
+		ResourceType: "Group",
+		Created:      &created,
+		LastModified: &modified,
+		Version:      scimVersion(resource),
+	}
+	return resource
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"it-auth-service/internal/models"
+)
+
+// applySCIMUserPatch aplica las operaciones de un PATCH SCIM (RFC 7644 §3.5.2) sobre
+// la representación actual del usuario. Los atributos de extensiones que el servicio
+// no anuncia se ignoran para no romper la sincronización de los IdP que los envían.
+func applySCIMUserPatch(user *models.SCIMUserResource, operations []models.SCIMPatchOperation) error {
+	return applySCIMPatch(operations, func(op, path string, value json.RawMessage) error {
+		return patchSCIMUserAttribute(user, op, path, value)
+	})
+}
+
+// applySCIMGroupPatch aplica las operaciones de un PATCH SCIM sobre un grupo
+func applySCIMGroupPatch(group *models.SCIMGroupResource, operations []models.SCIMPatchOperation) error {
+	return applySCIMPatch(operations, func(op, path string, value json.RawMessage) error {
+		return patchSCIMGroupAttribute(group, op, path, value)
+	})
+}
+
+func applySCIMPatch(operations []models.SCIMPatchOperation, apply func(op, path string, value json.RawMessage) error) error {
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		switch op {
+		case "add", "replace", "remove":
+		default:
+			return fmt.Errorf("%w: unsupported operation %q", ErrSCIMInvalidSyntax, operation.Op)
+		}
+
+		if operation.Path != "" {
+			if err := apply(op, operation.Path, operation.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Sin path, el valor es un objeto con los atributos a modificar
+		if op == "remove" {
+			return fmt.Errorf("%w: remove requires a path", ErrSCIMNoTarget)
+		}
+		var attributes map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+			return fmt.Errorf("%w: value must be an object when path is omitted", ErrSCIMInvalidValue)
+		}
+		for path, value := range attributes {
+			if err := apply(op, path, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func patchSCIMUserAttribute(user *models.SCIMUserResource, op, path string, raw json.RawMessage) error {
+	attr, _, sub := splitSCIMValuePath(path)
+	attr = normalizeSCIMAttr(attr, models.SCIMSchemaUser)
+	if sub != "" {
+		attr += "." + strings.ToLower(sub)
+	}
+	remove := op == "remove"
+
+	switch attr {
+	case "username":
+		if remove {
+			return fmt.Errorf("%w: userName is required", ErrSCIMMutability)
+		}
+		return decodeSCIMString(raw, &user.UserName)
+	case "externalid":
+		if remove {
+			user.ExternalID = ""
+			return nil
+		}
+		return decodeSCIMString(raw, &user.ExternalID)
+	case "displayname":
+		if remove {
+			user.DisplayName = ""
+			return nil
+		}
+		return decodeSCIMString(raw, &user.DisplayName)
+	case "active":
+		if remove {
+			return fmt.Errorf("%w: active cannot be removed", ErrSCIMMutability)
+		}
+		active, err := decodeSCIMBool(raw)
+		if err != nil {
+			return err
+		}
+		user.Active = &active
+		return nil
+	case "name":
+		if remove {
+			user.Name = nil
+			return nil
+		}
+		var name map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &name); err != nil {
+			return fmt.Errorf("%w: name must be an object", ErrSCIMInvalidValue)
+		}
+		for key, value := range name {
+			if err := patchSCIMUserAttribute(user, op, "name."+key, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "name.givenname", "name.familyname", "name.formatted":
+		if user.Name == nil {
+			user.Name = &models.SCIMName{}
+		}
+		target := map[string]*string{
+			"name.givenname":  &user.Name.GivenName,
+			"name.familyname": &user.Name.FamilyName,
+			"name.formatted":  &user.Name.Formatted,
+		}[attr]
+		if remove {
+			*target = ""
+			return nil
+		}
+		return decodeSCIMString(raw, target)
+	case "emails", "emails.value":
+		if remove {
+			return fmt.Errorf("%w: an email is required", ErrSCIMMutability)
+		}
+		emails, err := decodeSCIMMultiValue(raw, attr == "emails.value")
+		if err != nil {
+			return err
+		}
+		user.Emails = emails
+		return nil
+	case "photos", "photos.value":
+		if remove {
+			user.Photos = nil
+			return nil
+		}
+		photos, err := decodeSCIMMultiValue(raw, attr == "photos.value")
+		if err != nil {
+			return err
+		}
+		user.Photos = photos
+		return nil
+	}
+
+	if strings.HasPrefix(attr, "urn:") {
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported attribute %q", ErrSCIMInvalidPath, path)
+}
+
+func patchSCIMGroupAttribute(group *models.SCIMGroupResource, op, path string, raw json.RawMessage) error {
+	attr, filter, _ := splitSCIMValuePath(path)
+	attr = normalizeSCIMAttr(attr, models.SCIMSchemaGroup)
+	remove := op == "remove"
+
+	switch attr {
+	case "displayname":
+		if remove {
+			return fmt.Errorf("%w: displayName is required", ErrSCIMMutability)
+		}
+		return decodeSCIMString(raw, &group.DisplayName)
+	case "externalid":
+		if remove {
+			group.ExternalID = ""
+			return nil
+		}
+		return decodeSCIMString(raw, &group.ExternalID)
+	case "members":
+		if filter != "" {
+			// members[value eq "id"]: sólo tiene sentido para quitar un miembro concreto
+			clauses, err := parseSCIMFilter(filter, models.SCIMSchemaGroup)
+			if err != nil || len(clauses) != 1 || clauses[0].Attr != "value" || clauses[0].Op != "eq" || !remove {
+				return fmt.Errorf("%w: unsupported members filter %q", ErrSCIMInvalidPath, filter)
+			}
+			id, _ := clauses[0].Value.(string)
+			group.Members = withoutSCIMMembers(group.Members, map[string]bool{id: true})
+			return nil
+		}
+
+		var members []models.SCIMMultiValue
+		if len(bytes.TrimSpace(raw)) > 0 {
+			decoded, err := decodeSCIMMultiValue(raw, false)
+			if err != nil {
+				return err
+			}
+			members = decoded
+		}
+
+		switch op {
+		case "replace":
+			group.Members = members
+		case "add":
+			present := make(map[string]bool, len(group.Members))
+			for _, member := range group.Members {
+				present[member.Value] = true
+			}
+			for _, member := range members {
+				if !present[member.Value] {
+					group.Members = append(group.Members, member)
+					present[member.Value] = true
+				}
+			}
+		case "remove":
+			if len(members) == 0 {
+				group.Members = nil
+				return nil
+			}
+			ids := make(map[string]bool, len(members))
+			for _, member := range members {
+				ids[member.Value] = true
+			}
+			group.Members = withoutSCIMMembers(group.Members, ids)
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(attr, "urn:") {
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported attribute %q", ErrSCIMInvalidPath, path)
+}
+
+// splitSCIMValuePath separa una ruta como emails[type eq "work"].value en el
+// atributo, el filtro entre corchetes y el subatributo
+func splitSCIMValuePath(path string) (attr, filter, sub string) {
+	open := strings.Index(path, "[")
+	closing := strings.LastIndex(path, "]")
+	if open < 0 || closing < open {
+		return path, "", ""
+	}
+	attr = path[:open]
+	filter = path[open+1 : closing]
+	sub = strings.TrimPrefix(path[closing+1:], ".")
+	return attr, filter, sub
+}
+
+func withoutSCIMMembers(members []models.SCIMMultiValue, ids map[string]bool) []models.SCIMMultiValue {
+	kept := members[:0:0]
+	for _, member := range members {
+		if !ids[member.Value] {
+			kept = append(kept, member)
+		}
+	}
+	return kept
+}
+
+// decodeSCIMString acepta una cadena o, como envían algunos IdP, una lista con un
+// único objeto {"value": ...}
+func decodeSCIMString(raw json.RawMessage, target *string) error {
+	if err := json.Unmarshal(raw, target); err == nil {
+		return nil
+	}
+	var values []models.SCIMMultiValue
+	if err := json.Unmarshal(raw, &values); err == nil && len(values) == 1 {
+		*target = values[0].Value
+		return nil
+	}
+	return fmt.Errorf("%w: expected a string", ErrSCIMInvalidValue)
+}
+
+// decodeSCIMBool acepta booleanos JSON y también "True"/"False" como cadena
+func decodeSCIMBool(raw json.RawMessage) (bool, error) {
+	var value bool
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		switch strings.ToLower(text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("%w: expected a boolean", ErrSCIMInvalidValue)
+}
+
+// decodeSCIMMultiValue decodifica una lista de valores, un único objeto o, si
+// valueOnly es true (rutas como emails[...].value), una cadena suelta
+func decodeSCIMMultiValue(raw json.RawMessage, valueOnly bool) ([]models.SCIMMultiValue, error) {
+	if valueOnly {
+		var value string
+		if err := decodeSCIMString(raw, &value); err != nil {
+			return nil, err
+		}
+		return []models.SCIMMultiValue{{Value: value, Primary: true}}, nil
+	}
+
+	var values []models.SCIMMultiValue
+	if err := json.Unmarshal(raw, &values); err == nil {
+		return values, nil
+	}
+	var single models.SCIMMultiValue
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []models.SCIMMultiValue{single}, nil
+	}
+	return nil, fmt.Errorf("%w: expected a list of values", ErrSCIMInvalidValue)
+}
@@ -0,0 +1,139 @@
+package services
+
+import "it-auth-service/internal/models"
+
+// Documentos de descubrimiento SCIM (RFC 7643 §5-7). Describen exactamente lo que
+// implementa el servicio para que el IdP no intente usar funciones no soportadas.
+
+// SCIMServiceProviderConfig describe las capacidades del servidor SCIM
+func SCIMServiceProviderConfig(baseURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"schemas":          []string{models.SCIMSchemaServiceProviderConfig},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            map[string]interface{}{"supported": true},
+		"bulk":             map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]interface{}{"supported": true, "maxResults": scimMaxPageSize},
+		"changePassword":   map[string]interface{}{"supported": false},
+		"sort":             map[string]interface{}{"supported": false},
+		"etag":             map[string]interface{}{"supported": true},
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer Token",
+			"description": "Per-tenant SCIM bearer token issued by an administrator",
+			"primary":     true,
+		}},
+		"meta": map[string]interface{}{
+			"resourceType": "ServiceProviderConfig",
+			"location":     baseURL + "/ServiceProviderConfig",
+		},
+	}
+}
+
+// SCIMResourceTypes describe los tipos de recurso expuestos
+func SCIMResourceTypes(baseURL string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"schemas":     []string{models.SCIMSchemaResourceType},
+			"id":          "User",
+			"name":        "User",
+			"endpoint":    "/Users",
+			"description": "User Account",
+			"schema":      models.SCIMSchemaUser,
+			"meta": map[string]interface{}{
+				"resourceType": "ResourceType",
+				"location":     baseURL + "/ResourceTypes/User",
+			},
+		},
+		{
+			"schemas":     []string{models.SCIMSchemaResourceType},
+			"id":          "Group",
+			"name":        "Group",
+			"endpoint":    "/Groups",
+			"description": "Group",
+			"schema":      models.SCIMSchemaGroup,
+			"meta": map[string]interface{}{
+				"resourceType": "ResourceType",
+				"location":     baseURL + "/ResourceTypes/Group",
+			},
+		},
+	}
+}
+
+// SCIMSchemas describe los atributos soportados de User y Group
+func SCIMSchemas(baseURL string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"schemas":     []string{models.SCIMSchemaSchema},
+			"id":          models.SCIMSchemaUser,
+			"name":        "User",
+			"description": "User Account",
+			"attributes": []map[string]interface{}{
+				scimAttribute("userName", "string", true, "server"),
+				scimAttribute("externalId", "string", false, "none"),
+				scimComplexAttribute("name", false, []map[string]interface{}{
+					scimAttribute("formatted", "string", false, "none"),
+					scimAttribute("givenName", "string", false, "none"),
+					scimAttribute("familyName", "string", false, "none"),
+				}),
+				scimAttribute("displayName", "string", false, "none"),
+				scimComplexAttribute("emails", true, []map[string]interface{}{
+					scimAttribute("value", "string", true, "server"),
+					scimAttribute("type", "string", false, "none"),
+					scimAttribute("primary", "boolean", false, "none"),
+				}),
+				scimComplexAttribute("photos", true, []map[string]interface{}{
+					scimAttribute("value", "reference", false, "none"),
+					scimAttribute("type", "string", false, "none"),
+				}),
+				scimAttribute("active", "boolean", false, "none"),
+			},
+			"meta": map[string]interface{}{
+				"resourceType": "Schema",
+				"location":     baseURL + "/Schemas/" + models.SCIMSchemaUser,
+			},
+		},
+		{
+			"schemas":     []string{models.SCIMSchemaSchema},
+			"id":          models.SCIMSchemaGroup,
+			"name":        "Group",
+			"description": "Group",
+			"attributes": []map[string]interface{}{
+				scimAttribute("displayName", "string", true, "server"),
+				scimAttribute("externalId", "string", false, "none"),
+				scimComplexAttribute("members", true, []map[string]interface{}{
+					scimAttribute("value", "string", true, "none"),
+					scimAttribute("display", "string", false, "none"),
+				}),
+			},
+			"meta": map[string]interface{}{
+				"resourceType": "Schema",
+				"location":     baseURL + "/Schemas/" + models.SCIMSchemaGroup,
+			},
+		},
+	}
+}
+
+func scimAttribute(name, kind string, required bool, uniqueness string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"type":        kind,
+		"multiValued": false,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  "readWrite",
+		"returned":    "default",
+		"uniqueness":  uniqueness,
+	}
+}
+
+func scimComplexAttribute(name string, multiValued bool, subAttributes []map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":          name,
+		"type":          "complex",
+		"multiValued":   multiValued,
+		"required":      false,
+		"mutability":    "readWrite",
+		"returned":      "default",
+		"subAttributes": subAttributes,
+	}
+}
@@ -0,0 +1,759 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/validator"
+	"it-auth-service/pkg/firebase"
+)
+
+const (
+	scimTokenPrefix      = "scim_"
+	scimTokenBytes       = 32
+	scimDefaultPageSize  = 100
+	scimMaxPageSize      = 200
+	scimLastUsedInterval = time.Minute
+	scimStatusReason     = "Changed by SCIM provisioning"
+)
+
+// Errores SCIM; el handler los traduce a status y scimType (RFC 7644 §3.12)
+var (
+	ErrSCIMInvalidToken       = errors.New("invalid SCIM token")
+	ErrSCIMTokenNotFound      = errors.New("SCIM token not found")
+	ErrSCIMNotFound           = errors.New("resource not found")
+	ErrSCIMUniqueness         = errors.New("resource already exists")
+	ErrSCIMPreconditionFailed = errors.New("resource version does not match")
+	ErrSCIMInvalidFilter      = errors.New("invalid filter")
+	ErrSCIMInvalidSyntax      = errors.New("invalid request syntax")
+	ErrSCIMInvalidValue       = errors.New("invalid attribute value")
+	ErrSCIMInvalidPath        = errors.New("invalid path")
+	ErrSCIMNoTarget           = errors.New("no target for operation")
+	ErrSCIMMutability         = errors.New("attribute cannot be modified")
+)
+
+// SCIMCaller identifica el token (y por tanto el tenant) que hace la petición SCIM
+type SCIMCaller struct {
+	Token     *models.SCIMToken
+	IPAddress string
+	UserAgent string
+}
+
+func (c SCIMCaller) tenantID() string {
+	return c.Token.TenantID
+}
+
+// actor representa al IdP en la auditoría y en las operaciones de administración
+func (c SCIMCaller) actor() AdminActor {
+	return AdminActor{
+		UserID:    "scim:" + c.Token.ID,
+		IPAddress: c.IPAddress,
+		UserAgent: c.UserAgent,
+	}
+}
+
+// scimUserValues son los datos de models.User que se derivan de un recurso SCIM
+type scimUserValues struct {
+	UserName   string `validate:"required,max=320"`
+	ExternalID string `validate:"max=255"`
+	Email      string `validate:"required,email,max=320"`
+	FirstName  string `validate:"max=100"`
+	LastName   string `validate:"max=100"`
+	PhotoURL   string `validate:"omitempty,max=2048,photourl"`
+	Active     bool
+}
+
+var scimUserFilterColumns = map[string]scimFilterColumn{
+	"id":              {expression: "users.id::text", caseExact: true},
+	"username":        {expression: "scim_user_links.user_name"},
+	"externalid":      {expression: "scim_user_links.external_id", caseExact: true},
+	"emails":          {expression: "users.email"},
+	"emails.value":    {expression: "users.email"},
+	"name.givenname":  {expression: "users.first_name"},
+	"name.familyname": {expression: "users.last_name"},
+	"active":          {expression: "users.status", activeStatus: true},
+}
+
+// SCIMService implementa el aprovisionamiento SCIM 2.0 de usuarios y grupos. Cada
+// tenant sólo ve y modifica los recursos que ha aprovisionado con sus tokens.
+type SCIMService struct {
+	db           *gorm.DB
+	users        *UserService
+	admin        *UserAdminService
+	identities   *IdentityService
+	audit        *AuditService
+	groups       *GroupService
+	lifecycle    *LifecycleService
+	firebaseAuth *firebase.Auth
+	disposable   *DisposableEmailService
+	config       *config.Config
+	logger       *logrus.Logger
+}
+
+func NewSCIMService(db *gorm.DB, userService *UserService, userAdminService *UserAdminService, identityService *IdentityService, auditService *AuditService, groupService *GroupService, lifecycleService *LifecycleService, firebaseAuth *firebase.Auth, disposableEmailService *DisposableEmailService, cfg *config.Config) *SCIMService {
+	return &SCIMService{
+		db:           db,
+		users:        userService,
+		admin:        userAdminService,
+		identities:   identityService,
+		audit:        auditService,
+		groups:       groupService,
+		lifecycle:    lifecycleService,
+		firebaseAuth: firebaseAuth,
+		disposable:   disposableEmailService,
+		config:       cfg,
+		logger:       logger.GetLogger(),
+	}
+}
+
+// CreateToken emite un token SCIM para un tenant; el valor en claro sólo se devuelve aquí
+func (s *SCIMService) CreateToken(ctx context.Context, actor AdminActor, req *models.CreateSCIMTokenRequest) (*models.CreateSCIMTokenResponse, error) {
+	secret, err := generateRandomToken(scimTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := scimTokenPrefix + secret
+
+	token := &models.SCIMToken{
+		TenantID:  req.TenantID,
+		Name:      req.Name,
+		TokenHash: sha256Hex(plaintext),
+		CreatedBy: actor.UserID,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			ActorID:   actor.UserID,
+			Action:    models.AuditActionSCIMTokenCreated,
+			IPAddress: actor.IPAddress,
+			UserAgent: actor.UserAgent,
+			Details: models.JSONMap{
+				"token_id":  token.ID,
+				"tenant_id": token.TenantID,
+				"name":      token.Name,
+			},
+		})
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create SCIM token")
+		return nil, fmt.Errorf("failed to create SCIM token: %w", err)
+	}
+
+	return &models.CreateSCIMTokenResponse{Token: plaintext, SCIM: token}, nil
+}
+
+// ListTokens devuelve los tokens SCIM, incluidos los revocados
+func (s *SCIMService) ListTokens(ctx context.Context) ([]*models.SCIMToken, error) {
+	var tokens []*models.SCIMToken
+	if err := s.db.WithContext(ctx).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeToken revoca un token SCIM; el IdP que lo use dejará de poder autenticarse
+func (s *SCIMService) RevokeToken(ctx context.Context, actor AdminActor, tokenID string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.SCIMToken{}).
+			Where("id = ? AND revoked_at IS NULL", tokenID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSCIMTokenNotFound
+		}
+
+		return s.audit.RecordTx(tx, &models.AuditLog{
+			ActorID:   actor.UserID,
+			Action:    models.AuditActionSCIMTokenRevoked,
+			IPAddress: actor.IPAddress,
+			UserAgent: actor.UserAgent,
+			Details:   models.JSONMap{"token_id": tokenID},
+		})
+	})
+}
+
+// Authenticate valida un bearer token SCIM y devuelve el token con su tenant
+func (s *SCIMService) Authenticate(ctx context.Context, plaintext string) (*models.SCIMToken, error) {
+	if !strings.HasPrefix(plaintext, scimTokenPrefix) {
+		return nil, ErrSCIMInvalidToken
+	}
+
+	var token models.SCIMToken
+	err := s.db.WithContext(ctx).
+		Where("token_hash = ? AND revoked_at IS NULL", sha256Hex(plaintext)).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSCIMInvalidToken
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	// last_used_at se actualiza como mucho una vez por minuto para no escribir en cada petición
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > scimLastUsedInterval {
+		if err := s.db.WithContext(ctx).Model(&token).Update("last_used_at", now).Error; err != nil {
+			s.logger.WithError(err).Warn("Failed to update SCIM token usage")
+		}
+	}
+
+	return &token, nil
+}
+
+// ListUsers busca los usuarios del tenant con un filtro SCIM y paginación por índice
+func (s *SCIMService) ListUsers(ctx context.Context, caller SCIMCaller, query *models.SCIMListQuery) (*models.SCIMListResponse, error) {
+	clauses, err := parseSCIMFilter(query.Filter, models.SCIMSchemaUser)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.db.WithContext(ctx).Model(&models.User{}).
+		Joins("JOIN scim_user_links ON scim_user_links.user_id = users.id AND scim_user_links.tenant_id = ?", caller.tenantID()).
+		Where("users.status NOT IN ?", models.DeletedStatuses)
+	db, err = applySCIMFilter(db, clauses, scimUserFilterColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	startIndex, count := scimPage(query)
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	resources := []*models.SCIMUserResource{}
+	if count > 0 {
+		var users []*models.User
+		if err := db.Session(&gorm.Session{}).
+			Order("users.created_at ASC, users.id ASC").
+			Offset(startIndex - 1).
+			Limit(count).
+			Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", err)
+		}
+
+		links, err := s.userLinks(ctx, caller.tenantID(), users)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			resources = append(resources, toSCIMUser(user, links[user.ID]))
+		}
+	}
+
+	return scimListResponse(total, startIndex, len(resources), resources), nil
+}
+
+// GetUser devuelve un usuario aprovisionado por el tenant
+func (s *SCIMService) GetUser(ctx context.Context, caller SCIMCaller, userID string) (*models.SCIMUserResource, error) {
+	user, link, err := s.loadUser(ctx, caller.tenantID(), userID)
+	if err != nil {
+		return nil, err
+	}
+	return toSCIMUser(user, link), nil
+}
+
+// CreateUser aprovisiona un usuario nuevo: lo crea en Firebase (o reutiliza la cuenta
+// con ese email), en la base de datos y lo vincula al tenant
+func (s *SCIMService) CreateUser(ctx context.Context, caller SCIMCaller, resource *models.SCIMUserResource) (*models.SCIMUserResource, error) {
+	values, err := scimUserValuesFrom(resource)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkUserNameAvailable(ctx, caller.tenantID(), values.UserName, ""); err != nil {
+		return nil, err
+	}
+
+	if err := s.disposable.Check(values.Email); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSCIMInvalidValue, err)
+	}
+
+	var emailOwners int64
+	if err := whereEmailOwner(s.db.WithContext(ctx).Model(&models.User{}), values.Email).Count(&emailOwners).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if emailOwners > 0 {
+		return nil, fmt.Errorf("%w: email is already registered", ErrSCIMUniqueness)
+	}
+
+	displayName := strings.TrimSpace(values.FirstName + " " + values.LastName)
+	firebaseID, err := ensureFirebaseUser(ctx, s.db, s.firebaseAuth, values.Email, displayName, values.PhotoURL, false)
+	if errors.Is(err, ErrFirebaseUserNotReusable) {
+		return nil, fmt.Errorf("%w: %v", ErrSCIMUniqueness, err)
+	}
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to create Firebase user for SCIM provisioning")
+		return nil, fmt.Errorf("failed to create Firebase user: %w", err)
+	}
+
+	username, err := s.users.usernames.GenerateUsername(ctx, values.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		FirebaseID:     firebaseID,
+		Email:          values.Email,
+		CanonicalEmail: canonicalEmail(values.Email),
+		Username:       username,
+		FirstName:      values.FirstName,
+		LastName:       values.LastName,
+		PhotoURL:       values.PhotoURL,
+		Provider:       "password",
+		Role:           models.RoleUser,
+		Status:         scimInitialStatus(s.config.LifecycleConfig),
+	}
+	link := &models.SCIMUserLink{
+		TenantID:   caller.tenantID(),
+		UserName:   values.UserName,
+		ExternalID: values.ExternalID,
+	}
+
+	actor := caller.actor()
+	var event *models.UserStatusChangedEvent
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if _, err := s.identities.linkIdentityTx(tx, user.ID, user.Provider, firebaseID, user.Email); err != nil {
+			return err
+		}
+		link.UserID = user.ID
+		if err := tx.Create(link).Error; err != nil {
+			return err
+		}
+		if err := joinTenantOrganizationTx(tx, link.TenantID, user.ID); err != nil {
+			return err
+		}
+		if err := s.audit.RecordTx(tx, &models.AuditLog{
+			UserID:    user.ID,
+			ActorID:   actor.UserID,
+			Action:    models.AuditActionUserProvisioned,
+			IPAddress: actor.IPAddress,
+			UserAgent: actor.UserAgent,
+			Details: models.JSONMap{
+				"tenant_id":   link.TenantID,
+				"external_id": link.ExternalID,
+			},
+		}); err != nil {
+			return err
+		}
+		// Un alta con active=false se suspende como cualquier otra cuenta, con su auditoría y su evento
+		if !values.Active {
+			var err error
+			event, err = s.lifecycle.transitionTx(tx, user, StatusChange{
+				To:     models.StatusSuspended,
+				Reason: scimStatusReason,
+				Actor:  actor,
+			})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, ErrIdentityLinkedToOtherUser) {
+			return nil, fmt.Errorf("%w: user already exists", ErrSCIMUniqueness)
+		}
+		s.logger.WithError(err).Error("Failed to provision SCIM user")
+		return nil, fmt.Errorf("failed to provision user: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":   user.ID,
+		"tenant_id": link.TenantID,
+	}).Info("User provisioned via SCIM")
+
+	s.users.notifyChanged(ctx, user)
+	if event != nil {
+		s.lifecycle.publish(ctx, event)
+	}
+	return toSCIMUser(user, link), nil
+}
+
+// scimInitialStatus es el estado con el que se crea un usuario aprovisionado. SCIM no
+// verifica el email, así que si la configuración lo exige queda pendiente de verificación.
+func scimInitialStatus(cfg config.LifecycleConfig) string {
+	if cfg.RequireVerifiedEmail {
+		return models.StatusPendingVerification
+	}
+	return models.StatusActive
+}
+
+// ReplaceUser sustituye el usuario por la representación recibida (PUT)
+func (s *SCIMService) ReplaceUser(ctx context.Context, caller SCIMCaller, userID, ifMatch string, resource *models.SCIMUserResource) (*models.SCIMUserResource, error) {
+	user, link, err := s.loadUser(ctx, caller.tenantID(), userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSCIMVersion(ifMatch, toSCIMUser(user, link)); err != nil {
+		return nil, err
+	}
+
+	values, err := scimUserValuesFrom(resource)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateUser(ctx, caller, user, link, values)
+}
+
+// PatchUser aplica operaciones PATCH sobre el usuario
+func (s *SCIMService) PatchUser(ctx context.Context, caller SCIMCaller, userID, ifMatch string, operations []models.SCIMPatchOperation) (*models.SCIMUserResource, error) {
+	user, link, err := s.loadUser(ctx, caller.tenantID(), userID)
+	if err != nil {
+		return nil, err
+	}
+	current := toSCIMUser(user, link)
+	if err := checkSCIMVersion(ifMatch, current); err != nil {
+		return nil, err
+	}
+
+	if err := applySCIMUserPatch(current, operations); err != nil {
+		return nil, err
+	}
+	values, err := scimUserValuesFrom(current)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateUser(ctx, caller, user, link, values)
+}
+
+// DeleteUser desaprovisiona el usuario: la cuenta queda eliminada (soft delete), se
+// cierran sus sesiones y deja de ser visible para el tenant
+func (s *SCIMService) DeleteUser(ctx context.Context, caller SCIMCaller, userID, ifMatch string) error {
+	user, link, err := s.loadUser(ctx, caller.tenantID(), userID)
+	if err != nil {
+		return err
+	}
+	if err := checkSCIMVersion(ifMatch, toSCIMUser(user, link)); err != nil {
+		return err
+	}
+
+	if _, err := s.admin.SetStatus(ctx, caller.actor(), user.ID, models.StatusPendingDeletion, "Deprovisioned via SCIM", nil); err != nil {
+		return err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND group_id IN (?)", user.ID,
+			tx.Model(&models.UserGroup{}).Select("id").Where("tenant_id = ?", caller.tenantID()),
+		).Delete(&models.UserGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := leaveTenantOrganizationTx(tx, caller.tenantID(), user.ID); err != nil {
+			return err
+		}
+		return tx.Delete(link).Error
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to unlink deprovisioned SCIM user")
+		return fmt.Errorf("failed to deprovision user: %w", err)
+	}
+	s.groups.InvalidateUser(user.ID)
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":   user.ID,
+		"tenant_id": caller.tenantID(),
+	}).Info("User deprovisioned via SCIM")
+	return nil
+}
+
+func (s *SCIMService) updateUser(ctx context.Context, caller SCIMCaller, user *models.User, link *models.SCIMUserLink, values *scimUserValues) (*models.SCIMUserResource, error) {
+	if !strings.EqualFold(values.UserName, link.UserName) {
+		if err := s.checkUserNameAvailable(ctx, caller.tenantID(), values.UserName, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	emailChanged := values.Email != user.Email
+	// El IdP no prueba que el usuario controle la nueva dirección; basta con que sólo
+	// cambien las mayúsculas para conservar la verificación
+	emailReplaced := !strings.EqualFold(values.Email, user.Email)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", user.ID).First(user).Error; err != nil {
+			return err
+		}
+
+		if emailChanged {
+			var owners int64
+			if err := whereEmailOwner(tx.Model(&models.User{}), values.Email).
+				Where("id <> ?", user.ID).
+				Count(&owners).Error; err != nil {
+				return err
+			}
+			if owners > 0 {
+				return fmt.Errorf("%w: email is already registered", ErrSCIMUniqueness)
+			}
+		}
+
+		updates := map[string]interface{}{
+			"email":           values.Email,
+			"canonical_email": canonicalEmail(values.Email),
+			"first_name":      values.FirstName,
+			"last_name":       values.LastName,
+			"photo_url":       values.PhotoURL,
+		}
+		if emailReplaced {
+			updates["email_verified"] = false
+		}
+		if err := tx.Model(user).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Model(link).
+			Where("tenant_id = ? AND user_id = ?", link.TenantID, link.UserID).
+			Updates(map[string]interface{}{
+				"user_name":   values.UserName,
+				"external_id": values.ExternalID,
+			}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, fmt.Errorf("%w: userName or email already exists", ErrSCIMUniqueness)
+		}
+		if !errors.Is(err, ErrSCIMUniqueness) {
+			s.logger.WithError(err).WithField("user_id", user.ID).Error("Failed to update SCIM user")
+		}
+		return nil, err
+	}
+
+	// El email de Firebase se mantiene alineado; si falla, la cuenta local ya es correcta
+	if emailChanged {
+		params := (&auth.UserToUpdate{}).Email(values.Email)
+		if emailReplaced {
+			params = params.EmailVerified(false)
+		}
+		if _, err := s.firebaseAuth.UpdateUser(ctx, user.FirebaseID, params); err != nil {
+			s.logger.WithError(err).WithField("user_id", user.ID).Warn("Failed to update Firebase email for SCIM user")
+		}
+	}
+
+	// Los cambios de active pasan por el flujo de administración para revocar sesiones y auditar
+	isActive := user.Status == models.StatusActive
+	switch {
+	case values.Active && user.Status == models.StatusSuspended:
+		if _, err := s.admin.SetStatus(ctx, caller.actor(), user.ID, models.StatusActive, scimStatusReason, nil); err != nil {
+			return nil, err
+		}
+	case !values.Active && (isActive || user.Status == models.StatusPendingVerification):
+		if _, err := s.admin.SetStatus(ctx, caller.actor(), user.ID, models.StatusSuspended, scimStatusReason, nil); err != nil {
+			return nil, err
+		}
+	default:
+		s.users.notifyChanged(ctx, user)
+	}
+
+	return s.GetUser(ctx, caller, user.ID)
+}
+
+// loadUser devuelve el usuario y su vínculo con el tenant; los usuarios de otros
+// tenants o eliminados se tratan como inexistentes
+func (s *SCIMService) loadUser(ctx context.Context, tenantID, userID string) (*models.User, *models.SCIMUserLink, error) {
+	var link models.SCIMUserLink
+	err := s.db.WithContext(ctx).Where("tenant_id = ? AND user_id::text = ?", tenantID, userID).First(&link).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrSCIMNotFound
+		}
+		return nil, nil, fmt.Errorf("database error: %w", err)
+	}
+
+	var user models.User
+	err = s.db.WithContext(ctx).Where("id = ? AND status NOT IN ?", link.UserID, models.DeletedStatuses).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrSCIMNotFound
+		}
+		return nil, nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return &user, &link, nil
+}
+
+func (s *SCIMService) userLinks(ctx context.Context, tenantID string, users []*models.User) (map[string]*models.SCIMUserLink, error) {
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+
+	var links []*models.SCIMUserLink
+	if err := s.db.WithContext(ctx).Where("tenant_id = ? AND user_id IN ?", tenantID, ids).Find(&links).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	byUser := make(map[string]*models.SCIMUserLink, len(links))
+	for _, link := range links {
+		byUser[link.UserID] = link
+	}
+	return byUser, nil
+}
+
+// checkUserNameAvailable comprueba que el userName no esté en uso en el tenant
+// (sin distinguir mayúsculas, como define SCIM para userName)
+func (s *SCIMService) checkUserNameAvailable(ctx context.Context, tenantID, userName, exceptUserID string) error {
+	db := s.db.WithContext(ctx).Model(&models.SCIMUserLink{}).
+		Where("tenant_id = ? AND LOWER(user_name) = LOWER(?)", tenantID, userName)
+	if exceptUserID != "" {
+		db = db.Where("user_id <> ?", exceptUserID)
+	}
+
+	var count int64
+	if err := db.Count(&count).Error; err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: userName is already in use", ErrSCIMUniqueness)
+	}
+	return nil
+}
+
+// scimUserValuesFrom extrae y valida los datos del usuario. El email es el primario de
+// emails o, si no se envía ninguno, el userName cuando tiene forma de email.
+func scimUserValuesFrom(resource *models.SCIMUserResource) (*scimUserValues, error) {
+	values := &scimUserValues{
+		UserName:   strings.TrimSpace(resource.UserName),
+		ExternalID: resource.ExternalID,
+		Active:     resource.Active == nil || *resource.Active,
+	}
+	if resource.Name != nil {
+		values.FirstName = resource.Name.GivenName
+		values.LastName = resource.Name.FamilyName
+	}
+	if photo := primarySCIMValue(resource.Photos); photo != "" {
+		values.PhotoURL = photo
+	}
+
+	values.Email = primarySCIMValue(resource.Emails)
+	if values.Email == "" && strings.Contains(values.UserName, "@") {
+		values.Email = values.UserName
+	}
+	values.Email = strings.ToLower(strings.TrimSpace(values.Email))
+
+	if err := validator.ValidateStruct(values); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSCIMInvalidValue, err)
+	}
+	return values, nil
+}
+
+func primarySCIMValue(values []models.SCIMMultiValue) string {
+	for _, value := range values {
+		if value.Primary {
+			return value.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+func toSCIMUser(user *models.User, link *models.SCIMUserLink) *models.SCIMUserResource {
+	active := user.Status == models.StatusActive
+	formatted := strings.TrimSpace(user.FirstName + " " + user.LastName)
+
+	resource := &models.SCIMUserResource{
+		Schemas:     []string{models.SCIMSchemaUser},
+		ID:          user.ID,
+		UserName:    user.Email,
+		DisplayName: formatted,
+		Emails:      []models.SCIMMultiValue{{Value: user.Email, Type: "work", Primary: true}},
+		Active:      &active,
+	}
+	if link != nil {
+		resource.UserName = link.UserName
+		resource.ExternalID = link.ExternalID
+	}
+	if formatted != "" {
+		resource.Name = &models.SCIMName{
+			Formatted:  formatted,
+			GivenName:  user.FirstName,
+			FamilyName: user.LastName,
+		}
+	} else {
+		resource.DisplayName = user.Email
+	}
+	if user.PhotoURL != "" {
+		resource.Photos = []models.SCIMMultiValue{{Value: user.PhotoURL, Type: "photo", Primary: true}}
+	}
+
+	created := user.CreatedAt
+	modified := user.UpdatedAt
+	resource.Meta = &models.SCIMMeta{
+		ResourceType: "User",
+		Created:      &created,
+		LastModified: &modified,
+		Version:      scimVersion(resource),
+	}
+	return resource
+}
+
+// scimVersion calcula el ETag débil del recurso a partir de su contenido (sin meta),
+// de modo que cambia con cualquier modificación visible por el cliente
+func scimVersion(resource interface{}) string {
+	data, _ := json.Marshal(resource)
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err == nil {
+		delete(object, "meta")
+		data, _ = json.Marshal(object)
+	}
+	return fmt.Sprintf(`W/"%s"`, sha256Hex(string(data))[:16])
+}
+
+// checkSCIMVersion compara la cabecera If-Match con la versión actual del recurso
+func checkSCIMVersion(ifMatch string, resource interface{}) error {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return nil
+	}
+
+	current := scimVersion(resource)
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == current || "W/"+candidate == current {
+			return nil
+		}
+	}
+	return ErrSCIMPreconditionFailed
+}
+
+// scimPage normaliza startIndex (base 1) y count
+func scimPage(query *models.SCIMListQuery) (int, int) {
+	startIndex := query.StartIndex
+	if startIndex < 1 {
+		startIndex = 1
+	}
+
+	count := scimDefaultPageSize
+	if query.Count != nil {
+		count = *query.Count
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > scimMaxPageSize {
+		count = scimMaxPageSize
+	}
+	return startIndex, count
+}
+
+func scimListResponse(total int64, startIndex, itemsPerPage int, resources interface{}) *models.SCIMListResponse {
+	return &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMSchemaListResponse},
+		TotalResults: total,
+		ItemsPerPage: itemsPerPage,
+		StartIndex:   startIndex,
+		Resources:    resources,
+	}
+}
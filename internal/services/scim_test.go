@@ -0,0 +1,135 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/models"
+)
+
+func TestParseSCIMFilter(t *testing.T) {
+	clauses, err := parseSCIMFilter(`userName eq "Ana@Example.com" and active eq true`, models.SCIMSchemaUser)
+	require.NoError(t, err)
+	require.Len(t, clauses, 2)
+	assert.Equal(t, scimFilterClause{Attr: "username", Op: "eq", Value: "Ana@Example.com"}, clauses[0])
+	assert.Equal(t, scimFilterClause{Attr: "active", Op: "eq", Value: true}, clauses[1])
+
+	clauses, err = parseSCIMFilter(`urn:ietf:params:scim:schemas:core:2.0:User:name.familyName SW "O\"Br"`, models.SCIMSchemaUser)
+	require.NoError(t, err)
+	assert.Equal(t, scimFilterClause{Attr: "name.familyname", Op: "sw", Value: `O"Br`}, clauses[0])
+
+	clauses, err = parseSCIMFilter("", models.SCIMSchemaUser)
+	require.NoError(t, err)
+	assert.Empty(t, clauses)
+
+	for _, filter := range []string{
+		`userName gt "a"`,
+		`userName eq "a" or userName eq "b"`,
+		`(userName eq "a")`,
+		`userName eq "a" and`,
+		`userName eq "unterminated`,
+		`userName eq 42`,
+	} {
+		_, err := parseSCIMFilter(filter, models.SCIMSchemaUser)
+		assert.ErrorIs(t, err, ErrSCIMInvalidFilter, filter)
+	}
+}
+
+func TestApplySCIMUserPatch(t *testing.T) {
+	active := true
+	user := &models.SCIMUserResource{
+		UserName: "ana@example.com",
+		Active:   &active,
+		Emails:   []models.SCIMMultiValue{{Value: "ana@example.com", Primary: true}},
+	}
+
+	var req models.SCIMPatchRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"Operations":[
+		{"op":"Replace","path":"active","value":"False"},
+		{"op":"replace","path":"emails[type eq \"work\"].value","value":"ana@corp.example"},
+		{"op":"add","value":{"name.givenName":"Ana","externalId":"00u1"}},
+		{"op":"add","path":"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department","value":"Sales"}
+	]}`), &req))
+
+	require.NoError(t, applySCIMUserPatch(user, req.Operations))
+	assert.False(t, *user.Active)
+	assert.Equal(t, "ana@corp.example", primarySCIMValue(user.Emails))
+	assert.Equal(t, "Ana", user.Name.GivenName)
+	assert.Equal(t, "00u1", user.ExternalID)
+
+	err := applySCIMUserPatch(user, []models.SCIMPatchOperation{{Op: "remove", Path: "userName"}})
+	assert.ErrorIs(t, err, ErrSCIMMutability)
+
+	err = applySCIMUserPatch(user, []models.SCIMPatchOperation{{Op: "replace", Path: "nickName", Value: json.RawMessage(`"a"`)}})
+	assert.ErrorIs(t, err, ErrSCIMInvalidPath)
+
+	err = applySCIMUserPatch(user, []models.SCIMPatchOperation{{Op: "move", Path: "active"}})
+	assert.ErrorIs(t, err, ErrSCIMInvalidSyntax)
+}
+
+func TestApplySCIMGroupPatchMembers(t *testing.T) {
+	group := &models.SCIMGroupResource{
+		DisplayName: "Engineering",
+		Members:     []models.SCIMMultiValue{{Value: "u1"}, {Value: "u2"}},
+	}
+
+	require.NoError(t, applySCIMGroupPatch(group, []models.SCIMPatchOperation{
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"u2"},{"value":"u3"}]`)},
+		{Op: "remove", Path: `members[value eq "u1"]`},
+	}))
+	assert.Equal(t, []models.SCIMMultiValue{{Value: "u2"}, {Value: "u3"}}, group.Members)
+
+	require.NoError(t, applySCIMGroupPatch(group, []models.SCIMPatchOperation{
+		{Op: "remove", Path: "members", Value: json.RawMessage(`[{"value":"u3"}]`)},
+	}))
+	assert.Equal(t, []models.SCIMMultiValue{{Value: "u2"}}, group.Members)
+
+	require.NoError(t, applySCIMGroupPatch(group, []models.SCIMPatchOperation{{Op: "remove", Path: "members"}}))
+	assert.Empty(t, group.Members)
+}
+
+func TestSCIMUserValuesFrom(t *testing.T) {
+	values, err := scimUserValuesFrom(&models.SCIMUserResource{UserName: "Ana@Example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "ana@example.com", values.Email, "userName is used as email when no emails are sent")
+	assert.True(t, values.Active)
+
+	_, err = scimUserValuesFrom(&models.SCIMUserResource{UserName: "ana"})
+	assert.ErrorIs(t, err, ErrSCIMInvalidValue)
+}
+
+func TestCheckSCIMVersion(t *testing.T) {
+	resource := &models.SCIMGroupResource{DisplayName: "Engineering"}
+	version := scimVersion(resource)
+
+	resource.Meta = &models.SCIMMeta{ResourceType: "Group", Version: version}
+	assert.Equal(t, version, scimVersion(resource), "meta must not affect the version")
+
+	assert.NoError(t, checkSCIMVersion("", resource))
+	assert.NoError(t, checkSCIMVersion("*", resource))
+	assert.NoError(t, checkSCIMVersion(version, resource))
+	assert.ErrorIs(t, checkSCIMVersion(`W/"stale"`, resource), ErrSCIMPreconditionFailed)
+}
+
+func TestSCIMInitialStatus(t *testing.T) {
+	assert.Equal(t, models.StatusActive, scimInitialStatus(config.LifecycleConfig{}))
+
+	// SCIM no verifica el email: si se exige, la cuenta espera a que el usuario lo confirme
+	assert.Equal(t, models.StatusPendingVerification, scimInitialStatus(config.LifecycleConfig{RequireVerifiedEmail: true}))
+
+	// Y desde ahí un alta con active=false se puede suspender
+	assert.NoError(t, checkTransition(&models.User{Status: models.StatusPendingVerification}, StatusChange{To: models.StatusSuspended}))
+}
+
+func TestToSCIMGroupKeepsMergedSCIMID(t *testing.T) {
+	group := &models.UserGroup{ID: "group-id", Name: "Sales"}
+	assert.Equal(t, "group-id", toSCIMGroup(group, nil).ID)
+
+	// El grupo al que se fusionó un grupo SCIM sigue presentándose con el id del IdP
+	scimID := "scim-group-id"
+	group.SCIMID = &scimID
+	assert.Equal(t, "scim-group-id", toSCIMGroup(group, nil).ID)
+}
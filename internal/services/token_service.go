@@ -3,16 +3,26 @@ package services
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"it-auth-service/internal/logger"
 	"it-auth-service/internal/models"
 )
 
+// ErrSessionNotFound indica que el token no tiene una sesión activa que actualizar
+// (cerrada o emitida antes de que todos los tokens registraran su sesión)
+var ErrSessionNotFound = errors.New("active session not found, log in again")
+
+// internalTokenTTL es la vida máxima de los JWT internos emitidos por el servicio
+const internalTokenTTL = 24 * time.Hour
+
 type TokenService struct {
 	db     *gorm.DB
 	logger *logrus.Logger
@@ -90,7 +100,7 @@ func (s *TokenService) IsTokenRevoked(ctx context.Context, tokenString string) (
 }
 
 // CreateSession crea una nueva sesión de usuario
-func (s *TokenService) CreateSession(ctx context.Context, userID, tokenString, ipAddress, userAgent, provider string) (*models.UserSession, error) {
+func (s *TokenService) CreateSession(ctx context.Context, userID, tokenString, ipAddress, userAgent, provider string, authCtx models.AuthContext) (*models.UserSession, error) {
 	tokenHash := s.hashToken(tokenString)
 	
 	session := &models.UserSession{
@@ -99,6 +109,9 @@ func (s *TokenService) CreateSession(ctx context.Context, userID, tokenString, i
 		IPAddress: ipAddress,
 		UserAgent: userAgent,
 		Provider:  provider,
+		AuthTime:  authCtx.AuthTime,
+		AMR:       strings.Join(authCtx.AMR, ","),
+		ACR:       authCtx.ACR,
 		IsActive:  true,
 	}
 	
@@ -143,6 +156,92 @@ func (s *TokenService) EndSession(ctx context.Context, tokenString, userID strin
 	return nil
 }
 
+// RevokeAllSessions revoca los tokens de todas las sesiones activas del usuario y las
+// cierra. Como la sesión sólo guarda el hash del token, se revoca hasta la vida máxima de un JWT.
+func (s *TokenService) RevokeAllSessions(ctx context.Context, userID, reason, ipAddress, userAgent string) (int, error) {
+	revoked := 0
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var sessions []*models.UserSession
+		if err := tx.Where("user_id = ? AND is_active = ?", userID, true).Find(&sessions).Error; err != nil {
+			return err
+		}
+
+		expiresAt := time.Now().Add(internalTokenTTL)
+		for _, session := range sessions {
+			revokedToken := &models.RevokedToken{
+				TokenHash: session.TokenHash,
+				UserID:    userID,
+				Reason:    reason,
+				ExpiresAt: expiresAt,
+				IPAddress: ipAddress,
+				UserAgent: userAgent,
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(revokedToken).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		if err := tx.Model(&models.UserSession{}).
+			Where("user_id = ? AND is_active = ?", userID, true).
+			Updates(map[string]interface{}{
+				"logout_at": &now,
+				"is_active": false,
+			}).Error; err != nil {
+			return err
+		}
+
+		revoked = len(sessions)
+		return nil
+	})
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to revoke user sessions")
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"user_id":  userID,
+		"sessions": revoked,
+		"reason":   reason,
+	}).Info("All user sessions revoked")
+
+	return revoked, nil
+}
+
+// UpgradeSession asocia la sesión activa al token emitido tras una reautenticación
+// y actualiza auth_time, amr y acr
+func (s *TokenService) UpgradeSession(ctx context.Context, oldTokenString, newTokenString, userID string, authCtx models.AuthContext) error {
+	return s.UpgradeSessionTx(s.db.WithContext(ctx), oldTokenString, newTokenString, userID, authCtx)
+}
+
+// UpgradeSessionTx es UpgradeSession dentro de una transacción existente
+func (s *TokenService) UpgradeSessionTx(tx *gorm.DB, oldTokenString, newTokenString, userID string, authCtx models.AuthContext) error {
+	result := tx.
+		Model(&models.UserSession{}).
+		Where("token_hash = ? AND user_id = ? AND is_active = ?", s.hashToken(oldTokenString), userID, true).
+		Updates(map[string]interface{}{
+			"token_hash": s.hashToken(newTokenString),
+			"auth_time":  authCtx.AuthTime,
+			"amr":        strings.Join(authCtx.AMR, ","),
+			"acr":        authCtx.ACR,
+		})
+	
+	if result.Error != nil {
+		s.logger.WithError(result.Error).Error("Failed to upgrade user session")
+		return fmt.Errorf("failed to upgrade session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	
+	s.logger.WithFields(map[string]interface{}{
+		"user_id": userID,
+		"acr":     authCtx.ACR,
+	}).Info("User session upgraded")
+	
+	return nil
+}
+
 // UpdateLastSeen actualiza la última actividad de la sesión
 func (s *TokenService) UpdateLastSeen(ctx context.Context, tokenString, userID string) error {
 	tokenHash := s.hashToken(tokenString)
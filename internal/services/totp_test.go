@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Vectores del RFC 6238 (SHA1) truncados a 6 dígitos
+const rfcTestSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // "12345678901234567890"
+
+func TestTOTPCodeAt_RFC6238Vectors(t *testing.T) {
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, expected := range cases {
+		code, err := totpCodeAt(rfcTestSecret, totpStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "unix time %d", unix)
+	}
+}
+
+func TestValidateTOTP_AllowsClockSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	step, ok := validateTOTP(rfcTestSecret, "081804", now.Add(totpPeriod*time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, totpStep(now), step)
+
+	_, ok = validateTOTP(rfcTestSecret, "081804", now.Add(3*totpPeriod*time.Second))
+	assert.False(t, ok)
+
+	_, ok = validateTOTP(rfcTestSecret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestGenerateTOTPSecret_RoundTrip(t *testing.T) {
+	secret, err := generateTOTPSecret()
+	assert.NoError(t, err)
+
+	code, err := totpCodeAt(secret, totpStep(time.Now()))
+	assert.NoError(t, err)
+
+	_, ok := validateTOTP(secret, code, time.Now())
+	assert.True(t, ok)
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	assert.Equal(t, "abcdefghij", normalizeRecoveryCode(" ABCDE-fghij "))
+	assert.Equal(t, "abcdefghij", normalizeRecoveryCode("abcde fghij"))
+}
+
+func TestSecretBox_RoundTrip(t *testing.T) {
+	box, err := newSecretBox("test-key")
+	assert.NoError(t, err)
+
+	sealed, err := box.Seal(rfcTestSecret)
+	assert.NoError(t, err)
+	assert.NotEqual(t, rfcTestSecret, sealed)
+
+	opened, err := box.Open(sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, rfcTestSecret, opened)
+}
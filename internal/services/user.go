@@ -11,15 +11,33 @@ import (
 	"it-auth-service/internal/models"
 )
 
+// UserChangeListener se invoca después de que un usuario cambie o se elimine
+type UserChangeListener func(ctx context.Context, user *models.User)
+
 type UserService struct {
-	db     *gorm.DB
-	logger *logrus.Logger
+	db        *gorm.DB
+	usernames *UsernameService
+	logger    *logrus.Logger
+	listeners []UserChangeListener
 }
 
-func NewUserService(db *gorm.DB) *UserService {
+func NewUserService(db *gorm.DB, usernameService *UsernameService) *UserService {
 	return &UserService{
-		db:     db,
-		logger: logger.GetLogger(),
+		db:        db,
+		usernames: usernameService,
+		logger:    logger.GetLogger(),
+	}
+}
+
+// OnUserChanged registra un listener para los cambios de usuario (p. ej. la
+// sincronización de custom claims de Firebase). Debe llamarse durante el arranque.
+func (s *UserService) OnUserChanged(listener UserChangeListener) {
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *UserService) notifyChanged(ctx context.Context, user *models.User) {
+	for _, listener := range s.listeners {
+		listener(ctx, user)
 	}
 }
 
@@ -39,11 +57,11 @@ func (s *UserService) GetUserByFirebaseID(ctx context.Context, firebaseID string
 	return &user, nil
 }
 
-// GetUserByEmail busca un usuario por su email
+// GetUserByEmail busca el usuario del email o de otra variante del mismo buzón
 func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
 	
-	err := s.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	err := whereEmailOwner(s.db.WithContext(ctx), email).Order("users.created_at ASC").First(&user).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("user not found")
@@ -79,15 +97,38 @@ func (s *UserService) CreateUser(ctx context.Context, user *models.User) (*model
 		return nil, fmt.Errorf("user with Firebase ID already exists")
 	}
 
-	// Verificar que no exista un usuario con el mismo email
+	// Verificar que no exista un usuario con el mismo email (o una variante del mismo buzón)
+	user.CanonicalEmail = canonicalEmail(user.Email)
 	existingUser, err = s.GetUserByEmail(ctx, user.Email)
 	if err == nil && existingUser != nil {
-		return nil, fmt.Errorf("user with email already exists")
+		return nil, fmt.Errorf("user with email already exists: %w", ErrEmailAlreadyRegistered)
 	}
 
-	// Crear el usuario
-	err = s.db.WithContext(ctx).Create(user).Error
-	if err != nil {
+	// Sin username elegido se genera uno libre; si otro registro concurrente toma el
+	// mismo, se genera otro
+	generated := user.Username == ""
+	if !generated {
+		if err := s.usernames.CheckAvailability(ctx, user.Username, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if generated {
+			username, err := s.usernames.GenerateUsername(ctx, user.Email)
+			if err != nil {
+				return nil, err
+			}
+			user.Username = username
+		}
+
+		err = s.db.WithContext(ctx).Create(user).Error
+		if err == nil {
+			break
+		}
+		if errors.Is(err, gorm.ErrDuplicatedKey) && generated && attempt < maxUsernameAttempts {
+			continue
+		}
 		s.logger.WithError(err).Error("Failed to create user")
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -114,50 +155,10 @@ func (s *UserService) UpdateUser(ctx context.Context, user *models.User) error {
 		"firebase_id": user.FirebaseID,
 	}).Info("User updated successfully")
 
+	s.notifyChanged(ctx, user)
 	return nil
 }
 
-// DeleteUser elimina un usuario (soft delete)
-func (s *UserService) DeleteUser(ctx context.Context, id string) error {
-	err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("status", "deleted").Error
-	if err != nil {
-		s.logger.WithError(err).Error("Failed to delete user")
-		return fmt.Errorf("failed to delete user: %w", err)
-	}
-
-	s.logger.WithField("user_id", id).Info("User deleted successfully")
-	return nil
-}
-
-// ListUsers lista usuarios con paginación
-func (s *UserService) ListUsers(ctx context.Context, page, limit int) ([]*models.User, int64, error) {
-	var users []*models.User
-	var total int64
-
-	// Contar total de usuarios activos
-	err := s.db.WithContext(ctx).Model(&models.User{}).Where("status != ?", "deleted").Count(&total).Error
-	if err != nil {
-		s.logger.WithError(err).Error("Failed to count users")
-		return nil, 0, fmt.Errorf("failed to count users: %w", err)
-	}
-
-	// Obtener usuarios con paginación
-	offset := (page - 1) * limit
-	err = s.db.WithContext(ctx).
-		Where("status != ?", "deleted").
-		Offset(offset).
-		Limit(limit).
-		Order("created_at DESC").
-		Find(&users).Error
-
-	if err != nil {
-		s.logger.WithError(err).Error("Failed to list users")
-		return nil, 0, fmt.Errorf("failed to list users: %w", err)
-	}
-
-	return users, total, nil
-}
-
 // GetUserProfile obtiene el perfil completo de un usuario
 func (s *UserService) GetUserProfile(ctx context.Context, userID string) (*models.User, error) {
 	user, err := s.GetUserByID(ctx, userID)
@@ -165,49 +166,58 @@ func (s *UserService) GetUserProfile(ctx context.Context, userID string) (*model
 		return nil, err
 	}
 
-	if user.Status == "deleted" {
+	if models.IsDeletedStatus(user.Status) {
 		return nil, fmt.Errorf("user not found")
 	}
 
 	return user, nil
 }
 
-// UpdateUserProfile actualiza el perfil de un usuario
-func (s *UserService) UpdateUserProfile(ctx context.Context, userID string, updates map[string]interface{}) (*models.User, error) {
+// UpdateUserProfile actualiza el perfil de un usuario con los campos enviados en la petición
+func (s *UserService) UpdateUserProfile(ctx context.Context, userID string, req *models.UpdateProfileRequest) (*models.User, error) {
 	user, err := s.GetUserByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	if user.Status == "deleted" {
+	if models.IsDeletedStatus(user.Status) {
 		return nil, fmt.Errorf("user not found")
 	}
 
-	// Aplicar actualizaciones permitidas
-	allowedFields := map[string]bool{
-		"username":   true,
-		"first_name": true,
-		"last_name":  true,
-		"photo_url":  true,
-	}
-
-	updateData := make(map[string]interface{})
-	for field, value := range updates {
-		if allowedFields[field] {
-			updateData[field] = value
-		}
-	}
-
+	updateData := profileUpdates(req)
 	if len(updateData) == 0 {
 		return user, nil
 	}
 
-	err = s.db.WithContext(ctx).Model(user).Updates(updateData).Error
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// El username tiene sus propias reglas (reservas, límite de cambios, historial)
+		if req.Username != nil {
+			if err := s.usernames.changeTx(tx, user, *req.Username); err != nil {
+				return err
+			}
+			delete(updateData, "username")
+		}
+		if len(updateData) == 0 {
+			return nil
+		}
+		return tx.Model(user).Updates(updateData).Error
+	})
 	if err != nil {
+		switch {
+		case errors.Is(err, ErrUsernameTaken), errors.Is(err, ErrUsernameReserved),
+			errors.Is(err, ErrUsernameInvalid), errors.Is(err, ErrUsernameChangeTooSoon):
+			return nil, err
+		}
 		s.logger.WithError(err).Error("Failed to update user profile")
 		return nil, fmt.Errorf("failed to update user profile: %w", err)
 	}
 
 	// Recargar el usuario actualizado
-	return s.GetUserByID(ctx, userID)
-}
\ No newline at end of file
+	updated, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyChanged(ctx, updated)
+	return updated, nil
+}
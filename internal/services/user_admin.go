@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/firebase"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrAccountSuspended   = errors.New("account is suspended")
+	ErrAccountDeleted     = errors.New("account has been deleted")
+	ErrCannotModifySelf   = errors.New("administrators cannot change their own status")
+	ErrInvalidStatusValue = errors.New("invalid status transition")
+)
+
+// AdminActor identifica al administrador que ejecuta una acción
+type AdminActor struct {
+	UserID    string
+	IPAddress string
+	UserAgent string
+}
+
+// UserAdminService agrupa las operaciones de administración sobre cuentas de otros usuarios
+type UserAdminService struct {
+	db           *gorm.DB
+	users        *UserService
+	tokens       *TokenService
+	identities   *IdentityService
+	audit        *AuditService
+	lifecycle    *LifecycleService
+	firebaseAuth *firebase.Auth
+	logger       *logrus.Logger
+}
+
+func NewUserAdminService(db *gorm.DB, userService *UserService, tokenService *TokenService, identityService *IdentityService, auditService *AuditService, lifecycleService *LifecycleService, firebaseAuth *firebase.Auth) *UserAdminService {
+	return &UserAdminService{
+		db:           db,
+		users:        userService,
+		tokens:       tokenService,
+		identities:   identityService,
+		audit:        auditService,
+		lifecycle:    lifecycleService,
+		firebaseAuth: firebaseAuth,
+		logger:       logger.GetLogger(),
+	}
+}
+
+// GetUser devuelve cualquier usuario, incluidos los suspendidos o eliminados
+func (s *UserAdminService) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &user, nil
+}
+
+// SetStatus cambia el estado de la cuenta y registra el motivo. Cualquier estado
+// distinto de active cierra además todas sus sesiones. "deleted" se acepta como alias
+// de pending_deletion: el estado final sólo lo alcanza el borrado definitivo.
+func (s *UserAdminService) SetStatus(ctx context.Context, actor AdminActor, userID, status, reason string, lockedUntil *time.Time) (*models.User, error) {
+	if actor.UserID == userID {
+		return nil, ErrCannotModifySelf
+	}
+	if status == models.StatusDeleted {
+		status = models.StatusPendingDeletion
+	}
+
+	user, err := s.lifecycle.Transition(ctx, userID, StatusChange{
+		To:          status,
+		Reason:      reason,
+		Actor:       actor,
+		LockedUntil: lockedUntil,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if status != models.StatusActive {
+		if _, err := s.revokeEverything(ctx, actor, user, "status_"+status); err != nil {
+			return user, err
+		}
+	}
+
+	return user, nil
+}
+
+// ForceLogout cierra todas las sesiones del usuario y revoca sus refresh tokens de Firebase
+func (s *UserAdminService) ForceLogout(ctx context.Context, actor AdminActor, userID, reason string) (int, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked, err := s.revokeEverything(ctx, actor, user, "force_logout")
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditLog{
+		UserID:    userID,
+		ActorID:   actor.UserID,
+		Action:    models.AuditActionForceLogout,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details: models.JSONMap{
+			"reason":           reason,
+			"revoked_sessions": revoked,
+		},
+	}); err != nil {
+		s.logger.WithError(err).Warn("Failed to audit forced logout")
+	}
+
+	return revoked, nil
+}
+
+// revokeEverything revoca los JWT de todas las sesiones y los refresh tokens de
+// Firebase de cada identidad, para que el cliente no pueda obtener nuevos tokens
+func (s *UserAdminService) revokeEverything(ctx context.Context, actor AdminActor, user *models.User, reason string) (int, error) {
+	revoked, err := s.tokens.RevokeAllSessions(ctx, user.ID, reason, actor.IPAddress, actor.UserAgent)
+	if err != nil {
+		return 0, err
+	}
+
+	subjects := []string{user.FirebaseID}
+	identities, err := s.identities.ListIdentities(ctx, user.ID)
+	if err != nil {
+		return revoked, err
+	}
+	for _, identity := range identities {
+		if identity.Subject != user.FirebaseID {
+			subjects = append(subjects, identity.Subject)
+		}
+	}
+
+	if s.firebaseAuth != nil {
+		for _, subject := range subjects {
+			if err := s.firebaseAuth.RevokeRefreshTokens(ctx, subject); err != nil {
+				s.logger.WithError(err).WithField("subject", subject).Warn("Failed to revoke Firebase refresh tokens")
+			}
+		}
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/pkg/firebase"
+)
+
+// newTestUserAdminService monta el servicio con sus dependencias sobre la misma
+// conexión; sin cliente de Firebase sólo se revocan las sesiones locales
+func newTestUserAdminService(db *gorm.DB) *UserAdminService {
+	users := &UserService{db: db, logger: logger.GetLogger()}
+	audit := NewAuditService(db)
+	return &UserAdminService{
+		db:         db,
+		users:      users,
+		tokens:     NewTokenService(db),
+		identities: NewIdentityService(db),
+		audit:      audit,
+		lifecycle:  NewLifecycleService(db, users, audit),
+		logger:     logger.GetLogger(),
+	}
+}
+
+// expectRevokeEverything espera la revocación de la única sesión activa del usuario
+func expectRevokeEverything(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "user_sessions" WHERE user_id = \$1 AND is_active = \$2`).
+		WithArgs(userID, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "token_hash", "is_active"}).
+			AddRow("session-id", userID, "token-hash", true))
+	mock.ExpectQuery(`INSERT INTO "revoked_tokens" .* ON CONFLICT DO NOTHING`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("revoked-id"))
+	mock.ExpectExec(`UPDATE "user_sessions" SET .*"is_active"=\$\d.* WHERE user_id = \$\d AND is_active = \$\d`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "user_identities" WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "subject"}))
+}
+
+func TestSetStatusRejectsInvalidTransition(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := newTestUserAdminService(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+		WithArgs("user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow("user-id", models.StatusDeleted))
+	mock.ExpectRollback()
+
+	// Una cuenta ya borrada no vuelve a ningún otro estado, y no se toca ninguna sesión
+	_, err := s.SetStatus(context.Background(), AdminActor{UserID: "admin-id"}, "user-id", models.StatusSuspended, "abuse", nil)
+	assert.ErrorIs(t, err, ErrInvalidStatusValue)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetStatusSuspendRevokesSessions(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := newTestUserAdminService(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1 .*FOR UPDATE`).
+		WithArgs("user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "firebase_id"}).AddRow("user-id", models.StatusActive, "firebase-uid"))
+	mock.ExpectExec(`UPDATE "users" SET .*"status"=`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+	expectRevokeEverything(mock, "user-id")
+
+	user, err := s.SetStatus(context.Background(), AdminActor{UserID: "admin-id"}, "user-id", models.StatusSuspended, "abuse", nil)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusSuspended, user.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForceLogoutRevokesSessions(t *testing.T) {
+	db, mock := newMockDB(t)
+	s := newTestUserAdminService(db)
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+		WithArgs("user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "firebase_id"}).AddRow("user-id", models.StatusActive, "firebase-uid"))
+	expectRevokeEverything(mock, "user-id")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+
+	revoked, err := s.ForceLogout(context.Background(), AdminActor{UserID: "admin-id"}, "user-id", "compromised")
+	require.NoError(t, err)
+	assert.Equal(t, 1, revoked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetStatusRejectsSelf(t *testing.T) {
+	s := &UserAdminService{}
+
+	_, err := s.SetStatus(context.Background(), AdminActor{UserID: "admin-id"}, "admin-id", models.StatusSuspended, "", nil)
+	assert.ErrorIs(t, err, ErrCannotModifySelf)
+}
+
+// fakeFirebase imita el emulador de Firebase Auth: guarda por UID el instante a
+// partir del cual los tokens son válidos, que RevokeRefreshTokens adelanta
+type fakeFirebase struct {
+	mu         sync.Mutex
+	validSince map[string]string
+}
+
+func (f *fakeFirebase) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/accounts:update"):
+		uid, _ := body["localId"].(string)
+		if validSince, ok := body["validSince"].(string); ok {
+			f.validSince[uid] = validSince
+		}
+		json.NewEncoder(w).Encode(map[string]string{"localId": uid})
+	case strings.HasSuffix(r.URL.Path, "/accounts:lookup"):
+		var users []map[string]string
+		ids, _ := body["localId"].([]interface{})
+		for _, id := range ids {
+			uid, _ := id.(string)
+			user := map[string]string{"localId": uid}
+			if validSince, ok := f.validSince[uid]; ok {
+				user["validSince"] = validSince
+			}
+			users = append(users, user)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"users": users})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// newFakeFirebaseAuth apunta el cliente de Firebase al emulador falso. En modo
+// emulador no se comprueba la firma de los tokens, así que basta con un JWT sin firmar
+func newFakeFirebaseAuth(t *testing.T) *firebase.Auth {
+	server := httptest.NewServer(&fakeFirebase{validSince: map[string]string{}})
+	t.Cleanup(server.Close)
+	t.Setenv("FIREBASE_AUTH_EMULATOR_HOST", strings.TrimPrefix(server.URL, "http://"))
+	t.Setenv("FIREBASE_PROJECT_ID", "test-project")
+
+	firebaseAuth, err := firebase.NewAuthFromEnv()
+	require.NoError(t, err)
+	return firebaseAuth
+}
+
+// unsignedIDToken construye un ID token del proyecto de pruebas emitido en issuedAt
+func unsignedIDToken(uid string, issuedAt time.Time) string {
+	encode := func(v interface{}) string {
+		raw, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	return encode(map[string]string{"alg": "none", "typ": "JWT"}) + "." + encode(map[string]interface{}{
+		"iss":       "https://securetoken.google.com/test-project",
+		"aud":       "test-project",
+		"sub":       uid,
+		"iat":       issuedAt.Unix(),
+		"exp":       issuedAt.Add(time.Hour).Unix(),
+		"auth_time": issuedAt.Unix(),
+	}) + "."
+}
+
+func TestForceLogoutRejectsOutstandingFirebaseToken(t *testing.T) {
+	firebaseAuth := newFakeFirebaseAuth(t)
+	db, mock := newMockDB(t)
+	admin := newTestUserAdminService(db)
+	admin.firebaseAuth = firebaseAuth
+	auth := &FirebaseAuthService{firebaseAuth: firebaseAuth, logger: logger.GetLogger()}
+
+	// Token emitido antes del cierre de sesión y aún sin caducar
+	idToken := unsignedIDToken("firebase-uid", time.Now().Add(-time.Minute))
+	_, err := auth.VerifyFirebaseToken(context.Background(), idToken)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE id = \$1`).
+		WithArgs("user-id").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "firebase_id"}).AddRow("user-id", models.StatusActive, "firebase-uid"))
+	expectRevokeEverything(mock, "user-id")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("audit-id"))
+	mock.ExpectCommit()
+
+	_, err = admin.ForceLogout(context.Background(), AdminActor{UserID: "admin-id"}, "user-id", "compromised")
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// El mismo token ya no sirve para obtener un JWT interno
+	_, err = auth.VerifyFirebaseToken(context.Background(), idToken)
+	assert.ErrorIs(t, err, ErrInvalidFirebaseToken)
+	assert.ErrorIs(t, err, firebase.ErrIDTokenRevoked)
+}
@@ -0,0 +1,694 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/validator"
+	"it-auth-service/pkg/firebase"
+)
+
+const (
+	// Límite de errores por fila que se guardan; el contador sigue aumentando
+	maxImportRowErrors = 10000
+	// Cada cuántas filas se persiste el progreso del job
+	importProgressInterval = 100
+)
+
+// Motivo de fallo de un job que no llegó a terminar
+const importErrorInterrupted = "import was interrupted before finishing, run it again"
+
+var (
+	ErrImportJobNotFound = errors.New("import job not found")
+	ErrInvalidImportFile = errors.New("invalid import file")
+	// Hay una cuenta de Firebase con el email que no puede reutilizarse
+	ErrFirebaseUserNotReusable = errors.New("email already belongs to a Firebase account that cannot be reused")
+)
+
+// importColumns son las columnas admitidas en la cabecera del CSV
+var importColumns = map[string]bool{
+	"email": true, "firebase_id": true, "username": true, "first_name": true, "last_name": true,
+	"photo_url": true, "provider": true, "role": true, "status": true, "email_verified": true,
+}
+
+// UserBulkService gestiona la importación y exportación masiva de usuarios
+type UserBulkService struct {
+	db           *gorm.DB
+	users        *UserService
+	identities   *IdentityService
+	audit        *AuditService
+	lifecycle    *LifecycleService
+	firebaseAuth *firebase.Auth
+	disposable   *DisposableEmailService
+	logger       *logrus.Logger
+}
+
+func NewUserBulkService(db *gorm.DB, userService *UserService, identityService *IdentityService, auditService *AuditService, lifecycleService *LifecycleService, firebaseAuth *firebase.Auth, disposableEmailService *DisposableEmailService) *UserBulkService {
+	return &UserBulkService{
+		db:           db,
+		users:        userService,
+		identities:   identityService,
+		audit:        auditService,
+		lifecycle:    lifecycleService,
+		firebaseAuth: firebaseAuth,
+		disposable:   disposableEmailService,
+		logger:       logger.GetLogger(),
+	}
+}
+
+// StartImport guarda el fichero recibido en disco y lo procesa en segundo plano.
+// Devuelve el job en estado pending para que el cliente consulte su progreso.
+func (s *UserBulkService) StartImport(ctx context.Context, actor AdminActor, opts models.UserImportOptions, body io.Reader) (*models.UserImportJob, error) {
+	spool, err := os.CreateTemp("", "user-import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import spool file: %w", err)
+	}
+	if _, err := io.Copy(spool, body); err != nil {
+		spool.Close()
+		os.Remove(spool.Name())
+		return nil, fmt.Errorf("failed to read import file: %w", err)
+	}
+	if err := spool.Close(); err != nil {
+		os.Remove(spool.Name())
+		return nil, fmt.Errorf("failed to store import file: %w", err)
+	}
+
+	job := &models.UserImportJob{
+		Format:              opts.Format,
+		DryRun:              opts.DryRun,
+		CreateFirebaseUsers: opts.CreateFirebaseUsers,
+		Status:              models.ImportStatusPending,
+		CreatedBy:           actor.UserID,
+	}
+	if err := s.db.WithContext(ctx).Create(job).Error; err != nil {
+		os.Remove(spool.Name())
+		s.logger.WithError(err).Error("Failed to create import job")
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	go s.runImport(job, spool.Name(), actor)
+
+	return job, nil
+}
+
+// GetImportJob devuelve el job con sus errores por fila
+func (s *UserBulkService) GetImportJob(ctx context.Context, jobID string) (*models.UserImportJobDetails, error) {
+	var job models.UserImportJob
+	if err := s.db.WithContext(ctx).Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrImportJobNotFound
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	var rowErrors []*models.UserImportRowError
+	if err := s.db.WithContext(ctx).Where("job_id = ?", jobID).Order("row ASC").Find(&rowErrors).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return &models.UserImportJobDetails{UserImportJob: &job, RowErrors: rowErrors}, nil
+}
+
+// FailStaleImports da por fallidos los jobs pendientes o en curso que no guardan
+// progreso desde hace más de staleAfter. El fichero de un job vive en un temporal del
+// proceso que lo ejecuta: si ese proceso se reinicia, el job no puede reanudarse.
+func (s *UserBulkService) FailStaleImports(ctx context.Context, staleAfter time.Duration) (int64, error) {
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&models.UserImportJob{}).
+		Where("status IN ? AND COALESCE(updated_at, created_at) < ?",
+			[]string{models.ImportStatusPending, models.ImportStatusRunning}, now.Add(-staleAfter)).
+		Updates(map[string]interface{}{
+			"status":       models.ImportStatusFailed,
+			"error":        importErrorInterrupted,
+			"completed_at": now,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to fail stale import jobs: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		s.logger.WithField("count", result.RowsAffected).Warn("Stale import jobs marked as failed")
+	}
+	return result.RowsAffected, nil
+}
+
+func (s *UserBulkService) runImport(job *models.UserImportJob, path string, actor AdminActor) {
+	defer os.Remove(path)
+	ctx := context.Background()
+	log := s.logger.WithField("job_id", job.ID)
+
+	// Un pánico no puede dejar el job en curso para siempre
+	defer func() {
+		if r := recover(); r != nil {
+			log.WithField("panic", r).Error("User import panicked")
+			completed := time.Now()
+			job.Status = models.ImportStatusFailed
+			job.Error = importErrorInterrupted
+			job.CompletedAt = &completed
+			s.saveJob(ctx, job)
+		}
+	}()
+
+	started := time.Now()
+	job.Status = models.ImportStatusRunning
+	job.StartedAt = &started
+	s.saveJob(ctx, job)
+
+	err := s.processImportFile(ctx, job, path)
+
+	completed := time.Now()
+	job.CompletedAt = &completed
+	job.Status = models.ImportStatusCompleted
+	if err != nil {
+		job.Status = models.ImportStatusFailed
+		job.Error = err.Error()
+		log.WithError(err).Error("User import failed")
+	}
+	s.saveJob(ctx, job)
+
+	if err := s.audit.Record(ctx, &models.AuditLog{
+		ActorID:   actor.UserID,
+		Action:    models.AuditActionUsersImported,
+		IPAddress: actor.IPAddress,
+		UserAgent: actor.UserAgent,
+		Details: models.JSONMap{
+			"job_id":  job.ID,
+			"dry_run": job.DryRun,
+			"status":  job.Status,
+			"total":   job.TotalRows,
+			"created": job.CreatedRows,
+			"updated": job.UpdatedRows,
+			"failed":  job.FailedRows,
+		},
+	}); err != nil {
+		log.WithError(err).Warn("Failed to audit user import")
+	}
+
+	log.WithFields(map[string]interface{}{
+		"status":  job.Status,
+		"total":   job.TotalRows,
+		"created": job.CreatedRows,
+		"updated": job.UpdatedRows,
+		"failed":  job.FailedRows,
+	}).Info("User import finished")
+}
+
+func (s *UserBulkService) processImportFile(ctx context.Context, job *models.UserImportJob, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := newImportRowReader(job.Format, file)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]int)
+	for rowNumber := 1; ; rowNumber++ {
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, ErrInvalidImportFile) {
+			return err
+		}
+
+		job.TotalRows++
+		if err == nil {
+			err = s.importRow(ctx, job, row, seen, rowNumber)
+		}
+		if err != nil {
+			email := ""
+			if row != nil {
+				email = row.Email
+			}
+			s.recordRowError(ctx, job, rowNumber, email, err)
+		}
+
+		if job.TotalRows%importProgressInterval == 0 {
+			s.saveJob(ctx, job)
+		}
+	}
+}
+
+// importRow valida la fila y crea o actualiza el usuario (o sólo lo simula en dry-run)
+func (s *UserBulkService) importRow(ctx context.Context, job *models.UserImportJob, row *models.ImportUserRow, seen map[string]int, rowNumber int) error {
+	row.Email = strings.ToLower(strings.TrimSpace(row.Email))
+	if err := validator.ValidateStruct(row); err != nil {
+		return err
+	}
+	// Las variantes del mismo buzón cuentan como el mismo email
+	canonical := canonicalEmail(row.Email)
+	if previous, dup := seen[canonical]; dup {
+		return fmt.Errorf("duplicate email, already imported at row %d", previous)
+	}
+	seen[canonical] = rowNumber
+
+	var existing models.User
+	err := whereEmailOwner(s.db.WithContext(ctx), row.Email).Order("users.created_at ASC").First(&existing).Error
+	switch {
+	case err == nil:
+		if err := s.updateImportedUser(ctx, job, &existing, row); err != nil {
+			return err
+		}
+		job.UpdatedRows++
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := s.createImportedUser(ctx, job, row); err != nil {
+			return err
+		}
+		job.CreatedRows++
+	default:
+		return fmt.Errorf("database error: %w", err)
+	}
+	return nil
+}
+
+func (s *UserBulkService) createImportedUser(ctx context.Context, job *models.UserImportJob, row *models.ImportUserRow) error {
+	if row.FirebaseID == "" && !job.CreateFirebaseUsers {
+		return errors.New("firebase_id is required unless create_firebase_users is enabled")
+	}
+	if err := s.disposable.Check(row.Email); err != nil {
+		return err
+	}
+	if err := s.checkUsernameAvailable(ctx, row.Username, ""); err != nil {
+		return err
+	}
+	provider := defaultString(row.Provider, "password")
+	status, change := importedStatus(row, job.CreatedBy)
+	if change != nil {
+		if err := checkTransition(&models.User{Status: status, Provider: provider, EmailVerified: row.EmailVerified}, *change); err != nil {
+			return err
+		}
+	}
+	if job.DryRun {
+		return nil
+	}
+
+	firebaseID := row.FirebaseID
+	if firebaseID == "" {
+		displayName := strings.TrimSpace(row.FirstName + " " + row.LastName)
+		uid, err := ensureFirebaseUser(ctx, s.db, s.firebaseAuth, row.Email, displayName, row.PhotoURL, row.EmailVerified)
+		if err != nil {
+			return err
+		}
+		firebaseID = uid
+	}
+
+	username := row.Username
+	if username == "" {
+		generated, err := s.users.usernames.GenerateUsername(ctx, row.Email)
+		if err != nil {
+			return err
+		}
+		username = generated
+	}
+
+	user := &models.User{
+		FirebaseID:     firebaseID,
+		Email:          row.Email,
+		CanonicalEmail: canonicalEmail(row.Email),
+		Username:       username,
+		FirstName:      row.FirstName,
+		LastName:       row.LastName,
+		PhotoURL:       row.PhotoURL,
+		Provider:       provider,
+		Role:           defaultString(row.Role, models.RoleUser),
+		Status:         status,
+		EmailVerified:  row.EmailVerified,
+	}
+
+	var event *models.UserStatusChangedEvent
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		if _, err := s.identities.linkIdentityTx(tx, user.ID, user.Provider, firebaseID, user.Email); err != nil {
+			return err
+		}
+		if change != nil {
+			var err error
+			event, err = s.lifecycle.transitionTx(tx, user, *change)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, ErrIdentityLinkedToOtherUser) {
+			return errors.New("firebase_id or username already belongs to another user")
+		}
+		if errors.Is(err, ErrInvalidStatusValue) {
+			return err
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	s.users.notifyChanged(ctx, user)
+	if event != nil {
+		s.lifecycle.publish(ctx, event)
+	}
+	return nil
+}
+
+// importedStatus decide el estado con el que se crea un usuario importado. Sin estado
+// en la fila queda activo si su email está verificado y pendiente de verificación si
+// no. Un estado explícito se aplica como transición desde pending_verification, con
+// las mismas guardas y la misma auditoría que en un usuario existente: activar exige
+// el email verificado.
+func importedStatus(row *models.ImportUserRow, actorID string) (string, *StatusChange) {
+	if row.Status == "" {
+		if row.EmailVerified {
+			return models.StatusActive, nil
+		}
+		return models.StatusPendingVerification, nil
+	}
+	return models.StatusPendingVerification, &StatusChange{
+		To:            row.Status,
+		Reason:        statusReasonImported,
+		Actor:         AdminActor{UserID: actorID},
+		VerifiedEmail: row.EmailVerified,
+	}
+}
+
+// updateImportedUser sobrescribe los campos informados en la fila; el Firebase ID
+// de un usuario existente nunca se cambia desde una importación. El estado no se
+// escribe directamente: pasa por la máquina de estados como cualquier otro cambio.
+func (s *UserBulkService) updateImportedUser(ctx context.Context, job *models.UserImportJob, user *models.User, row *models.ImportUserRow) error {
+	if row.FirebaseID != "" && row.FirebaseID != user.FirebaseID {
+		return errors.New("firebase_id does not match the existing user with this email")
+	}
+	if err := s.checkUsernameAvailable(ctx, row.Username, user.ID); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	for column, value := range map[string]string{
+		"username":   row.Username,
+		"first_name": row.FirstName,
+		"last_name":  row.LastName,
+		"photo_url":  row.PhotoURL,
+		"role":       row.Role,
+	} {
+		if value != "" {
+			updates[column] = value
+		}
+	}
+	if row.EmailVerified && !user.EmailVerified {
+		updates["email_verified"] = true
+	}
+	var change *StatusChange
+	if row.Status != "" && row.Status != user.Status {
+		change = &StatusChange{
+			To:            row.Status,
+			Reason:        statusReasonImported,
+			Actor:         AdminActor{UserID: job.CreatedBy},
+			VerifiedEmail: row.EmailVerified,
+		}
+		if err := checkTransition(user, *change); err != nil {
+			return err
+		}
+	}
+	if (len(updates) == 0 && change == nil) || job.DryRun {
+		return nil
+	}
+
+	previousUsername := user.Username
+	var event *models.UserStatusChangedEvent
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", user.ID).First(user).Error; err != nil {
+			return err
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(user).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if change != nil && user.Status != change.To {
+			var err error
+			if event, err = s.lifecycle.transitionTx(tx, user, *change); err != nil {
+				return err
+			}
+		}
+		// El username anterior queda retenido igual que en un cambio del propio usuario
+		if row.Username != "" && previousUsername != "" &&
+			NormalizeUsername(row.Username) != NormalizeUsername(previousUsername) {
+			return s.users.usernames.recordChangeTx(tx, user.ID, previousUsername)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrUsernameTaken
+		}
+		if errors.Is(err, ErrInvalidStatusValue) {
+			return err
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	s.users.notifyChanged(ctx, user)
+	if event != nil {
+		s.lifecycle.publish(ctx, event)
+	}
+	return nil
+}
+
+func (s *UserBulkService) checkUsernameAvailable(ctx context.Context, username, userID string) error {
+	if username == "" {
+		return nil
+	}
+
+	return s.users.usernames.CheckAvailability(ctx, username, userID)
+}
+
+// ensureFirebaseUser crea el usuario en Firebase o reutiliza el existente con el mismo
+// email. Sólo se reutiliza una cuenta de Firebase que no pertenece a ningún usuario y
+// cuyo email está verificado: si no, quien la controla se quedaría con el usuario creado.
+func ensureFirebaseUser(ctx context.Context, db *gorm.DB, firebaseAuth *firebase.Auth, email, displayName, photoURL string, emailVerified bool) (string, error) {
+	params := (&auth.UserToCreate{}).
+		Email(email).
+		EmailVerified(emailVerified)
+	if displayName != "" {
+		params = params.DisplayName(displayName)
+	}
+	if photoURL != "" {
+		params = params.PhotoURL(photoURL)
+	}
+
+	record, err := firebaseAuth.CreateUser(ctx, params)
+	if errors.Is(err, firebase.ErrEmailAlreadyExists) {
+		record, err = firebaseAuth.GetUserByEmail(ctx, email)
+		if err != nil {
+			return "", err
+		}
+		linked, err := isFirebaseSubjectLinked(db.WithContext(ctx), record.UID)
+		if err != nil {
+			return "", fmt.Errorf("database error: %w", err)
+		}
+		if err := checkReusableFirebaseUser(record, linked); err != nil {
+			return "", err
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return record.UID, nil
+}
+
+// checkReusableFirebaseUser decide si una cuenta de Firebase existente con el email
+// puede asociarse a un usuario nuevo
+func checkReusableFirebaseUser(record *auth.UserRecord, linked bool) error {
+	if linked {
+		return fmt.Errorf("%w: it is linked to another user", ErrFirebaseUserNotReusable)
+	}
+	if !record.EmailVerified {
+		return fmt.Errorf("%w: its email is not verified", ErrFirebaseUserNotReusable)
+	}
+	return nil
+}
+
+// isFirebaseSubjectLinked indica si el Firebase UID ya es una identidad o el Firebase ID
+// principal de algún usuario
+func isFirebaseSubjectLinked(db *gorm.DB, subject string) (bool, error) {
+	var count int64
+	if err := db.Model(&models.UserIdentity{}).Where("subject = ?", subject).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := db.Model(&models.User{}).Where("firebase_id = ?", subject).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (s *UserBulkService) recordRowError(ctx context.Context, job *models.UserImportJob, row int, email string, rowErr error) {
+	job.FailedRows++
+	if job.FailedRows > maxImportRowErrors {
+		return
+	}
+
+	entry := &models.UserImportRowError{
+		JobID:   job.ID,
+		Row:     row,
+		Email:   email,
+		Message: rowErr.Error(),
+	}
+	if err := s.db.WithContext(ctx).Create(entry).Error; err != nil {
+		s.logger.WithError(err).WithField("job_id", job.ID).Warn("Failed to record import row error")
+	}
+}
+
+func (s *UserBulkService) saveJob(ctx context.Context, job *models.UserImportJob) {
+	if err := s.db.WithContext(ctx).Save(job).Error; err != nil {
+		s.logger.WithError(err).WithField("job_id", job.ID).Warn("Failed to save import job progress")
+	}
+}
+
+// importRowReader lee filas de un fichero de importación; devuelve io.EOF al terminar.
+// Un error distinto de io.EOF afecta sólo a la fila actual.
+type importRowReader interface {
+	Next() (*models.ImportUserRow, error)
+}
+
+func newImportRowReader(format string, r io.Reader) (importRowReader, error) {
+	switch format {
+	case models.BulkFormatCSV:
+		return newCSVRowReader(r)
+	case models.BulkFormatNDJSON:
+		return &ndjsonRowReader{reader: bufio.NewReader(r)}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidImportFile, format)
+	}
+}
+
+type csvRowReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVRowReader(r io.Reader) (*csvRowReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing CSV header", ErrInvalidImportFile)
+	}
+
+	columns := make([]string, len(header))
+	hasEmail := false
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !importColumns[column] {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImportFile, column)
+		}
+		hasEmail = hasEmail || column == "email"
+		columns[i] = column
+	}
+	if !hasEmail {
+		return nil, fmt.Errorf("%w: email column is required", ErrInvalidImportFile)
+	}
+
+	return &csvRowReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvRowReader) Next() (*models.ImportUserRow, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, fmt.Errorf("invalid row: %v", parseErr.Err)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+	}
+
+	row := &models.ImportUserRow{}
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		switch r.columns[i] {
+		case "email":
+			row.Email = value
+		case "firebase_id":
+			row.FirebaseID = value
+		case "username":
+			row.Username = value
+		case "first_name":
+			row.FirstName = value
+		case "last_name":
+			row.LastName = value
+		case "photo_url":
+			row.PhotoURL = value
+		case "provider":
+			row.Provider = value
+		case "role":
+			row.Role = value
+		case "status":
+			row.Status = value
+		case "email_verified":
+			if value != "" {
+				verified, err := strconv.ParseBool(value)
+				if err != nil {
+					return row, fmt.Errorf("email_verified must be a boolean")
+				}
+				row.EmailVerified = verified
+			}
+		}
+	}
+	return row, nil
+}
+
+type ndjsonRowReader struct {
+	reader *bufio.Reader
+}
+
+// Next lee una línea por fila; las líneas vacías se ignoran y un JSON mal
+// formado sólo invalida su propia línea
+func (r *ndjsonRowReader) Next() (*models.ImportUserRow, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return nil, io.EOF
+			}
+			continue
+		}
+
+		row := &models.ImportUserRow{}
+		if err := json.Unmarshal(line, row); err != nil {
+			return nil, fmt.Errorf("invalid row: %v", err)
+		}
+		return row, nil
+	}
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
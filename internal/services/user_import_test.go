@@ -0,0 +1,132 @@
+package services
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/models"
+)
+
+func TestCSVRowReader(t *testing.T) {
+	input := "\ufeffEmail,first_name,email_verified\n" +
+		"ana@example.com,Ana,true\n" +
+		"bad@example.com,Bad,maybe\n" +
+		"short@example.com\n" +
+		"luis@example.com,Luis,\n"
+
+	reader, err := newImportRowReader(models.BulkFormatCSV, strings.NewReader(input))
+	require.NoError(t, err)
+
+	row, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "ana@example.com", row.Email)
+	assert.Equal(t, "Ana", row.FirstName)
+	assert.True(t, row.EmailVerified)
+
+	_, err = reader.Next()
+	assert.Error(t, err, "invalid boolean is a row error")
+	assert.NotErrorIs(t, err, ErrInvalidImportFile)
+
+	_, err = reader.Next()
+	assert.Error(t, err, "wrong field count is a row error")
+	assert.NotErrorIs(t, err, ErrInvalidImportFile)
+
+	row, err = reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "luis@example.com", row.Email)
+	assert.False(t, row.EmailVerified)
+
+	_, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestCSVRowReaderRejectsHeader(t *testing.T) {
+	_, err := newImportRowReader(models.BulkFormatCSV, strings.NewReader("email,password\n"))
+	assert.ErrorIs(t, err, ErrInvalidImportFile)
+
+	_, err = newImportRowReader(models.BulkFormatCSV, strings.NewReader("username\n"))
+	assert.ErrorIs(t, err, ErrInvalidImportFile, "email column is mandatory")
+}
+
+func TestNDJSONRowReader(t *testing.T) {
+	input := `{"email":"ana@example.com","role":"admin"}` + "\n\n" +
+		`{"email": broken` + "\n" +
+		`{"email":"luis@example.com"}`
+
+	reader, err := newImportRowReader(models.BulkFormatNDJSON, strings.NewReader(input))
+	require.NoError(t, err)
+
+	row, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "admin", row.Role)
+
+	_, err = reader.Next()
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidImportFile, "a malformed line must not abort the import")
+
+	row, err = reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "luis@example.com", row.Email)
+
+	_, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestParseExportFields(t *testing.T) {
+	fields, err := ParseExportFields("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultExportFields, fields)
+
+	fields, err = ParseExportFields("email, role")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"email", "role"}, fields)
+
+	_, err = ParseExportFields("email,mfa_secret")
+	assert.ErrorIs(t, err, ErrInvalidExportField)
+}
+
+func TestEscapeCSVFormula(t *testing.T) {
+	for _, value := range []string{"=HYPERLINK(\"http://evil\")", "+1", "-2+3", "@SUM(A1)", "\tx"} {
+		assert.Equal(t, "'"+value, escapeCSVFormula(value))
+	}
+	assert.Equal(t, "user@example.com", escapeCSVFormula("user@example.com"))
+	assert.Equal(t, "2024-01-01T00:00:00Z", escapeCSVFormula("2024-01-01T00:00:00Z"))
+	assert.Equal(t, "", escapeCSVFormula(""))
+}
+
+func TestImportedStatus(t *testing.T) {
+	status, change := importedStatus(&models.ImportUserRow{EmailVerified: true}, "admin-1")
+	assert.Equal(t, models.StatusActive, status)
+	assert.Nil(t, change)
+
+	status, change = importedStatus(&models.ImportUserRow{}, "admin-1")
+	assert.Equal(t, models.StatusPendingVerification, status, "an unverified email is not activated by default")
+	assert.Nil(t, change)
+
+	// Un estado explícito pasa por la máquina de estados como en una actualización
+	check := func(row *models.ImportUserRow) error {
+		status, change := importedStatus(row, "admin-1")
+		require.NotNil(t, change)
+		assert.Equal(t, statusReasonImported, change.Reason)
+		assert.Equal(t, "admin-1", change.Actor.UserID)
+		return checkTransition(&models.User{Status: status, Provider: "password", EmailVerified: row.EmailVerified}, *change)
+	}
+	assert.ErrorIs(t, check(&models.ImportUserRow{Status: models.StatusActive}), ErrInvalidStatusValue)
+	assert.NoError(t, check(&models.ImportUserRow{Status: models.StatusActive, EmailVerified: true}))
+	assert.NoError(t, check(&models.ImportUserRow{Status: models.StatusSuspended}))
+}
+
+func TestCheckReusableFirebaseUser(t *testing.T) {
+	verified := &auth.UserRecord{UserInfo: &auth.UserInfo{UID: "uid-1"}, EmailVerified: true}
+	unverified := &auth.UserRecord{UserInfo: &auth.UserInfo{UID: "uid-2"}}
+
+	assert.NoError(t, checkReusableFirebaseUser(verified, false))
+
+	// Una cuenta ajena no se adjunta a la fila importada aunque tenga el mismo email
+	assert.ErrorIs(t, checkReusableFirebaseUser(verified, true), ErrFirebaseUserNotReusable)
+	assert.ErrorIs(t, checkReusableFirebaseUser(unverified, false), ErrFirebaseUserNotReusable)
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"it-auth-service/internal/models"
+)
+
+const (
+	defaultUserPageSize = 20
+	maxUserPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// userSortKey describe cómo ordenar y paginar por un campo
+type userSortKey struct {
+	expression string
+	isTime     bool
+	value      func(user *models.User) string
+}
+
+var userSortKeys = map[string]userSortKey{
+	models.UserSortCreatedAt: {
+		expression: "created_at",
+		isTime:     true,
+		value:      func(u *models.User) string { return u.CreatedAt.UTC().Format(time.RFC3339Nano) },
+	},
+	models.UserSortLastLoginAt: {
+		expression: models.UserLastLoginExpression,
+		isTime:     true,
+		value: func(u *models.User) string {
+			if u.LastLoginAt == nil {
+				return time.Unix(0, 0).UTC().Format(time.RFC3339Nano)
+			}
+			return u.LastLoginAt.UTC().Format(time.RFC3339Nano)
+		},
+	},
+	models.UserSortEmail: {
+		expression: "email",
+		value:      func(u *models.User) string { return u.Email },
+	},
+	models.UserSortUsername: {
+		expression: "coalesce(username, '')",
+		value:      func(u *models.User) string { return u.Username },
+	},
+}
+
+// userCursor es la posición opaca de la paginación por keyset
+type userCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// SearchUsers lista usuarios con filtros, búsqueda libre y paginación por keyset
+// (estable e independiente del tamaño de la tabla). El total sólo se calcula si se pide.
+func (s *UserService) SearchUsers(ctx context.Context, query *models.ListUsersQuery) (*models.ListUsersResult, error) {
+	sortName, descending := parseUserSort(query.Sort)
+	key := userSortKeys[sortName]
+
+	limit := query.Limit
+	if limit <= 0 || limit > maxUserPageSize {
+		limit = defaultUserPageSize
+	}
+
+	filtered := applyUserFilters(s.db.WithContext(ctx).Model(&models.User{}), query)
+
+	result := &models.ListUsersResult{}
+	if query.IncludeTotal {
+		var total int64
+		if err := filtered.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			s.logger.WithError(err).Error("Failed to count users")
+			return nil, fmt.Errorf("failed to count users: %w", err)
+		}
+		result.Total = &total
+	}
+
+	direction := "ASC"
+	comparator := ">"
+	if descending {
+		direction = "DESC"
+		comparator = "<"
+	}
+
+	page := filtered.Session(&gorm.Session{})
+	if query.Cursor != "" {
+		cursor, err := decodeUserCursor(query.Cursor, query.Sort)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{} = cursor.Value
+		if key.isTime {
+			parsed, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			value = parsed
+		}
+		page = page.Where(fmt.Sprintf("(%s, id) %s (?, ?)", key.expression, comparator), value, cursor.ID)
+	}
+
+	var users []*models.User
+	err := page.
+		Order(fmt.Sprintf("%s %s, id %s", key.expression, direction, direction)).
+		Limit(limit + 1).
+		Find(&users).Error
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to list users")
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	// Se pide una fila de más para saber si hay otra página
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[len(users)-1]
+		result.NextCursor = encodeUserCursor(userCursor{
+			Sort:  query.Sort,
+			Value: key.value(last),
+			ID:    last.ID,
+		})
+	}
+
+	result.Users = users
+	return result, nil
+}
+
+func applyUserFilters(db *gorm.DB, query *models.ListUsersQuery) *gorm.DB {
+	if query.Status != "" {
+		db = db.Where("status IN ?", splitCSV(query.Status))
+	} else {
+		db = db.Where("status NOT IN ?", models.DeletedStatuses)
+	}
+	if query.Provider != "" {
+		// Cualquiera de las identidades vinculadas, no sólo el provider con el que se
+		// creó la cuenta; las cuentas anteriores a la tabla de identidades no tienen filas
+		db = db.Where(`(EXISTS (SELECT 1 FROM user_identities i WHERE i.user_id = users.id::text AND i.provider = ?)
+			OR (users.provider = ? AND NOT EXISTS (SELECT 1 FROM user_identities i WHERE i.user_id = users.id::text)))`,
+			query.Provider, query.Provider)
+	}
+	if query.EmailDomain != "" {
+		db = db.Where(models.UserEmailDomainExpression+" = ?", strings.ToLower(strings.TrimPrefix(query.EmailDomain, "@")))
+	}
+	if query.EmailVerified != nil {
+		db = db.Where("email_verified = ?", *query.EmailVerified)
+	}
+	if query.CreatedAfter != nil {
+		db = db.Where("created_at >= ?", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		db = db.Where("created_at < ?", *query.CreatedBefore)
+	}
+	if query.LastLoginAfter != nil {
+		db = db.Where("last_login_at >= ?", *query.LastLoginAfter)
+	}
+	if query.LastLoginBefore != nil {
+		db = db.Where("last_login_at < ?", *query.LastLoginBefore)
+	}
+	if query.OrgID != "" {
+		db = db.Where("EXISTS (SELECT 1 FROM organization_memberships m WHERE m.user_id = users.id AND m.org_id = ?)", query.OrgID)
+	}
+	if query.ScopeOrgID != "" {
+		// Se suma al filtro org_id: pedir otra organización devuelve una lista vacía
+		db = db.Where("EXISTS (SELECT 1 FROM organization_memberships m WHERE m.user_id = users.id AND m.org_id = ?)", query.ScopeOrgID)
+	}
+	if term := strings.TrimSpace(query.Query); term != "" {
+		db = db.Where(models.UserSearchExpression+" ILIKE ?", "%"+escapeLike(term)+"%")
+	}
+	return db
+}
+
+// parseUserSort devuelve el campo de ordenación y si es descendente; por defecto -created_at
+func parseUserSort(sort string) (string, bool) {
+	if sort == "" {
+		return models.UserSortCreatedAt, true
+	}
+	descending := strings.HasPrefix(sort, "-")
+	name := strings.TrimPrefix(sort, "-")
+	if _, ok := userSortKeys[name]; !ok {
+		return models.UserSortCreatedAt, true
+	}
+	return name, descending
+}
+
+func encodeUserCursor(cursor userCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserCursor rechaza cursores corruptos o generados con otra ordenación
+func decodeUserCursor(encoded, sort string) (*userCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor userCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" || cursor.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// escapeLike escapa los comodines de LIKE para buscar el texto literal
+func escapeLike(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(term)
+}
+
+func splitCSV(value string) []string {
+	var values []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"it-auth-service/internal/models"
+)
+
+func TestUserCursorRoundTrip(t *testing.T) {
+	encoded := encodeUserCursor(userCursor{Sort: "-email", Value: "a@example.com", ID: "42"})
+
+	cursor, err := decodeUserCursor(encoded, "-email")
+	require.NoError(t, err)
+	assert.Equal(t, "a@example.com", cursor.Value)
+	assert.Equal(t, "42", cursor.ID)
+
+	_, err = decodeUserCursor(encoded, "email")
+	assert.ErrorIs(t, err, ErrInvalidCursor, "cursor must not be reused with another sort")
+
+	_, err = decodeUserCursor("not-a-cursor", "-email")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestParseUserSort(t *testing.T) {
+	name, desc := parseUserSort("")
+	assert.Equal(t, "created_at", name)
+	assert.True(t, desc)
+
+	name, desc = parseUserSort("username")
+	assert.Equal(t, "username", name)
+	assert.False(t, desc)
+
+	name, desc = parseUserSort("-last_login_at")
+	assert.Equal(t, "last_login_at", name)
+	assert.True(t, desc)
+}
+
+func TestEscapeLike(t *testing.T) {
+	assert.Equal(t, `50\% off\_now`, escapeLike("50% off_now"))
+}
+
+func TestApplyUserFiltersForcesOrgScope(t *testing.T) {
+	db, _ := newDryRunDB(t)
+
+	// Un admin del tenant A que pide los miembros del tenant B sigue limitado a A
+	query := &models.ListUsersQuery{OrgID: "org-b", ScopeOrgID: "org-a"}
+	stmt := applyUserFilters(db.Model(&models.User{}), query).Find(&[]*models.User{}).Statement
+
+	assert.Contains(t, stmt.Vars, "org-a")
+	assert.Contains(t, stmt.Vars, "org-b")
+	assert.Equal(t, 2, strings.Count(stmt.SQL.String(), "organization_memberships"))
+
+	// Sin ámbito impuesto (administrador global) no hay filtro de organización
+	stmt = applyUserFilters(db.Model(&models.User{}), &models.ListUsersQuery{}).Find(&[]*models.User{}).Statement
+	assert.NotContains(t, stmt.SQL.String(), "organization_memberships")
+}
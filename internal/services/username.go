@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+	"it-auth-service/internal/models"
+	"it-auth-service/internal/validator"
+)
+
+const (
+	// Longitud máxima de la base generada, dejando sitio para el sufijo numérico
+	usernameBaseMaxLength = 24
+	usernameMaxSuffix     = 9999
+	usernameFallbackBase  = "user"
+	// Reintentos al crear un usuario si otro registro toma a la vez el username generado
+	maxUsernameAttempts  = 3
+	guestUsernamePrefix  = "guest_"
+	erasedUsernamePrefix = "erased-"
+)
+
+var (
+	ErrUsernameInvalid          = errors.New("username must be 3-30 letters, digits, dots, hyphens or underscores and start with a letter or digit")
+	ErrUsernameReserved         = errors.New("username is reserved")
+	ErrUsernameChangeTooSoon    = errors.New("username was changed recently, try again later")
+	ErrReservedUsernameNotFound = errors.New("reserved username not found")
+)
+
+// builtinReservedUsernames son nombres que ningún usuario puede tomar, además de los
+// que se reservan desde administración
+var builtinReservedUsernames = map[string]bool{
+	"admin": true, "administrator": true, "root": true, "system": true, "sysadmin": true,
+	"support": true, "help": true, "helpdesk": true, "security": true, "abuse": true,
+	"postmaster": true, "hostmaster": true, "webmaster": true, "noreply": true, "no-reply": true,
+	"info": true, "staff": true, "moderator": true, "owner": true, "official": true,
+	"api": true, "auth": true, "login": true, "logout": true, "signup": true, "register": true,
+	"settings": true, "account": true, "profile": true, "me": true, "www": true, "mail": true,
+	"scim": true, "guest": true, "anonymous": true, "null": true, "undefined": true, "user": true,
+}
+
+// Prefijos que usa el propio servicio para invitados y cuentas borradas
+var reservedUsernamePrefixes = []string{guestUsernamePrefix, erasedUsernamePrefix}
+
+// UsernameService centraliza la asignación de usernames: generación sin colisiones,
+// nombres reservados, unicidad sin distinguir mayúsculas y cambios limitados con un
+// historial que impide reutilizar un nombre abandonado durante un tiempo
+type UsernameService struct {
+	db             *gorm.DB
+	logger         *logrus.Logger
+	changeInterval time.Duration
+	holdPeriod     time.Duration
+}
+
+func NewUsernameService(db *gorm.DB, cfg *config.Config) *UsernameService {
+	return &UsernameService{
+		db:             db,
+		logger:         logger.GetLogger(),
+		changeInterval: cfg.UsernameConfig.ChangeInterval,
+		holdPeriod:     cfg.UsernameConfig.HoldPeriod,
+	}
+}
+
+// NormalizeUsername devuelve la forma con la que se comparan los usernames
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// CheckAvailability devuelve nil si la cuenta userID (vacío para una cuenta nueva)
+// puede usar el username
+func (s *UsernameService) CheckAvailability(ctx context.Context, username, userID string) error {
+	return s.checkAvailableTx(s.db.WithContext(ctx), username, userID)
+}
+
+// Availability describe la disponibilidad de un username para mostrarla al usuario
+func (s *UsernameService) Availability(ctx context.Context, username, userID string) (*models.UsernameAvailabilityResponse, error) {
+	response := &models.UsernameAvailabilityResponse{Username: username}
+	err := s.CheckAvailability(ctx, username, userID)
+	switch {
+	case err == nil:
+		response.Available = true
+	case errors.Is(err, ErrUsernameInvalid):
+		response.Reason = "invalid"
+	case errors.Is(err, ErrUsernameReserved):
+		response.Reason = "reserved"
+	case errors.Is(err, ErrUsernameTaken):
+		response.Reason = "taken"
+	default:
+		return nil, err
+	}
+	return response, nil
+}
+
+// GenerateUsername propone un username libre a partir del email
+func (s *UsernameService) GenerateUsername(ctx context.Context, email string) (string, error) {
+	return s.generateTx(s.db.WithContext(ctx), email)
+}
+
+// generateTx usa la parte local del email y, si está ocupada, le añade el primer
+// sufijo numérico libre (john, john2, john3...)
+func (s *UsernameService) generateTx(tx *gorm.DB, email string) (string, error) {
+	base := usernameBase(email)
+
+	unavailable, err := s.unavailableWithPrefix(tx, base)
+	if err != nil {
+		return "", err
+	}
+	if !unavailable[base] && !isBuiltinReservedUsername(base) {
+		return base, nil
+	}
+	for n := 2; n <= usernameMaxSuffix; n++ {
+		candidate := fmt.Sprintf("%s%d", base, n)
+		if !unavailable[candidate] {
+			return candidate, nil
+		}
+	}
+
+	suffix, err := generateRandomToken(4)
+	if err != nil {
+		return "", err
+	}
+	return base + "_" + NormalizeUsername(suffix), nil
+}
+
+// unavailableWithPrefix reúne los usernames ocupados, retenidos o reservados que
+// empiezan por el prefijo, para elegir sufijo con una sola consulta por tabla
+func (s *UsernameService) unavailableWithPrefix(tx *gorm.DB, prefix string) (map[string]bool, error) {
+	pattern := escapeLike(prefix) + "%"
+	unavailable := make(map[string]bool)
+
+	var names []string
+	if err := tx.Model(&models.User{}).
+		Where("lower(username) LIKE ?", pattern).
+		Pluck("lower(username)", &names).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	for _, name := range names {
+		unavailable[name] = true
+	}
+
+	names = nil
+	if err := tx.Model(&models.UsernameHistory{}).
+		Where("username_lower LIKE ? AND held_until > ?", pattern, time.Now()).
+		Pluck("username_lower", &names).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	for _, name := range names {
+		unavailable[name] = true
+	}
+
+	names = nil
+	if err := tx.Model(&models.ReservedUsername{}).
+		Where("name LIKE ?", pattern).
+		Pluck("name", &names).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	for _, name := range names {
+		unavailable[name] = true
+	}
+
+	return unavailable, nil
+}
+
+func (s *UsernameService) checkAvailableTx(tx *gorm.DB, username, userID string) error {
+	if !validator.IsValidUsername(username) {
+		return ErrUsernameInvalid
+	}
+	lower := NormalizeUsername(username)
+
+	if isBuiltinReservedUsername(lower) {
+		return ErrUsernameReserved
+	}
+	var count int64
+	if err := tx.Model(&models.ReservedUsername{}).Where("name = ?", lower).Count(&count).Error; err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if count > 0 {
+		return ErrUsernameReserved
+	}
+
+	users := tx.Model(&models.User{}).Where("lower(username) = ?", lower)
+	if userID != "" {
+		users = users.Where("id <> ?", userID)
+	}
+	if err := users.Count(&count).Error; err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if count > 0 {
+		return ErrUsernameTaken
+	}
+
+	held := tx.Model(&models.UsernameHistory{}).Where("username_lower = ? AND held_until > ?", lower, time.Now())
+	if userID != "" {
+		held = held.Where("user_id <> ?", userID)
+	}
+	if err := held.Count(&count).Error; err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if count > 0 {
+		return ErrUsernameTaken
+	}
+	return nil
+}
+
+// changeTx cambia el username elegido por el propio usuario: como mucho una vez por
+// intervalo, y el nombre anterior queda retenido para él durante el periodo de espera.
+// Cambiar sólo mayúsculas/minúsculas no cuenta como cambio.
+func (s *UsernameService) changeTx(tx *gorm.DB, user *models.User, username string) error {
+	var locked models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", user.ID).First(&locked).Error; err != nil {
+		return err
+	}
+	if username == locked.Username {
+		return nil
+	}
+
+	caseOnly := NormalizeUsername(username) == NormalizeUsername(locked.Username)
+	if caseOnly {
+		if !validator.IsValidUsername(username) {
+			return ErrUsernameInvalid
+		}
+	} else {
+		var last models.UsernameHistory
+		err := tx.Where("user_id = ?", user.ID).Order("changed_at DESC").First(&last).Error
+		if err == nil && time.Since(last.ChangedAt) < s.changeInterval {
+			return ErrUsernameChangeTooSoon
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err := s.checkAvailableTx(tx, username, user.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&locked).Update("username", username).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrUsernameTaken
+		}
+		return err
+	}
+	if !caseOnly && locked.Username != "" {
+		if err := s.recordChangeTx(tx, user.ID, locked.Username); err != nil {
+			return err
+		}
+	}
+
+	user.Username = username
+	return nil
+}
+
+// recordChangeTx retiene el username anterior de la cuenta
+func (s *UsernameService) recordChangeTx(tx *gorm.DB, userID, previous string) error {
+	return tx.Create(&models.UsernameHistory{
+		UserID:        userID,
+		Username:      previous,
+		UsernameLower: NormalizeUsername(previous),
+		HeldUntil:     time.Now().Add(s.holdPeriod),
+	}).Error
+}
+
+// ListReserved devuelve los usernames reservados desde administración
+func (s *UsernameService) ListReserved(ctx context.Context) ([]*models.ReservedUsername, error) {
+	var reserved []*models.ReservedUsername
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&reserved).Error; err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return reserved, nil
+}
+
+// Reserve bloquea un username para que ninguna cuenta nueva pueda tomarlo. No afecta
+// a la cuenta que ya lo tenga.
+func (s *UsernameService) Reserve(ctx context.Context, actor AdminActor, req *models.ReserveUsernameRequest) (*models.ReservedUsername, error) {
+	reserved := &models.ReservedUsername{
+		Name:      NormalizeUsername(req.Name),
+		Reason:    req.Reason,
+		CreatedBy: actor.UserID,
+	}
+	err := s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"reason", "created_by"}),
+		}).
+		Create(reserved).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve username: %w", err)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"name":     reserved.Name,
+		"actor_id": actor.UserID,
+	}).Info("Username reserved")
+	return reserved, nil
+}
+
+// Unreserve libera un username reservado desde administración
+func (s *UsernameService) Unreserve(ctx context.Context, name string) error {
+	result := s.db.WithContext(ctx).Where("name = ?", NormalizeUsername(name)).Delete(&models.ReservedUsername{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to release username: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrReservedUsernameNotFound
+	}
+	return nil
+}
+
+// usernameBase deriva la base de un username de la parte local del email, sin la
+// etiqueta "+..." y sólo con caracteres válidos
+func usernameBase(email string) string {
+	local := strings.ToLower(email)
+	if at := strings.LastIndex(local, "@"); at >= 0 {
+		local = local[:at]
+	}
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+
+	var b strings.Builder
+	for _, r := range local {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '.' || r == '-' || r == '_':
+			// El username debe empezar por letra o dígito
+			if b.Len() > 0 {
+				b.WriteRune(r)
+			}
+		}
+	}
+
+	base := b.String()
+	if len(base) > usernameBaseMaxLength {
+		base = base[:usernameBaseMaxLength]
+	}
+	if len(base) < 3 || isBuiltinReservedUsername(base) {
+		return usernameFallbackBase
+	}
+	return base
+}
+
+func isBuiltinReservedUsername(lower string) bool {
+	if builtinReservedUsernames[lower] {
+		return true
+	}
+	for _, prefix := range reservedUsernamePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsernameBase(t *testing.T) {
+	cases := map[string]string{
+		"John.Doe@example.com":            "john.doe",
+		"john+newsletter@example.com":     "john",
+		"_john@example.com":               "john",
+		"jöhn@example.com":                "jhn",
+		"ab@example.com":                  "user",
+		"admin@example.com":               "user",
+		"guest_1234@example.com":          "user",
+		"":                                "user",
+		"averyveryverylongemailaddress@x": "averyveryverylongemailad",
+	}
+	for email, expected := range cases {
+		assert.Equal(t, expected, usernameBase(email), email)
+	}
+}
+
+func TestIsBuiltinReservedUsername(t *testing.T) {
+	assert.True(t, isBuiltinReservedUsername("admin"))
+	assert.True(t, isBuiltinReservedUsername("guest_abc"))
+	assert.True(t, isBuiltinReservedUsername("erased-123"))
+	assert.False(t, isBuiltinReservedUsername("administrator2"))
+	assert.False(t, isBuiltinReservedUsername("john"))
+}
@@ -2,6 +2,8 @@ package validator
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -9,8 +11,13 @@ import (
 
 var validate *validator.Validate
 
+// usernamePattern: 3-30 caracteres alfanuméricos, punto, guion o guion bajo, empezando por alfanumérico
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{2,29}$`)
+
 func init() {
 	validate = validator.New()
+	_ = validate.RegisterValidation("username", validateUsername)
+	_ = validate.RegisterValidation("photourl", validatePhotoURL)
 }
 
 func ValidateStruct(s interface{}) error {
@@ -22,4 +29,27 @@ func ValidateStruct(s interface{}) error {
 		return fmt.Errorf("validation failed: %s", strings.Join(errors, ", "))
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// IsValidUsername comprueba el formato de un username fuera de una validación de struct
+func IsValidUsername(username string) bool {
+	return usernamePattern.MatchString(username)
+}
+
+func validateUsername(fl validator.FieldLevel) bool {
+	return IsValidUsername(fl.Field().String())
+}
+
+// validatePhotoURL acepta una URL http(s) absoluta o vacío (para borrar la foto)
+func validatePhotoURL(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != ""
+}
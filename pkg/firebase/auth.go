@@ -2,6 +2,7 @@ package firebase
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -53,6 +54,23 @@ func (a *Auth) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token,
 	return token, nil
 }
 
+// ErrIDTokenRevoked indica que el token se emitió antes de revocar las sesiones
+// del usuario o que la cuenta de Firebase está deshabilitada
+var ErrIDTokenRevoked = errors.New("firebase ID token has been revoked")
+
+// VerifyIDTokenAndCheckRevoked verifica el token y además consulta a Firebase si
+// se revocaron los refresh tokens del usuario después de emitirlo
+func (a *Auth) VerifyIDTokenAndCheckRevoked(ctx context.Context, idToken string) (*auth.Token, error) {
+	token, err := a.client.VerifyIDTokenAndCheckRevoked(ctx, idToken)
+	if err != nil {
+		if auth.IsIDTokenRevoked(err) || auth.IsUserDisabled(err) {
+			return nil, ErrIDTokenRevoked
+		}
+		return nil, fmt.Errorf("failed to verify ID token: %w", err)
+	}
+	return token, nil
+}
+
 func (a *Auth) GetUser(ctx context.Context, uid string) (*auth.UserRecord, error) {
 	user, err := a.client.GetUser(ctx, uid)
 	if err != nil {
@@ -96,23 +114,83 @@ func (a *Auth) CreateCustomTokenWithClaims(ctx context.Context, uid string, clai
 func (a *Auth) UpdateUser(ctx context.Context, uid string, user *auth.UserToUpdate) (*auth.UserRecord, error) {
 	updatedUser, err := a.client.UpdateUser(ctx, uid, user)
 	if err != nil {
+		if auth.IsEmailAlreadyExists(err) {
+			return nil, ErrEmailAlreadyExists
+		}
 		return nil, fmt.Errorf("failed to update user: %w", err)
 	}
 	return updatedUser, nil
 }
 
+// ErrUserNotFound indica que no existe ningún usuario de Firebase con ese UID
+var ErrUserNotFound = errors.New("firebase user not found")
+
 func (a *Auth) DeleteUser(ctx context.Context, uid string) error {
 	err := a.client.DeleteUser(ctx, uid)
 	if err != nil {
+		if auth.IsUserNotFound(err) {
+			return ErrUserNotFound
+		}
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
 	return nil
 }
 
+// ErrEmailAlreadyExists indica que ya existe un usuario de Firebase con ese email
+var ErrEmailAlreadyExists = errors.New("firebase user with this email already exists")
+
+// CreateUser crea un usuario en Firebase Auth
+func (a *Auth) CreateUser(ctx context.Context, user *auth.UserToCreate) (*auth.UserRecord, error) {
+	record, err := a.client.CreateUser(ctx, user)
+	if err != nil {
+		if auth.IsEmailAlreadyExists(err) {
+			return nil, ErrEmailAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return record, nil
+}
+
+// SetCustomUserClaims reemplaza los custom claims del usuario en Firebase
+func (a *Auth) SetCustomUserClaims(ctx context.Context, uid string, claims map[string]interface{}) error {
+	err := a.client.SetCustomUserClaims(ctx, uid, claims)
+	if err != nil {
+		return fmt.Errorf("failed to set custom user claims: %w", err)
+	}
+	return nil
+}
+
+// GetUsers obtiene en lote (máximo 100) los usuarios con los UIDs indicados
+func (a *Auth) GetUsers(ctx context.Context, uids []string) ([]*auth.UserRecord, error) {
+	identifiers := make([]auth.UserIdentifier, 0, len(uids))
+	for _, uid := range uids {
+		identifiers = append(identifiers, auth.UIDIdentifier{UID: uid})
+	}
+
+	result, err := a.client.GetUsers(ctx, identifiers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	return result.Users, nil
+}
+
 func (a *Auth) GetProjectID() string {
 	return a.projectID
 }
 
+// NewAuthFromEnv crea un Auth usando las mismas credenciales del entorno que GetAuthClient
+func NewAuthFromEnv() (*Auth, error) {
+	client, err := GetAuthClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Auth{
+		client:    client,
+		projectID: os.Getenv("FIREBASE_PROJECT_ID"),
+	}, nil
+}
+
 // GetAuthClient obtiene un cliente de Firebase Auth usando las credenciales del entorno
 func GetAuthClient() (*auth.Client, error) {
 	ctx := context.Background()
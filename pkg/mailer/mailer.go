@@ -0,0 +1,77 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"it-auth-service/internal/config"
+	"it-auth-service/internal/logger"
+)
+
+// Message es un email de texto plano
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer envía los emails transaccionales del servicio (invitaciones, avisos...)
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer devuelve un mailer SMTP o, si no hay servidor configurado, uno que sólo
+// registra los emails en el log
+func NewMailer(cfg config.MailConfig) Mailer {
+	if cfg.SMTPHost == "" {
+		logger.GetLogger().Warn("SMTP_HOST not set, emails will only be logged")
+		return &logMailer{}
+	}
+	return &smtpMailer{cfg: cfg}
+}
+
+type smtpMailer struct {
+	cfg config.MailConfig
+}
+
+func (m *smtpMailer) Send(_ context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", m.cfg.SMTPUsername, m.cfg.SMTPPassword, m.cfg.SMTPHost)
+	}
+
+	addr := fmt.Sprintf("%s:%d", m.cfg.SMTPHost, m.cfg.SMTPPort)
+	if err := smtp.SendMail(addr, auth, m.cfg.From, []string{msg.To}, buildMessage(m.cfg.From, msg)); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+type logMailer struct{}
+
+func (m *logMailer) Send(_ context.Context, msg Message) error {
+	logger.GetLogger().WithFields(map[string]interface{}{
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+	}).Info("Email not sent (no SMTP server configured)")
+	return nil
+}
+
+// buildMessage compone el mensaje RFC 5322; las cabeceras no admiten saltos de línea
+// para evitar inyección de cabeceras
+func buildMessage(from string, msg Message) []byte {
+	clean := strings.NewReplacer("\r", "", "\n", "")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", clean.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", clean.Replace(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", clean.Replace(msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}